@@ -5,12 +5,13 @@ import (
 	"context"
 	"net"
 
-	"github.com/tidwall/resp"
+	"redis-clone/serdes"
 )
 
 type Client struct {
 	addr string
 	conn net.Conn
+	rd   *serdes.Reader
 }
 
 func New(addr string) (*Client, error) {
@@ -22,6 +23,7 @@ func New(addr string) (*Client, error) {
 	return &Client{
 		addr: addr,
 		conn: conn,
+		rd:   serdes.NewReader(conn),
 	}, nil
 }
 
@@ -29,30 +31,36 @@ func New(addr string) (*Client, error) {
 func (c *Client) Set(ctx context.Context, key string, val any) error {
     buf := &bytes.Buffer{} // NOTE: Hmm this can be done differently
 
-	wr := resp.NewWriter(buf)
-	err := wr.WriteArray([]resp.Value{
-		resp.StringValue("set"),
-		resp.StringValue(key),
-		resp.AnyValue(val),
+	wr := serdes.NewWriter(buf, 2)
+	err := wr.WriteArray([]serdes.Value{
+		serdes.StringValue("set"),
+		serdes.StringValue(key),
+		serdes.AnyValue(val),
 	})
 	if err != nil {
 		return err
 	}
 
 	_, err = c.conn.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
 
-	buf.Reset()
-	return err
+	reply, err := c.rd.ReadValue()
+	if err != nil {
+		return err
+	}
+	return reply.Error()
 }
 
 // Get sends a GET RPC to the server and get's a response back.
 func (c *Client) Get(ctx context.Context, key string) (string, error) {
 	buf := &bytes.Buffer{}
 
-	wr := resp.NewWriter(buf)
-	err := wr.WriteArray([]resp.Value{
-		resp.StringValue("get"),
-		resp.StringValue(key),
+	wr := serdes.NewWriter(buf, 2)
+	err := wr.WriteArray([]serdes.Value{
+		serdes.StringValue("get"),
+		serdes.StringValue(key),
 	})
 	if err != nil {
 		return "", err
@@ -63,10 +71,14 @@ func (c *Client) Get(ctx context.Context, key string) (string, error) {
 		return "", err
 	}
 
-	respBuffer := make([]byte, 1024)
-	n, err := c.conn.Read(respBuffer)
-
-	return string(respBuffer[:n]), err
+	reply, err := c.rd.ReadValue()
+	if err != nil {
+		return "", err
+	}
+	if err := reply.Error(); err != nil {
+		return "", err
+	}
+	return reply.String(), nil
 }
 
 func (c *Client) Close() error {
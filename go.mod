@@ -2,10 +2,7 @@ module redis-clone
 
 go 1.21.3
 
-require (
-	github.com/redis/go-redis/v9 v9.14.0
-	github.com/tidwall/resp v0.1.1
-)
+require github.com/redis/go-redis/v9 v9.14.0
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
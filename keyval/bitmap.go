@@ -0,0 +1,377 @@
+package keyval
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+var ErrBitOpNotSingle = errors.New("ERR BITOP NOT must be called with a single source key.")
+
+// BitOp is one of the bitwise operations of BITOP.
+type BitOp int
+
+const (
+	BitAnd BitOp = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitFieldOverflow is how BITFIELD SET and INCRBY deal with values that do not fit.
+type BitFieldOverflow int
+
+const (
+	// OverflowWrap wraps around, like two's complement arithmetic does.
+	OverflowWrap BitFieldOverflow = iota
+	// OverflowSat saturates at the minimum or the maximum value.
+	OverflowSat
+	// OverflowFail leaves the field untouched and yields a null.
+	OverflowFail
+)
+
+// BitFieldOp is a BITFIELD operation over an integer of Bits bits stored at
+// Offset, a GET unless Set or Incr is set.
+type BitFieldOp struct {
+	Set, Incr bool
+	Signed    bool
+	Bits      int
+	Offset    uint64
+	Value     int64
+	Overflow  BitFieldOverflow
+}
+
+// SetBit sets or clears the bit at offset of the string stored at key,
+// growing it as needed, and returns the previous bit.
+func (kv *KV) SetBit(key string, offset uint64, bit int) (int, error) {
+	var old int
+	err := kv.updateString(key, func(value []byte, _ bool) ([]byte, error) {
+		value = growBits(value, offset+1)
+		old = getBit(value, offset)
+		setBit(value, offset, bit)
+		return value, nil
+	})
+
+	return old, err
+}
+
+// GetBit returns the bit at offset of the string stored at key, bits past its
+// end being 0.
+func (kv *KV) GetBit(key string, offset uint64) (int, error) {
+	value, _, err := kv.Get([]byte(key))
+	if err != nil || offset >= uint64(len(value))*8 {
+		return 0, err
+	}
+
+	return getBit(value, offset), nil
+}
+
+// BitCount counts the set bits of the string stored at key. With hasRange only
+// those between start and end, both inclusive, are counted. The offsets are in
+// bytes unless bit is set, negative ones counting from the end.
+func (kv *KV) BitCount(key string, start, end int64, hasRange, bit bool) (int64, error) {
+	value, _, err := kv.Get([]byte(key))
+	if err != nil {
+		return 0, err
+	}
+
+	first, last, ok := bitRange(len(value), start, end, hasRange, bit)
+	if !ok {
+		return 0, nil
+	}
+
+	var count int64
+	for pos := first; pos <= last; {
+		// Whole bytes are counted at once.
+		if pos%8 == 0 && pos+7 <= last {
+			count += int64(bits.OnesCount8(value[pos/8]))
+			pos += 8
+			continue
+		}
+		count += int64(getBit(value, uint64(pos)))
+		pos++
+	}
+
+	return count, nil
+}
+
+// BitPos returns the position of the first bit set to bit in the string stored
+// at key, in the range given like for BitCount, or -1 if there is none. Like
+// redis, looking for a clear bit without an explicit end treats the string as
+// padded with zeros on the right.
+func (kv *KV) BitPos(key string, bit int, start, end int64, hasStart, hasEnd, bitUnit bool) (int64, error) {
+	value, ok, err := kv.Get([]byte(key))
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+
+	if !hasEnd {
+		end = -1
+	}
+	first, last, ok := bitRange(len(value), start, end, hasStart, bitUnit)
+	if !ok {
+		return -1, nil
+	}
+	for pos := first; pos <= last; pos++ {
+		if getBit(value, uint64(pos)) == bit {
+			return pos, nil
+		}
+	}
+	if bit == 0 && !hasEnd {
+		return last + 1, nil
+	}
+
+	return -1, nil
+}
+
+// bitRange turns a BITCOUNT or BITPOS range into the positions of its first
+// and last bits, and reports whether it holds any bit.
+func bitRange(size int, start, end int64, hasRange, bit bool) (int64, int64, bool) {
+	n := int64(size)
+	if bit {
+		n *= 8
+	}
+	if !hasRange {
+		start, end = 0, n-1
+	}
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	end = min(end, n-1)
+	if n == 0 || start > end {
+		return 0, 0, false
+	}
+	if !bit {
+		return start * 8, end*8 + 7, true
+	}
+
+	return start, end, true
+}
+
+// BitOp stores at destination the bitwise operation of the strings stored at
+// keys, shorter strings being padded with zeros, and returns its length. An
+// empty result deletes destination.
+func (kv *KV) BitOp(op BitOp, destination string, keys []string) (int, error) {
+	if op == BitNot && len(keys) != 1 {
+		return 0, ErrBitOpNotSingle
+	}
+
+	defer kv.lock(append([]string{destination}, keys...)...)()
+
+	values := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		o, err := kv.lookupType(key, TypeString)
+		if err != nil {
+			return 0, err
+		}
+		if o != nil {
+			values[i] = o.value.([]byte)
+		}
+		size = max(size, len(values[i]))
+	}
+
+	res := make([]byte, size)
+	for i := range res {
+		b := byteAt(values[0], i)
+		for _, value := range values[1:] {
+			switch op {
+			case BitAnd:
+				b &= byteAt(value, i)
+			case BitOr:
+				b |= byteAt(value, i)
+			case BitXor:
+				b ^= byteAt(value, i)
+			}
+		}
+		if op == BitNot {
+			b = ^b
+		}
+		res[i] = b
+	}
+
+	kv.discard(destination)
+	if size > 0 {
+		kv.add(destination, newStringObject(res))
+	}
+
+	return size, nil
+}
+
+// BitField runs the operations over the string stored at key in order. It
+// returns for each the value it read, the previous one for a SET and the new
+// one for an INCRBY, and whether there is one, a failed overflow having none.
+// The string is only created or grown when there are writes.
+func (kv *KV) BitField(key string, ops []BitFieldOp) ([]int64, []bool, error) {
+	results, ok := make([]int64, 0, len(ops)), make([]bool, 0, len(ops))
+	run := func(value []byte) {
+		for _, op := range ops {
+			field := getField(value, op.Offset, op.Bits, op.Signed)
+			if !op.Set && !op.Incr {
+				results, ok = append(results, field), append(ok, true)
+				continue
+			}
+
+			next, incr := op.Value, int64(0)
+			if op.Incr {
+				next, incr = field, op.Value
+			}
+			res, overflow := fieldOverflow(next, incr, op.Bits, op.Signed, op.Overflow)
+			if overflow && op.Overflow == OverflowFail {
+				results, ok = append(results, 0), append(ok, false)
+				continue
+			}
+			setField(value, op.Offset, op.Bits, res)
+			if op.Set {
+				res = field
+			}
+			results, ok = append(results, res), append(ok, true)
+		}
+	}
+
+	var end uint64
+	for _, op := range ops {
+		if op.Set || op.Incr {
+			end = max(end, op.Offset+uint64(op.Bits))
+		}
+	}
+	if end == 0 {
+		value, _, err := kv.Get([]byte(key))
+		if err != nil {
+			return nil, nil, err
+		}
+		run(value)
+		return results, ok, nil
+	}
+
+	err := kv.updateString(key, func(value []byte, _ bool) ([]byte, error) {
+		value = growBits(value, end)
+		run(value)
+		return value, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return results, ok, nil
+}
+
+// fieldOverflow adds incr to value and fits the result in an integer of the
+// given width according to the overflow mode, it reports whether it did not
+// fit. A SET is an increment of zero of the new value.
+func fieldOverflow(value, incr int64, width int, signed bool, mode BitFieldOverflow) (int64, bool) {
+	if !signed {
+		maxVal := uint64(1)<<width - 1
+		u := uint64(value)
+		switch {
+		case u > maxVal || (incr > 0 && uint64(incr) > maxVal-u):
+			if mode == OverflowSat {
+				return int64(maxVal), true
+			}
+		case incr < 0 && uint64(-incr) > u:
+			if mode == OverflowSat {
+				return 0, true
+			}
+		default:
+			return int64(u + uint64(incr)), false
+		}
+		return int64((u + uint64(incr)) & maxVal), true
+	}
+
+	maxVal := int64(math.MaxInt64)
+	if width < 64 {
+		maxVal = int64(1)<<(width-1) - 1
+	}
+	minVal := -maxVal - 1
+	switch {
+	case value > maxVal || (incr > 0 && value > maxVal-incr):
+		if mode == OverflowSat {
+			return maxVal, true
+		}
+	case value < minVal || (incr < 0 && value < minVal-incr):
+		if mode == OverflowSat {
+			return minVal, true
+		}
+	default:
+		return value + incr, false
+	}
+
+	// Wrap around by keeping the low bits and extending the sign.
+	c := uint64(value) + uint64(incr)
+	if width < 64 {
+		mask := ^uint64(0) << width
+		if c&(uint64(1)<<(width-1)) != 0 {
+			c |= mask
+		} else {
+			c &^= mask
+		}
+	}
+	return int64(c), true
+}
+
+// getField reads the integer of the given width stored at the bit offset,
+// most significant bit first, bits past the end of the string being 0.
+func getField(value []byte, offset uint64, width int, signed bool) int64 {
+	var u uint64
+	for i := 0; i < width; i++ {
+		u <<= 1
+		if pos := offset + uint64(i); pos < uint64(len(value))*8 {
+			u |= uint64(getBit(value, pos))
+		}
+	}
+	if signed && width < 64 && u&(uint64(1)<<(width-1)) != 0 {
+		u |= ^uint64(0) << width
+	}
+
+	return int64(u)
+}
+
+// setField writes the low bits of the integer at the bit offset, the string
+// must be long enough.
+func setField(value []byte, offset uint64, width int, field int64) {
+	for i := 0; i < width; i++ {
+		setBit(value, offset+uint64(i), int(uint64(field)>>(width-1-i))&1)
+	}
+}
+
+// growBits returns the string padded with zero bytes so it holds n bits.
+func growBits(value []byte, n uint64) []byte {
+	size := int((n + 7) / 8)
+	if len(value) >= size {
+		return value
+	}
+	return append(value, make([]byte, size-len(value))...)
+}
+
+func getBit(value []byte, offset uint64) int {
+	return int(value[offset/8]>>(7-offset%8)) & 1
+}
+
+func setBit(value []byte, offset uint64, bit int) {
+	mask := byte(1) << (7 - offset%8)
+	if bit == 1 {
+		value[offset/8] |= mask
+	} else {
+		value[offset/8] &^= mask
+	}
+}
+
+// byteAt returns the byte at i, 0 past the end of the string.
+func byteAt(value []byte, i int) byte {
+	if i < len(value) {
+		return value[i]
+	}
+	return 0
+}
@@ -0,0 +1,775 @@
+package keyval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrBusyGroup   = errors.New("BUSYGROUP Consumer Group name already exists")
+	ErrXGroupNoKey = errors.New("ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+)
+
+// ConsumerGroup is a consumer group of a stream: the last entry it
+// delivered and the pending entries list (PEL) of the entries delivered but
+// not acknowledged yet, shared with its consumers.
+type ConsumerGroup struct {
+	lastID StreamID
+	// entriesRead is the number of entries the group read, -1 if unknown.
+	entriesRead int64
+	pel         *rax[*pendingEntry]
+	consumers   map[string]*consumer
+}
+
+type consumer struct {
+	name string
+	// seenTime is when the consumer last interacted with the group, and
+	// activeTime when it last read or claimed entries, -1 if never.
+	seenTime   int64
+	activeTime int64
+	pel        *rax[*pendingEntry]
+}
+
+type pendingEntry struct {
+	consumer      *consumer
+	deliveryTime  int64
+	deliveryCount int64
+}
+
+// PendingEntry describes an entry of a PEL, as listed by XPENDING.
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	Idle          int64
+	DeliveryTime  int64
+	DeliveryCount int64
+}
+
+// PendingSummary is the reply of XPENDING without a range: the number of
+// pending entries, the lowest and highest of their IDs and how many each
+// consumer has, consumers being sorted by name.
+type PendingSummary struct {
+	Count     int
+	Lowest    StreamID
+	Highest   StreamID
+	Consumers []ConsumerPending
+}
+
+type ConsumerPending struct {
+	Name    string
+	Pending int
+}
+
+// XPendingArgs is the range of the extended form of XPENDING.
+type XPendingArgs struct {
+	MinIdle    int64
+	Start, End StreamID
+	Count      int
+	Consumer   string
+}
+
+// XClaimArgs are the options of XCLAIM. DeliveryTime is the unix time in
+// milliseconds set on the claimed entries, -1 for now, and RetryCount their
+// delivery count, -1 to increment it.
+type XClaimArgs struct {
+	DeliveryTime int64
+	RetryCount   int64
+	Force        bool
+	JustID       bool
+	LastID       *StreamID
+}
+
+func newConsumerGroup(lastID StreamID, entriesRead int64) *ConsumerGroup {
+	return &ConsumerGroup{
+		lastID:      lastID,
+		entriesRead: entriesRead,
+		pel:         newRax[*pendingEntry](),
+		consumers:   map[string]*consumer{},
+	}
+}
+
+// consumer returns the consumer with the name, creating it if needed, and
+// records it was seen now.
+// clone returns a copy of the group, its consumers and its PEL.
+func (g *ConsumerGroup) clone() *ConsumerGroup {
+	c := newConsumerGroup(g.lastID, g.entriesRead)
+	for name, cons := range g.consumers {
+		c.consumers[name] = &consumer{name: name, seenTime: cons.seenTime, activeTime: cons.activeTime, pel: newRax[*pendingEntry]()}
+	}
+	g.pel.Ascend(StreamID{}, func(id StreamID, nack *pendingEntry) bool {
+		owner := c.consumers[nack.consumer.name]
+		copied := &pendingEntry{consumer: owner, deliveryTime: nack.deliveryTime, deliveryCount: nack.deliveryCount}
+		c.pel.Insert(id, copied)
+		owner.pel.Insert(id, copied)
+		return true
+	})
+
+	return c
+}
+
+func (g *ConsumerGroup) consumer(name string, now int64) *consumer {
+	c := g.consumers[name]
+	if c == nil {
+		c = &consumer{name: name, activeTime: -1, pel: newRax[*pendingEntry]()}
+		g.consumers[name] = c
+	}
+	c.seenTime = now
+
+	return c
+}
+
+// deliver records that the entry was delivered to the consumer.
+func (g *ConsumerGroup) deliver(id StreamID, c *consumer, now int64) {
+	if nack, ok := g.pel.Get(id); ok {
+		nack.consumer.pel.Remove(id)
+	}
+	nack := &pendingEntry{consumer: c, deliveryTime: now, deliveryCount: 1}
+	g.pel.Insert(id, nack)
+	c.pel.Insert(id, nack)
+}
+
+// claim gives the pending entry to the consumer.
+func (g *ConsumerGroup) claim(id StreamID, nack *pendingEntry, c *consumer) {
+	if nack.consumer != c {
+		if nack.consumer != nil {
+			nack.consumer.pel.Remove(id)
+		}
+		nack.consumer = c
+		c.pel.Insert(id, nack)
+	}
+}
+
+// ack removes the entry from the PEL and reports whether it was pending.
+func (g *ConsumerGroup) ack(id StreamID) bool {
+	nack, ok := g.pel.Get(id)
+	if !ok {
+		return false
+	}
+	g.pel.Remove(id)
+	if nack.consumer != nil {
+		nack.consumer.pel.Remove(id)
+	}
+	return true
+}
+
+// lag returns the number of entries of the stream the group has yet to read,
+// false if it can't be known.
+func (g *ConsumerGroup) lag(s *Stream) (int64, bool) {
+	if s.entriesAdded == 0 {
+		return 0, true
+	}
+	if g.entriesRead >= 0 && !s.hasTombstones(g.lastID, MaxStreamID) && !g.lastID.Less(s.firstID) {
+		return s.entriesAdded - g.entriesRead, true
+	}
+	if read := s.estimateEntriesRead(g.lastID); read >= 0 {
+		return s.entriesAdded - read, true
+	}
+	return 0, false
+}
+
+// XGroupCreate creates the consumer group of the stream stored at key, which
+// is created with mkStream if missing. With useLast the group starts at the
+// last entry of the stream rather than at id.
+func (kv *KV) XGroupCreate(key, group string, id StreamID, useLast, mkStream bool, entriesRead int64) error {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		if !mkStream {
+			return ErrXGroupNoKey
+		}
+		o := newStreamObject()
+		kv.add(key, o)
+		s = o.value.(*Stream)
+	}
+	if s.groups[group] != nil {
+		return ErrBusyGroup
+	}
+	if useLast {
+		id = s.lastID
+	}
+	s.groups[group] = newConsumerGroup(id, entriesRead)
+
+	return nil
+}
+
+// XGroupSetID sets the last delivered ID of the consumer group.
+func (kv *KV) XGroupSetID(key, group string, id StreamID, useLast bool, entriesRead int64) error {
+	defer kv.lock(key)()
+
+	s, g, err := kv.xgroup(key, group)
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return errNoGroup(key, group)
+	}
+	if useLast {
+		id = s.lastID
+	}
+	g.lastID = id
+	g.entriesRead = entriesRead
+
+	return nil
+}
+
+// XGroupDestroy deletes the consumer group and reports whether it existed.
+func (kv *KV) XGroupDestroy(key, group string) (bool, error) {
+	defer kv.lock(key)()
+
+	s, g, err := kv.xgroup(key, group)
+	if err != nil || g == nil {
+		return false, err
+	}
+	delete(s.groups, group)
+
+	return true, nil
+}
+
+// XGroupCreateConsumer creates the consumer and reports whether it is new.
+func (kv *KV) XGroupCreateConsumer(key, group, name string) (bool, error) {
+	defer kv.lock(key)()
+
+	_, g, err := kv.xgroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if g == nil {
+		return false, errNoGroup(key, group)
+	}
+	if g.consumers[name] != nil {
+		return false, nil
+	}
+	g.consumer(name, Now())
+
+	return true, nil
+}
+
+// XGroupDelConsumer deletes the consumer along with its pending entries and
+// returns how many it had.
+func (kv *KV) XGroupDelConsumer(key, group, name string) (int, error) {
+	defer kv.lock(key)()
+
+	_, g, err := kv.xgroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	if g == nil {
+		return 0, errNoGroup(key, group)
+	}
+	c := g.consumers[name]
+	if c == nil {
+		return 0, nil
+	}
+
+	pending := c.pel.Len()
+	c.pel.Ascend(StreamID{}, func(id StreamID, _ *pendingEntry) bool {
+		g.pel.Remove(id)
+		return true
+	})
+	delete(g.consumers, name)
+
+	return pending, nil
+}
+
+// XReadGroup reads from the streams stored at keys on behalf of the consumer
+// of the group. For the streams whose newOnly flag is set it delivers up to
+// count entries never delivered to the group, adding them to the PEL unless
+// noAck is set, and skips the streams without any. For the others it returns
+// the history of the consumer: its pending entries after the given ID.
+func (kv *KV) XReadGroup(group, name string, keys []string, ids []StreamID, newOnly []bool, count int, noAck bool) ([]StreamRead, error) {
+	defer kv.lock(keys...)()
+
+	streams := make([]*Stream, len(keys))
+	for i, key := range keys {
+		s, err := kv.stream(key)
+		if err != nil {
+			return nil, err
+		}
+		if s == nil || s.groups[group] == nil {
+			return nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+		}
+		streams[i] = s
+	}
+
+	now := Now()
+	ret := []StreamRead{}
+	for i, s := range streams {
+		g := s.groups[group]
+		c := g.consumer(name, now)
+
+		if !newOnly[i] {
+			entries := []StreamEntry{}
+			if start, ok := ids[i].Next(); ok {
+				c.pel.Ascend(start, func(id StreamID, _ *pendingEntry) bool {
+					entry, ok := s.Get(id)
+					if !ok {
+						entry = StreamEntry{ID: id}
+					}
+					entries = append(entries, entry)
+					return len(entries) != count
+				})
+			}
+			ret = append(ret, StreamRead{Key: keys[i], Entries: entries})
+			continue
+		}
+
+		start, ok := g.lastID.Next()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, MaxStreamID, false, count)
+		if len(entries) == 0 {
+			continue
+		}
+		for _, e := range entries {
+			if g.entriesRead >= 0 && !s.hasTombstones(e.ID, MaxStreamID) {
+				g.entriesRead++
+			} else if s.entriesAdded > 0 {
+				g.entriesRead = s.estimateEntriesRead(e.ID)
+			}
+			g.lastID = e.ID
+			if !noAck {
+				g.deliver(e.ID, c, now)
+			}
+		}
+		c.activeTime = now
+		ret = append(ret, StreamRead{Key: keys[i], Entries: entries})
+	}
+
+	return ret, nil
+}
+
+// XAck acknowledges the entries, removing them from the PEL of the group,
+// and returns how many were pending.
+func (kv *KV) XAck(key, group string, ids []StreamID) (int, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil || s.groups[group] == nil {
+		return 0, err
+	}
+	g := s.groups[group]
+
+	acked := 0
+	for _, id := range ids {
+		if g.ack(id) {
+			acked++
+		}
+	}
+
+	return acked, nil
+}
+
+// XPendingSummary summarizes the PEL of the group.
+func (kv *KV) XPendingSummary(key, group string) (PendingSummary, error) {
+	defer kv.lock(key)()
+
+	_, g, err := kv.xgroupOrNoGroup(key, group)
+	if err != nil {
+		return PendingSummary{}, err
+	}
+
+	summary := PendingSummary{Count: g.pel.Len()}
+	summary.Lowest, _, _ = g.pel.First()
+	summary.Highest, _, _ = g.pel.Last()
+	for _, name := range sortedNames(g.consumers) {
+		if n := g.consumers[name].pel.Len(); n > 0 {
+			summary.Consumers = append(summary.Consumers, ConsumerPending{Name: name, Pending: n})
+		}
+	}
+
+	return summary, nil
+}
+
+// XPending lists the entries of the PEL of the group, or of one of its
+// consumers, in the range that have been idle for long enough.
+func (kv *KV) XPending(key, group string, args XPendingArgs) ([]PendingEntry, error) {
+	defer kv.lock(key)()
+
+	_, g, err := kv.xgroupOrNoGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []PendingEntry{}
+	pel := g.pel
+	if args.Consumer != "" {
+		c := g.consumers[args.Consumer]
+		if c == nil {
+			return ret, nil
+		}
+		pel = c.pel
+	}
+
+	now := Now()
+	if args.Count <= 0 {
+		return ret, nil
+	}
+	pel.Ascend(args.Start, func(id StreamID, nack *pendingEntry) bool {
+		if args.End.Less(id) {
+			return false
+		}
+		if idle := now - nack.deliveryTime; idle >= args.MinIdle {
+			ret = append(ret, PendingEntry{
+				ID:            id,
+				Consumer:      nack.consumer.name,
+				Idle:          idle,
+				DeliveryTime:  nack.deliveryTime,
+				DeliveryCount: nack.deliveryCount,
+			})
+		}
+		return len(ret) < args.Count
+	})
+
+	return ret, nil
+}
+
+// XClaim gives the pending entries idle for at least minIdle milliseconds to
+// the consumer and returns them, leaving out those deleted from the stream
+// which are dropped from the PEL.
+func (kv *KV) XClaim(key, group, name string, minIdle int64, ids []StreamID, args XClaimArgs) ([]StreamEntry, error) {
+	defer kv.lock(key)()
+
+	s, g, err := kv.xgroupOrNoGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	if args.LastID != nil && g.lastID.Less(*args.LastID) {
+		g.lastID = *args.LastID
+	}
+
+	now := Now()
+	deliveryTime := args.DeliveryTime
+	if deliveryTime < 0 || deliveryTime > now {
+		deliveryTime = now
+	}
+	c := g.consumer(name, now)
+
+	ret := []StreamEntry{}
+	for _, id := range ids {
+		nack, ok := g.pel.Get(id)
+		entry, exists := s.Get(id)
+		if !ok && args.Force && exists {
+			nack = &pendingEntry{deliveryTime: now}
+			g.pel.Insert(id, nack)
+			ok = true
+		}
+		if !ok {
+			continue
+		}
+		if !exists {
+			g.ack(id)
+			continue
+		}
+		// An entry just added to the PEL by FORCE has no owner yet and is
+		// claimed whatever its idle time.
+		if nack.consumer != nil && minIdle > 0 && now-nack.deliveryTime < minIdle {
+			continue
+		}
+
+		g.claim(id, nack, c)
+		nack.deliveryTime = deliveryTime
+		if args.RetryCount >= 0 {
+			nack.deliveryCount = args.RetryCount
+		} else if !args.JustID {
+			nack.deliveryCount++
+		}
+		c.activeTime = now
+		ret = append(ret, entry)
+	}
+
+	return ret, nil
+}
+
+// XAutoClaim scans the PEL of the group from start and gives up to count
+// entries idle for at least minIdle milliseconds to the consumer. It returns
+// the ID to continue the scan from, 0-0 once done, the claimed entries and
+// the IDs of the entries dropped from the PEL because they were deleted.
+func (kv *KV) XAutoClaim(key, group, name string, minIdle int64, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	defer kv.lock(key)()
+
+	s, g, err := kv.xgroupOrNoGroup(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+
+	now := Now()
+	c := g.consumer(name, now)
+
+	// The PEL can't change while it is walked, so the entries to examine are
+	// collected first, plus the one to continue from.
+	type pending struct {
+		id   StreamID
+		nack *pendingEntry
+	}
+	attempts := count * 10
+	scanned := []pending{}
+	g.pel.Ascend(start, func(id StreamID, nack *pendingEntry) bool {
+		scanned = append(scanned, pending{id, nack})
+		return len(scanned) <= attempts
+	})
+
+	next := StreamID{}
+	claimed, deleted := []StreamEntry{}, []StreamID{}
+	for i, p := range scanned {
+		if i == attempts || len(claimed) == count {
+			next = p.id
+			break
+		}
+		entry, exists := s.Get(p.id)
+		if !exists {
+			g.ack(p.id)
+			deleted = append(deleted, p.id)
+			continue
+		}
+		if minIdle > 0 && now-p.nack.deliveryTime < minIdle {
+			continue
+		}
+		g.claim(p.id, p.nack, c)
+		p.nack.deliveryTime = now
+		if !justID {
+			p.nack.deliveryCount++
+		}
+		c.activeTime = now
+		claimed = append(claimed, entry)
+	}
+
+	return next, claimed, deleted, nil
+}
+
+// xgroup returns the stream stored at key, which must exist as the XGROUP
+// subcommands require, and its consumer group if there is one. The caller
+// must hold the lock.
+func (kv *KV) xgroup(key, group string) (*Stream, *ConsumerGroup, error) {
+	s, err := kv.stream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil {
+		return nil, nil, ErrXGroupNoKey
+	}
+	return s, s.groups[group], nil
+}
+
+// errNoGroup is the error of the XGROUP and XINFO subcommands for a missing group.
+func errNoGroup(key, group string) error {
+	return fmt.Errorf("NOGROUP No such consumer group '%s' for key name '%s'", group, key)
+}
+
+// xgroupOrNoGroup is xgroup with the error of XPENDING and XCLAIM for a
+// missing key, the caller must hold the lock.
+func (kv *KV) xgroupOrNoGroup(key, group string) (*Stream, *ConsumerGroup, error) {
+	s, err := kv.stream(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s == nil || s.groups[group] == nil {
+		return nil, nil, fmt.Errorf("NOGROUP No such key '%s' or consumer group '%s'", key, group)
+	}
+	return s, s.groups[group], nil
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StreamInfo is what XINFO STREAM reports. Entries and GroupsInfo are only
+// filled with FULL.
+type StreamInfo struct {
+	Length         int
+	RadixTreeKeys  int
+	RadixTreeNodes int
+	LastID         StreamID
+	MaxDeletedID   StreamID
+	EntriesAdded   int64
+	FirstID        StreamID
+	Groups         int
+	FirstEntry     *StreamEntry
+	LastEntry      *StreamEntry
+	Entries        []StreamEntry
+	GroupsInfo     []GroupInfo
+}
+
+// GroupInfo is what XINFO GROUPS reports about a group, EntriesRead is -1
+// and HasLag false when they are unknown. PEL and ConsumersInfo are only
+// filled by XINFO STREAM FULL.
+type GroupInfo struct {
+	Name          string
+	Consumers     int
+	Pending       int
+	LastID        StreamID
+	EntriesRead   int64
+	Lag           int64
+	HasLag        bool
+	PEL           []PendingEntry
+	ConsumersInfo []ConsumerInfo
+}
+
+// ConsumerInfo is what XINFO CONSUMERS reports about a consumer, Inactive is
+// -1 for a consumer that never read anything. PEL is only filled by XINFO
+// STREAM FULL.
+type ConsumerInfo struct {
+	Name       string
+	Pending    int
+	Idle       int64
+	Inactive   int64
+	SeenTime   int64
+	ActiveTime int64
+	PEL        []PendingEntry
+}
+
+// XInfoStream describes the stream stored at key, with FULL it also lists up
+// to count entries, and pending entries of each PEL, 0 meaning all of them.
+func (kv *KV) XInfoStream(key string, full bool, count int) (StreamInfo, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil {
+		return StreamInfo{}, err
+	}
+	if s == nil {
+		return StreamInfo{}, ErrNoSuchKey
+	}
+
+	info := StreamInfo{
+		Length:         s.length,
+		RadixTreeKeys:  s.index.Len(),
+		RadixTreeNodes: s.index.nodes,
+		LastID:         s.lastID,
+		MaxDeletedID:   s.maxDeletedID,
+		EntriesAdded:   s.entriesAdded,
+		FirstID:        s.firstID,
+		Groups:         len(s.groups),
+	}
+	if !full {
+		if first := s.Range(StreamID{}, MaxStreamID, false, 1); len(first) > 0 {
+			info.FirstEntry = &first[0]
+		}
+		if last := s.Range(StreamID{}, MaxStreamID, true, 1); len(last) > 0 {
+			info.LastEntry = &last[0]
+		}
+		return info, nil
+	}
+
+	info.Entries = s.Range(StreamID{}, MaxStreamID, false, count)
+	now := Now()
+	for _, name := range sortedNames(s.groups) {
+		g := s.groups[name]
+		gi := groupInfo(s, name, g)
+		gi.PEL = pendingEntries(g.pel, count, now)
+		for _, cname := range sortedNames(g.consumers) {
+			ci := consumerInfo(g.consumers[cname], now)
+			ci.PEL = pendingEntries(g.consumers[cname].pel, count, now)
+			gi.ConsumersInfo = append(gi.ConsumersInfo, ci)
+		}
+		info.GroupsInfo = append(info.GroupsInfo, gi)
+	}
+
+	return info, nil
+}
+
+// XInfoGroups describes the consumer groups of the stream stored at key, sorted by name.
+func (kv *KV) XInfoGroups(key string) ([]GroupInfo, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNoSuchKey
+	}
+
+	ret := []GroupInfo{}
+	for _, name := range sortedNames(s.groups) {
+		ret = append(ret, groupInfo(s, name, s.groups[name]))
+	}
+
+	return ret, nil
+}
+
+// XInfoConsumers describes the consumers of the group, sorted by name.
+func (kv *KV) XInfoConsumers(key, group string) ([]ConsumerInfo, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNoSuchKey
+	}
+	g := s.groups[group]
+	if g == nil {
+		return nil, errNoGroup(key, group)
+	}
+
+	now := Now()
+	ret := []ConsumerInfo{}
+	for _, name := range sortedNames(g.consumers) {
+		ret = append(ret, consumerInfo(g.consumers[name], now))
+	}
+
+	return ret, nil
+}
+
+func groupInfo(s *Stream, name string, g *ConsumerGroup) GroupInfo {
+	lag, hasLag := g.lag(s)
+	return GroupInfo{
+		Name:        name,
+		Consumers:   len(g.consumers),
+		Pending:     g.pel.Len(),
+		LastID:      g.lastID,
+		EntriesRead: g.entriesRead,
+		Lag:         lag,
+		HasLag:      hasLag,
+	}
+}
+
+func consumerInfo(c *consumer, now int64) ConsumerInfo {
+	inactive := int64(-1)
+	if c.activeTime >= 0 {
+		inactive = now - c.activeTime
+	}
+	return ConsumerInfo{
+		Name:       c.name,
+		Pending:    c.pel.Len(),
+		Idle:       now - c.seenTime,
+		Inactive:   inactive,
+		SeenTime:   c.seenTime,
+		ActiveTime: c.activeTime,
+	}
+}
+
+// pendingEntries lists up to count entries of the PEL, all of them if count is 0.
+func pendingEntries(pel *rax[*pendingEntry], count int, now int64) []PendingEntry {
+	ret := []PendingEntry{}
+	pel.Ascend(StreamID{}, func(id StreamID, nack *pendingEntry) bool {
+		if count > 0 && len(ret) == count {
+			return false
+		}
+		name := ""
+		if nack.consumer != nil {
+			name = nack.consumer.name
+		}
+		ret = append(ret, PendingEntry{
+			ID:            id,
+			Consumer:      name,
+			Idle:          now - nack.deliveryTime,
+			DeliveryTime:  nack.deliveryTime,
+			DeliveryCount: nack.deliveryCount,
+		})
+		return true
+	})
+	return ret
+}
@@ -0,0 +1,220 @@
+package keyval
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"math/rand"
+)
+
+const dictMinSize = 4
+
+var dictSeed = maphash.MakeSeed()
+
+// dict is a chained hash table like the one redis uses. We need it instead of
+// a go map for the SCAN family of commands: its reverse binary cursor
+// guarantees that every entry present during a whole iteration is returned,
+// even when the table grows or shrinks between two calls.
+type dict[V any] struct {
+	table []*dictEntry[V]
+	used  int
+}
+
+type dictEntry[V any] struct {
+	key  string
+	val  V
+	next *dictEntry[V]
+}
+
+func newDict[V any]() *dict[V] {
+	return &dict[V]{}
+}
+
+// Len returns the number of entries in the dict.
+func (d *dict[V]) Len() int {
+	return d.used
+}
+
+// Get returns the value stored at key.
+func (d *dict[V]) Get(key string) (V, bool) {
+	if e := d.find(key); e != nil {
+		return e.val, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Set stores the value at key and reports whether the key is new.
+func (d *dict[V]) Set(key string, val V) bool {
+	if e := d.find(key); e != nil {
+		e.val = val
+		return false
+	}
+
+	if d.used >= len(d.table) {
+		d.resize(max(len(d.table)*2, dictMinSize))
+	}
+	i := d.bucket(key)
+	d.table[i] = &dictEntry[V]{key: key, val: val, next: d.table[i]}
+	d.used++
+
+	return true
+}
+
+// Delete removes the key and returns the value it held.
+func (d *dict[V]) Delete(key string) (V, bool) {
+	var zero V
+	if d.used == 0 {
+		return zero, false
+	}
+
+	i := d.bucket(key)
+	for prev, e := (*dictEntry[V])(nil), d.table[i]; e != nil; prev, e = e, e.next {
+		if e.key != key {
+			continue
+		}
+		if prev == nil {
+			d.table[i] = e.next
+		} else {
+			prev.next = e.next
+		}
+		d.used--
+		if len(d.table) > dictMinSize && d.used*8 < len(d.table) {
+			d.resize(max(1<<bits.Len(uint(d.used)), dictMinSize))
+		}
+		return e.val, true
+	}
+
+	return zero, false
+}
+
+// Each calls fn for every entry until fn returns false. fn must not modify the dict.
+func (d *dict[V]) Each(fn func(key string, val V) bool) {
+	for _, e := range d.table {
+		for ; e != nil; e = e.next {
+			if !fn(e.key, e.val) {
+				return
+			}
+		}
+	}
+}
+
+// Keys returns all the keys of the dict.
+func (d *dict[V]) Keys() []string {
+	keys := make([]string, 0, d.used)
+	d.Each(func(key string, _ V) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return keys
+}
+
+// Scan calls fn for the entries of the bucket designated by cursor and returns
+// the next cursor, zero once the iteration is complete. Like redis it
+// increments the reversed bits of the cursor so that a table that was resized
+// since the previous call neither skips nor, mostly, repeats buckets.
+// fn must not modify the dict.
+func (d *dict[V]) Scan(cursor uint64, fn func(key string, val V)) uint64 {
+	if d.used == 0 {
+		return 0
+	}
+
+	mask := uint64(len(d.table) - 1)
+	for e := d.table[cursor&mask]; e != nil; e = e.next {
+		fn(e.key, e.val)
+	}
+
+	cursor |= ^mask
+	cursor = bits.Reverse64(cursor)
+	cursor++
+	return bits.Reverse64(cursor)
+}
+
+// clone returns a copy of the dict, the values themselves are copied as is.
+func (d *dict[V]) clone() *dict[V] {
+	c := &dict[V]{table: make([]*dictEntry[V], len(d.table)), used: d.used}
+	for i, e := range d.table {
+		for ; e != nil; e = e.next {
+			c.table[i] = &dictEntry[V]{key: e.key, val: e.val, next: c.table[i]}
+		}
+	}
+
+	return c
+}
+
+// Random returns a random entry of the dict.
+func (d *dict[V]) Random() (string, V, bool) {
+	if d.used == 0 {
+		var zero V
+		return "", zero, false
+	}
+
+	// The load factor never drops under 1/8 so this does not loop for long.
+	var e *dictEntry[V]
+	for e == nil {
+		e = d.table[rand.Intn(len(d.table))]
+	}
+	chain := 0
+	for c := e; c != nil; c = c.next {
+		chain++
+	}
+	for n := rand.Intn(chain); n > 0; n-- {
+		e = e.next
+	}
+
+	return e.key, e.val, true
+}
+
+func (d *dict[V]) find(key string) *dictEntry[V] {
+	if d.used == 0 {
+		return nil
+	}
+	for e := d.table[d.bucket(key)]; e != nil; e = e.next {
+		if e.key == key {
+			return e
+		}
+	}
+	return nil
+}
+
+func (d *dict[V]) bucket(key string) uint64 {
+	return maphash.String(dictSeed, key) & uint64(len(d.table)-1)
+}
+
+// resize rehashes every entry into a table of the given size, a power of two.
+func (d *dict[V]) resize(size int) {
+	old := d.table
+	d.table = make([]*dictEntry[V], size)
+	for _, e := range old {
+		for e != nil {
+			next := e.next
+			i := d.bucket(e.key)
+			e.next = d.table[i]
+			d.table[i] = e
+			e = next
+		}
+	}
+}
+
+// scanDict runs a SCAN like iteration over the dict: it visits buckets until
+// about count entries were returned, giving up after ten times as many
+// buckets so sparse tables do not stall the server. Entries that do not
+// match the glob pattern are skipped, an empty pattern matches everything.
+func scanDict[V any](d *dict[V], cursor uint64, match string, count int, fn func(key string, val V)) uint64 {
+	// The budget is clamped so a huge count can't overflow it.
+	limit := min(count, math.MaxInt/10)
+	emitted := 0
+	for buckets := 0; buckets < limit*10; buckets++ {
+		cursor = d.Scan(cursor, func(key string, val V) {
+			if match == "" || GlobMatch(match, key) {
+				fn(key, val)
+				emitted++
+			}
+		})
+		if cursor == 0 || emitted >= count {
+			break
+		}
+	}
+
+	return cursor
+}
@@ -0,0 +1,115 @@
+package keyval
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// TestDictScanWhileGrowing checks the SCAN guarantee: every key present for
+// the whole iteration is returned even though the table keeps growing.
+func TestDictScanWhileGrowing(t *testing.T) {
+	d := newDict[int]()
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := map[string]bool{}
+	cursor, next := uint64(0), 1000
+	for {
+		cursor = d.Scan(cursor, func(key string, _ int) {
+			seen[key] = true
+		})
+		for i := 0; i < 50 && next < 5000; i++ {
+			d.Set(strconv.Itoa(next), next)
+			next++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Fatalf("key %d was never returned", i)
+		}
+	}
+}
+
+// TestScanDictHugeCount checks a count too big for the bucket budget still
+// returns every entry.
+func TestScanDictHugeCount(t *testing.T) {
+	d := newDict[int]()
+	for i := 0; i < 100; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	for _, count := range []int{math.MaxInt, math.MaxInt/2 + 1} {
+		seen := 0
+		cursor := scanDict(d, 0, "", count, func(string, int) { seen++ })
+		if cursor != 0 || seen != 100 {
+			t.Errorf("expected COUNT %d to return the 100 entries but got %d, cursor %d", count, seen, cursor)
+		}
+	}
+}
+
+// TestDictScanWhileShrinking is the same with a table that shrinks.
+func TestDictScanWhileShrinking(t *testing.T) {
+	d := newDict[int]()
+	for i := 0; i < 5000; i++ {
+		d.Set(strconv.Itoa(i), i)
+	}
+
+	seen := map[string]bool{}
+	cursor, victim := uint64(0), 100
+	for {
+		cursor = d.Scan(cursor, func(key string, _ int) {
+			seen[key] = true
+		})
+		for i := 0; i < 50 && victim < 5000; i++ {
+			d.Delete(strconv.Itoa(victim))
+			victim++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if !seen[strconv.Itoa(i)] {
+			t.Fatalf("key %d was never returned", i)
+		}
+	}
+	if d.Len() != 100 {
+		t.Fatalf("expected 100 keys left but got %d", d.Len())
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	testCases := []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*", "anything", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hallo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"user:*:name", "user:42:name", true},
+		{"user:*:name", "user:42:age", false},
+		{"a*", "", false},
+	}
+
+	for _, tc := range testCases {
+		if got := GlobMatch(tc.pattern, tc.s); got != tc.match {
+			t.Errorf("GlobMatch(%q, %q) = %v, expected %v", tc.pattern, tc.s, got, tc.match)
+		}
+	}
+}
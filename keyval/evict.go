@@ -0,0 +1,313 @@
+package keyval
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+)
+
+// EvictionPolicy is how the keys to evict are picked once the memory limit
+// is reached, the maxmemory-policy of redis.
+type EvictionPolicy int
+
+const (
+	NoEviction EvictionPolicy = iota
+	AllKeysLRU
+	AllKeysLFU
+	AllKeysRandom
+	VolatileLRU
+	VolatileLFU
+	VolatileRandom
+	VolatileTTL
+)
+
+// LFU reports whether the policy picks the least frequently used keys.
+func (p EvictionPolicy) LFU() bool {
+	return p == AllKeysLFU || p == VolatileLFU
+}
+
+// volatile reports whether the policy only evicts the keys with a time to live.
+func (p EvictionPolicy) volatile() bool {
+	return p == VolatileLRU || p == VolatileLFU || p == VolatileRandom || p == VolatileTTL
+}
+
+const (
+	// evictionPoolSize is the number of best candidates kept between two
+	// evictions, the samples of every eviction compete with them.
+	evictionPoolSize = 16
+
+	// The access counter of LFU is a logarithmic counter, it starts at
+	// lfuInitVal so new keys get a chance to be used before being evicted,
+	// and it is decremented every lfuDecayTime minutes the key is not
+	// accessed.
+	lfuInitVal   = 5
+	lfuLogFactor = 10
+	lfuDecayTime = 1
+)
+
+// touch records an access to the object at now, in unix milliseconds.
+func (o *object) touch(now int64) {
+	o.lfu = lfuLogIncr(o.lfuDecr(now))
+	o.ldt = now / 60000
+	o.lru = now
+}
+
+// lfuDecr returns the access counter of the object, decremented by the
+// decay periods elapsed since it was last accessed.
+func (o *object) lfuDecr(now int64) uint8 {
+	periods := (now/60000 - o.ldt) / lfuDecayTime
+	if periods >= int64(o.lfu) {
+		return 0
+	}
+
+	return o.lfu - uint8(max(periods, 0))
+}
+
+// lfuLogIncr increments the counter with a probability that lowers as it
+// grows, 255 accesses of an 8 bits counter then stand for a million.
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == math.MaxUint8 {
+		return counter
+	}
+	base := max(float64(counter)-lfuInitVal, 0)
+	if rand.Float64() < 1/(base*lfuLogFactor+1) {
+		counter++
+	}
+
+	return counter
+}
+
+// evictionCandidate is a sampled key, the higher its idle score the better
+// it is to evict.
+type evictionCandidate struct {
+	idle int64
+	db   int
+	key  string
+}
+
+// Evictor deletes keys of a set of keyspaces, the databases of a server,
+// until their memory gets under a limit. Like redis, the keys are picked
+// among a few sampled ones and the best candidates of the previous samples,
+// an approximation of the policy that does not need to track every key.
+type Evictor struct {
+	Policy EvictionPolicy
+	// Limit is the maximum memory in bytes, zero means no limit.
+	Limit int64
+	// Samples is the number of keys sampled in each keyspace per eviction.
+	Samples int
+	// Evicted is the number of keys evicted so far.
+	Evicted int64
+
+	pool []evictionCandidate
+	// next is the keyspace the random policies evict from next.
+	next int
+}
+
+// NewEvictor creates an evictor without a limit.
+func NewEvictor() *Evictor {
+	return &Evictor{Policy: NoEviction, Samples: 5}
+}
+
+// Used returns the memory of the keyspaces.
+func (e *Evictor) Used(dbs []*KV) int64 {
+	used := int64(0)
+	for _, kv := range dbs {
+		used += kv.Used()
+	}
+
+	return used
+}
+
+// Evict deletes keys until the memory of the keyspaces is under the limit
+// and reports whether it is. It can't be with the noeviction policy, or when
+// the policy only allows keys with a time to live and there are not enough.
+func (e *Evictor) Evict(dbs []*KV) bool {
+	if e.Limit <= 0 {
+		return true
+	}
+	used := e.Used(dbs)
+	for used > e.Limit {
+		if e.Policy == NoEviction {
+			return false
+		}
+		db, key, ok := e.pick(dbs)
+		if !ok {
+			return false
+		}
+		if freed, ok := dbs[db].evict(key); ok {
+			used -= freed
+			e.Evicted++
+		}
+	}
+
+	return true
+}
+
+// pick returns the next key to evict and the index of its keyspace.
+func (e *Evictor) pick(dbs []*KV) (int, string, bool) {
+	if e.Policy == AllKeysRandom || e.Policy == VolatileRandom {
+		for range dbs {
+			db := e.next % len(dbs)
+			e.next++
+			if key, ok := dbs[db].randomKey(e.Policy.volatile()); ok {
+				return db, key, true
+			}
+		}
+		return 0, "", false
+	}
+
+	for {
+		for db, kv := range dbs {
+			kv.sample(e.Policy, e.Samples, func(key string, idle int64) {
+				e.offer(evictionCandidate{idle: idle, db: db, key: key})
+			})
+		}
+		if len(e.pool) == 0 {
+			return 0, "", false
+		}
+		// The best candidate may have been deleted or changed since it was
+		// sampled, it is then dropped and the next one is tried.
+		for len(e.pool) > 0 {
+			best := e.pool[len(e.pool)-1]
+			e.pool = e.pool[:len(e.pool)-1]
+			if best.db < len(dbs) && dbs[best.db].evictable(best.key, e.Policy.volatile()) {
+				return best.db, best.key, true
+			}
+		}
+	}
+}
+
+// offer adds the candidate to the pool, sorted by idle score, unless the
+// pool is full of better ones. A key already in the pool is not added twice.
+func (e *Evictor) offer(c evictionCandidate) {
+	for _, p := range e.pool {
+		if p.db == c.db && p.key == c.key {
+			return
+		}
+	}
+	i, _ := slices.BinarySearchFunc(e.pool, c.idle, func(p evictionCandidate, idle int64) int {
+		switch {
+		case p.idle < idle:
+			return -1
+		case p.idle > idle:
+			return 1
+		}
+		return 0
+	})
+	if len(e.pool) == evictionPoolSize {
+		if i == 0 {
+			return
+		}
+		// The worst candidate makes room.
+		e.pool = e.pool[1:]
+		i--
+	}
+	e.pool = slices.Insert(e.pool, i, c)
+}
+
+// sample calls fn with up to n keys, the volatile ones for the volatile
+// policies, and their idle score for the policy.
+func (kv *KV) sample(policy EvictionPolicy, n int, fn func(key string, idle int64)) {
+	defer kv.lockAll()()
+
+	now := Now()
+	score := func(key string, o *object) int64 {
+		switch {
+		case policy == VolatileTTL:
+			// The sooner the key expires the better.
+			at, _ := kv.expireAt(key)
+			return math.MaxInt64 - at
+		case policy.LFU():
+			return math.MaxUint8 - int64(o.lfuDecr(now))
+		default:
+			return now - o.lru
+		}
+	}
+
+	// Like dictGetSomeKeys in redis, a keyspace with no more candidates than
+	// the sample is sampled whole.
+	weight := keyCount
+	if policy.volatile() {
+		weight = expireCount
+	}
+	total := 0
+	for _, s := range kv.shards {
+		total += weight(s)
+	}
+	if total <= n {
+		for _, s := range kv.shards {
+			keys := s.data.Keys()
+			if policy.volatile() {
+				keys = s.expires.Keys()
+			}
+			for _, key := range keys {
+				o, _ := s.data.Get(key)
+				fn(key, score(key, o))
+			}
+		}
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		key, ok := kv.pickKey(policy.volatile())
+		if !ok {
+			return
+		}
+		o, _ := kv.shard(key).data.Get(key)
+		fn(key, score(key, o))
+	}
+}
+
+// randomKey returns a random key, a volatile one if volatile is set.
+func (kv *KV) randomKey(volatile bool) (string, bool) {
+	defer kv.lockAll()()
+
+	return kv.pickKey(volatile)
+}
+
+// pickKey is randomKey for the callers holding the lock of every shard.
+func (kv *KV) pickKey(volatile bool) (string, bool) {
+	if volatile {
+		s, ok := kv.randomShard(expireCount)
+		if !ok {
+			return "", false
+		}
+		key, _, _ := s.expires.Random()
+		return key, true
+	}
+	s, ok := kv.randomShard(keyCount)
+	if !ok {
+		return "", false
+	}
+	key, _, _ := s.data.Random()
+
+	return key, true
+}
+
+// evictable reports whether the key can still be evicted.
+func (kv *KV) evictable(key string, volatile bool) bool {
+	defer kv.lock(key)()
+
+	if volatile {
+		_, ok := kv.expireAt(key)
+		return ok
+	}
+
+	return kv.exists(key)
+}
+
+// evict deletes the key and returns the memory it freed.
+func (kv *KV) evict(key string) (int64, bool) {
+	defer kv.lock(key)()
+
+	s := kv.shard(key)
+	s.settle()
+	o, ok := s.data.Get(key)
+	if !ok {
+		return 0, false
+	}
+	freed := o.size
+	s.delete(key, s.lazy.Eviction.Load())
+
+	return freed, true
+}
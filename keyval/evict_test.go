@@ -0,0 +1,141 @@
+package keyval
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestMemoryAccounting checks the memory follows the keys as they are
+// added, grown in place and deleted.
+func TestMemoryAccounting(t *testing.T) {
+	kv := NewKeyVal()
+	if used := kv.Used(); used != 0 {
+		t.Fatalf("expected an empty keyspace to use nothing but got %d", used)
+	}
+
+	kv.Set([]byte("string"), []byte(strings.Repeat("x", 1000)))
+	afterString := kv.Used()
+	if afterString < 1000 {
+		t.Fatalf("expected at least the 1000 bytes of the string but got %d", afterString)
+	}
+
+	kv.SAdd("set", []string{"a"})
+	small := kv.Used()
+	members := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, "member:"+strconv.Itoa(i))
+	}
+	kv.SAdd("set", members)
+	if large := kv.Used(); large < small+10000 {
+		t.Fatalf("expected the set growing in place to be accounted but went from %d to %d", small, large)
+	}
+
+	kv.Del([]string{"set"})
+	if used := kv.Used(); used != afterString {
+		t.Fatalf("expected deleting the set to free it but got %d instead of %d", used, afterString)
+	}
+	kv.Flush(false)
+	if used := kv.Used(); used != 0 {
+		t.Fatalf("expected a flushed keyspace to use nothing but got %d", used)
+	}
+}
+
+// eachObject calls fn with the keys of every shard.
+func eachObject(kv *KV, fn func(key string, o *object) bool) {
+	for _, s := range kv.shards {
+		s.data.Each(fn)
+	}
+}
+
+func TestEvictLRU(t *testing.T) {
+	kv := NewKeyVal()
+	for i := 0; i < 100; i++ {
+		kv.Set([]byte("old:"+strconv.Itoa(i)), []byte("value"))
+		kv.Set([]byte("new:"+strconv.Itoa(i)), []byte("value"))
+	}
+	now := Now()
+	eachObject(kv, func(key string, o *object) bool {
+		if strings.HasPrefix(key, "old:") {
+			o.lru = now - 3600*1000
+		}
+		return true
+	})
+
+	e := NewEvictor()
+	e.Policy, e.Limit = AllKeysLRU, kv.Used()/2
+	if !e.Evict([]*KV{kv}) {
+		t.Fatal("expected the eviction to get under the limit")
+	}
+	if used := kv.Used(); used > e.Limit {
+		t.Fatalf("expected at most %d bytes but got %d", e.Limit, used)
+	}
+	old, recent := 0, 0
+	eachObject(kv, func(key string, _ *object) bool {
+		if strings.HasPrefix(key, "old:") {
+			old++
+		} else {
+			recent++
+		}
+		return true
+	})
+	if old >= recent || e.Evicted == 0 {
+		t.Fatalf("expected the least recently used keys to be evicted but %d old and %d new keys are left", old, recent)
+	}
+}
+
+func TestEvictVolatile(t *testing.T) {
+	kv := NewKeyVal()
+	for i := 0; i < 10; i++ {
+		kv.Set([]byte("persistent:"+strconv.Itoa(i)), []byte("value"))
+	}
+	kv.Set([]byte("soon"), []byte("value"))
+	kv.Set([]byte("later"), []byte("value"))
+	kv.Expire("soon", Now()+1000, 0)
+	kv.Expire("later", Now()+100000, 0)
+
+	// The two volatile keys are fewer than the sample, both are looked at.
+	e := NewEvictor()
+	e.Policy, e.Limit = VolatileTTL, kv.Used()-1
+	if !e.Evict([]*KV{kv}) {
+		t.Fatal("expected the eviction to get under the limit")
+	}
+	if kv.Exists([]string{"soon"}) != 0 || kv.Exists([]string{"later"}) != 1 {
+		t.Fatal("expected the key expiring first to be evicted")
+	}
+
+	// There are not enough volatile keys to get under the limit.
+	e.Limit = 1
+	if e.Evict([]*KV{kv}) {
+		t.Fatal("expected the eviction to fail")
+	}
+	if n := kv.DBSize(); n != 10 {
+		t.Fatalf("expected the persistent keys to be kept but got %d keys", n)
+	}
+
+	e.Policy = NoEviction
+	if e.Evict([]*KV{kv}) || kv.DBSize() != 10 {
+		t.Fatal("expected noeviction not to evict anything")
+	}
+}
+
+func TestLFUCounter(t *testing.T) {
+	kv := NewKeyVal()
+	kv.Set([]byte("hot"), []byte("value"))
+	kv.Set([]byte("cold"), []byte("value"))
+	for i := 0; i < 1000; i++ {
+		kv.Get([]byte("hot"))
+	}
+
+	hot, _ := kv.Freq("hot")
+	cold, _ := kv.Freq("cold")
+	if cold != lfuInitVal || hot <= cold {
+		t.Fatalf("expected the hot key to be counted more than the cold one but got %d and %d", hot, cold)
+	}
+
+	o, _ := kv.shard("hot").data.Get("hot")
+	o.ldt -= 3
+	if decayed, _ := kv.Freq("hot"); decayed != hot-3 {
+		t.Fatalf("expected the counter to decay by 3 but got %d from %d", decayed, hot)
+	}
+}
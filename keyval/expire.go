@@ -0,0 +1,169 @@
+package keyval
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	// activeExpireSamples is how many volatile keys a single sweep looks at.
+	activeExpireSamples = 20
+	// activeExpireBudget bounds the time a single ActiveExpireCycle may take so the
+	// server loop is never starved by the sweeper.
+	activeExpireBudget = 25 * time.Millisecond
+)
+
+// ExpireFlags are the NX, XX, GT and LT options of the EXPIRE family.
+type ExpireFlags uint8
+
+const (
+	// ExpireNX sets the expiry only when the key has none.
+	ExpireNX ExpireFlags = 1 << iota
+	// ExpireXX sets the expiry only when the key already has one.
+	ExpireXX
+	// ExpireGT sets the expiry only when the new one is greater than the current one.
+	ExpireGT
+	// ExpireLT sets the expiry only when the new one is less than the current one.
+	ExpireLT
+)
+
+// Now returns the current unix time in milliseconds, this is the clock used for expiries.
+func Now() int64 {
+	return time.Now().UnixMilli()
+}
+
+// Expire sets the absolute expiry (unix milliseconds) of the key.
+// It reports whether the timeout was applied, a key that does not exist or a
+// condition that does not hold leaves the key untouched.
+// As in redis an expiry in the past deletes the key right away.
+func (kv *KV) Expire(key string, at int64, flags ExpireFlags) bool {
+	defer kv.lock(key)()
+
+	kv.expireIfNeeded(key)
+	if !kv.exists(key) {
+		return false
+	}
+
+	current, volatile := kv.expireAt(key)
+	switch {
+	case flags&ExpireNX != 0 && volatile:
+		return false
+	case flags&ExpireXX != 0 && !volatile:
+		return false
+	// A key without a ttl is considered to have an infinite one.
+	case flags&ExpireGT != 0 && (!volatile || at <= current):
+		return false
+	case flags&ExpireLT != 0 && volatile && at >= current:
+		return false
+	}
+
+	if at <= Now() {
+		kv.shard(key).expire(key)
+		return true
+	}
+	kv.setExpire(key, at)
+
+	return true
+}
+
+// TTL returns the remaining time to live of the key in milliseconds.
+// It returns -2 if the key does not exist and -1 if it has no expiry.
+func (kv *KV) TTL(key string) int64 {
+	at := kv.ExpireTime(key)
+	if at < 0 {
+		return at
+	}
+	ttl := at - Now()
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	return ttl
+}
+
+// ExpireTime returns the absolute expiry of the key in unix milliseconds.
+// It returns -2 if the key does not exist and -1 if it has no expiry.
+func (kv *KV) ExpireTime(key string) int64 {
+	defer kv.lock(key)()
+
+	kv.expireIfNeeded(key)
+	if !kv.exists(key) {
+		return -2
+	}
+	at, ok := kv.expireAt(key)
+	if !ok {
+		return -1
+	}
+
+	return at
+}
+
+// Persist removes the expiry of the key and reports whether there was one.
+func (kv *KV) Persist(key string) bool {
+	defer kv.lock(key)()
+
+	kv.expireIfNeeded(key)
+
+	return kv.persist(key)
+}
+
+// ActiveExpireCycle is the background counterpart of the lazy expiry, it
+// samples volatile keys and deletes the expired ones. Like redis it keeps
+// going while more than a quarter of the sample was expired, within a small
+// time budget shared by the shards. It returns the number of keys that were
+// deleted.
+func (kv *KV) ActiveExpireCycle() int {
+	start := time.Now()
+	deleted := 0
+	// The shards the budget is left for vary from one cycle to the next.
+	first := rand.Intn(len(kv.shards))
+	for i := range kv.shards {
+		if time.Since(start) > activeExpireBudget {
+			break
+		}
+		deleted += kv.expireShard(kv.shards[(first+i)%len(kv.shards)], start)
+	}
+
+	return deleted
+}
+
+// expireShard runs the sampling of ActiveExpireCycle on one shard, locking
+// only this one.
+func (kv *KV) expireShard(s *shard, start time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for {
+		// Like redis, the sample is drawn from the volatile keys at random.
+		sampled, expired := min(s.expires.Len(), activeExpireSamples), 0
+		now := Now()
+		for i := 0; i < sampled; i++ {
+			key, at, ok := s.expires.Random()
+			if ok && at <= now {
+				s.expire(key)
+				expired++
+			}
+		}
+		deleted += expired
+
+		if expired*4 <= sampled || time.Since(start) > activeExpireBudget {
+			return deleted
+		}
+	}
+}
+
+// expireIfNeeded deletes the key if its time to live elapsed, the caller must hold the lock.
+func (kv *KV) expireIfNeeded(key string) bool {
+	return kv.shard(key).expireIfNeeded(key)
+}
+
+func (s *shard) expireIfNeeded(key string) bool {
+	at, ok := s.expires.Get(key)
+	if !ok || at > Now() {
+		return false
+	}
+	s.expire(key)
+
+	return true
+}
@@ -0,0 +1,171 @@
+package keyval
+
+import (
+	"errors"
+	"sort"
+)
+
+var ErrGeoMember = errors.New("ERR could not decode requested zset member")
+
+// GeoMember is a member of a geo index with its coordinates, and for the
+// results of a search its distance to the center and its geohash score.
+type GeoMember struct {
+	Member              string
+	Longitude, Latitude float64
+	Dist                float64
+	Hash                uint64
+}
+
+// GeoSort is the order of the results of a search.
+type GeoSort int
+
+const (
+	GeoUnsorted GeoSort = iota
+	GeoAsc
+	GeoDesc
+)
+
+// GeoQuery describes a GEOSEARCH. The center is the position of Member with
+// FromMember and the given coordinates otherwise. The shape is a circle of
+// Radius, or a Width by Height box with ByBox, in units of Conversion meters,
+// the distances of the results are in the same units. Count limits the
+// results unless it is zero, with Any the search stops as soon as it found
+// that many.
+type GeoQuery struct {
+	FromMember          bool
+	Member              string
+	Longitude, Latitude float64
+	ByBox               bool
+	Radius              float64
+	Width, Height       float64
+	Conversion          float64
+	Sort                GeoSort
+	Count               int
+	Any                 bool
+}
+
+// GeoAdd adds the members to the geo index stored at key, the coordinates
+// must be in the valid ranges. It behaves like ZAdd.
+func (kv *KV) GeoAdd(key string, opts ZAddOptions, members []GeoMember) (int, error) {
+	zmembers := make([]ZMember, 0, len(members))
+	for _, m := range members {
+		zmembers = append(zmembers, ZMember{Member: m.Member, Score: GeoScore(m.Longitude, m.Latitude)})
+	}
+
+	return kv.ZAdd(key, opts, zmembers)
+}
+
+// GeoSearch returns the members of the geo index stored at key within the shape.
+func (kv *KV) GeoSearch(key string, q GeoQuery) ([]GeoMember, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return []GeoMember{}, err
+	}
+
+	return z.geoSearch(q)
+}
+
+// GeoSearchStore stores the result of the search at destination as a geo
+// index, or as a sorted set of the distances with storeDist, and returns its
+// size.
+func (kv *KV) GeoSearchStore(destination, source string, q GeoQuery, storeDist bool) (int, error) {
+	defer kv.lock(destination, source)()
+
+	z, err := kv.zset(source)
+	if err != nil {
+		return 0, err
+	}
+	var found []GeoMember
+	if z != nil {
+		if found, err = z.geoSearch(q); err != nil {
+			return 0, err
+		}
+	}
+
+	members := make([]ZMember, 0, len(found))
+	for _, m := range found {
+		score := float64(m.Hash)
+		if storeDist {
+			score = m.Dist
+		}
+		members = append(members, ZMember{Member: m.Member, Score: score})
+	}
+	kv.storeZSet(destination, members)
+
+	return len(members), nil
+}
+
+// geoSearch looks for the members in the box of the center and its
+// neighbors, filtering those out of the shape, like redis does.
+func (z *ZSet) geoSearch(q GeoQuery) ([]GeoMember, error) {
+	shape := geoShape{
+		long:   q.Longitude,
+		lat:    q.Latitude,
+		box:    q.ByBox,
+		radius: q.Radius * q.Conversion,
+		width:  q.Width * q.Conversion,
+		height: q.Height * q.Conversion,
+	}
+	if q.FromMember {
+		score, ok := z.Score(q.Member)
+		if !ok {
+			return nil, ErrGeoMember
+		}
+		shape.long, shape.lat = GeoDecode(score)
+	}
+
+	limit := 0
+	if q.Any {
+		limit = q.Count
+	}
+	// Without an order COUNT would not return the closest members.
+	if q.Count > 0 && q.Sort == GeoUnsorted && !q.Any {
+		q.Sort = GeoAsc
+	}
+
+	found := []GeoMember{}
+	boxes := shape.searchBoxes()
+	last := 0
+	for i, box := range boxes {
+		if box.isZero() {
+			continue
+		}
+		// With a huge radius the neighbors may be the same box. Like redis
+		// the center box is not compared.
+		if last > 0 && box == boxes[last] {
+			continue
+		}
+		if limit > 0 && len(found) >= limit {
+			break
+		}
+		min, max := box.scoreRange()
+		r := scoreRange(ScoreBound{Value: min}, ScoreBound{Value: max, Exclusive: true})
+		for x := z.zsl.firstInRange(r); x != nil && r.lteMax(x); x = x.level[0].forward {
+			long, lat := GeoDecode(x.score)
+			if dist, ok := shape.contains(long, lat); ok {
+				found = append(found, GeoMember{Member: x.member, Longitude: long, Latitude: lat, Dist: dist, Hash: uint64(x.score)})
+				if limit > 0 && len(found) >= limit {
+					break
+				}
+			}
+		}
+		last = i
+	}
+
+	switch q.Sort {
+	case GeoAsc:
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Dist < found[j].Dist })
+	case GeoDesc:
+		sort.SliceStable(found, func(i, j int) bool { return found[i].Dist > found[j].Dist })
+	}
+	if q.Count > 0 && len(found) > q.Count {
+		found = found[:q.Count]
+	}
+	for i := range found {
+		found[i].Dist /= q.Conversion
+	}
+
+	return found, nil
+}
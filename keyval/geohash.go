@@ -0,0 +1,332 @@
+package keyval
+
+import "math"
+
+// The geo commands store the members of a sorted set with a score that is
+// the 52 bits geohash of their coordinates, the 26 bits of the latitude and
+// the 26 bits of the longitude interleaved, like redis does. The latitudes
+// are limited to what the web mercator projection covers.
+const (
+	GeoLongMin = -180
+	GeoLongMax = 180
+	GeoLatMin  = -85.05112878
+	GeoLatMax  = 85.05112878
+
+	geoStepMax  = 26
+	mercatorMax = 20037726.37
+	// earthRadius is the radius redis computes the distances with, in meters.
+	earthRadius = 6372797.560856
+)
+
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// geoRange is the span of a coordinate.
+type geoRange struct {
+	min, max float64
+}
+
+var (
+	geoLongRange = geoRange{GeoLongMin, GeoLongMax}
+	geoLatRange  = geoRange{GeoLatMin, GeoLatMax}
+)
+
+// geoHashBits is a geohash of step bits per coordinate, the zero value marks
+// a neighbor the search skips.
+type geoHashBits struct {
+	bits uint64
+	step uint
+}
+
+func (h geoHashBits) isZero() bool {
+	return h.bits == 0 && h.step == 0
+}
+
+// geoArea is the box a geohash stands for.
+type geoArea struct {
+	long, lat geoRange
+}
+
+// GeoScore returns the sorted set score of the coordinates, which must be in
+// the valid ranges.
+func GeoScore(long, lat float64) float64 {
+	return float64(geoEncode(geoLongRange, geoLatRange, long, lat, geoStepMax).bits)
+}
+
+// GeoDecode returns the coordinates of the center of the box of the sorted
+// set score.
+func GeoDecode(score float64) (float64, float64) {
+	area := geoDecode(geoLongRange, geoLatRange, geoHashBits{bits: uint64(score), step: geoStepMax})
+	long := min(max((area.long.min+area.long.max)/2, GeoLongMin), GeoLongMax)
+	lat := min(max((area.lat.min+area.lat.max)/2, GeoLatMin), GeoLatMax)
+
+	return long, lat
+}
+
+// GeoHash returns the standard 11 characters geohash of the sorted set
+// score. It is encoded again over the whole latitude range, and as 52 bits
+// are not enough for 11 characters the last one is always 0.
+func GeoHash(score float64) string {
+	long, lat := GeoDecode(score)
+	hash := geoEncode(geoRange{-180, 180}, geoRange{-90, 90}, long, lat, geoStepMax)
+
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(hash.bits>>(52-(i+1)*5)) & 0x1f
+		}
+		buf[i] = geoAlphabet[idx]
+	}
+
+	return string(buf)
+}
+
+// GeoDistance returns the distance in meters between two points with the
+// haversine formula.
+func GeoDistance(long1, lat1, long2, lat2 float64) float64 {
+	long1r, long2r := degRad(long1), degRad(long2)
+	v := math.Sin((long2r - long1r) / 2)
+	// When the longitudes are the same there is no need for the expensive part.
+	if v == 0 {
+		return geoLatDistance(lat1, lat2)
+	}
+	lat1r, lat2r := degRad(lat1), degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func geoLatDistance(lat1, lat2 float64) float64 {
+	return earthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+func degRad(deg float64) float64 {
+	return deg * (math.Pi / 180)
+}
+
+func radDeg(rad float64) float64 {
+	return rad / (math.Pi / 180)
+}
+
+func geoEncode(longRange, latRange geoRange, long, lat float64, step uint) geoHashBits {
+	latOffset := (lat - latRange.min) / (latRange.max - latRange.min)
+	longOffset := (long - longRange.min) / (longRange.max - longRange.min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+
+	return geoHashBits{bits: interleave64(uint32(latOffset), uint32(longOffset)), step: step}
+}
+
+func geoDecode(longRange, latRange geoRange, hash geoHashBits) geoArea {
+	sep := deinterleave64(hash.bits)
+	ilat, ilong := float64(uint32(sep)), float64(uint32(sep>>32))
+	scale := float64(uint64(1) << hash.step)
+	latScale, longScale := latRange.max-latRange.min, longRange.max-longRange.min
+
+	return geoArea{
+		long: geoRange{
+			min: longRange.min + ilong/scale*longScale,
+			max: longRange.min + (ilong+1)/scale*longScale,
+		},
+		lat: geoRange{
+			min: latRange.min + ilat/scale*latScale,
+			max: latRange.min + (ilat+1)/scale*latScale,
+		},
+	}
+}
+
+// interleave64 interleaves the bits of x and y, x taking the even positions
+// and y the odd ones.
+func interleave64(x, y uint32) uint64 {
+	return spread(x) | spread(y)<<1
+}
+
+// deinterleave64 reverses interleave64, x in the low half and y in the high one.
+func deinterleave64(interleaved uint64) uint64 {
+	return squash(interleaved) | squash(interleaved>>1)<<32
+}
+
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000FFFF0000FFFF
+	x = (x | x<<8) & 0x00FF00FF00FF00FF
+	x = (x | x<<4) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+
+	return x
+}
+
+func squash(x uint64) uint64 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0F0F0F0F0F0F0F0F
+	x = (x | x>>4) & 0x00FF00FF00FF00FF
+	x = (x | x>>8) & 0x0000FFFF0000FFFF
+	x = (x | x>>16) & 0x00000000FFFFFFFF
+
+	return x
+}
+
+// geoMoveX moves the geohash d boxes east, or west when d is negative.
+func geoMoveX(hash geoHashBits, d int) geoHashBits {
+	x := hash.bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - hash.step*2)
+	if d > 0 {
+		x += zz + 1
+	} else {
+		x |= zz
+		x -= zz + 1
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - hash.step*2)
+
+	return geoHashBits{bits: x | y, step: hash.step}
+}
+
+// geoMoveY moves the geohash d boxes north, or south when d is negative.
+func geoMoveY(hash geoHashBits, d int) geoHashBits {
+	x := hash.bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - hash.step*2)
+	if d > 0 {
+		y += zz + 1
+	} else {
+		y |= zz
+		y -= zz + 1
+	}
+	y &= 0x5555555555555555 >> (64 - hash.step*2)
+
+	return geoHashBits{bits: x | y, step: hash.step}
+}
+
+// geoShape is the area a GEOSEARCH looks into, a circle of radius meters or
+// a box of width by height meters around the center.
+type geoShape struct {
+	long, lat     float64
+	box           bool
+	radius        float64
+	width, height float64
+}
+
+// contains returns the distance of the point to the center of the shape and
+// whether it is in the shape.
+func (s geoShape) contains(long, lat float64) (float64, bool) {
+	if !s.box {
+		dist := GeoDistance(s.long, s.lat, long, lat)
+		return dist, dist <= s.radius
+	}
+	// The latitude distance is the cheaper, it goes first.
+	if geoLatDistance(lat, s.lat) > s.height/2 {
+		return 0, false
+	}
+	if GeoDistance(long, lat, s.long, lat) > s.width/2 {
+		return 0, false
+	}
+
+	return GeoDistance(s.long, s.lat, long, lat), true
+}
+
+// boundingBox returns the minimum and maximum longitudes and latitudes of the shape.
+func (s geoShape) boundingBox() (float64, float64, float64, float64) {
+	height, width := s.radius, s.radius
+	if s.box {
+		height, width = s.height/2, s.width/2
+	}
+	latDelta := radDeg(height / earthRadius)
+	longDeltaTop := radDeg(width / earthRadius / math.Cos(degRad(s.lat+latDelta)))
+	longDeltaBottom := radDeg(width / earthRadius / math.Cos(degRad(s.lat-latDelta)))
+	// The hemispheres go opposite ways, the widest side is not the same.
+	longDelta := longDeltaTop
+	if s.lat < 0 {
+		longDelta = longDeltaBottom
+	}
+
+	return s.long - longDelta, s.lat - latDelta, s.long + longDelta, s.lat + latDelta
+}
+
+// geoEstimateSteps returns the precision of the boxes that, with their
+// neighbors, cover a circle of the radius in meters at the latitude.
+func geoEstimateSteps(radius, lat float64) uint {
+	if radius == 0 {
+		return geoStepMax
+	}
+	step := 1
+	for ; radius < mercatorMax; radius *= 2 {
+		step++
+	}
+	step -= 2
+	// The boxes get narrower towards the poles.
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+
+	return uint(min(max(step, 1), geoStepMax))
+}
+
+// searchBoxes returns the box of the center of the shape and its eight
+// neighbors, the ones that cannot hold a point of the shape being zero.
+func (s geoShape) searchBoxes() [9]geoHashBits {
+	minLong, minLat, maxLong, maxLat := s.boundingBox()
+	radius := s.radius
+	if s.box {
+		radius = math.Sqrt((s.width/2)*(s.width/2) + (s.height/2)*(s.height/2))
+	}
+	steps := geoEstimateSteps(radius, s.lat)
+
+	boxes := func(steps uint) (geoHashBits, geoArea, [8]geoHashBits) {
+		hash := geoEncode(geoLongRange, geoLatRange, s.long, s.lat, steps)
+		// north, south, east, west, north east, north west, south east, south west
+		neighbors := [8]geoHashBits{
+			geoMoveY(hash, 1),
+			geoMoveY(hash, -1),
+			geoMoveX(hash, 1),
+			geoMoveX(hash, -1),
+			geoMoveY(geoMoveX(hash, 1), 1),
+			geoMoveY(geoMoveX(hash, -1), 1),
+			geoMoveY(geoMoveX(hash, 1), -1),
+			geoMoveY(geoMoveX(hash, -1), -1),
+		}
+		return hash, geoDecode(geoLongRange, geoLatRange, hash), neighbors
+	}
+	hash, area, neighbors := boxes(steps)
+
+	// Near the edges of the box the estimated step may be too coarse for the
+	// neighbors to cover the whole shape.
+	north := geoDecode(geoLongRange, geoLatRange, neighbors[0])
+	south := geoDecode(geoLongRange, geoLatRange, neighbors[1])
+	east := geoDecode(geoLongRange, geoLatRange, neighbors[2])
+	west := geoDecode(geoLongRange, geoLatRange, neighbors[3])
+	if steps > 1 && (north.lat.max < maxLat || south.lat.min > minLat || east.long.max < maxLong || west.long.min > minLong) {
+		steps--
+		hash, area, neighbors = boxes(steps)
+	}
+
+	if steps >= 2 {
+		if area.lat.min < minLat {
+			neighbors[1], neighbors[6], neighbors[7] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.lat.max > maxLat {
+			neighbors[0], neighbors[4], neighbors[5] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.long.min < minLong {
+			neighbors[3], neighbors[7], neighbors[5] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+		if area.long.max > maxLong {
+			neighbors[2], neighbors[6], neighbors[4] = geoHashBits{}, geoHashBits{}, geoHashBits{}
+		}
+	}
+
+	return [9]geoHashBits{hash, neighbors[0], neighbors[1], neighbors[2], neighbors[3], neighbors[4], neighbors[5], neighbors[6], neighbors[7]}
+}
+
+// scoreRange returns the scores of the points in the box, the maximum being
+// exclusive.
+func (h geoHashBits) scoreRange() (float64, float64) {
+	shift := 52 - h.step*2
+	return float64(h.bits << shift), float64((h.bits + 1) << shift)
+}
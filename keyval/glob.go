@@ -0,0 +1,87 @@
+package keyval
+
+// GlobMatch reports whether s matches the glob style pattern, with the exact
+// semantic of redis' stringmatchlen: * and ? wildcards, [abc], [^abc] and
+// [a-z] classes and backslash escapes.
+func GlobMatch(pattern, s string) bool {
+	p := 0
+	for p < len(pattern) && len(s) > 0 {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p+1 == len(pattern) {
+				return true
+			}
+			for ; len(s) > 0; s = s[1:] {
+				if GlobMatch(pattern[p+1:], s) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			s = s[1:]
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+			match := false
+			for {
+				if p >= len(pattern) {
+					// An unterminated class, step back so the loop ends on its last character.
+					p--
+					break
+				}
+				if pattern[p] == '\\' && p+1 < len(pattern) {
+					p++
+					if pattern[p] == s[0] {
+						match = true
+					}
+				} else if pattern[p] == ']' {
+					break
+				} else if p+2 < len(pattern) && pattern[p+1] == '-' {
+					start, end := pattern[p], pattern[p+2]
+					if start > end {
+						start, end = end, start
+					}
+					if s[0] >= start && s[0] <= end {
+						match = true
+					}
+					p += 2
+				} else if pattern[p] == s[0] {
+					match = true
+				}
+				p++
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s = s[1:]
+		case '\\':
+			if p+1 < len(pattern) {
+				p++
+			}
+			fallthrough
+		default:
+			if pattern[p] != s[0] {
+				return false
+			}
+			s = s[1:]
+		}
+		p++
+		if len(s) == 0 {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			break
+		}
+	}
+
+	return p == len(pattern) && len(s) == 0
+}
@@ -0,0 +1,367 @@
+package keyval
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+const (
+	// Like redis, small hashes are reported as listpacks by OBJECT ENCODING.
+	hashMaxListpackEntries = 128
+	hashMaxListpackValue   = 64
+)
+
+var (
+	ErrHashNotInteger = errors.New("ERR hash value is not an integer")
+	ErrHashNotFloat   = errors.New("ERR hash value is not a float")
+	ErrOverflow       = errors.New("ERR increment or decrement would overflow")
+	ErrNaN            = errors.New("ERR increment would produce NaN or Infinity")
+)
+
+// Hash is the value of redis hashes, a dict of fields to values.
+type Hash = dict[string]
+
+func newHashObject() *object {
+	return &object{typ: TypeHash, value: newDict[string]()}
+}
+
+// HSet sets the field value pairs of the hash stored at key, creating it if
+// needed. It returns the number of fields that were added.
+func (kv *KV) HSet(key string, pairs []string) (int, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hashForWrite(key)
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if h.Set(pairs[i], pairs[i+1]) {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// HSetNX sets the field only if it does not exist yet and reports whether it did.
+func (kv *KV) HSetNX(key, field, value string) (bool, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hashForWrite(key)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := h.Get(field); ok {
+		return false, nil
+	}
+	h.Set(field, value)
+
+	return true, nil
+}
+
+// HGet returns the value of the field in the hash stored at key.
+func (kv *KV) HGet(key, field string) (string, bool, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return "", false, err
+	}
+	value, ok := h.Get(field)
+
+	return value, ok, nil
+}
+
+// HMGet returns the values of the fields, and for each whether it exists.
+func (kv *KV) HMGet(key string, fields []string) ([]string, []bool, error) {
+	defer kv.lock(key)()
+
+	values, found := make([]string, len(fields)), make([]bool, len(fields))
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return values, found, err
+	}
+	for i, field := range fields {
+		values[i], found[i] = h.Get(field)
+	}
+
+	return values, found, nil
+}
+
+// HDel removes the fields from the hash and returns how many existed. An
+// emptied hash is deleted.
+func (kv *KV) HDel(key string, fields []string) (int, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, field := range fields {
+		if _, ok := h.Delete(field); ok {
+			removed++
+		}
+	}
+	if h.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return removed, nil
+}
+
+// HExists reports whether the field exists in the hash stored at key.
+func (kv *KV) HExists(key, field string) (bool, error) {
+	_, ok, err := kv.HGet(key, field)
+	return ok, err
+}
+
+// HLen returns the number of fields of the hash stored at key.
+func (kv *KV) HLen(key string) (int, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return 0, err
+	}
+
+	return h.Len(), nil
+}
+
+// HGetAll returns the fields and the values of the hash stored at key,
+// interleaved, only the fields or only the values.
+func (kv *KV) HGetAll(key string, fields, values bool) ([]string, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return []string{}, err
+	}
+
+	ret := make([]string, 0, h.Len()*2)
+	h.Each(func(field, value string) bool {
+		if fields {
+			ret = append(ret, field)
+		}
+		if values {
+			ret = append(ret, value)
+		}
+		return true
+	})
+
+	return ret, nil
+}
+
+// HIncrBy increments the integer stored in the field, a missing field counts as 0.
+func (kv *KV) HIncrBy(key, field string, delta int64) (int64, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	if value, ok := hashField(h, field); ok {
+		if n, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return 0, ErrHashNotInteger
+		}
+	}
+	if n, err = addInt64(n, delta); err != nil {
+		return 0, err
+	}
+	if h == nil {
+		h, _ = kv.hashForWrite(key)
+	}
+	h.Set(field, strconv.FormatInt(n, 10))
+
+	return n, nil
+}
+
+// HIncrByFloat increments the number stored in the field and returns its new
+// value as it was stored.
+func (kv *KV) HIncrByFloat(key, field string, delta float64) (string, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil {
+		return "", err
+	}
+
+	var n float64
+	if value, ok := hashField(h, field); ok {
+		if n, err = parseFloat(value); err != nil {
+			return "", ErrHashNotFloat
+		}
+	}
+	n += delta
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return "", ErrNaN
+	}
+	value := formatFloat(n)
+	if h == nil {
+		h, _ = kv.hashForWrite(key)
+	}
+	h.Set(field, value)
+
+	return value, nil
+}
+
+// HStrLen returns the length of the value of the field.
+func (kv *KV) HStrLen(key, field string) (int, error) {
+	value, _, err := kv.HGet(key, field)
+	return len(value), err
+}
+
+// HRandField draws random fields of the hash stored at key, see randomSample
+// for the meaning of count and fn. With withValues the batches interleave the
+// fields with their values, n still counting the fields.
+func (kv *KV) HRandField(key string, count int, withValues bool, fn func(n int, batch []string) error) error {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil {
+		return err
+	}
+	if h == nil {
+		return fn(0, nil)
+	}
+
+	random := func() string {
+		field, _, _ := h.Random()
+		return field
+	}
+	return randomSample(count, h.Len(), random, h.Keys, func(n int, fields []string) error {
+		if !withValues {
+			return fn(n, fields)
+		}
+		batch := make([]string, 0, len(fields)*2)
+		for _, field := range fields {
+			value, _ := h.Get(field)
+			batch = append(batch, field, value)
+		}
+		return fn(n, batch)
+	})
+}
+
+// HScan runs one step of a HSCAN iteration over the hash stored at key. It
+// returns the next cursor and the matching fields with their values.
+func (kv *KV) HScan(key string, cursor uint64, match string, count int, withValues bool) (uint64, []string, error) {
+	defer kv.lock(key)()
+
+	h, err := kv.hash(key)
+	if err != nil || h == nil {
+		return 0, []string{}, err
+	}
+
+	ret := []string{}
+	cursor = scanDict(h, cursor, match, count, func(field, value string) {
+		ret = append(ret, field)
+		if withValues {
+			ret = append(ret, value)
+		}
+	})
+
+	return cursor, ret, nil
+}
+
+// hash returns the hash stored at key, the caller must hold the lock.
+func (kv *KV) hash(key string) (*Hash, error) {
+	o, err := kv.lookupType(key, TypeHash)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	return o.value.(*Hash), nil
+}
+
+// hashField returns the value of the field of a hash that may not exist.
+func hashField(h *Hash, field string) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	return h.Get(field)
+}
+
+// hashForWrite is hash but creates the hash when missing, the caller must hold the lock.
+func (kv *KV) hashForWrite(key string) (*Hash, error) {
+	o, err := kv.lookupType(key, TypeHash)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = newHashObject()
+		kv.add(key, o)
+	}
+	return o.value.(*Hash), nil
+}
+
+// sampleBatch is the number of members randomSample draws at once for a
+// negative count.
+const sampleBatch = 1024
+
+// randomSample draws random members of a collection of the given size,
+// following the semantic of the redis *RANDMEMBER commands: a positive count
+// yields distinct members, at most all of them, and a negative one exactly
+// -count members that may repeat. The members are passed to fn by batches,
+// along with n their total, so that a huge negative count is never held in
+// memory whole.
+func randomSample(count, size int, random func() string, members func() []string, fn func(n int, batch []string) error) error {
+	if count < 0 {
+		n := -count
+		for left := n; left > 0; left -= sampleBatch {
+			batch := make([]string, 0, min(left, sampleBatch))
+			for len(batch) < cap(batch) {
+				batch = append(batch, random())
+			}
+			if err := fn(n, batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if count >= size {
+		return fn(size, members())
+	}
+
+	// Like redis, when most of the collection is requested it's cheaper to
+	// shuffle it than to keep drawing until we have enough distinct members.
+	if count*3 > size {
+		all := members()
+		rand.Shuffle(len(all), func(i, j int) { all[i], all[j] = all[j], all[i] })
+		return fn(count, all[:count])
+	}
+
+	ret := make([]string, 0, count)
+	picked := make(map[string]struct{}, count)
+	for len(ret) < count {
+		member := random()
+		if _, ok := picked[member]; ok {
+			continue
+		}
+		picked[member] = struct{}{}
+		ret = append(ret, member)
+	}
+
+	return fn(count, ret)
+}
+
+// parseFloat parses a float the way redis does, refusing NaN.
+func parseFloat(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, strconv.ErrSyntax
+	}
+	return f, nil
+}
+
+// formatFloat formats a float as redis does for the human readable replies of
+// INCRBYFLOAT and HINCRBYFLOAT: no exponent and no trailing zeros.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,400 @@
+package keyval
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// The HyperLogLogs are strings laid out exactly like redis lays them out, a
+// 16 bytes header, "HYLL", the encoding, three unused bytes and the cached
+// cardinality in little endian, followed by the registers. The dense encoding
+// packs the 6 bits registers, the sparse one run length encodes them with the
+// ZERO, XZERO and VAL opcodes. A dumped HyperLogLog is then usable by either
+// server.
+const (
+	hllP                 = 14
+	hllQ                 = 64 - hllP
+	hllRegisters         = 1 << hllP
+	hllBits              = 6
+	hllRegisterMax       = 1<<hllBits - 1
+	hllHeaderSize        = 16
+	hllDenseSize         = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllDense             = 0
+	hllSparse            = 1
+	hllSparseMaxBytes    = 3000
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+	hllSparseZeroMaxLen  = 64
+	hllAlphaInf          = 0.721347520444481703680
+)
+
+var (
+	ErrNotHLL       = errors.New("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	ErrHLLCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+)
+
+// PFAdd adds the elements to the HyperLogLog stored at key, creating it as
+// needed, and reports whether its registers changed or it was created.
+func (kv *KV) PFAdd(key string, elements []string) (bool, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupHLL(key)
+	if err != nil {
+		return false, err
+	}
+	updated := false
+	if o == nil {
+		o = newStringObject(hllCreate())
+		kv.add(key, o)
+		updated = true
+	}
+
+	value := o.value.([]byte)
+	if value[4] == hllDense {
+		registers := value[hllHeaderSize:]
+		for _, element := range elements {
+			index, count := hllPatLen([]byte(element))
+			if count > hllDenseGet(registers, index) {
+				hllDenseSet(registers, index, count)
+				updated = true
+			}
+		}
+	} else {
+		regs := make([]uint8, hllRegisters)
+		if !hllMerge(regs, value) {
+			return false, ErrHLLCorrupted
+		}
+		changed := false
+		for _, element := range elements {
+			index, count := hllPatLen([]byte(element))
+			if count > regs[index] {
+				regs[index] = count
+				changed = true
+			}
+		}
+		if changed {
+			value = hllStore(value, regs, false)
+			updated = true
+		}
+	}
+	if updated {
+		hllInvalidateCache(value)
+		o.value = value
+	}
+
+	return updated, nil
+}
+
+// PFCount returns the estimated cardinality of the union of the HyperLogLogs
+// stored at keys, missing keys counting as empty ones. The cardinality of a
+// single HyperLogLog is cached in its header.
+func (kv *KV) PFCount(keys []string) (int64, error) {
+	defer kv.lock(keys...)()
+
+	if len(keys) == 1 {
+		o, err := kv.lookupHLL(keys[0])
+		if err != nil || o == nil {
+			return 0, err
+		}
+		value := o.value.([]byte)
+		if value[15]&0x80 == 0 {
+			return int64(binary.LittleEndian.Uint64(value[8:hllHeaderSize])), nil
+		}
+		regs := make([]uint8, hllRegisters)
+		if !hllMerge(regs, value) {
+			return 0, ErrHLLCorrupted
+		}
+		card := hllCount(regs)
+		binary.LittleEndian.PutUint64(value[8:hllHeaderSize], card)
+		return int64(card), nil
+	}
+
+	regs := make([]uint8, hllRegisters)
+	for _, key := range keys {
+		o, err := kv.lookupHLL(key)
+		if err != nil {
+			return 0, err
+		}
+		if o != nil && !hllMerge(regs, o.value.([]byte)) {
+			return 0, ErrHLLCorrupted
+		}
+	}
+
+	return int64(hllCount(regs)), nil
+}
+
+// PFMerge stores at destination the union of the HyperLogLogs stored at keys
+// and at destination itself. The result is dense as soon as one of them is.
+func (kv *KV) PFMerge(destination string, keys []string) error {
+	defer kv.lock(append([]string{destination}, keys...)...)()
+
+	regs := make([]uint8, hllRegisters)
+	dense := false
+	for _, key := range append([]string{destination}, keys...) {
+		o, err := kv.lookupHLL(key)
+		if err != nil {
+			return err
+		}
+		if o == nil {
+			continue
+		}
+		value := o.value.([]byte)
+		dense = dense || value[4] == hllDense
+		if !hllMerge(regs, value) {
+			return ErrHLLCorrupted
+		}
+	}
+
+	o := kv.lookup(destination)
+	if o == nil {
+		o = newStringObject(hllCreate())
+		kv.add(destination, o)
+	}
+	value := o.value.([]byte)
+	value = hllStore(value, regs, dense || value[4] == hllDense)
+	hllInvalidateCache(value)
+	o.value = value
+
+	return nil
+}
+
+// lookupHLL returns the object of key, checking it holds a HyperLogLog.
+func (kv *KV) lookupHLL(key string) (*object, error) {
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	if !isHLL(o.value.([]byte)) {
+		return nil, ErrNotHLL
+	}
+
+	return o, nil
+}
+
+// isHLL checks the header of value, the registers of the sparse encoding are
+// only checked when they are read.
+func isHLL(value []byte) bool {
+	if len(value) < hllHeaderSize || string(value[:4]) != "HYLL" || value[4] > hllSparse {
+		return false
+	}
+
+	return value[4] != hllDense || len(value) == hllDenseSize
+}
+
+// hllCreate returns an empty sparse HyperLogLog, a single XZERO covering all
+// the registers.
+func hllCreate() []byte {
+	value := make([]byte, hllHeaderSize, hllHeaderSize+2)
+	copy(value, "HYLL")
+	value[4] = hllSparse
+
+	return append(value, 0x40|byte((hllRegisters-1)>>8), byte((hllRegisters-1)&0xff))
+}
+
+func hllInvalidateCache(value []byte) {
+	value[15] |= 0x80
+}
+
+// hllPatLen hashes element and returns the register it goes in and the
+// length of the run of zeros of the hash, plus one, that is the value the
+// register gets if it is bigger.
+func hllPatLen(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ
+
+	return index, uint8(bits.TrailingZeros64(hash)) + 1
+}
+
+// murmurHash64A is the 64 bits MurmurHash2 redis hashes the elements with.
+func murmurHash64A(key []byte, seed uint32) uint64 {
+	const (
+		m = 0xc6a4a7935bd1e995
+		r = 47
+	)
+	h := uint64(seed) ^ uint64(len(key))*m
+	n := len(key) &^ 7
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	if tail := key[n:]; len(tail) > 0 {
+		for i := len(tail) - 1; i >= 0; i-- {
+			h ^= uint64(tail[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+
+	return h
+}
+
+// hllDenseGet returns register i of the packed dense registers.
+func hllDenseGet(registers []byte, i int) uint8 {
+	b, fb := i*hllBits/8, uint(i*hllBits&7)
+	v := uint(registers[b]) >> fb
+	if b+1 < len(registers) {
+		v |= uint(registers[b+1]) << (8 - fb)
+	}
+
+	return uint8(v & hllRegisterMax)
+}
+
+func hllDenseSet(registers []byte, i int, v uint8) {
+	b, fb := i*hllBits/8, uint(i*hllBits&7)
+	registers[b] &^= hllRegisterMax << fb
+	registers[b] |= v << fb
+	if b+1 < len(registers) {
+		registers[b+1] &^= hllRegisterMax >> (8 - fb)
+		registers[b+1] |= v >> (8 - fb)
+	}
+}
+
+// hllMerge raises regs to the registers of the HyperLogLog value, it reports
+// false when the sparse opcodes do not cover exactly all the registers.
+func hllMerge(regs []uint8, value []byte) bool {
+	if value[4] == hllDense {
+		for i := range regs {
+			regs[i] = max(regs[i], hllDenseGet(value[hllHeaderSize:], i))
+		}
+		return true
+	}
+
+	i := 0
+	ops := value[hllHeaderSize:]
+	for p := 0; p < len(ops); p++ {
+		switch op := ops[p]; op & 0xc0 {
+		case 0x00: // ZERO: 00xxxxxx
+			i += int(op&0x3f) + 1
+		case 0x40: // XZERO: 01xxxxxx yyyyyyyy
+			if p++; p == len(ops) {
+				return false
+			}
+			i += int(op&0x3f)<<8 | int(ops[p]) + 1
+		default: // VAL: 1vvvvvxx
+			n, v := int(op&0x3)+1, (op>>2)&0x1f+1
+			if i+n > hllRegisters {
+				return false
+			}
+			for _, r := range regs[i : i+n] {
+				regs[i] = max(r, v)
+				i++
+			}
+		}
+		if i > hllRegisters {
+			return false
+		}
+	}
+
+	return i == hllRegisters
+}
+
+// hllStore returns value with its registers replaced by regs. A sparse value
+// stays sparse unless dense is set, a register does not fit a VAL opcode or
+// it would take more than hllSparseMaxBytes.
+func hllStore(value []byte, regs []uint8, dense bool) []byte {
+	if !dense && slices.Max(regs) <= hllSparseValMaxValue {
+		if sparse := hllSparseEncode(value[:hllHeaderSize], regs); len(sparse) <= hllSparseMaxBytes {
+			return sparse
+		}
+	}
+
+	if value[4] != hllDense {
+		header := value[:hllHeaderSize]
+		value = make([]byte, hllDenseSize)
+		copy(value, header)
+		value[4] = hllDense
+	}
+	for i, r := range regs {
+		hllDenseSet(value[hllHeaderSize:], i, r)
+	}
+
+	return value
+}
+
+// hllSparseEncode run length encodes regs after header, runs of zeros take a
+// ZERO opcode up to 64 registers and an XZERO past it, runs of values take a
+// VAL opcode every 4 registers.
+func hllSparseEncode(header []byte, regs []uint8) []byte {
+	value := append(make([]byte, 0, hllHeaderSize+64), header...)
+	for i := 0; i < len(regs); {
+		j := i + 1
+		for j < len(regs) && regs[j] == regs[i] {
+			j++
+		}
+		switch n := j - i; {
+		case regs[i] != 0:
+			for ; n > 0; n -= hllSparseValMaxLen {
+				value = append(value, 0x80|(regs[i]-1)<<2|byte(min(n, hllSparseValMaxLen)-1))
+			}
+		case n <= hllSparseZeroMaxLen:
+			value = append(value, byte(n-1))
+		default:
+			value = append(value, 0x40|byte((n-1)>>8), byte((n-1)&0xff))
+		}
+		i = j
+	}
+
+	return value
+}
+
+// hllCount estimates the cardinality of the registers with the estimator of
+// Otmar Ertl redis uses since 5.0.
+func hllCount(regs []uint8) uint64 {
+	var histo [64]int
+	for _, r := range regs {
+		histo[r]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histo[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+
+	return uint64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
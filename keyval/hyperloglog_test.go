@@ -0,0 +1,74 @@
+package keyval
+
+import (
+	"math/rand"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// TestHLLEncodings checks that both encodings give back the registers they
+// were given and that the sparse one rejects opcodes not covering them all.
+func TestHLLEncodings(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	regs := make([]uint8, hllRegisters)
+	for i := 0; i < 200; i++ {
+		regs[rnd.Intn(hllRegisters)] = uint8(rnd.Intn(hllSparseValMaxValue) + 1)
+	}
+	// A run longer than a VAL opcode and one of zeros longer than a ZERO one.
+	for i := 100; i < 110; i++ {
+		regs[i] = 3
+	}
+
+	sparse := hllStore(hllCreate(), regs, false)
+	if sparse[4] != hllSparse || !isHLL(sparse) {
+		t.Fatalf("expected a valid sparse encoding")
+	}
+	dense := hllStore(hllCreate(), regs, true)
+	if dense[4] != hllDense || len(dense) != hllDenseSize || !isHLL(dense) {
+		t.Fatalf("expected a valid dense encoding")
+	}
+	for _, value := range [][]byte{sparse, dense} {
+		got := make([]uint8, hllRegisters)
+		if !hllMerge(got, value) || !slices.Equal(got, regs) {
+			t.Fatalf("encoding %d did not round trip", value[4])
+		}
+	}
+
+	if hllMerge(make([]uint8, hllRegisters), append(slices.Clip(sparse), 0)) {
+		t.Fatal("expected an extra opcode to be detected")
+	}
+	if hllMerge(make([]uint8, hllRegisters), sparse[:len(sparse)-1]) {
+		t.Fatal("expected a missing opcode to be detected")
+	}
+
+	regs[0] = hllSparseValMaxValue + 1
+	if value := hllStore(sparse, regs, false); value[4] != hllDense {
+		t.Fatal("expected a register too big for a VAL opcode to promote to dense")
+	}
+}
+
+// TestHLLCount checks the estimate stays within the standard error of 0.81%
+// redis documents, with some margin.
+func TestHLLCount(t *testing.T) {
+	kv := NewKeyVal()
+	elements := make([]string, 0, 1000)
+	for n := 0; n < 100000; {
+		elements = elements[:0]
+		for i := 0; i < 1000; i++ {
+			elements = append(elements, "element:"+strconv.Itoa(n))
+			n++
+		}
+		if _, err := kv.PFAdd("hll", elements); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	card, err := kv.PFCount([]string{"hll"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := card - 100000; diff < -2500 || diff > 2500 {
+		t.Fatalf("estimate %d is too far from 100000", card)
+	}
+}
@@ -0,0 +1,123 @@
+package keyval
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+)
+
+// intset is the compact encoding redis uses for small sets of integers: a
+// sorted array of integers all stored with the smallest width (2, 4 or 8
+// bytes) able to hold the biggest of them. Adding a wider integer upgrades
+// the whole array.
+type intset struct {
+	width    int
+	contents []byte
+}
+
+func newIntset() *intset {
+	return &intset{width: 2}
+}
+
+// intWidth returns the number of bytes needed to store the value.
+func intWidth(value int64) int {
+	switch {
+	case value >= math.MinInt16 && value <= math.MaxInt16:
+		return 2
+	case value >= math.MinInt32 && value <= math.MaxInt32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// Len returns the number of integers in the set.
+func (is *intset) Len() int {
+	return len(is.contents) / is.width
+}
+
+// Get returns the integer at position i.
+func (is *intset) Get(i int) int64 {
+	b := is.contents[i*is.width:]
+	switch is.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+func (is *intset) put(i int, value int64) {
+	b := is.contents[i*is.width:]
+	switch is.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(value))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(value))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(value))
+	}
+}
+
+// search returns the position of the value, or where it would be inserted.
+func (is *intset) search(value int64) (int, bool) {
+	i := sort.Search(is.Len(), func(i int) bool { return is.Get(i) >= value })
+	return i, i < is.Len() && is.Get(i) == value
+}
+
+// Contains reports whether the value is in the set.
+func (is *intset) Contains(value int64) bool {
+	_, ok := is.search(value)
+	return ok
+}
+
+// Add inserts the value and reports whether it was not already there.
+func (is *intset) Add(value int64) bool {
+	if w := intWidth(value); w > is.width {
+		is.upgrade(w)
+	}
+
+	i, ok := is.search(value)
+	if ok {
+		return false
+	}
+	is.contents = append(is.contents, make([]byte, is.width)...)
+	copy(is.contents[(i+1)*is.width:], is.contents[i*is.width:])
+	is.put(i, value)
+
+	return true
+}
+
+// Remove deletes the value and reports whether it was there.
+func (is *intset) Remove(value int64) bool {
+	i, ok := is.search(value)
+	if !ok {
+		return false
+	}
+	is.contents = append(is.contents[:i*is.width], is.contents[(i+1)*is.width:]...)
+
+	return true
+}
+
+// Random returns a random integer of the set, which must not be empty.
+func (is *intset) Random() int64 {
+	return is.Get(rand.Intn(is.Len()))
+}
+
+// upgrade re-encodes every integer with the new, wider, width.
+func (is *intset) upgrade(width int) {
+	old := &intset{width: is.width, contents: is.contents}
+	is.width = width
+	is.contents = make([]byte, old.Len()*width)
+	for i := 0; i < old.Len(); i++ {
+		is.put(i, old.Get(i))
+	}
+}
+
+func (is *intset) clone() *intset {
+	return &intset{width: is.width, contents: slices.Clone(is.contents)}
+}
@@ -0,0 +1,53 @@
+package keyval
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestIntsetUpgrade checks that adding wider integers upgrades the encoding
+// while keeping the set sorted.
+func TestIntsetUpgrade(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	is := newIntset()
+	model := map[int64]bool{}
+
+	values := []int64{5, -3, math.MaxInt16 + 1, math.MinInt32 - 1}
+	for i := 0; i < 1000; i++ {
+		values = append(values, rnd.Int63n(1000)-500)
+	}
+	for _, value := range values {
+		if added := is.Add(value); added == model[value] {
+			t.Fatalf("Add(%d) reported %v", value, added)
+		}
+		model[value] = true
+	}
+	if is.width != 8 {
+		t.Fatalf("expected an 8 bytes width but got %d", is.width)
+	}
+
+	for value := range model {
+		if value%2 == 0 {
+			if !is.Remove(value) {
+				t.Fatalf("Remove(%d) missed the value", value)
+			}
+			delete(model, value)
+		}
+	}
+
+	expected := make([]int64, 0, len(model))
+	for value := range model {
+		expected = append(expected, value)
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	if is.Len() != len(expected) {
+		t.Fatalf("expected %d integers but got %d", len(expected), is.Len())
+	}
+	for i, value := range expected {
+		if got := is.Get(i); got != value {
+			t.Fatalf("expected %d at %d but got %d", value, i, got)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package keyval
+
+import "math/bits"
+
+// Scan runs one step of a SCAN iteration over the keyspace. It returns the
+// next cursor and the keys matching the glob pattern, only those holding typ
+// unless it is TypeNone. Like the other SCAN commands every key present for
+// the whole iteration is returned, even if the keyspace grows or shrinks
+// between two calls.
+//
+// The shards are scanned one after the other, the low bits of the cursor
+// are the shard and the others the cursor of its dict.
+func (kv *KV) Scan(cursor uint64, match string, count int, typ Type) (uint64, []string) {
+	shardBits := bits.Len(uint(len(kv.shards) - 1))
+	i, cursor := int(cursor&(1<<shardBits-1)), cursor>>shardBits
+
+	keys := []string{}
+	for i < len(kv.shards) {
+		cursor, keys = kv.scanShard(kv.shards[i], cursor, match, count-len(keys), typ, keys)
+		if cursor != 0 {
+			return cursor<<shardBits | uint64(i), keys
+		}
+		if i++; len(keys) >= count && i < len(kv.shards) {
+			return uint64(i), keys
+		}
+	}
+
+	return 0, keys
+}
+
+// scanShard is Scan over a single shard, it appends the keys it found to keys.
+func (kv *KV) scanShard(s *shard, cursor uint64, match string, count int, typ Type, keys []string) (uint64, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := []string{}
+	cursor = scanDict(s.data, cursor, match, count, func(key string, o *object) {
+		if typ == TypeNone || o.typ == typ {
+			found = append(found, key)
+		}
+	})
+
+	return cursor, append(keys, kv.unexpired(found)...)
+}
+
+// Keys returns all the keys matching the glob pattern.
+func (kv *KV) Keys(pattern string) []string {
+	defer kv.lockAll()()
+
+	keys := []string{}
+	for _, s := range kv.shards {
+		s.data.Each(func(key string, _ *object) bool {
+			if pattern == "*" || GlobMatch(pattern, key) {
+				keys = append(keys, key)
+			}
+			return true
+		})
+	}
+
+	return kv.unexpired(keys)
+}
+
+// RandomKey returns a random key, unless the keyspace is empty.
+func (kv *KV) RandomKey() (string, bool) {
+	defer kv.lockAll()()
+
+	for {
+		s, ok := kv.randomShard(keyCount)
+		if !ok {
+			return "", false
+		}
+		if key, _, _ := s.data.Random(); !kv.expireIfNeeded(key) {
+			return key, true
+		}
+	}
+}
+
+// DBSize returns the number of keys, the expired ones that were not deleted
+// yet included.
+func (kv *KV) DBSize() int {
+	defer kv.lockAll()()
+
+	n := 0
+	for _, s := range kv.shards {
+		n += s.data.Len()
+	}
+
+	return n
+}
+
+// unexpired deletes the expired keys and returns the others. The keys are
+// collected first because the dict must not change while it is iterated. The
+// caller must hold the lock.
+func (kv *KV) unexpired(keys []string) []string {
+	ret := keys[:0]
+	for _, key := range keys {
+		if !kv.expireIfNeeded(key) {
+			ret = append(ret, key)
+		}
+	}
+
+	return ret
+}
+
+// Del deletes the keys and returns how many existed.
+func (kv *KV) Del(keys []string) int {
+	defer kv.lock(keys...)()
+
+	n := 0
+	for _, key := range keys {
+		if kv.peek(key) != nil && kv.remove(key) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Exists returns how many of the keys exist, a key given twice counting twice.
+func (kv *KV) Exists(keys []string) int {
+	defer kv.lock(keys...)()
+
+	n := 0
+	for _, key := range keys {
+		if kv.peek(key) != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Touch records an access to the keys and returns how many exist.
+func (kv *KV) Touch(keys []string) int {
+	defer kv.lock(keys...)()
+
+	n := 0
+	for _, key := range keys {
+		if kv.lookup(key) != nil {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Rename moves the value of source, with its time to live, to destination
+// and reports whether it did. With nx nothing happens if destination exists.
+func (kv *KV) Rename(source, destination string, nx bool) (bool, error) {
+	defer kv.lock(source, destination)()
+
+	o := kv.lookup(source)
+	if o == nil {
+		return false, ErrNoSuchKey
+	}
+	if source == destination {
+		return !nx, nil
+	}
+	if nx && kv.lookup(destination) != nil {
+		return false, nil
+	}
+
+	expireAt, volatile := kv.expireAt(source)
+	kv.remove(source)
+	kv.discard(destination)
+	kv.add(destination, o)
+	if volatile {
+		kv.setExpire(destination, expireAt)
+	}
+
+	return true, nil
+}
+
+// Copy stores a copy of the value of source, with its time to live, at
+// destination in the keyspace to, which may be kv itself, and reports whether
+// it did. Unless replace is set nothing happens if destination exists.
+func (kv *KV) Copy(source string, to *KV, destination string, replace bool) (bool, error) {
+	if to == kv && source == destination {
+		return false, ErrSameObject
+	}
+	defer lockAcross(kv, source, to, destination)()
+
+	o := kv.lookup(source)
+	if o == nil {
+		return false, nil
+	}
+	if to.lookup(destination) != nil {
+		if !replace {
+			return false, nil
+		}
+		to.discard(destination)
+	}
+
+	to.add(destination, o.clone())
+	if expireAt, ok := kv.expireAt(source); ok {
+		to.setExpire(destination, expireAt)
+	}
+
+	return true, nil
+}
+
+// Move moves the key, with its time to live, to the keyspace to unless it
+// already holds the key, and reports whether it did.
+func (kv *KV) Move(key string, to *KV) (bool, error) {
+	if to == kv {
+		return false, ErrSameObject
+	}
+	defer lockAcross(kv, key, to, key)()
+
+	o := kv.lookup(key)
+	if o == nil || to.lookup(key) != nil {
+		return false, nil
+	}
+
+	expireAt, volatile := kv.expireAt(key)
+	kv.remove(key)
+	to.add(key, o)
+	if volatile {
+		to.setExpire(key, expireAt)
+	}
+
+	return true, nil
+}
+
+// Flush deletes every key, with async the keys are released in the
+// background.
+func (kv *KV) Flush(async bool) {
+	defer kv.lockAll()()
+
+	for _, s := range kv.shards {
+		if data := s.data; async && data.Len() > 0 {
+			reclaimer.free(int64(data.Len()), func() {
+				data.Each(func(_ string, o *object) bool {
+					o.release()
+					return true
+				})
+				data.release()
+			})
+		}
+		s.data = newDict[*object]()
+		s.expires = newDict[int64]()
+		s.used = 0
+		clear(s.dirty)
+	}
+}
+
+// KeyspaceStats are the figures of a keyspace INFO reports.
+type KeyspaceStats struct {
+	Keys    int
+	Expires int
+	// AvgTTL is the average time to live of the volatile keys, in milliseconds.
+	AvgTTL int64
+}
+
+// Stats returns the figures of the keyspace, the expired keys that were not
+// deleted yet included.
+func (kv *KV) Stats() KeyspaceStats {
+	defer kv.lockAll()()
+
+	stats := KeyspaceStats{}
+	now, total := Now(), int64(0)
+	for _, s := range kv.shards {
+		stats.Keys += s.data.Len()
+		stats.Expires += s.expires.Len()
+		s.expires.Each(func(_ string, at int64) bool {
+			total += max(at-now, 0)
+			return true
+		})
+	}
+	if stats.Expires > 0 {
+		stats.AvgTTL = total / int64(stats.Expires)
+	}
+
+	return stats
+}
@@ -1,99 +1,194 @@
 package keyval
 
 import (
-	"fmt"
-	"strconv"
+	"errors"
 	"sync"
 )
 
-// KV is the inner hashMap we are using for our inMem data store.
+var (
+	// ErrWrongType is returned when a command is run against a key holding another kind of value.
+	ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	// ErrNoSuchKey is returned by the commands that require the key to exist.
+	ErrNoSuchKey = errors.New("ERR no such key")
+	// ErrIndexOutOfRange is returned when writing past the bounds of a list.
+	ErrIndexOutOfRange = errors.New("ERR index out of range")
+	// ErrSameObject is returned when the source and the destination of a command are the same key.
+	ErrSameObject = errors.New("ERR source and destination objects are the same")
+)
+
+// KV is the inner hashMap we are using for our inMem data store. The keys
+// are spread over shards each with its own lock, so commands on different
+// keys don't wait for one another. The helpers that must be called with
+// "the lock" held need the locks of the shards of the keys they are given.
 type KV struct {
-	mu       sync.RWMutex
-	data     map[string][]byte
-	slices   map[string][]string
+	id     uint64
+	shards []*shard
+
+	// ready holds the keys that were created with a type blocking commands
+	// wait for, or streams that got new entries, in order, see ReadyKeys.
+	// Commands holding different shards signal them, hence its own lock.
+	readyMu sync.Mutex
+	ready   []string
 }
 
-// NewKeyVal creates an inMemory data store.
+// NewKeyVal creates an inMemory data store with DefaultShards shards.
 func NewKeyVal() *KV {
-	return &KV{
-		data:   map[string][]byte{},
-		slices: map[string][]string{},
+	return NewShardedKeyVal(DefaultShards)
+}
+
+// NewShardedKeyVal creates an inMemory data store with n shards, a single
+// one makes every command take the same lock.
+func NewShardedKeyVal(n int) *KV {
+	kv := &KV{id: kvIDs.Add(1), shards: make([]*shard, max(n, 1))}
+	lazy := &LazyFree{}
+	for i := range kv.shards {
+		kv.shards[i] = newShard(lazy)
 	}
+
+	return kv
 }
 
 // Set sets a key and a value into the store.
+// Like in redis, any time to live previously associated with the key is discarded.
 func (kv *KV) Set(key, value []byte) error {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+	defer kv.lock(string(key))()
 
-	kv.data[string(key)] = []byte(value)
+	kv.discard(string(key))
+	kv.add(string(key), newStringObject(value))
 
 	return nil
 }
 
-// Get gets the value associated with the key from the store.
-func (kv *KV) Get(key []byte) ([]byte, bool) {
-	kv.mu.RLock()
-	defer kv.mu.RUnlock()
-
-	val, ok := kv.data[string(key)]
-
-	return val, ok
+// SetOptions are the conditions and the expiry the redis SET command can be given.
+type SetOptions struct {
+	NX, XX bool
+	// Get asks for the previous value, which then has to be a string.
+	Get     bool
+	KeepTTL bool
+	// ExpireAt is the absolute expiry in unix milliseconds, zero means none.
+	ExpireAt int64
 }
 
-// NOTE: We are not returning anything because redis a key is ignored in case it doesn't exists
-func (kv *KV) Del(key []byte) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+// SetWithOptions is Set with the whole redis SET grammar, it returns the
+// previous value of the key, whether there was one and whether the write happened.
+func (kv *KV) SetWithOptions(key, value []byte, opts SetOptions) ([]byte, bool, bool, error) {
+	defer kv.lock(string(key))()
 
-	delete(kv.data, string(key))
-}
+	k := string(key)
+	o := kv.lookup(k)
 
-func (kv *KV) Incr(key []byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
-	m, ok := kv.data[string(key)]
-	if !ok {
-		return 0, fmt.Errorf("sorry but this key doesn't exists")
+	var old []byte
+	if opts.Get && o != nil {
+		if o.typ != TypeString {
+			return nil, false, false, ErrWrongType
+		}
+		old = o.value.([]byte)
 	}
 
-	intValue, err := strconv.Atoi(string(m))
-	if err != nil {
-		return 0, err
+	hadOld := old != nil
+	if (opts.NX && o != nil) || (opts.XX && o == nil) {
+		return old, hadOld, false, nil
 	}
 
-	intValue += 1
-	kv.data[string(key)] = []byte(strconv.Itoa(intValue))
+	expireAt, volatile := kv.expireAt(k)
+	kv.discard(k)
+	switch {
+	case opts.ExpireAt != 0:
+		if opts.ExpireAt <= Now() {
+			return old, hadOld, true, nil
+		}
+		kv.setExpire(k, opts.ExpireAt)
+	case opts.KeepTTL && volatile:
+		kv.setExpire(k, expireAt)
+	}
+	kv.add(k, newStringObject(value))
 
-	return intValue, nil
+	return old, hadOld, true, nil
 }
 
-// TODO: This has some code duplication remove it later to something shared (Pay attention to concurrency)
-// And also the mutex usage here is a bit expensive we can think of doing this with atomics depends on the benchmarks
-func (kv *KV) Decr(key []byte) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
-	m, ok := kv.data[string(key)]
-	if !ok {
-		return 0, fmt.Errorf("sorry but this key doesn't exists")
+// Get gets the value associated with the key from the store.
+func (kv *KV) Get(key []byte) ([]byte, bool, error) {
+	defer kv.lock(string(key))()
+
+	o, err := kv.lookupType(string(key), TypeString)
+	if err != nil || o == nil {
+		return nil, false, err
 	}
 
-	intValue, err := strconv.Atoi(string(m))
-	if err != nil {
-		return 0, err
+	return o.value.([]byte), true, nil
+}
+
+// ReadyKeys returns and forgets the keys signaled since the previous call,
+// these are the keys blocked clients may now be served from.
+func (kv *KV) ReadyKeys() []string {
+	kv.readyMu.Lock()
+	defer kv.readyMu.Unlock()
+
+	keys := kv.ready
+	kv.ready = nil
+
+	return keys
+}
+
+// add stores a new object at key, the caller must hold the lock. An object
+// that was never stored starts its access tracking, one that is moved keeps it.
+func (kv *KV) add(key string, o *object) {
+	if o.lru == 0 {
+		now := Now()
+		o.lru, o.lfu, o.ldt = now, lfuInitVal, now/60000
 	}
+	o.size = 0
+	s := kv.shard(key)
+	s.dirty[key] = o
+	s.data.Set(key, o)
+	if o.typ == TypeList || o.typ == TypeZSet {
+		kv.signalReady(key)
+	}
+}
+
+// signalReady records that clients blocked on the key may now be served, the
+// caller must hold the lock of its shard.
+func (kv *KV) signalReady(key string) {
+	kv.readyMu.Lock()
+	kv.ready = append(kv.ready, key)
+	kv.readyMu.Unlock()
+}
 
-	intValue -= 1
-	kv.data[string(key)] = []byte(strconv.Itoa(intValue))
+// exists reports whether the key holds any kind of value, the caller must hold the lock.
+func (kv *KV) exists(key string) bool {
+	_, ok := kv.shard(key).data.Get(key)
+	return ok
+}
 
-	return intValue, nil
+// remove drops the key and its time to live, the caller must hold the lock.
+func (kv *KV) remove(key string) bool {
+	return kv.shard(key).remove(key)
 }
 
-func (kv *KV) Push(key string, value []string) (int, error) {
-	kv.mu.Lock()
-	defer kv.mu.Unlock()
+func (s *shard) remove(key string) bool {
+	o, existed := s.data.Delete(key)
+	if existed {
+		s.used -= o.size
+	}
+	s.expires.Delete(key)
+	delete(s.dirty, key)
+	return existed
+}
 
-	kv.slices[key] = append(kv.slices[key], value...)
+// expireAt returns the absolute expiry of the key in unix milliseconds and
+// whether it has one, the caller must hold the lock.
+func (kv *KV) expireAt(key string) (int64, bool) {
+	return kv.shard(key).expires.Get(key)
+}
+
+// setExpire sets the absolute expiry of the key, the caller must hold the lock.
+func (kv *KV) setExpire(key string, at int64) {
+	kv.shard(key).expires.Set(key, at)
+}
 
-	return len(kv.slices[key]), nil
+// persist removes the expiry of the key and reports whether there was one,
+// the caller must hold the lock.
+func (kv *KV) persist(key string) bool {
+	_, ok := kv.shard(key).expires.Delete(key)
+	return ok
 }
@@ -0,0 +1,209 @@
+package keyval
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// lazyFreeThreshold is the effort, about the number of allocations to
+// release, past which a value is released in the background, like
+// LAZYFREE_THRESHOLD in redis. Smaller values are not worth the hand-off.
+const lazyFreeThreshold = 64
+
+// LazyFree are the lazyfree-lazy-* options of redis, they tell which kinds of
+// deletion release the large values in the background rather than on the
+// spot. UNLINK and FLUSHALL ASYNC always do.
+type LazyFree struct {
+	// Eviction is for the keys evicted under maxmemory.
+	Eviction atomic.Bool
+	// Expire is for the keys whose time to live elapsed.
+	Expire atomic.Bool
+	// ServerDel is for the keys deleted as a side effect of a command, like
+	// the destination a SET or a SUNIONSTORE overwrites.
+	ServerDel atomic.Bool
+}
+
+// SetLazyFree makes the keyspace follow the options, which may be shared
+// with other keyspaces. It must be called before the keyspace is used.
+func (kv *KV) SetLazyFree(lazy *LazyFree) {
+	for _, s := range kv.shards {
+		s.lazy = lazy
+	}
+}
+
+// Unlink deletes the keys like Del and returns how many existed, their large
+// values are released in the background.
+func (kv *KV) Unlink(keys []string) int {
+	defer kv.lock(keys...)()
+
+	n := 0
+	for _, key := range keys {
+		if kv.peek(key) != nil && kv.shard(key).delete(key, true) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// discard deletes the key as a side effect of a command overwriting it,
+// lazily with the ServerDel option. The caller must hold the lock.
+func (kv *KV) discard(key string) bool {
+	s := kv.shard(key)
+	return s.delete(key, s.lazy.ServerDel.Load())
+}
+
+// delete removes the key like remove, and when lazy is set hands its value
+// to the reclaimer if it is large. The caller must hold the lock and must not
+// use the value afterwards.
+func (s *shard) delete(key string, lazy bool) bool {
+	o, ok := s.data.Get(key)
+	s.remove(key)
+	if ok && lazy && o.freeEffort() > lazyFreeThreshold {
+		reclaimer.free(1, o.release)
+	}
+
+	return ok
+}
+
+// expire deletes the key whose time to live elapsed, lazily with the Expire
+// option. The caller must hold the lock.
+func (s *shard) expire(key string) {
+	s.delete(key, s.lazy.Expire.Load())
+}
+
+// freeEffort estimates the work releasing the value takes, like
+// lazyfreeGetFreeEffort in redis: the number of nodes or elements of a
+// collection, 1 for the others.
+func (o *object) freeEffort() int {
+	switch v := o.value.(type) {
+	case *List:
+		return v.nodes
+	case *Hash:
+		return v.Len()
+	case *Set:
+		if v.dict != nil {
+			return v.dict.Len()
+		}
+	case *ZSet:
+		return v.Len()
+	case *Stream:
+		effort := v.index.nodes
+		for _, g := range v.groups {
+			effort += 1 + g.pel.Len()
+		}
+		return effort
+	}
+
+	return 1
+}
+
+// release breaks the value of the object up. Go has no free, but unlinking
+// the parts of a large value one by one is the walk redis does to free it,
+// after which the garbage collector reclaims the parts it no longer reaches.
+func (o *object) release() {
+	switch v := o.value.(type) {
+	case *List:
+		for n := v.head; n != nil; {
+			next := n.next
+			n.prev, n.next, n.entries = nil, nil, nil
+			n = next
+		}
+		*v = List{}
+	case *Hash:
+		v.release()
+	case *Set:
+		if v.dict != nil {
+			v.dict.release()
+		}
+	case *ZSet:
+		v.dict.release()
+		for n := v.zsl.header; n != nil; {
+			next := n.level[0].forward
+			n.backward, n.level = nil, nil
+			n = next
+		}
+		*v.zsl = skiplist{}
+	case *Stream:
+		v.index = newRax[*streamNode]()
+		clear(v.groups)
+	}
+	o.value = nil
+}
+
+// release empties the dict, unlinking its entries.
+func (d *dict[V]) release() {
+	for i, e := range d.table {
+		for e != nil {
+			next := e.next
+			e.next = nil
+			e = next
+		}
+		d.table[i] = nil
+	}
+	d.table, d.used = nil, 0
+}
+
+// reclaimer releases the values handed to it in the background, one after
+// the other like the lazyfree thread of redis. It is shared by every
+// keyspace of the process.
+var reclaimer = &lazyFreer{wake: make(chan struct{}, 1)}
+
+type lazyFreer struct {
+	start sync.Once
+	mu    sync.Mutex
+	jobs  []lazyFreeJob
+	// wake tells the goroutine there are jobs, it holds at most one signal
+	// so handing a job off never blocks.
+	wake chan struct{}
+
+	pending atomic.Int64
+	freed   atomic.Int64
+}
+
+// lazyFreeJob releases objects values.
+type lazyFreeJob struct {
+	objects int64
+	release func()
+}
+
+// free queues release, which releases objects values.
+func (r *lazyFreer) free(objects int64, release func()) {
+	r.start.Do(func() { go r.run() })
+
+	r.pending.Add(objects)
+	r.mu.Lock()
+	r.jobs = append(r.jobs, lazyFreeJob{objects: objects, release: release})
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *lazyFreer) run() {
+	for range r.wake {
+		r.mu.Lock()
+		jobs := r.jobs
+		r.jobs = nil
+		r.mu.Unlock()
+
+		for _, job := range jobs {
+			job.release()
+			r.pending.Add(-job.objects)
+			r.freed.Add(job.objects)
+		}
+	}
+}
+
+// LazyFreePending returns the number of values waiting to be released in the
+// background, the lazyfree_pending_objects of INFO.
+func LazyFreePending() int64 {
+	return reclaimer.pending.Load()
+}
+
+// LazyFreed returns the number of values released in the background so far,
+// the lazyfreed_objects of INFO.
+func LazyFreed() int64 {
+	return reclaimer.freed.Load()
+}
@@ -0,0 +1,63 @@
+package keyval
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// waitFreed waits for the reclaimer to have released n values since freed.
+func waitFreed(t *testing.T, freed, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for LazyFreed() < freed+n || LazyFreePending() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d values to be released but got %d, %d pending", n, LazyFreed()-freed, LazyFreePending())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLazyFree(t *testing.T) {
+	kv := NewKeyVal()
+	members := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, "member:"+strconv.Itoa(i))
+	}
+	kv.SAdd("big", members)
+	kv.SAdd("small", []string{"a", "b"})
+
+	freed := LazyFreed()
+	if n := kv.Unlink([]string{"big", "small", "missing"}); n != 2 {
+		t.Fatalf("expected 2 keys to be unlinked but got %d", n)
+	}
+	if kv.Exists([]string{"big"}) != 0 {
+		t.Fatal("expected the unlinked key to be gone at once")
+	}
+	// Only the large value is worth releasing in the background.
+	waitFreed(t, freed, 1)
+	if n := LazyFreed() - freed; n != 1 {
+		t.Fatalf("expected only the large value to be released lazily but got %d", n)
+	}
+
+	// Overwriting is only lazy with the server-del option.
+	kv.SAdd("big", members)
+	kv.Set([]byte("big"), []byte("value"))
+	freed = LazyFreed()
+	kv.SAdd("other", members)
+	lazy := &LazyFree{}
+	lazy.ServerDel.Store(true)
+	kv.SetLazyFree(lazy)
+	kv.Set([]byte("other"), []byte("value"))
+	waitFreed(t, freed, 1)
+
+	for i := 0; i < 10; i++ {
+		kv.Set([]byte("key:"+strconv.Itoa(i)), []byte("value"))
+	}
+	freed = LazyFreed()
+	kv.Flush(true)
+	if n := kv.DBSize(); n != 0 {
+		t.Fatalf("expected the keyspace to be empty at once but got %d keys", n)
+	}
+	waitFreed(t, freed, 12)
+}
@@ -0,0 +1,347 @@
+package keyval
+
+// ListSide is the end of a list elements are pushed to or popped from.
+type ListSide int
+
+const (
+	Left ListSide = iota
+	Right
+)
+
+// Push inserts the values one after the other at the given side of the list
+// stored at key, creating it if needed unless onlyIfExists is set (LPUSHX and
+// RPUSHX). It returns the length of the list after the operation.
+func (kv *KV) Push(key string, values []string, side ListSide, onlyIfExists bool) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil {
+		return 0, err
+	}
+	if o == nil {
+		if onlyIfExists {
+			return 0, nil
+		}
+		o = newListObject()
+		kv.add(key, o)
+	}
+
+	list := o.value.(*List)
+	for _, value := range values {
+		if side == Left {
+			list.PushHead(value)
+		} else {
+			list.PushTail(value)
+		}
+	}
+
+	return list.Len(), nil
+}
+
+// Pop removes and returns up to count elements from the given side of the
+// list stored at key, a missing key yields a nil slice.
+func (kv *KV) Pop(key string, side ListSide, count int) ([]string, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return nil, err
+	}
+
+	return kv.popList(key, o.value.(*List), side, count), nil
+}
+
+// LLen returns the length of the list stored at key.
+func (kv *KV) LLen(key string) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return 0, err
+	}
+
+	return o.value.(*List).Len(), nil
+}
+
+// LIndex returns the element at index in the list stored at key, negative
+// indexes count from the tail. It reports false when the index is out of range.
+func (kv *KV) LIndex(key string, index int) (string, bool, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return "", false, err
+	}
+
+	list := o.value.(*List)
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return "", false, nil
+	}
+
+	return list.Index(index), true, nil
+}
+
+// LSet replaces the element at index in the list stored at key.
+func (kv *KV) LSet(key string, index int, value string) error {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		return ErrNoSuchKey
+	}
+
+	list := o.value.(*List)
+	if index < 0 {
+		index += list.Len()
+	}
+	if index < 0 || index >= list.Len() {
+		return ErrIndexOutOfRange
+	}
+	list.Set(index, value)
+
+	return nil
+}
+
+// LRange returns the elements between start and stop (both included) of the
+// list stored at key, with the redis handling of negative and out of range indexes.
+func (kv *KV) LRange(key string, start, stop int) ([]string, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return []string{}, err
+	}
+
+	list := o.value.(*List)
+	start, stop, ok := normalizeRange(start, stop, list.Len())
+	if !ok {
+		return []string{}, nil
+	}
+
+	return list.Range(start, stop), nil
+}
+
+// LRem removes count occurrences of value from the list stored at key, see
+// List.Remove for the meaning of count. It returns the number of removed elements.
+func (kv *KV) LRem(key string, count int, value string) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return 0, err
+	}
+
+	list := o.value.(*List)
+	removed := list.Remove(count, value)
+	if list.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return removed, nil
+}
+
+// LTrim trims the list stored at key so it only holds the elements between
+// start and stop, both included.
+func (kv *KV) LTrim(key string, start, stop int) error {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return err
+	}
+
+	list := o.value.(*List)
+	start, stop, ok := normalizeRange(start, stop, list.Len())
+	if !ok {
+		kv.remove(key)
+		return nil
+	}
+	list.Trim(start, stop)
+
+	return nil
+}
+
+// LInsert inserts value before or after the first occurrence of pivot in the
+// list stored at key. It returns the new length of the list, 0 when there is
+// no such key and -1 when the pivot was not found.
+func (kv *KV) LInsert(key string, before bool, pivot, value string) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return 0, err
+	}
+
+	list := o.value.(*List)
+	at := -1
+	list.Each(func(index int, elem string) bool {
+		if elem == pivot {
+			at = index
+			return false
+		}
+		return true
+	})
+	if at == -1 {
+		return -1, nil
+	}
+	if !before {
+		at++
+	}
+	list.Insert(at, value)
+
+	return list.Len(), nil
+}
+
+// LPos returns the indexes of the elements matching value in the list stored
+// at key. A negative rank searches from the tail and skips the first |rank|-1
+// matches, count limits the number of matches and maxLen the number of
+// compared elements, zero meaning no limit for both.
+func (kv *KV) LPos(key, value string, rank, count, maxLen int) ([]int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeList)
+	if err != nil || o == nil {
+		return nil, err
+	}
+
+	list := o.value.(*List)
+	matches := []int{}
+	skip := rank - 1
+	each := list.Each
+	if rank < 0 {
+		skip = -rank - 1
+		each = list.EachReverse
+	}
+
+	compared := 0
+	each(func(index int, elem string) bool {
+		if maxLen != 0 && compared == maxLen {
+			return false
+		}
+		compared++
+		if elem != value {
+			return true
+		}
+		if skip > 0 {
+			skip--
+			return true
+		}
+		matches = append(matches, index)
+		return count == 0 || len(matches) < count
+	})
+
+	return matches, nil
+}
+
+// LMove atomically pops an element from one side of the source list and
+// pushes it to one side of the destination list. It reports false when the
+// source list does not exist.
+func (kv *KV) LMove(source, destination string, from, to ListSide) (string, bool, error) {
+	defer kv.lock(source, destination)()
+
+	src, err := kv.lookupType(source, TypeList)
+	if err != nil || src == nil {
+		return "", false, err
+	}
+	dst, err := kv.lookupType(destination, TypeList)
+	if err != nil {
+		return "", false, err
+	}
+
+	list := src.value.(*List)
+	var value string
+	if from == Left {
+		value, _ = list.PopHead()
+	} else {
+		value, _ = list.PopTail()
+	}
+
+	if dst == nil {
+		dst = newListObject()
+		kv.add(destination, dst)
+	}
+	if to == Left {
+		dst.value.(*List).PushHead(value)
+	} else {
+		dst.value.(*List).PushTail(value)
+	}
+
+	if list.Len() == 0 {
+		kv.remove(source)
+	}
+
+	return value, true, nil
+}
+
+// LMPop pops up to count elements from the first non empty list among keys.
+// It returns the key the elements were popped from, or an empty key when all
+// the lists are empty.
+func (kv *KV) LMPop(keys []string, side ListSide, count int) (string, []string, error) {
+	defer kv.lock(keys...)()
+
+	for _, key := range keys {
+		o, err := kv.lookupType(key, TypeList)
+		if err != nil {
+			return "", nil, err
+		}
+		if o != nil {
+			return key, kv.popList(key, o.value.(*List), side, count), nil
+		}
+	}
+
+	return "", nil, nil
+}
+
+// popList pops up to count elements and deletes the key once the list is
+// empty, the caller must hold the lock.
+func (kv *KV) popList(key string, list *List, side ListSide, count int) []string {
+	values := make([]string, 0, min(count, list.Len()))
+	for len(values) < count {
+		var value string
+		var ok bool
+		if side == Left {
+			value, ok = list.PopHead()
+		} else {
+			value, ok = list.PopTail()
+		}
+		if !ok {
+			break
+		}
+		values = append(values, value)
+	}
+
+	if list.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return values
+}
+
+// normalizeRange resolves negative indexes and clamps the range to the length
+// of the collection like redis does for LRANGE, LTRIM and friends. It reports
+// false when the range is empty.
+func normalizeRange(start, stop, length int) (int, int, bool) {
+	if start < 0 {
+		start += length
+	}
+	if stop < 0 {
+		stop += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= length {
+		return 0, 0, false
+	}
+	if stop >= length {
+		stop = length - 1
+	}
+
+	return start, stop, true
+}
@@ -0,0 +1,164 @@
+package keyval
+
+// The memory of a key is estimated rather than measured, from the length of
+// its strings and fixed overheads for the structures holding them. Like
+// redis computes MEMORY USAGE, the elements of collections are not all
+// walked, the size of the first few is averaged and multiplied by their
+// number, so the estimate costs the same for any size of value.
+const (
+	// memorySamples is how many elements of a collection are looked at,
+	// unless MEMORY USAGE asks for another number.
+	memorySamples = 5
+
+	// keyOverhead is the dict entry, the object and the key header of a key.
+	keyOverhead = 80
+	// stringOverhead is the header of a string element.
+	stringOverhead = 16
+	// entryOverhead is a dict entry holding an element, and pointerSize a
+	// bucket of a dict table.
+	entryOverhead = 48
+	pointerSize   = 8
+	// quicklistNodeOverhead is a node of a list, skiplistNodeOverhead one of
+	// a sorted set, its levels averaging less than two forward pointers.
+	quicklistNodeOverhead = 48
+	skiplistNodeOverhead  = 64
+	// streamNodeOverhead is a node of a stream index, pendingOverhead an
+	// entry of a PEL and consumerOverhead a consumer of a group.
+	streamNodeOverhead = 64
+	pendingOverhead    = 96
+	consumerOverhead   = 96
+)
+
+// Used returns the estimated memory of the keyspace in bytes. The objects
+// accessed since the previous call are estimated again first, as commands
+// modify them in place.
+func (kv *KV) Used() int64 {
+	used := int64(0)
+	for _, s := range kv.shards {
+		s.mu.Lock()
+		s.settle()
+		used += s.used
+		s.mu.Unlock()
+	}
+
+	return used
+}
+
+// MemoryUsage returns the estimated memory of the key in bytes, as reported
+// by MEMORY USAGE, and whether it exists. samples is how many elements of a
+// collection are looked at, zero for all of them.
+func (kv *KV) MemoryUsage(key string, samples int) (int64, bool) {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return 0, false
+	}
+
+	return memoryOf(key, o, samples), true
+}
+
+// settle estimates again the objects of the shard accessed since the
+// previous call, the ones that were deleted meanwhile are already accounted
+// for. The caller must hold the lock.
+func (s *shard) settle() {
+	for key, o := range s.dirty {
+		if cur, ok := s.data.Get(key); ok && cur == o {
+			size := memoryOf(key, o, memorySamples)
+			s.used += size - o.size
+			o.size = size
+		}
+	}
+	clear(s.dirty)
+}
+
+// memoryOf estimates the memory of the key holding o from samples elements of
+// its value, all of them when zero.
+func memoryOf(key string, o *object, samples int) int64 {
+	return int64(keyOverhead+len(key)) + o.memory(samples)
+}
+
+// memory estimates the memory of the value of the object.
+func (o *object) memory(samples int) int64 {
+	switch v := o.value.(type) {
+	case []byte:
+		return int64(cap(v))
+	case *List:
+		return v.memory(samples)
+	case *Hash:
+		return dictMemory(v, samples, func(field, value string) int { return len(field) + len(value) + 2*stringOverhead })
+	case *Set:
+		if v.ints != nil {
+			return int64(cap(v.ints.contents))
+		}
+		return dictMemory(v.dict, samples, func(member string, _ struct{}) int { return len(member) + stringOverhead })
+	case *ZSet:
+		return dictMemory(v.dict, samples, func(member string, _ float64) int { return len(member) + stringOverhead + 8 }) +
+			int64(v.Len()*skiplistNodeOverhead)
+	case *Stream:
+		return v.memory(samples)
+	default:
+		return 0
+	}
+}
+
+// dictMemory estimates the memory of the dict from the size of its first
+// samples entries.
+func dictMemory[V any](d *dict[V], samples int, size func(key string, val V) int) int64 {
+	sampled, total := 0, 0
+	d.Each(func(key string, val V) bool {
+		total += size(key, val)
+		sampled++
+		return moreSamples(sampled, samples)
+	})
+
+	return int64(len(d.table)*pointerSize + d.Len()*entryOverhead + average(total, sampled, d.Len()))
+}
+
+func (l *List) memory(samples int) int64 {
+	sampled, total := 0, 0
+	l.Each(func(_ int, value string) bool {
+		total += len(value) + stringOverhead
+		sampled++
+		return moreSamples(sampled, samples)
+	})
+
+	return int64(l.nodes*quicklistNodeOverhead + average(total, sampled, l.length))
+}
+
+func (s *Stream) memory(samples int) int64 {
+	sampled, total := 0, 0
+	s.index.Ascend(StreamID{}, func(_ StreamID, n *streamNode) bool {
+		for _, entry := range n.entries {
+			total += 16
+			for _, field := range entry.Fields {
+				total += len(field) + stringOverhead
+			}
+			if sampled++; !moreSamples(sampled, samples) {
+				break
+			}
+		}
+		return moreSamples(sampled, samples)
+	})
+	size := s.index.nodes*streamNodeOverhead + average(total, sampled, s.length)
+	for _, g := range s.groups {
+		size += g.pel.Len()*pendingOverhead + len(g.consumers)*consumerOverhead
+	}
+
+	return int64(size)
+}
+
+// moreSamples tells whether elements are still to be looked at after sampled
+// of them, samples being zero to look at them all.
+func moreSamples(sampled, samples int) bool {
+	return samples == 0 || sampled < samples
+}
+
+// average extrapolates the total size of the sampled elements to n elements.
+func average(total, sampled, n int) int {
+	if sampled == 0 {
+		return 0
+	}
+
+	return total * n / sampled
+}
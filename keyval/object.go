@@ -0,0 +1,238 @@
+package keyval
+
+import (
+	"math"
+	"slices"
+	"strconv"
+)
+
+// sharedIntegers is the number of small integers redis shares between keys,
+// their REFCOUNT never changes.
+const sharedIntegers = 10000
+
+// Type is the kind of value a key holds.
+type Type int
+
+const (
+	TypeNone Type = iota
+	TypeString
+	TypeList
+	TypeHash
+	TypeSet
+	TypeZSet
+	TypeStream
+)
+
+// String returns the name redis uses for the type, that's what the TYPE command replies with.
+func (t Type) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeList:
+		return "list"
+	case TypeHash:
+		return "hash"
+	case TypeSet:
+		return "set"
+	case TypeZSet:
+		return "zset"
+	case TypeStream:
+		return "stream"
+	default:
+		return "none"
+	}
+}
+
+// object is what every key of the keyspace points to, value holds a []byte
+// for strings, a *List for lists, a *Hash for hashes, a *Set for sets, a
+// *ZSet for sorted sets and a *Stream for streams.
+type object struct {
+	typ   Type
+	value any
+	// lru is when the value was last accessed, in unix milliseconds, lfu
+	// the logarithmic access counter and ldt when it was last decremented,
+	// in minutes.
+	lru int64
+	lfu uint8
+	ldt int64
+	// size is the memory accounted for the key, see settle.
+	size int64
+}
+
+func newStringObject(value []byte) *object {
+	return &object{typ: TypeString, value: value}
+}
+
+func newListObject() *object {
+	return &object{typ: TypeList, value: NewList()}
+}
+
+// clone returns a deep copy of the object, for COPY.
+func (o *object) clone() *object {
+	c := &object{typ: o.typ}
+	switch v := o.value.(type) {
+	case []byte:
+		c.value = slices.Clone(v)
+	case *List:
+		c.value = v.clone()
+	case *Hash:
+		c.value = v.clone()
+	case *Set:
+		c.value = v.clone()
+	case *ZSet:
+		c.value = v.clone()
+	case *Stream:
+		c.value = v.clone()
+	}
+
+	return c
+}
+
+// encoding returns the internal representation redis would report for this value.
+func (o *object) encoding() string {
+	switch o.typ {
+	case TypeString:
+		b := o.value.([]byte)
+		if len(b) <= 20 {
+			if _, err := strconv.ParseInt(string(b), 10, 64); err == nil {
+				return "int"
+			}
+		}
+		if len(b) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case TypeList:
+		// Like redis, a list that fits in a single small chunk is reported as a listpack.
+		if o.value.(*List).nodes <= 1 {
+			return "listpack"
+		}
+		return "quicklist"
+	case TypeHash:
+		h := o.value.(*Hash)
+		small := h.Len() <= hashMaxListpackEntries
+		h.Each(func(field, value string) bool {
+			small = small && len(field) <= hashMaxListpackValue && len(value) <= hashMaxListpackValue
+			return small
+		})
+		if small {
+			return "listpack"
+		}
+		return "hashtable"
+	case TypeSet:
+		return o.value.(*Set).encoding()
+	case TypeZSet:
+		return o.value.(*ZSet).encoding()
+	case TypeStream:
+		return "stream"
+	default:
+		return ""
+	}
+}
+
+// Type returns the type of the value stored at key, TypeNone if there is no such key.
+func (kv *KV) Type(key string) Type {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return TypeNone
+	}
+
+	return o.typ
+}
+
+// Encoding returns the internal encoding of the value stored at key, as
+// reported by OBJECT ENCODING, and whether the key exists.
+func (kv *KV) Encoding(key string) (string, bool) {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return "", false
+	}
+
+	return o.encoding(), true
+}
+
+// IdleTime returns the number of seconds since the value stored at key was
+// last accessed, as reported by OBJECT IDLETIME, and whether the key exists.
+func (kv *KV) IdleTime(key string) (int64, bool) {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return 0, false
+	}
+
+	return (Now() - o.lru) / 1000, true
+}
+
+// RefCount returns the number of references to the value stored at key, as
+// reported by OBJECT REFCOUNT, and whether the key exists. Values are never
+// shared but the small integers redis keeps shared.
+func (kv *KV) RefCount(key string) (int64, bool) {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return 0, false
+	}
+	if o.typ == TypeString {
+		value := string(o.value.([]byte))
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 && n < sharedIntegers && strconv.FormatInt(n, 10) == value {
+			return math.MaxInt32, true
+		}
+	}
+
+	return 1, true
+}
+
+// Freq returns the logarithmic access counter of the value stored at key, as
+// reported by OBJECT FREQ, and whether the key exists.
+func (kv *KV) Freq(key string) (int64, bool) {
+	defer kv.lock(key)()
+
+	o := kv.peek(key)
+	if o == nil {
+		return 0, false
+	}
+
+	return int64(o.lfuDecr(Now())), true
+}
+
+// lookup returns the object stored at key after expiring it if needed and
+// records the access, the caller must hold the lock.
+func (kv *KV) lookup(key string) *object {
+	s := kv.shard(key)
+	o := s.peek(key)
+	if o != nil {
+		o.touch(Now())
+		s.dirty[key] = o
+	}
+
+	return o
+}
+
+// peek is lookup for the commands that do not count as an access to the
+// value, like TYPE or OBJECT.
+func (kv *KV) peek(key string) *object {
+	return kv.shard(key).peek(key)
+}
+
+func (s *shard) peek(key string) *object {
+	s.expireIfNeeded(key)
+	o, _ := s.data.Get(key)
+	return o
+}
+
+// lookupType is lookup for commands that only work on one type of value, a
+// missing key is not an error and yields a nil object.
+func (kv *KV) lookupType(key string, typ Type) (*object, error) {
+	o := kv.lookup(key)
+	if o != nil && o.typ != typ {
+		return nil, ErrWrongType
+	}
+
+	return o, nil
+}
@@ -0,0 +1,350 @@
+package keyval
+
+// quicklistNodeSize is the maximum number of elements a single quicklist node holds.
+const quicklistNodeSize = 128
+
+// quicklistNode is one chunk of a quicklist, a small slice of elements linked to its neighbours.
+type quicklistNode struct {
+	prev, next *quicklistNode
+	entries    []string
+}
+
+// List is the value of redis lists, like redis it is a quicklist: a doubly
+// linked list of small chunks. Pushing and popping at both ends is cheap and
+// random access only has to skip whole chunks.
+// All the indexes a List takes are zero based and already in range, the
+// redis negative indexes are resolved by the callers.
+type List struct {
+	head, tail *quicklistNode
+	length     int
+	nodes      int
+}
+
+// NewList creates an empty list.
+func NewList() *List {
+	return &List{}
+}
+
+func (l *List) clone() *List {
+	c := NewList()
+	l.Each(func(_ int, value string) bool {
+		c.PushTail(value)
+		return true
+	})
+
+	return c
+}
+
+// Len returns the number of elements in the list.
+func (l *List) Len() int {
+	return l.length
+}
+
+// PushHead inserts the value at the head of the list.
+func (l *List) PushHead(value string) {
+	if l.head == nil || len(l.head.entries) >= quicklistNodeSize {
+		l.linkBefore(l.head, &quicklistNode{})
+	}
+	n := l.head
+	n.entries = append(n.entries, "")
+	copy(n.entries[1:], n.entries)
+	n.entries[0] = value
+	l.length++
+}
+
+// PushTail inserts the value at the tail of the list.
+func (l *List) PushTail(value string) {
+	if l.tail == nil || len(l.tail.entries) >= quicklistNodeSize {
+		l.linkAfter(l.tail, &quicklistNode{})
+	}
+	l.tail.entries = append(l.tail.entries, value)
+	l.length++
+}
+
+// PopHead removes and returns the first element of the list.
+func (l *List) PopHead() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	value := l.head.entries[0]
+	l.deleteAt(l.head, 0)
+
+	return value, true
+}
+
+// PopTail removes and returns the last element of the list.
+func (l *List) PopTail() (string, bool) {
+	if l.length == 0 {
+		return "", false
+	}
+	value := l.tail.entries[len(l.tail.entries)-1]
+	l.deleteAt(l.tail, len(l.tail.entries)-1)
+
+	return value, true
+}
+
+// Index returns the element at index.
+func (l *List) Index(index int) string {
+	n, i := l.seek(index)
+	return n.entries[i]
+}
+
+// Set replaces the element at index.
+func (l *List) Set(index int, value string) {
+	n, i := l.seek(index)
+	n.entries[i] = value
+}
+
+// Range returns the elements between start and stop, both included.
+func (l *List) Range(start, stop int) []string {
+	ret := make([]string, 0, stop-start+1)
+	n, i := l.seek(start)
+	for n != nil && len(ret) < stop-start+1 {
+		for ; i < len(n.entries) && len(ret) < stop-start+1; i++ {
+			ret = append(ret, n.entries[i])
+		}
+		n, i = n.next, 0
+	}
+
+	return ret
+}
+
+// Each calls fn for every element from head to tail until fn returns false.
+func (l *List) Each(fn func(index int, value string) bool) {
+	index := 0
+	for n := l.head; n != nil; n = n.next {
+		for _, value := range n.entries {
+			if !fn(index, value) {
+				return
+			}
+			index++
+		}
+	}
+}
+
+// EachReverse calls fn for every element from tail to head until fn returns false.
+func (l *List) EachReverse(fn func(index int, value string) bool) {
+	index := l.length - 1
+	for n := l.tail; n != nil; n = n.prev {
+		for i := len(n.entries) - 1; i >= 0; i-- {
+			if !fn(index, n.entries[i]) {
+				return
+			}
+			index--
+		}
+	}
+}
+
+// Insert inserts the value at index, shifting the element currently there
+// towards the tail. An index equal to the length appends the value.
+func (l *List) Insert(index int, value string) {
+	switch {
+	case index == 0:
+		l.PushHead(value)
+		return
+	case index == l.length:
+		l.PushTail(value)
+		return
+	}
+
+	n, i := l.seek(index)
+	if len(n.entries) >= quicklistNodeSize {
+		l.split(n)
+		if i >= len(n.entries) {
+			i -= len(n.entries)
+			n = n.next
+		}
+	}
+	n.entries = append(n.entries, "")
+	copy(n.entries[i+1:], n.entries[i:])
+	n.entries[i] = value
+	l.length++
+}
+
+// Remove deletes up to count occurrences of value, from head to tail, or from
+// tail to head when count is negative. A count of zero removes them all.
+// It returns the number of removed elements.
+func (l *List) Remove(count int, value string) int {
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+
+	removed := 0
+	keep := func(entry string) bool {
+		if entry != value || (limit != 0 && removed == limit) {
+			return true
+		}
+		removed++
+		return false
+	}
+
+	for n := l.head; n != nil && count >= 0; n = n.next {
+		entries := n.entries[:0]
+		for i := range n.entries {
+			if keep(n.entries[i]) {
+				entries = append(entries, n.entries[i])
+			}
+		}
+		n.entries = entries
+	}
+	for n := l.tail; n != nil && count < 0; n = n.prev {
+		// Walk the chunk backwards, compacting the kept entries towards its end.
+		j := len(n.entries)
+		for i := len(n.entries) - 1; i >= 0; i-- {
+			if keep(n.entries[i]) {
+				j--
+				n.entries[j] = n.entries[i]
+			}
+		}
+		n.entries = n.entries[j:]
+	}
+
+	l.length -= removed
+	for n := l.head; n != nil; {
+		next := n.next
+		if len(n.entries) == 0 {
+			l.unlink(n)
+		}
+		n = next
+	}
+
+	return removed
+}
+
+// Trim keeps only the elements between start and stop, both included. An
+// empty range (start > stop) empties the list.
+func (l *List) Trim(start, stop int) {
+	if start > stop {
+		*l = List{}
+		return
+	}
+
+	l.trimHead(start)
+	l.trimTail(l.length - (stop - start + 1))
+}
+
+// trimHead drops the first count elements.
+func (l *List) trimHead(count int) {
+	for count > 0 {
+		n := l.head
+		if len(n.entries) <= count {
+			count -= len(n.entries)
+			l.length -= len(n.entries)
+			l.unlink(n)
+			continue
+		}
+		n.entries = append(n.entries[:0:0], n.entries[count:]...)
+		l.length -= count
+		count = 0
+	}
+}
+
+// trimTail drops the last count elements.
+func (l *List) trimTail(count int) {
+	for count > 0 {
+		n := l.tail
+		if len(n.entries) <= count {
+			count -= len(n.entries)
+			l.length -= len(n.entries)
+			l.unlink(n)
+			continue
+		}
+		n.entries = n.entries[:len(n.entries)-count]
+		l.length -= count
+		count = 0
+	}
+}
+
+// seek returns the node holding the element at index and its offset in that
+// node, walking from whichever end of the list is closer.
+func (l *List) seek(index int) (*quicklistNode, int) {
+	if index < l.length/2 {
+		n := l.head
+		for index >= len(n.entries) {
+			index -= len(n.entries)
+			n = n.next
+		}
+		return n, index
+	}
+
+	n := l.tail
+	index = l.length - 1 - index
+	for index >= len(n.entries) {
+		index -= len(n.entries)
+		n = n.prev
+	}
+
+	return n, len(n.entries) - 1 - index
+}
+
+// deleteAt removes the i-th element of the node, unlinking the node once empty.
+func (l *List) deleteAt(n *quicklistNode, i int) {
+	n.entries = append(n.entries[:i], n.entries[i+1:]...)
+	l.length--
+	if len(n.entries) == 0 {
+		l.unlink(n)
+	}
+}
+
+// split moves the second half of a full node into a new node right after it.
+func (l *List) split(n *quicklistNode) {
+	half := len(n.entries) / 2
+	next := &quicklistNode{entries: append(make([]string, 0, quicklistNodeSize), n.entries[half:]...)}
+	n.entries = n.entries[:half]
+	l.linkAfter(n, next)
+}
+
+// linkBefore links the new node before at, a nil at means the tail of the list.
+func (l *List) linkBefore(at, n *quicklistNode) {
+	if at == nil {
+		l.linkAfter(l.tail, n)
+		return
+	}
+	n.next, n.prev = at, at.prev
+	if at.prev != nil {
+		at.prev.next = n
+	} else {
+		l.head = n
+	}
+	at.prev = n
+	l.nodes++
+}
+
+// linkAfter links the new node after at, a nil at means the head of the list.
+func (l *List) linkAfter(at, n *quicklistNode) {
+	if at == nil {
+		n.next = l.head
+		if l.head != nil {
+			l.head.prev = n
+		} else {
+			l.tail = n
+		}
+		l.head = n
+		l.nodes++
+		return
+	}
+	n.prev, n.next = at, at.next
+	if at.next != nil {
+		at.next.prev = n
+	} else {
+		l.tail = n
+	}
+	at.next = n
+	l.nodes++
+}
+
+func (l *List) unlink(n *quicklistNode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		l.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		l.tail = n.prev
+	}
+	n.prev, n.next = nil, nil
+	l.nodes--
+}
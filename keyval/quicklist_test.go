@@ -0,0 +1,117 @@
+package keyval
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// TestListAgainstSlice runs random operations on a List and on a plain slice
+// and checks that both always agree.
+func TestListAgainstSlice(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	list := NewList()
+	model := []string{}
+
+	for i := 0; i < 20000; i++ {
+		value := strconv.Itoa(rnd.Intn(50))
+		switch op := rnd.Intn(8); op {
+		case 0:
+			list.PushHead(value)
+			model = append([]string{value}, model...)
+		case 1:
+			list.PushTail(value)
+			model = append(model, value)
+		case 2:
+			got, ok := list.PopHead()
+			if ok != (len(model) > 0) {
+				t.Fatalf("PopHead reported %v with %d elements", ok, len(model))
+			}
+			if ok {
+				if got != model[0] {
+					t.Fatalf("PopHead: expected %s but got %s", model[0], got)
+				}
+				model = model[1:]
+			}
+		case 3:
+			got, ok := list.PopTail()
+			if ok {
+				if got != model[len(model)-1] {
+					t.Fatalf("PopTail: expected %s but got %s", model[len(model)-1], got)
+				}
+				model = model[:len(model)-1]
+			}
+		case 4:
+			at := rnd.Intn(len(model) + 1)
+			list.Insert(at, value)
+			model = append(model[:at], append([]string{value}, model[at:]...)...)
+		case 5:
+			count := rnd.Intn(5) - 2
+			removed := list.Remove(count, value)
+			expected := removeFromSlice(&model, count, value)
+			if removed != expected {
+				t.Fatalf("Remove(%d, %s): expected %d but got %d", count, value, expected, removed)
+			}
+		case 6:
+			if len(model) > 0 && rnd.Intn(10) == 0 {
+				start := rnd.Intn(len(model))
+				stop := start + rnd.Intn(len(model)-start)
+				list.Trim(start, stop)
+				model = append([]string{}, model[start:stop+1]...)
+			}
+		case 7:
+			if len(model) > 0 {
+				at := rnd.Intn(len(model))
+				list.Set(at, value)
+				model[at] = value
+			}
+		}
+
+		if list.Len() != len(model) {
+			t.Fatalf("expected a length of %d but got %d", len(model), list.Len())
+		}
+	}
+
+	if len(model) > 0 && !reflect.DeepEqual(list.Range(0, len(model)-1), model) {
+		t.Fatal("the list and the model diverged")
+	}
+	for i := range model {
+		if list.Index(i) != model[i] {
+			t.Fatalf("Index(%d): expected %s but got %s", i, model[i], list.Index(i))
+		}
+	}
+}
+
+func removeFromSlice(s *[]string, count int, value string) int {
+	removed := 0
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	if count >= 0 {
+		kept := []string{}
+		for _, elem := range *s {
+			if elem == value && (limit == 0 || removed < limit) {
+				removed++
+				continue
+			}
+			kept = append(kept, elem)
+		}
+		*s = kept
+		return removed
+	}
+
+	kept := make([]string, len(*s))
+	j := len(*s)
+	for i := len(*s) - 1; i >= 0; i-- {
+		if (*s)[i] == value && removed < limit {
+			removed++
+			continue
+		}
+		j--
+		kept[j] = (*s)[i]
+	}
+	*s = kept[j:]
+	return removed
+}
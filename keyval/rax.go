@@ -0,0 +1,199 @@
+package keyval
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// raxKeyLen is the length of the keys of a rax: a stream ID encoded as two
+// big-endian uint64, so that the byte order of the keys is the ID order.
+const raxKeyLen = 16
+
+// rax is the radix tree streams index their entries with, like the rax redis
+// uses for the same purpose: each level of the tree consumes one byte of the
+// key and keeps its children sorted, so ranges are walked in ID order. Unlike
+// redis it does not compress the paths, keys being only 16 bytes long.
+type rax[V any] struct {
+	root  raxNode[V]
+	size  int
+	nodes int
+}
+
+type raxNode[V any] struct {
+	bytes    []byte
+	children []*raxNode[V]
+	value    V
+}
+
+func newRax[V any]() *rax[V] {
+	return &rax[V]{nodes: 1}
+}
+
+// raxKey encodes the ID as a rax key.
+func raxKey(id StreamID) [raxKeyLen]byte {
+	var key [raxKeyLen]byte
+	binary.BigEndian.PutUint64(key[:8], id.Ms)
+	binary.BigEndian.PutUint64(key[8:], id.Seq)
+	return key
+}
+
+// Len returns the number of keys in the tree.
+func (r *rax[V]) Len() int {
+	return r.size
+}
+
+// Get returns the value stored for the ID.
+func (r *rax[V]) Get(id StreamID) (V, bool) {
+	key := raxKey(id)
+	n := &r.root
+	for _, b := range key {
+		if n = n.child(b); n == nil {
+			var zero V
+			return zero, false
+		}
+	}
+	return n.value, true
+}
+
+// Insert stores the value for the ID and reports whether the ID is new.
+func (r *rax[V]) Insert(id StreamID, value V) bool {
+	key := raxKey(id)
+	n := &r.root
+	added := false
+	for _, b := range key {
+		i := sort.Search(len(n.bytes), func(i int) bool { return n.bytes[i] >= b })
+		if i == len(n.bytes) || n.bytes[i] != b {
+			n.bytes = append(n.bytes, 0)
+			copy(n.bytes[i+1:], n.bytes[i:])
+			n.bytes[i] = b
+			n.children = append(n.children, nil)
+			copy(n.children[i+1:], n.children[i:])
+			n.children[i] = &raxNode[V]{}
+			r.nodes++
+			added = true
+		}
+		n = n.children[i]
+	}
+	n.value = value
+	if added {
+		r.size++
+	}
+
+	return added
+}
+
+// Remove deletes the ID and reports whether it was in the tree, the nodes
+// left without children are freed.
+func (r *rax[V]) Remove(id StreamID) bool {
+	key := raxKey(id)
+	var path [raxKeyLen]*raxNode[V]
+	n := &r.root
+	for depth, b := range key {
+		path[depth] = n
+		if n = n.child(b); n == nil {
+			return false
+		}
+	}
+
+	for depth := raxKeyLen - 1; depth >= 0; depth-- {
+		parent := path[depth]
+		i := sort.Search(len(parent.bytes), func(i int) bool { return parent.bytes[i] >= key[depth] })
+		parent.bytes = append(parent.bytes[:i], parent.bytes[i+1:]...)
+		parent.children = append(parent.children[:i], parent.children[i+1:]...)
+		r.nodes--
+		if len(parent.bytes) > 0 {
+			break
+		}
+	}
+	r.size--
+
+	return true
+}
+
+// Ascend calls fn for the IDs greater than or equal to from, in increasing
+// order, until fn returns false.
+func (r *rax[V]) Ascend(from StreamID, fn func(id StreamID, value V) bool) {
+	key := raxKey(from)
+	var prefix [raxKeyLen]byte
+	r.root.walk(key, 0, true, false, &prefix, fn)
+}
+
+// Descend calls fn for the IDs lower than or equal to from, in decreasing
+// order, until fn returns false.
+func (r *rax[V]) Descend(from StreamID, fn func(id StreamID, value V) bool) {
+	key := raxKey(from)
+	var prefix [raxKeyLen]byte
+	r.root.walk(key, 0, true, true, &prefix, fn)
+}
+
+// First returns the lowest ID of the tree.
+func (r *rax[V]) First() (StreamID, V, bool) {
+	return r.edge(false)
+}
+
+// Last returns the highest ID of the tree.
+func (r *rax[V]) Last() (StreamID, V, bool) {
+	return r.edge(true)
+}
+
+func (r *rax[V]) edge(last bool) (id StreamID, value V, ok bool) {
+	fn := func(i StreamID, v V) bool {
+		id, value, ok = i, v, true
+		return false
+	}
+	if last {
+		r.Descend(MaxStreamID, fn)
+	} else {
+		r.Ascend(StreamID{}, fn)
+	}
+	return id, value, ok
+}
+
+func (n *raxNode[V]) child(b byte) *raxNode[V] {
+	i := sort.Search(len(n.bytes), func(i int) bool { return n.bytes[i] >= b })
+	if i == len(n.bytes) || n.bytes[i] != b {
+		return nil
+	}
+	return n.children[i]
+}
+
+// walk visits the leaves under the node in order, or in reverse order with
+// desc. While bounded, the path followed so far equals the start key, and
+// the children on the wrong side of its next byte are skipped.
+func (n *raxNode[V]) walk(key [raxKeyLen]byte, depth int, bounded, desc bool, prefix *[raxKeyLen]byte, fn func(StreamID, V) bool) bool {
+	if depth == raxKeyLen {
+		id := StreamID{
+			Ms:  binary.BigEndian.Uint64(prefix[:8]),
+			Seq: binary.BigEndian.Uint64(prefix[8:]),
+		}
+		return fn(id, n.value)
+	}
+
+	visit := func(i int) bool {
+		prefix[depth] = n.bytes[i]
+		return n.children[i].walk(key, depth+1, bounded && n.bytes[i] == key[depth], desc, prefix, fn)
+	}
+	if desc {
+		end := len(n.bytes)
+		if bounded {
+			end = sort.Search(len(n.bytes), func(i int) bool { return n.bytes[i] > key[depth] })
+		}
+		for i := end - 1; i >= 0; i-- {
+			if !visit(i) {
+				return false
+			}
+		}
+		return true
+	}
+
+	start := 0
+	if bounded {
+		start = sort.Search(len(n.bytes), func(i int) bool { return n.bytes[i] >= key[depth] })
+	}
+	for i := start; i < len(n.bytes); i++ {
+		if !visit(i) {
+			return false
+		}
+	}
+	return true
+}
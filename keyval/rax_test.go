@@ -0,0 +1,79 @@
+package keyval
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// TestRaxOrder checks the rax against a sorted slice: ascending and
+// descending walks from any ID, and that removing keys prunes the nodes.
+func TestRaxOrder(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	r := newRax[int]()
+	model := map[StreamID]int{}
+
+	for i := 0; i < 2000; i++ {
+		id := StreamID{Ms: uint64(rnd.Intn(300)), Seq: uint64(rnd.Intn(4))}
+		if _, ok := model[id]; r.Insert(id, i) == ok {
+			t.Fatalf("Insert(%v) reported a wrong novelty", id)
+		}
+		model[id] = i
+	}
+	for id := range model {
+		if id.Seq == 0 {
+			if !r.Remove(id) {
+				t.Fatalf("Remove(%v) missed the key", id)
+			}
+			delete(model, id)
+		}
+	}
+	if r.Len() != len(model) {
+		t.Fatalf("expected %d keys but got %d", len(model), r.Len())
+	}
+
+	ids := make([]StreamID, 0, len(model))
+	for id := range model {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Less(ids[j]) })
+
+	if first, _, _ := r.First(); first != ids[0] {
+		t.Fatalf("expected first key %v but got %v", ids[0], first)
+	}
+	if last, _, _ := r.Last(); last != ids[len(ids)-1] {
+		t.Fatalf("expected last key %v but got %v", ids[len(ids)-1], last)
+	}
+
+	for _, from := range []StreamID{{}, {Ms: 150, Seq: 2}, {Ms: 1000}} {
+		i := sort.Search(len(ids), func(i int) bool { return !ids[i].Less(from) })
+		var got []StreamID
+		r.Ascend(from, func(id StreamID, value int) bool {
+			if value != model[id] {
+				t.Fatalf("unexpected value %d for %v", value, id)
+			}
+			got = append(got, id)
+			return true
+		})
+		if len(got) != len(ids)-i || (len(got) > 0 && got[0] != ids[i]) {
+			t.Fatalf("ascending from %v: expected %d keys but got %d", from, len(ids)-i, len(got))
+		}
+
+		j := sort.Search(len(ids), func(j int) bool { return from.Less(ids[j]) })
+		got = got[:0]
+		r.Descend(from, func(id StreamID, _ int) bool {
+			got = append(got, id)
+			return true
+		})
+		if len(got) != j || (len(got) > 0 && got[0] != ids[j-1]) {
+			t.Fatalf("descending from %v: expected %d keys but got %d", from, j, len(got))
+		}
+	}
+
+	for _, id := range ids {
+		r.Remove(id)
+	}
+	if r.Len() != 0 || r.nodes != 1 {
+		t.Fatalf("expected an empty tree but got %d keys and %d nodes", r.Len(), r.nodes)
+	}
+}
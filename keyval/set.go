@@ -0,0 +1,477 @@
+package keyval
+
+import (
+	"sort"
+	"strconv"
+)
+
+// setMaxIntsetEntries is the biggest set kept in the intset encoding.
+const setMaxIntsetEntries = 512
+
+// Set is the value of redis sets. While all its members are integers and
+// there are not too many of them it is stored as an intset, then it gets
+// converted to a dict for good.
+type Set struct {
+	ints *intset
+	dict *dict[struct{}]
+}
+
+// NewSet creates an empty set, in the intset encoding.
+func NewSet() *Set {
+	return &Set{ints: newIntset()}
+}
+
+func newSetObject() *object {
+	return &object{typ: TypeSet, value: NewSet()}
+}
+
+func (s *Set) clone() *Set {
+	if s.ints != nil {
+		return &Set{ints: s.ints.clone()}
+	}
+	return &Set{dict: s.dict.clone()}
+}
+
+// Len returns the number of members of the set.
+func (s *Set) Len() int {
+	if s.ints != nil {
+		return s.ints.Len()
+	}
+	return s.dict.Len()
+}
+
+// Add inserts the member and reports whether it is new.
+func (s *Set) Add(member string) bool {
+	if s.ints != nil {
+		if n, ok := parseCanonicalInt(member); ok {
+			if !s.ints.Add(n) {
+				return false
+			}
+			if s.ints.Len() > setMaxIntsetEntries {
+				s.convert()
+			}
+			return true
+		}
+		s.convert()
+	}
+
+	return s.dict.Set(member, struct{}{})
+}
+
+// Remove deletes the member and reports whether it was there.
+func (s *Set) Remove(member string) bool {
+	if s.ints != nil {
+		n, ok := parseCanonicalInt(member)
+		return ok && s.ints.Remove(n)
+	}
+
+	_, ok := s.dict.Delete(member)
+	return ok
+}
+
+// Contains reports whether the member is in the set.
+func (s *Set) Contains(member string) bool {
+	if s.ints != nil {
+		n, ok := parseCanonicalInt(member)
+		return ok && s.ints.Contains(n)
+	}
+
+	_, ok := s.dict.Get(member)
+	return ok
+}
+
+// Each calls fn for every member until fn returns false. fn must not modify the set.
+func (s *Set) Each(fn func(member string) bool) {
+	if s.ints != nil {
+		for i := 0; i < s.ints.Len(); i++ {
+			if !fn(strconv.FormatInt(s.ints.Get(i), 10)) {
+				return
+			}
+		}
+		return
+	}
+
+	s.dict.Each(func(member string, _ struct{}) bool {
+		return fn(member)
+	})
+}
+
+// Members returns all the members of the set.
+func (s *Set) Members() []string {
+	members := make([]string, 0, s.Len())
+	s.Each(func(member string) bool {
+		members = append(members, member)
+		return true
+	})
+	return members
+}
+
+// Random returns a random member of the set, which must not be empty.
+func (s *Set) Random() string {
+	if s.ints != nil {
+		return strconv.FormatInt(s.ints.Random(), 10)
+	}
+
+	member, _, _ := s.dict.Random()
+	return member
+}
+
+// encoding returns the name redis gives to the current encoding of the set.
+func (s *Set) encoding() string {
+	if s.ints != nil {
+		return "intset"
+	}
+	return "hashtable"
+}
+
+// convert moves the members from the intset to a dict.
+func (s *Set) convert() {
+	s.dict = newDict[struct{}]()
+	for i := 0; i < s.ints.Len(); i++ {
+		s.dict.Set(strconv.FormatInt(s.ints.Get(i), 10), struct{}{})
+	}
+	s.ints = nil
+}
+
+// parseCanonicalInt parses the member as an integer only if that's its
+// canonical representation, so that converting it back gives the same string.
+func parseCanonicalInt(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(n, 10) != s {
+		return 0, false
+	}
+	return n, true
+}
+
+// SetOp is one of the set algebra operations.
+type SetOp int
+
+const (
+	SetInter SetOp = iota
+	SetUnion
+	SetDiff
+)
+
+// SAdd adds the members to the set stored at key and returns how many were new.
+func (kv *KV) SAdd(key string, members []string) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeSet)
+	if err != nil {
+		return 0, err
+	}
+	if o == nil {
+		o = newSetObject()
+		kv.add(key, o)
+	}
+
+	set, added := o.value.(*Set), 0
+	for _, member := range members {
+		if set.Add(member) {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// SRem removes the members from the set stored at key and returns how many
+// were there. An emptied set is deleted.
+func (kv *KV) SRem(key string, members []string) (int, error) {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if set.Remove(member) {
+			removed++
+		}
+	}
+	if set.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return removed, nil
+}
+
+// SMIsMember reports for each member whether it belongs to the set stored at key.
+func (kv *KV) SMIsMember(key string, members []string) ([]bool, error) {
+	defer kv.lock(key)()
+
+	ret := make([]bool, len(members))
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return ret, err
+	}
+	for i, member := range members {
+		ret[i] = set.Contains(member)
+	}
+
+	return ret, nil
+}
+
+// SCard returns the number of members of the set stored at key.
+func (kv *KV) SCard(key string) (int, error) {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return 0, err
+	}
+
+	return set.Len(), nil
+}
+
+// SMembers returns all the members of the set stored at key.
+func (kv *KV) SMembers(key string) ([]string, error) {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+
+	return set.Members(), nil
+}
+
+// SPop removes and returns up to count distinct random members of the set
+// stored at key. An emptied set is deleted.
+func (kv *KV) SPop(key string, count int) ([]string, error) {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return []string{}, err
+	}
+
+	if count >= set.Len() {
+		kv.remove(key)
+		return set.Members(), nil
+	}
+	popped := make([]string, 0, count)
+	for len(popped) < count {
+		member := set.Random()
+		set.Remove(member)
+		popped = append(popped, member)
+	}
+
+	return popped, nil
+}
+
+// SRandMember draws random members of the set stored at key, see
+// randomSample for the meaning of count and fn.
+func (kv *KV) SRandMember(key string, count int, fn func(n int, batch []string) error) error {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		return fn(0, nil)
+	}
+
+	return randomSample(count, set.Len(), set.Random, set.Members, fn)
+}
+
+// SMove moves the member from the source set to the destination set and
+// reports whether it was in the source.
+func (kv *KV) SMove(source, destination, member string) (bool, error) {
+	defer kv.lock(source, destination)()
+
+	src, err := kv.lookupType(source, TypeSet)
+	if err != nil {
+		return false, err
+	}
+	dst, err := kv.lookupType(destination, TypeSet)
+	if err != nil || src == nil {
+		return false, err
+	}
+
+	set := src.value.(*Set)
+	if source == destination {
+		return set.Contains(member), nil
+	}
+	if !set.Remove(member) {
+		return false, nil
+	}
+	if set.Len() == 0 {
+		kv.remove(source)
+	}
+	if dst == nil {
+		dst = newSetObject()
+		kv.add(destination, dst)
+	}
+	dst.value.(*Set).Add(member)
+
+	return true, nil
+}
+
+// SetOp runs the set algebra operation over the sets stored at keys, missing
+// keys being empty sets.
+func (kv *KV) SetOp(op SetOp, keys []string) ([]string, error) {
+	defer kv.lock(keys...)()
+
+	res, err := kv.setOp(op, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Members(), nil
+}
+
+// SetOpStore is SetOp but stores the result at destination, replacing
+// whatever was there, and returns its size.
+func (kv *KV) SetOpStore(op SetOp, destination string, keys []string) (int, error) {
+	defer kv.lock(append([]string{destination}, keys...)...)()
+
+	res, err := kv.setOp(op, keys)
+	if err != nil {
+		return 0, err
+	}
+
+	kv.discard(destination)
+	if res.Len() > 0 {
+		kv.add(destination, &object{typ: TypeSet, value: res})
+	}
+
+	return res.Len(), nil
+}
+
+// SInterCard returns the size of the intersection of the sets, stopping
+// early once limit is reached unless it is zero.
+func (kv *KV) SInterCard(keys []string, limit int) (int, error) {
+	defer kv.lock(keys...)()
+
+	sets, err := kv.sets(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	interEach(sets, func(string) bool {
+		count++
+		return limit == 0 || count < limit
+	})
+
+	return count, nil
+}
+
+// SScan runs one step of a SSCAN iteration over the set stored at key. Like
+// redis, a set still in the intset encoding is returned whole.
+func (kv *KV) SScan(key string, cursor uint64, match string, count int) (uint64, []string, error) {
+	defer kv.lock(key)()
+
+	set, err := kv.set(key)
+	if err != nil || set == nil {
+		return 0, []string{}, err
+	}
+
+	ret := []string{}
+	if set.ints != nil {
+		set.Each(func(member string) bool {
+			if match == "" || GlobMatch(match, member) {
+				ret = append(ret, member)
+			}
+			return true
+		})
+		return 0, ret, nil
+	}
+
+	cursor = scanDict(set.dict, cursor, match, count, func(member string, _ struct{}) {
+		ret = append(ret, member)
+	})
+
+	return cursor, ret, nil
+}
+
+// setOp computes the set algebra operation into a new set, the caller must hold the lock.
+func (kv *KV) setOp(op SetOp, keys []string) (*Set, error) {
+	sets, err := kv.sets(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	res := NewSet()
+	switch op {
+	case SetInter:
+		interEach(sets, func(member string) bool {
+			res.Add(member)
+			return true
+		})
+	case SetUnion:
+		for _, set := range sets {
+			if set != nil {
+				set.Each(func(member string) bool {
+					res.Add(member)
+					return true
+				})
+			}
+		}
+	case SetDiff:
+		if sets[0] == nil {
+			break
+		}
+		sets[0].Each(func(member string) bool {
+			for _, other := range sets[1:] {
+				if other != nil && other.Contains(member) {
+					return true
+				}
+			}
+			res.Add(member)
+			return true
+		})
+	}
+
+	return res, nil
+}
+
+// interEach calls fn for each member of the intersection of the sets until fn
+// returns false. It iterates over the smallest set, a nil set being empty.
+func interEach(sets []*Set, fn func(member string) bool) {
+	for _, set := range sets {
+		if set == nil {
+			return
+		}
+	}
+
+	sorted := append([]*Set{}, sets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Len() < sorted[j].Len() })
+	sorted[0].Each(func(member string) bool {
+		for _, other := range sorted[1:] {
+			if !other.Contains(member) {
+				return true
+			}
+		}
+		return fn(member)
+	})
+}
+
+// sets returns the sets stored at keys, nil for the missing ones, the caller must hold the lock.
+func (kv *KV) sets(keys []string) ([]*Set, error) {
+	sets := make([]*Set, len(keys))
+	for i, key := range keys {
+		set, err := kv.set(key)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// set returns the set stored at key, the caller must hold the lock.
+func (kv *KV) set(key string) (*Set, error) {
+	o, err := kv.lookupType(key, TypeSet)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	return o.value.(*Set), nil
+}
@@ -0,0 +1,151 @@
+package keyval
+
+import (
+	"hash/maphash"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultShards is the number of shards of a keyspace created by NewKeyVal.
+const DefaultShards = 16
+
+// shardSeed is not dictSeed: the keys of a shard would otherwise share the
+// low bits of their hash and only fill a fraction of the buckets of its dict.
+var shardSeed = maphash.MakeSeed()
+
+// kvIDs numbers the keyspaces, the commands spanning two of them lock the
+// one with the lower id first.
+var kvIDs atomic.Uint64
+
+// shard is a stripe of the keyspace, it holds the keys hashing to it and is
+// guarded by its own mutex. The mutex is not a RWMutex since even reads
+// record the access of the key and may delete it when expired.
+type shard struct {
+	mu sync.Mutex
+	// data is a dict rather than a map so SCAN can iterate the keyspace.
+	data *dict[*object]
+	// expires holds the absolute unix time in milliseconds of the volatile
+	// keys, a dict too so they can be drawn at random.
+	expires *dict[int64]
+	// used is the memory of the keys, the objects in dirty may have changed
+	// since it was computed.
+	used  int64
+	dirty map[string]*object
+	// lazy are the options of the keyspace telling which deletions release
+	// large values in the background.
+	lazy *LazyFree
+}
+
+func newShard(lazy *LazyFree) *shard {
+	return &shard{
+		data:    newDict[*object](),
+		expires: newDict[int64](),
+		dirty:   map[string]*object{},
+		lazy:    lazy,
+	}
+}
+
+// shardIndex returns the index of the shard holding the key.
+func (kv *KV) shardIndex(key string) int {
+	if len(kv.shards) == 1 {
+		return 0
+	}
+
+	return int(maphash.String(shardSeed, key) % uint64(len(kv.shards)))
+}
+
+// shard returns the shard holding the key.
+func (kv *KV) shard(key string) *shard {
+	return kv.shards[kv.shardIndex(key)]
+}
+
+// lock locks the shards holding the keys and returns the function unlocking
+// them. The shards are always locked in ascending order so two commands
+// sharing some keys can't deadlock.
+func (kv *KV) lock(keys ...string) func() {
+	if len(keys) == 1 {
+		s := kv.shard(keys[0])
+		s.mu.Lock()
+		return s.mu.Unlock
+	}
+
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		indexes = append(indexes, kv.shardIndex(key))
+	}
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+
+	return kv.lockShards(indexes)
+}
+
+// lockAll locks every shard, for the commands working on the whole keyspace.
+func (kv *KV) lockAll() func() {
+	indexes := make([]int, len(kv.shards))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	return kv.lockShards(indexes)
+}
+
+// lockShards locks the shards at the sorted indexes.
+func (kv *KV) lockShards(indexes []int) func() {
+	for _, i := range indexes {
+		kv.shards[i].mu.Lock()
+	}
+
+	return func() {
+		for i := len(indexes) - 1; i >= 0; i-- {
+			kv.shards[indexes[i]].mu.Unlock()
+		}
+	}
+}
+
+// lockAcross locks the shard of key in kv and the one of otherKey in other,
+// which may be kv itself, and returns the function unlocking them. Distinct
+// keyspaces are locked in the order of their ids.
+func lockAcross(kv *KV, key string, other *KV, otherKey string) func() {
+	if other == kv {
+		return kv.lock(key, otherKey)
+	}
+	if other.id < kv.id {
+		kv, key, other, otherKey = other, otherKey, kv, key
+	}
+	unlock := kv.lock(key)
+	unlockOther := other.lock(otherKey)
+
+	return func() {
+		unlockOther()
+		unlock()
+	}
+}
+
+// randomShard returns a random shard, drawn with a probability proportional
+// to its weight so that a key drawn from it is drawn uniformly over the whole
+// keyspace. It fails when every weight is zero, the caller must hold the lock
+// of every shard.
+func (kv *KV) randomShard(weight func(s *shard) int) (*shard, bool) {
+	total := 0
+	for _, s := range kv.shards {
+		total += weight(s)
+	}
+	if total == 0 {
+		return nil, false
+	}
+
+	n := rand.Intn(total)
+	for _, s := range kv.shards {
+		if n -= weight(s); n < 0 {
+			return s, true
+		}
+	}
+
+	return nil, false
+}
+
+// keyCount and expireCount are the usual weights given to randomShard.
+func keyCount(s *shard) int    { return s.data.Len() }
+func expireCount(s *shard) int { return s.expires.Len() }
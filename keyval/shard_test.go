@@ -0,0 +1,150 @@
+package keyval
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestScanShards checks a SCAN iteration goes through every shard and
+// returns each key, whatever the count.
+func TestScanShards(t *testing.T) {
+	kv := NewKeyVal()
+	for i := 0; i < 1000; i++ {
+		kv.Set([]byte("key:"+strconv.Itoa(i)), []byte("value"))
+	}
+
+	for _, count := range []int{1, 10, 5000} {
+		seen := map[string]bool{}
+		cursor := uint64(0)
+		for {
+			var keys []string
+			cursor, keys = kv.Scan(cursor, "", count, TypeNone)
+			for _, key := range keys {
+				seen[key] = true
+			}
+			if cursor == 0 {
+				break
+			}
+		}
+		if len(seen) != 1000 {
+			t.Fatalf("expected 1000 keys with a count of %d but got %d", count, len(seen))
+		}
+	}
+}
+
+// TestShardsConcurrentCommands runs single and multi key commands from many
+// goroutines, any lost update or deadlock fails it.
+func TestShardsConcurrentCommands(t *testing.T) {
+	kv := NewKeyVal()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				kv.IncrBy("counter:"+strconv.Itoa(i%10), 1)
+				// The keys are given in a different order by each goroutine.
+				if g%2 == 0 {
+					kv.MSet([]string{"a", "1", "b", "2", "c", "3"}, false)
+				} else {
+					kv.MSet([]string{"c", "3", "b", "2", "a", "1"}, false)
+				}
+				kv.Rename("a", "z", false)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for i := 0; i < 10; i++ {
+		v, _, _ := kv.Get([]byte("counter:" + strconv.Itoa(i)))
+		if string(v) != "800" {
+			t.Fatalf("expected counter:%d to be 800 but got %s", i, v)
+		}
+	}
+}
+
+// The benchmarks compare a single shard, every command taking the same lock
+// as before the keyspace was sharded, with the default number of shards.
+var benchShards = []struct {
+	name   string
+	shards int
+}{
+	{"global-lock", 1},
+	{"sharded", DefaultShards},
+}
+
+const benchKeys = 10000
+
+func benchParallel(b *testing.B, setup func(kv *KV), op func(kv *KV, key string)) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key:" + strconv.Itoa(i)
+	}
+
+	for _, bs := range benchShards {
+		b.Run(bs.name, func(b *testing.B) {
+			kv := NewShardedKeyVal(bs.shards)
+			setup(kv)
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := int(next.Add(1)) * 7919
+				for pb.Next() {
+					op(kv, keys[i%benchKeys])
+					i++
+				}
+			})
+		})
+	}
+}
+
+func fill(kv *KV) {
+	for i := 0; i < benchKeys; i++ {
+		kv.Set([]byte("key:"+strconv.Itoa(i)), []byte(strconv.Itoa(i)))
+	}
+}
+
+func BenchmarkParallelGet(b *testing.B) {
+	benchParallel(b, fill, func(kv *KV, key string) {
+		kv.Get([]byte(key))
+	})
+}
+
+func BenchmarkParallelSet(b *testing.B) {
+	value := []byte("value")
+	benchParallel(b, func(*KV) {}, func(kv *KV, key string) {
+		kv.Set([]byte(key), value)
+	})
+}
+
+func BenchmarkParallelIncr(b *testing.B) {
+	benchParallel(b, fill, func(kv *KV, key string) {
+		kv.IncrBy(key, 1)
+	})
+}
+
+// TestRandomShardWeight checks a shard is drawn in proportion to its keys,
+// not as often as the others whatever it holds.
+func TestRandomShardWeight(t *testing.T) {
+	kv := NewShardedKeyVal(2)
+	kv.shards[0].data.Set("lonely", newStringObject([]byte("value")))
+	for i := 0; i < 99; i++ {
+		kv.shards[1].data.Set("crowded:"+strconv.Itoa(i), newStringObject([]byte("value")))
+	}
+
+	drawn := 0
+	for i := 0; i < 10000; i++ {
+		if s, _ := kv.randomShard(keyCount); s == kv.shards[0] {
+			drawn++
+		}
+	}
+	// About 100 draws are expected.
+	if drawn > 500 {
+		t.Fatalf("expected the shard holding 1 key out of 100 to be drawn about 1%% of the time but got %d out of 10000", drawn)
+	}
+	if _, ok := kv.randomShard(expireCount); ok {
+		t.Fatal("expected no shard without volatile keys")
+	}
+}
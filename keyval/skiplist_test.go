@@ -0,0 +1,70 @@
+package keyval
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// TestZSetAgainstMap runs random updates on a ZSet and checks its order, ranks
+// and ranges against a plain map.
+func TestZSetAgainstMap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	z := NewZSet()
+	model := map[string]float64{}
+
+	for i := 0; i < 5000; i++ {
+		member := strconv.Itoa(rnd.Intn(200))
+		if rnd.Intn(3) == 0 {
+			_, ok := model[member]
+			if z.Remove(member) != ok {
+				t.Fatalf("Remove(%s) disagrees with the model", member)
+			}
+			delete(model, member)
+			continue
+		}
+		score := float64(rnd.Intn(50))
+		_, ok := model[member]
+		if z.Add(member, score) == ok {
+			t.Fatalf("Add(%s) disagrees with the model", member)
+		}
+		model[member] = score
+	}
+
+	expected := make([]ZMember, 0, len(model))
+	for member, score := range model {
+		expected = append(expected, ZMember{Member: member, Score: score})
+	}
+	sort.Slice(expected, func(i, j int) bool {
+		a, b := expected[i], expected[j]
+		return a.Score < b.Score || (a.Score == b.Score && a.Member < b.Member)
+	})
+
+	members := z.Members()
+	if len(members) != len(expected) || z.zsl.length != len(expected) {
+		t.Fatalf("expected %d members but got %d", len(expected), len(members))
+	}
+	for i, m := range expected {
+		if members[i] != m {
+			t.Fatalf("expected %v at %d but got %v", m, i, members[i])
+		}
+		if rank, _ := z.Rank(m.Member, false); rank != i {
+			t.Fatalf("expected %s at rank %d but got %d", m.Member, i, rank)
+		}
+	}
+
+	inRange := 0
+	for _, m := range expected {
+		if m.Score > 10 && m.Score <= 20 {
+			inRange++
+		}
+	}
+	r := scoreRange(ScoreBound{Value: 10, Exclusive: true}, ScoreBound{Value: 20})
+	if n := z.count(r); n != inRange {
+		t.Fatalf("expected %d members in (10, 20] but got %d", inRange, n)
+	}
+	if got := z.rangeIn(r, true, 0, -1); len(got) != inRange {
+		t.Fatalf("expected %d members in (10, 20] but got %d", inRange, len(got))
+	}
+}
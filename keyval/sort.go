@@ -0,0 +1,221 @@
+package keyval
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrSortScore is returned by SORT without ALPHA when a weight is not a number.
+var ErrSortScore = errors.New("ERR One or more scores can't be converted into double")
+
+// SortOptions are the options of SORT.
+type SortOptions struct {
+	// By is the pattern of the keys holding the weights of the elements,
+	// empty to weigh the elements themselves. A pattern without a * leaves
+	// the elements in the order of the value.
+	By string
+	// Get are the patterns of the values returned for each element, "#"
+	// being the element itself. Without any the elements are returned.
+	Get []string
+	// Offset and Count are the LIMIT, a negative Count meaning all the
+	// elements past Offset.
+	Offset, Count int
+	Desc          bool
+	// Alpha compares the weights as strings rather than numbers.
+	Alpha bool
+}
+
+// sortElement is an element of the value being sorted and its weight.
+type sortElement struct {
+	value string
+	// weight is nil when the BY key is missing.
+	weight []byte
+	score  float64
+}
+
+// Sort returns the elements of the list, set or sorted set stored at key
+// sorted, or the values of the GET patterns for each of them, nil for the
+// missing ones.
+func (kv *KV) Sort(key string, opts SortOptions) ([][]byte, error) {
+	// The patterns may name any key.
+	defer kv.lockAll()()
+
+	return kv.sort(key, opts, false)
+}
+
+// SortStore is Sort storing the result as a list at destination, the nil
+// values as empty strings, and returning its length. An empty result deletes
+// destination.
+func (kv *KV) SortStore(key, destination string, opts SortOptions) (int, error) {
+	defer kv.lockAll()()
+
+	values, err := kv.sort(key, opts, true)
+	if err != nil {
+		return 0, err
+	}
+	kv.discard(destination)
+	if len(values) == 0 {
+		return 0, nil
+	}
+	list := NewList()
+	for _, value := range values {
+		list.PushTail(string(value))
+	}
+	kv.add(destination, &object{typ: TypeList, value: list})
+
+	return len(values), nil
+}
+
+// sort is Sort, store telling the result is stored. The caller must hold the
+// lock of every shard.
+func (kv *KV) sort(key string, opts SortOptions, store bool) ([][]byte, error) {
+	o := kv.lookup(key)
+	if o != nil && o.typ != TypeList && o.typ != TypeSet && o.typ != TypeZSet {
+		return nil, ErrWrongType
+	}
+
+	dontSort := opts.By != "" && !strings.Contains(opts.By, "*")
+	// Like redis, the members of a set are sorted anyway when stored, their
+	// order would otherwise depend on the layout of the set.
+	if dontSort && o != nil && o.typ == TypeSet && store {
+		dontSort, opts.Alpha, opts.By = false, true, ""
+	}
+
+	var values []string
+	if o != nil {
+		switch v := o.value.(type) {
+		case *List:
+			v.Each(func(_ int, value string) bool {
+				values = append(values, value)
+				return true
+			})
+		case *Set:
+			values = v.Members()
+		case *ZSet:
+			// Unsorted, the members come in the order of their scores,
+			// which DESC reverses.
+			for _, m := range v.rangeByRank(0, -1, dontSort && opts.Desc) {
+				values = append(values, m.Member)
+			}
+		}
+	}
+
+	elements := make([]sortElement, len(values))
+	for i, value := range values {
+		elements[i].value = value
+		if dontSort {
+			continue
+		}
+		elements[i].weight = []byte(value)
+		if opts.By != "" {
+			elements[i].weight = kv.lookupByPattern(opts.By, value)
+		}
+		if opts.Alpha || elements[i].weight == nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(string(elements[i].weight), 64)
+		if err != nil || math.IsNaN(score) {
+			return nil, ErrSortScore
+		}
+		elements[i].score = score
+	}
+	if !dontSort {
+		slices.SortStableFunc(elements, func(a, b sortElement) int {
+			cmp := compareSortElements(a, b, opts)
+			if opts.Desc {
+				return -cmp
+			}
+			return cmp
+		})
+	}
+
+	start, end := max(opts.Offset, 0), len(elements)
+	if opts.Count >= 0 && opts.Count < end-start {
+		end = start + opts.Count
+	}
+	if start >= end {
+		return [][]byte{}, nil
+	}
+
+	ret := make([][]byte, 0, (end-start)*max(len(opts.Get), 1))
+	for _, e := range elements[start:end] {
+		if len(opts.Get) == 0 {
+			ret = append(ret, []byte(e.value))
+		}
+		for _, pattern := range opts.Get {
+			ret = append(ret, kv.lookupByPattern(pattern, e.value))
+		}
+	}
+
+	return ret, nil
+}
+
+// compareSortElements orders the elements by weight. Equal numbers are
+// ordered as strings so the order does not depend on the layout of the value,
+// and missing weights come first with ALPHA.
+func compareSortElements(a, b sortElement, opts SortOptions) int {
+	if !opts.Alpha {
+		switch {
+		case a.score < b.score:
+			return -1
+		case a.score > b.score:
+			return 1
+		}
+		return strings.Compare(a.value, b.value)
+	}
+
+	switch {
+	case a.weight == nil && b.weight == nil:
+		return 0
+	case a.weight == nil:
+		return -1
+	case b.weight == nil:
+		return 1
+	}
+
+	return bytes.Compare(a.weight, b.weight)
+}
+
+// lookupByPattern returns the value the pattern of BY or GET names for the
+// element, nil if there is none. The first * of the pattern is replaced by
+// the element to get the key, which must hold a string, and a -> after it
+// names a field of the hash stored at the key instead. "#" is the element
+// itself. The caller must hold the lock of every shard.
+func (kv *KV) lookupByPattern(pattern, element string) []byte {
+	if pattern == "#" {
+		return []byte(element)
+	}
+	star := strings.IndexByte(pattern, '*')
+	if star < 0 {
+		return nil
+	}
+
+	key, field := pattern, ""
+	if arrow := strings.Index(pattern[star+1:], "->"); arrow >= 0 && star+1+arrow+2 < len(pattern) {
+		key, field = pattern[:star+1+arrow], pattern[star+1+arrow+2:]
+	}
+	key = key[:star] + element + key[star+1:]
+
+	o := kv.lookup(key)
+	switch {
+	case o == nil:
+		return nil
+	case field != "":
+		if o.typ != TypeHash {
+			return nil
+		}
+		value, ok := o.value.(*Hash).Get(field)
+		if !ok {
+			return nil
+		}
+		return []byte(value)
+	case o.typ == TypeString:
+		return o.value.([]byte)
+	default:
+		return nil
+	}
+}
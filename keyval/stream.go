@@ -0,0 +1,505 @@
+package keyval
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+const (
+	// Like redis, the entries of a stream are stored in nodes of at most
+	// this many entries, indexed by the ID of the first one.
+	streamNodeMaxEntries = 100
+	// streamTrimDefaultLimit is how many entries an approximate trimming
+	// removes at most when no LIMIT is given.
+	streamTrimDefaultLimit = 100 * streamNodeMaxEntries
+)
+
+var (
+	ErrStreamIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamExhausted  = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// MaxStreamID is the greatest possible stream ID.
+var MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+
+// StreamID identifies a stream entry: the milliseconds time it was created at
+// and a sequence number for the entries created in the same millisecond.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// String formats the ID the way redis does, <ms>-<seq>.
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Less reports whether the ID sorts before the other.
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// IsZero reports whether the ID is 0-0.
+func (id StreamID) IsZero() bool {
+	return id.Ms == 0 && id.Seq == 0
+}
+
+// Next returns the ID right after this one, false if it is the greatest.
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// StreamEntry is an entry of a stream, its fields and values are interleaved.
+// Fields is nil for entries that were deleted while still pending.
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+// StreamTrim is the trimming of XADD and XTRIM: only keep MaxLen entries, or
+// with ByMinID the entries whose ID is not lower than MinID. An approximate
+// trimming only removes whole nodes and at most Limit entries, 0 meaning no
+// limit and a negative one the default.
+type StreamTrim struct {
+	MaxLen  int64
+	ByMinID bool
+	MinID   StreamID
+	Approx  bool
+	Limit   int64
+}
+
+// XAddArgs are the arguments of XADD besides the key and the fields. With
+// AutoID the ID is generated, with AutoSeq only its sequence number is.
+type XAddArgs struct {
+	ID         StreamID
+	AutoID     bool
+	AutoSeq    bool
+	NoMkStream bool
+	Trim       *StreamTrim
+}
+
+// StreamRead is what was read from one stream by XREAD or XREADGROUP.
+type StreamRead struct {
+	Key     string
+	Entries []StreamEntry
+}
+
+// Stream is the value of redis streams: nodes of entries indexed by a radix
+// tree, along with the consumer groups.
+type Stream struct {
+	index        *rax[*streamNode]
+	length       int
+	lastID       StreamID
+	firstID      StreamID
+	maxDeletedID StreamID
+	entriesAdded int64
+	groups       map[string]*ConsumerGroup
+}
+
+// streamNode holds consecutive entries of a stream, in ID order.
+type streamNode struct {
+	entries []StreamEntry
+}
+
+func NewStream() *Stream {
+	return &Stream{
+		index:  newRax[*streamNode](),
+		groups: map[string]*ConsumerGroup{},
+	}
+}
+
+// clone returns a copy of the stream and its consumer groups. The fields of
+// the entries are never modified and can be shared.
+func (s *Stream) clone() *Stream {
+	c := NewStream()
+	c.length, c.lastID, c.firstID = s.length, s.lastID, s.firstID
+	c.maxDeletedID, c.entriesAdded = s.maxDeletedID, s.entriesAdded
+	s.index.Ascend(StreamID{}, func(id StreamID, n *streamNode) bool {
+		c.index.Insert(id, &streamNode{entries: slices.Clone(n.entries)})
+		return true
+	})
+	for name, g := range s.groups {
+		c.groups[name] = g.clone()
+	}
+
+	return c
+}
+
+// Len returns the number of entries of the stream.
+func (s *Stream) Len() int {
+	return s.length
+}
+
+// Range returns the entries with an ID between start and end, from the end
+// if rev is set, and at most count of them unless count is not positive.
+func (s *Stream) Range(start, end StreamID, rev bool, count int) []StreamEntry {
+	ret := []StreamEntry{}
+	if end.Less(start) {
+		return ret
+	}
+
+	if rev {
+		s.index.Descend(end, func(_ StreamID, n *streamNode) bool {
+			for i := len(n.entries) - 1; i >= 0; i-- {
+				e := n.entries[i]
+				if end.Less(e.ID) {
+					continue
+				}
+				if e.ID.Less(start) {
+					return false
+				}
+				ret = append(ret, e)
+				if len(ret) == count {
+					return false
+				}
+			}
+			return true
+		})
+		return ret
+	}
+
+	// The node holding start is the last one indexed by a lower ID.
+	from := start
+	s.index.Descend(start, func(id StreamID, _ *streamNode) bool {
+		from = id
+		return false
+	})
+	s.index.Ascend(from, func(_ StreamID, n *streamNode) bool {
+		for _, e := range n.entries {
+			if e.ID.Less(start) {
+				continue
+			}
+			if end.Less(e.ID) {
+				return false
+			}
+			ret = append(ret, e)
+			if len(ret) == count {
+				return false
+			}
+		}
+		return true
+	})
+
+	return ret
+}
+
+// Get returns the entry with the ID.
+func (s *Stream) Get(id StreamID) (StreamEntry, bool) {
+	entries := s.Range(id, id, false, 1)
+	if len(entries) == 0 {
+		return StreamEntry{}, false
+	}
+	return entries[0], true
+}
+
+// append adds the entry at the end of the stream, its ID must be greater than the last one.
+func (s *Stream) append(id StreamID, fields []string) {
+	_, last, ok := s.index.Last()
+	if !ok || len(last.entries) >= streamNodeMaxEntries {
+		last = &streamNode{}
+		s.index.Insert(id, last)
+	}
+	last.entries = append(last.entries, StreamEntry{ID: id, Fields: fields})
+
+	if s.length == 0 {
+		s.firstID = id
+	}
+	s.length++
+	s.lastID = id
+	s.entriesAdded++
+}
+
+// delete removes the entry with the ID and reports whether it was there.
+func (s *Stream) delete(id StreamID) bool {
+	var key StreamID
+	var node *streamNode
+	s.index.Descend(id, func(k StreamID, n *streamNode) bool {
+		key, node = k, n
+		return false
+	})
+	if node == nil {
+		return false
+	}
+	i := sort.Search(len(node.entries), func(i int) bool { return !node.entries[i].ID.Less(id) })
+	if i == len(node.entries) || node.entries[i].ID != id {
+		return false
+	}
+
+	node.entries = append(node.entries[:i:i], node.entries[i+1:]...)
+	if len(node.entries) == 0 {
+		s.index.Remove(key)
+	}
+	s.length--
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	if id == s.firstID {
+		s.updateFirstID()
+	}
+
+	return true
+}
+
+// trim removes entries from the head of the stream as the trimming says and
+// returns how many.
+func (s *Stream) trim(t StreamTrim) int {
+	limit := t.Limit
+	if !t.Approx {
+		limit = 0
+	} else if limit < 0 {
+		limit = streamTrimDefaultLimit
+	}
+
+	removed := 0
+	for {
+		key, node, ok := s.index.First()
+		if !ok {
+			break
+		}
+		// How many entries of the node have to go.
+		n := 0
+		if t.ByMinID {
+			n = sort.Search(len(node.entries), func(i int) bool { return !node.entries[i].ID.Less(t.MinID) })
+		} else if excess := int64(s.length) - t.MaxLen; excess > 0 {
+			n = int(min(excess, int64(len(node.entries))))
+		}
+		if n == 0 || (limit > 0 && int64(removed+n) > limit) {
+			break
+		}
+
+		if n == len(node.entries) {
+			s.index.Remove(key)
+		} else if t.Approx {
+			break
+		} else {
+			node.entries = node.entries[n:]
+		}
+		s.length -= n
+		removed += n
+	}
+	if removed > 0 {
+		s.updateFirstID()
+	}
+
+	return removed
+}
+
+// updateFirstID records the ID of the first entry, 0-0 for an empty stream.
+func (s *Stream) updateFirstID() {
+	s.firstID = StreamID{}
+	if _, first, ok := s.index.First(); ok {
+		s.firstID = first.entries[0].ID
+	}
+}
+
+// nextID returns the ID of a new entry as XADD asks for it.
+func (s *Stream) nextID(args XAddArgs) (StreamID, error) {
+	switch {
+	case args.AutoID:
+		if ms := uint64(Now()); ms > s.lastID.Ms {
+			return StreamID{Ms: ms}, nil
+		}
+		id, ok := s.lastID.Next()
+		if !ok {
+			return StreamID{}, ErrStreamExhausted
+		}
+		return id, nil
+	case args.AutoSeq:
+		if args.ID.Ms > s.lastID.Ms {
+			return StreamID{Ms: args.ID.Ms}, nil
+		}
+		if args.ID.Ms < s.lastID.Ms || s.lastID.Seq == math.MaxUint64 {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return StreamID{Ms: s.lastID.Ms, Seq: s.lastID.Seq + 1}, nil
+	default:
+		if !s.lastID.Less(args.ID) {
+			return StreamID{}, ErrStreamIDTooSmall
+		}
+		return args.ID, nil
+	}
+}
+
+// hasTombstones reports whether entries between start and end may have been deleted.
+func (s *Stream) hasTombstones(start, end StreamID) bool {
+	if s.length == 0 || s.maxDeletedID.IsZero() || s.maxDeletedID.Less(s.firstID) {
+		return false
+	}
+	return !s.maxDeletedID.Less(start) && !end.Less(s.maxDeletedID)
+}
+
+// estimateEntriesRead estimates how many entries were added up to the ID,
+// -1 if it can't be known because of deletions.
+func (s *Stream) estimateEntriesRead(id StreamID) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	if s.length == 0 && !s.lastID.Less(id) {
+		return s.entriesAdded
+	}
+	if id == s.lastID {
+		return s.entriesAdded
+	}
+	if s.lastID.Less(id) {
+		return -1
+	}
+	if s.maxDeletedID.IsZero() || s.maxDeletedID.Less(s.firstID) {
+		if id.Less(s.firstID) {
+			return s.entriesAdded - int64(s.length)
+		}
+		if id == s.firstID {
+			return s.entriesAdded - int64(s.length) + 1
+		}
+	}
+	return -1
+}
+
+func newStreamObject() *object {
+	return &object{typ: TypeStream, value: NewStream()}
+}
+
+// XAdd appends an entry to the stream stored at key, creating it unless
+// NoMkStream is set, and trims it if asked to. It returns the ID of the new
+// entry, and false if the stream did not exist with NoMkStream.
+func (kv *KV) XAdd(key string, args XAddArgs, fields []string) (StreamID, bool, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if s == nil && args.NoMkStream {
+		return StreamID{}, false, nil
+	}
+
+	next := NewStream()
+	if s != nil {
+		next = s
+	}
+	id, err := next.nextID(args)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if s == nil {
+		kv.add(key, &object{typ: TypeStream, value: next})
+	}
+	next.append(id, fields)
+	if args.Trim != nil {
+		next.trim(*args.Trim)
+	}
+	// Readers blocked on the stream wait for new entries, not for its creation.
+	kv.signalReady(key)
+
+	return id, true, nil
+}
+
+// XLen returns the number of entries of the stream stored at key.
+func (kv *KV) XLen(key string) (int, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+
+	return s.Len(), nil
+}
+
+// XRange returns the entries of the stream stored at key with an ID between
+// start and end, see Stream.Range.
+func (kv *KV) XRange(key string, start, end StreamID, rev bool, count int) ([]StreamEntry, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil {
+		return []StreamEntry{}, err
+	}
+
+	return s.Range(start, end, rev, count), nil
+}
+
+// XDel deletes the entries from the stream stored at key and returns how many
+// there were. Unlike other types, an emptied stream is not deleted.
+func (kv *KV) XDel(key string, ids []StreamID) (int, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, id := range ids {
+		if s.delete(id) {
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// XTrim trims the stream stored at key and returns the number of entries removed.
+func (kv *KV) XTrim(key string, trim StreamTrim) (int, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil {
+		return 0, err
+	}
+
+	return s.trim(trim), nil
+}
+
+// XLastID returns the ID of the last entry added to the stream stored at
+// key, that's what $ stands for in XREAD. It is 0-0 for a missing stream.
+func (kv *KV) XLastID(key string) (StreamID, error) {
+	defer kv.lock(key)()
+
+	s, err := kv.stream(key)
+	if err != nil || s == nil {
+		return StreamID{}, err
+	}
+
+	return s.lastID, nil
+}
+
+// XRead returns up to count entries with an ID greater than the given one
+// from each of the streams stored at keys, skipping the streams without any.
+func (kv *KV) XRead(keys []string, ids []StreamID, count int) ([]StreamRead, error) {
+	defer kv.lock(keys...)()
+
+	ret := []StreamRead{}
+	for i, key := range keys {
+		s, err := kv.stream(key)
+		if err != nil {
+			return nil, err
+		}
+		start, ok := ids[i].Next()
+		if s == nil || !ok {
+			continue
+		}
+		if entries := s.Range(start, MaxStreamID, false, count); len(entries) > 0 {
+			ret = append(ret, StreamRead{Key: key, Entries: entries})
+		}
+	}
+
+	return ret, nil
+}
+
+// stream returns the stream stored at key, the caller must hold the lock.
+func (kv *KV) stream(key string) (*Stream, error) {
+	o, err := kv.lookupType(key, TypeStream)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	return o.value.(*Stream), nil
+}
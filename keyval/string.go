@@ -0,0 +1,358 @@
+package keyval
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// stringMaxSize is the biggest string redis accepts, its proto-max-bulk-len.
+const stringMaxSize = 512 << 20
+
+var (
+	ErrNotInteger    = errors.New("ERR value is not an integer or out of range")
+	ErrNotFloat      = errors.New("ERR value is not a valid float")
+	ErrStringTooLong = errors.New("ERR string exceeds maximum allowed size (proto-max-bulk-len)")
+	// ErrLCSTooBig is returned when the table LCS needs would not fit in memory.
+	ErrLCSTooBig = errors.New("ERR Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+)
+
+// MGet returns the values of the keys, nil for the keys that are missing or
+// do not hold a string.
+func (kv *KV) MGet(keys []string) [][]byte {
+	defer kv.lock(keys...)()
+
+	ret := make([][]byte, len(keys))
+	for i, key := range keys {
+		if o := kv.lookup(key); o != nil && o.typ == TypeString {
+			ret[i] = o.value.([]byte)
+		}
+	}
+
+	return ret
+}
+
+// MSet sets the interleaved keys and values, discarding their time to live.
+// With nx nothing is set if any of the keys exists, it reports whether the
+// keys were set.
+func (kv *KV) MSet(pairs []string, nx bool) bool {
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+	}
+	defer kv.lock(keys...)()
+
+	if nx {
+		for i := 0; i < len(pairs); i += 2 {
+			if kv.lookup(pairs[i]) != nil {
+				return false
+			}
+		}
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		kv.discard(pairs[i])
+		kv.add(pairs[i], newStringObject([]byte(pairs[i+1])))
+	}
+
+	return true
+}
+
+// GetDel returns the value of the key and deletes it.
+func (kv *KV) GetDel(key string) ([]byte, bool, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil || o == nil {
+		return nil, false, err
+	}
+	kv.remove(key)
+
+	return o.value.([]byte), true, nil
+}
+
+// GetEx returns the value of the key and sets its absolute expiry in unix
+// milliseconds, or removes it with persist. A zero expireAt leaves the time to
+// live alone and one in the past deletes the key.
+func (kv *KV) GetEx(key string, expireAt int64, persist bool) ([]byte, bool, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil || o == nil {
+		return nil, false, err
+	}
+	switch {
+	case persist:
+		kv.persist(key)
+	case expireAt != 0 && expireAt <= Now():
+		kv.shard(key).expire(key)
+	case expireAt != 0:
+		kv.setExpire(key, expireAt)
+	}
+
+	return o.value.([]byte), true, nil
+}
+
+// Append appends the value to the string stored at key, creating it if
+// needed, and returns the new length.
+func (kv *KV) Append(key string, value []byte) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil {
+		return 0, err
+	}
+	if o == nil {
+		kv.add(key, newStringObject(append([]byte{}, value...)))
+		return len(value), nil
+	}
+
+	old := o.value.([]byte)
+	if len(old)+len(value) > stringMaxSize {
+		return 0, ErrStringTooLong
+	}
+	// Keys never share their values, so the string can grow in place.
+	o.value = append(old, value...)
+
+	return len(old) + len(value), nil
+}
+
+// StrLen returns the length of the string stored at key.
+func (kv *KV) StrLen(key string) (int, error) {
+	value, _, err := kv.Get([]byte(key))
+	return len(value), err
+}
+
+// GetRange returns the substring between the start and end offsets, both
+// inclusive, negative offsets counting from the end of the string.
+func (kv *KV) GetRange(key string, start, end int64) ([]byte, error) {
+	value, _, err := kv.Get([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	n := int64(len(value))
+	if start < 0 && end < 0 && start > end {
+		return []byte{}, nil
+	}
+	if start < 0 {
+		start = max(n+start, 0)
+	}
+	if end < 0 {
+		end = max(n+end, 0)
+	}
+	end = min(end, n-1)
+	if n == 0 || start > end {
+		return []byte{}, nil
+	}
+
+	return value[start : end+1], nil
+}
+
+// SetRange overwrites the string stored at key from offset with the value,
+// padding it with zero bytes if needed, and returns the new length. A missing
+// key is created unless the value is empty.
+func (kv *KV) SetRange(key string, offset int64, value []byte) (int, error) {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil {
+		return 0, err
+	}
+	var old []byte
+	if o != nil {
+		old = o.value.([]byte)
+	}
+	if len(value) == 0 {
+		return len(old), nil
+	}
+	// Written like checkStringLength in redis, so a huge offset can't overflow.
+	if offset > stringMaxSize-int64(len(value)) {
+		return 0, ErrStringTooLong
+	}
+
+	b := make([]byte, max(len(old), int(offset)+len(value)))
+	copy(b, old)
+	copy(b[offset:], value)
+	if o == nil {
+		kv.add(key, newStringObject(b))
+	} else {
+		o.value = b
+	}
+
+	return len(b), nil
+}
+
+// IncrBy adds delta to the integer stored at key, a missing key counting as
+// 0, and returns the new value.
+func (kv *KV) IncrBy(key string, delta int64) (int64, error) {
+	var n int64
+	err := kv.updateString(key, func(old []byte, ok bool) ([]byte, error) {
+		var cur int64
+		if ok {
+			var valid bool
+			if cur, valid = parseCanonicalInt(string(old)); !valid {
+				return nil, ErrNotInteger
+			}
+		}
+		var err error
+		if n, err = addInt64(cur, delta); err != nil {
+			return nil, err
+		}
+		return strconv.AppendInt(nil, n, 10), nil
+	})
+
+	return n, err
+}
+
+// IncrByFloat adds delta to the number stored at key, a missing key counting
+// as 0, and returns the new value as it was stored.
+func (kv *KV) IncrByFloat(key string, delta float64) ([]byte, error) {
+	var ret []byte
+	err := kv.updateString(key, func(old []byte, ok bool) ([]byte, error) {
+		var cur float64
+		if ok {
+			var err error
+			if cur, err = parseFloat(string(old)); err != nil {
+				return nil, ErrNotFloat
+			}
+		}
+		n := cur + delta
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, ErrNaN
+		}
+		ret = []byte(formatFloat(n))
+		return ret, nil
+	})
+
+	return ret, err
+}
+
+// updateString replaces the string stored at key by what fn makes of it, the
+// arithmetic commands all go through it. fn is told whether the key exists and
+// the key keeps its time to live.
+func (kv *KV) updateString(key string, fn func(old []byte, ok bool) ([]byte, error)) error {
+	defer kv.lock(key)()
+
+	o, err := kv.lookupType(key, TypeString)
+	if err != nil {
+		return err
+	}
+	var old []byte
+	if o != nil {
+		old = o.value.([]byte)
+	}
+	value, err := fn(old, o != nil)
+	if err != nil {
+		return err
+	}
+	if o == nil {
+		kv.add(key, newStringObject(value))
+	} else {
+		o.value = value
+	}
+
+	return nil
+}
+
+// addInt64 adds delta to n, failing instead of overflowing. All the integer
+// increments, whatever the type they work on, go through it.
+func addInt64(n, delta int64) (int64, error) {
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, ErrOverflow
+	}
+	return n + delta, nil
+}
+
+// LCSMatch is a range of the longest common subsequence, the offsets of its
+// first and last bytes in each string.
+type LCSMatch struct {
+	A, B [2]int
+	Len  int
+}
+
+// LCS computes the longest common subsequence of the strings stored at the
+// keys, missing keys being empty strings. The matching ranges are returned
+// from the end of the strings, like redis does.
+func (kv *KV) LCS(key1, key2 string) ([]byte, []LCSMatch, error) {
+	defer kv.lock(key1, key2)()
+
+	values := [2][]byte{}
+	for i, key := range []string{key1, key2} {
+		o := kv.lookup(key)
+		if o != nil && o.typ != TypeString {
+			return nil, nil, errors.New("WRONGTYPE The specified keys must contain string values")
+		}
+		if o != nil {
+			values[i] = o.value.([]byte)
+		}
+	}
+
+	return lcs(values[0], values[1])
+}
+
+// lcs is the dynamic programming LCS of redis: a table of the LCS lengths of
+// every pair of prefixes, walked back from the end to build the sequence.
+func lcs(a, b []byte) ([]byte, []LCSMatch, error) {
+	alen, blen := len(a), len(b)
+	if uint64(alen+1)*uint64(blen+1) > stringMaxSize/4 {
+		return nil, nil, ErrLCSTooBig
+	}
+
+	width := blen + 1
+	dp := make([]uint32, (alen+1)*width)
+	for i := 1; i <= alen; i++ {
+		for j := 1; j <= blen; j++ {
+			if a[i-1] == b[j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j-1] + 1
+			} else {
+				dp[i*width+j] = max(dp[(i-1)*width+j], dp[i*width+j-1])
+			}
+		}
+	}
+
+	idx := int(dp[alen*width+blen])
+	seq := make([]byte, idx)
+	matches := []LCSMatch{}
+	// A start of alen means no range is being built.
+	aStart, aEnd, bStart, bEnd := alen, 0, 0, 0
+	for i, j := alen, blen; i > 0 && j > 0; {
+		emit := false
+		if a[i-1] == b[j-1] {
+			seq[idx-1] = a[i-1]
+			switch {
+			case aStart == alen:
+				aStart, aEnd, bStart, bEnd = i-1, i-1, j-1, j-1
+			case aStart == i && bStart == j:
+				aStart--
+				bStart--
+			default:
+				emit = true
+			}
+			if aStart == 0 || bStart == 0 {
+				emit = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != alen {
+				emit = true
+			}
+		}
+		if emit {
+			matches = append(matches, LCSMatch{
+				A:   [2]int{aStart, aEnd},
+				B:   [2]int{bStart, bEnd},
+				Len: aEnd - aStart + 1,
+			})
+			aStart = alen
+		}
+	}
+
+	return seq, matches, nil
+}
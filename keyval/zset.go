@@ -0,0 +1,804 @@
+package keyval
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	// Like redis, small sorted sets are reported as listpacks by OBJECT ENCODING.
+	zsetMaxListpackEntries = 128
+	zsetMaxListpackValue   = 64
+)
+
+var ErrScoreNaN = errors.New("ERR resulting score is not a number (NaN)")
+
+// ZSet is the value of redis sorted sets: a dict from members to scores for
+// the lookups and a skiplist ordered by score for the ranges.
+type ZSet struct {
+	dict *dict[float64]
+	zsl  *skiplist
+}
+
+// ZMember is a member of a sorted set with its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ScoreBound is an end of a score range, -inf and +inf are valid values.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is an end of a lexicographical range, Inf is -1 for "-" and 1 for
+// "+", in which case Value is unused.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// ZRangeBy tells what the ends of a ZRangeSpec are.
+type ZRangeBy int
+
+const (
+	ZRangeByRank ZRangeBy = iota
+	ZRangeByScore
+	ZRangeByLex
+)
+
+// ZRangeSpec describes a range of a sorted set as given to ZRANGE. Only the
+// ends matching By are used, and Count is negative when there is no LIMIT.
+type ZRangeSpec struct {
+	By             ZRangeBy
+	Rev            bool
+	Start, Stop    int64
+	Min, Max       ScoreBound
+	LexMin, LexMax LexBound
+	Offset, Count  int64
+}
+
+// ZAddOptions are the flags of ZADD.
+type ZAddOptions struct {
+	NX, XX, GT, LT, CH bool
+}
+
+// ZAggregate is how ZUNION and ZINTER combine the scores of a member.
+type ZAggregate int
+
+const (
+	AggregateSum ZAggregate = iota
+	AggregateMin
+	AggregateMax
+)
+
+func NewZSet() *ZSet {
+	return &ZSet{dict: newDict[float64](), zsl: newSkiplist()}
+}
+
+func (z *ZSet) clone() *ZSet {
+	c := NewZSet()
+	for _, m := range z.Members() {
+		c.Add(m.Member, m.Score)
+	}
+
+	return c
+}
+
+func newZSetObject() *object {
+	return &object{typ: TypeZSet, value: NewZSet()}
+}
+
+// Len returns the number of members of the sorted set.
+func (z *ZSet) Len() int {
+	return z.dict.Len()
+}
+
+// Score returns the score of the member.
+func (z *ZSet) Score(member string) (float64, bool) {
+	return z.dict.Get(member)
+}
+
+// Add sets the score of the member and reports whether it was added.
+func (z *ZSet) Add(member string, score float64) bool {
+	if cur, ok := z.dict.Get(member); ok {
+		if cur != score {
+			z.zsl.delete(cur, member)
+			z.zsl.insert(score, member)
+			z.dict.Set(member, score)
+		}
+		return false
+	}
+
+	z.zsl.insert(score, member)
+	z.dict.Set(member, score)
+
+	return true
+}
+
+// Remove deletes the member and reports whether it was there.
+func (z *ZSet) Remove(member string) bool {
+	score, ok := z.dict.Delete(member)
+	if ok {
+		z.zsl.delete(score, member)
+	}
+	return ok
+}
+
+// Rank returns the 0-based rank of the member, from the highest score if rev is set.
+func (z *ZSet) Rank(member string, rev bool) (int, bool) {
+	score, ok := z.dict.Get(member)
+	if !ok {
+		return 0, false
+	}
+
+	rank := z.zsl.rank(score, member)
+	if rev {
+		return z.Len() - rank, true
+	}
+	return rank - 1, true
+}
+
+// Members returns all the members in order.
+func (z *ZSet) Members() []ZMember {
+	return z.rangeByRank(0, -1, false)
+}
+
+// Random returns a random member, the sorted set must not be empty.
+func (z *ZSet) Random() ZMember {
+	member, score, _ := z.dict.Random()
+	return ZMember{Member: member, Score: score}
+}
+
+// Range returns the members in the range, in order.
+func (z *ZSet) Range(spec ZRangeSpec) []ZMember {
+	switch spec.By {
+	case ZRangeByScore:
+		return z.rangeIn(scoreRange(spec.Min, spec.Max), spec.Rev, spec.Offset, spec.Count)
+	case ZRangeByLex:
+		return z.rangeIn(lexRange(spec.LexMin, spec.LexMax), spec.Rev, spec.Offset, spec.Count)
+	default:
+		return z.rangeByRank(spec.Start, spec.Stop, spec.Rev)
+	}
+}
+
+// Pop removes and returns up to count members with the lowest scores, or the
+// highest ones if max is set.
+func (z *ZSet) Pop(max bool, count int) []ZMember {
+	ret := []ZMember{}
+	for len(ret) < count && z.Len() > 0 {
+		x := z.zsl.first()
+		if max {
+			x = z.zsl.tail
+		}
+		ret = append(ret, ZMember{Member: x.member, Score: x.score})
+		z.Remove(x.member)
+	}
+
+	return ret
+}
+
+// encoding returns the name redis gives to the encoding of a sorted set this big.
+func (z *ZSet) encoding() string {
+	if z.Len() > zsetMaxListpackEntries {
+		return "skiplist"
+	}
+	for x := z.zsl.first(); x != nil; x = x.level[0].forward {
+		if len(x.member) > zsetMaxListpackValue {
+			return "skiplist"
+		}
+	}
+	return "listpack"
+}
+
+// rangeByRank returns the members between the two ranks, negative ones
+// counting from the end, like ZRANGE without BYSCORE nor BYLEX.
+func (z *ZSet) rangeByRank(start, stop int64, rev bool) []ZMember {
+	n := int64(z.Len())
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= n {
+		return []ZMember{}
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+
+	ret := make([]ZMember, 0, stop-start+1)
+	x := z.zsl.byRank(int(start + 1))
+	if rev {
+		x = z.zsl.byRank(int(n - start))
+	}
+	for i := start; i <= stop; i++ {
+		ret = append(ret, ZMember{Member: x.member, Score: x.score})
+		if rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+
+	return ret
+}
+
+// rangeIn returns the members in the range, skipping offset of them and
+// returning at most count unless it is negative.
+func (z *ZSet) rangeIn(r zrange, rev bool, offset, count int64) []ZMember {
+	ret := []ZMember{}
+	if offset < 0 {
+		return ret
+	}
+
+	x := z.zsl.firstInRange(r)
+	if rev {
+		x = z.zsl.lastInRange(r)
+	}
+	for ; x != nil && count != 0; count-- {
+		if (rev && !r.gteMin(x)) || (!rev && !r.lteMax(x)) {
+			break
+		}
+		if offset > 0 {
+			offset--
+			count++
+		} else {
+			ret = append(ret, ZMember{Member: x.member, Score: x.score})
+		}
+		if rev {
+			x = x.backward
+		} else {
+			x = x.level[0].forward
+		}
+	}
+
+	return ret
+}
+
+// count returns the number of members in the range.
+func (z *ZSet) count(r zrange) int {
+	first := z.zsl.firstInRange(r)
+	if first == nil {
+		return 0
+	}
+	last := z.zsl.lastInRange(r)
+
+	return z.zsl.rank(last.score, last.member) - z.zsl.rank(first.score, first.member) + 1
+}
+
+// scoreRange returns the range of the nodes with a score between min and max.
+func scoreRange(min, max ScoreBound) zrange {
+	return zrange{
+		gteMin: func(n *skiplistNode) bool {
+			if min.Exclusive {
+				return n.score > min.Value
+			}
+			return n.score >= min.Value
+		},
+		lteMax: func(n *skiplistNode) bool {
+			if max.Exclusive {
+				return n.score < max.Value
+			}
+			return n.score <= max.Value
+		},
+	}
+}
+
+// lexRange returns the range of the nodes with a member between min and max,
+// which only makes sense when all the members have the same score.
+func lexRange(min, max LexBound) zrange {
+	return zrange{
+		gteMin: func(n *skiplistNode) bool {
+			switch {
+			case min.Inf != 0:
+				return min.Inf < 0
+			case min.Exclusive:
+				return n.member > min.Value
+			default:
+				return n.member >= min.Value
+			}
+		},
+		lteMax: func(n *skiplistNode) bool {
+			switch {
+			case max.Inf != 0:
+				return max.Inf > 0
+			case max.Exclusive:
+				return n.member < max.Value
+			default:
+				return n.member <= max.Value
+			}
+		},
+	}
+}
+
+// ZAdd sets the scores of the members of the sorted set stored at key,
+// creating it if needed, as restricted by the options. It returns the number
+// of members added, plus the number of scores updated with CH.
+func (kv *KV) ZAdd(key string, opts ZAddOptions, members []ZMember) (int, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil {
+		return 0, err
+	}
+	created := z == nil
+	if created {
+		z = NewZSet()
+	}
+
+	added, updated := 0, 0
+	for _, m := range members {
+		cur, ok := z.Score(m.Member)
+		switch {
+		case !ok && !opts.XX:
+			z.Add(m.Member, m.Score)
+			added++
+		case ok && !opts.NX && m.Score != cur && zaddAllowed(opts, cur, m.Score):
+			z.Add(m.Member, m.Score)
+			updated++
+		}
+	}
+	if created && z.Len() > 0 {
+		kv.add(key, &object{typ: TypeZSet, value: z})
+	}
+
+	if opts.CH {
+		return added + updated, nil
+	}
+	return added, nil
+}
+
+// ZIncrBy increments the score of the member of the sorted set stored at key,
+// as ZADD INCR does. It returns the new score, unless the options prevented
+// the update.
+func (kv *KV) ZIncrBy(key string, opts ZAddOptions, member string, increment float64) (float64, bool, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	var cur float64
+	ok := false
+	if z != nil {
+		cur, ok = z.Score(member)
+	}
+	if (ok && opts.NX) || (!ok && opts.XX) {
+		return 0, false, nil
+	}
+	score := cur + increment
+	if math.IsNaN(score) {
+		return 0, false, ErrScoreNaN
+	}
+	if ok && !zaddAllowed(opts, cur, score) {
+		return 0, false, nil
+	}
+
+	if z == nil {
+		o := newZSetObject()
+		kv.add(key, o)
+		z = o.value.(*ZSet)
+	}
+	z.Add(member, score)
+
+	return score, true, nil
+}
+
+// zaddAllowed reports whether the GT and LT options allow to update the score.
+func zaddAllowed(opts ZAddOptions, cur, score float64) bool {
+	return !(opts.GT && score <= cur) && !(opts.LT && score >= cur)
+}
+
+// ZCard returns the number of members of the sorted set stored at key.
+func (kv *KV) ZCard(key string) (int, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	return z.Len(), nil
+}
+
+// ZMScore returns the scores of the members of the sorted set stored at key,
+// found tells which members exist.
+func (kv *KV) ZMScore(key string, members []string) ([]float64, []bool, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scores := make([]float64, len(members))
+	found := make([]bool, len(members))
+	if z != nil {
+		for i, member := range members {
+			scores[i], found[i] = z.Score(member)
+		}
+	}
+
+	return scores, found, nil
+}
+
+// ZRank returns the rank and the score of the member of the sorted set stored
+// at key, ranking from the highest score if rev is set.
+func (kv *KV) ZRank(key, member string, rev bool) (int, float64, bool, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, 0, false, err
+	}
+	rank, ok := z.Rank(member, rev)
+	score, _ := z.Score(member)
+
+	return rank, score, ok, nil
+}
+
+// ZRange returns the members in the range of the sorted set stored at key.
+func (kv *KV) ZRange(key string, spec ZRangeSpec) ([]ZMember, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return []ZMember{}, err
+	}
+
+	return z.Range(spec), nil
+}
+
+// ZRangeStore stores the members in the range of the sorted set stored at
+// source as a new sorted set at destination and returns its size.
+func (kv *KV) ZRangeStore(destination, source string, spec ZRangeSpec) (int, error) {
+	defer kv.lock(destination, source)()
+
+	z, err := kv.zset(source)
+	if err != nil {
+		return 0, err
+	}
+	var members []ZMember
+	if z != nil {
+		members = z.Range(spec)
+	}
+
+	kv.storeZSet(destination, members)
+
+	return len(members), nil
+}
+
+// ZCount returns the number of members of the sorted set stored at key with a
+// score between min and max.
+func (kv *KV) ZCount(key string, min, max ScoreBound) (int, error) {
+	return kv.zcount(key, scoreRange(min, max))
+}
+
+// ZLexCount returns the number of members of the sorted set stored at key
+// between min and max.
+func (kv *KV) ZLexCount(key string, min, max LexBound) (int, error) {
+	return kv.zcount(key, lexRange(min, max))
+}
+
+func (kv *KV) zcount(key string, r zrange) (int, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	return z.count(r), nil
+}
+
+// ZRem removes the members from the sorted set stored at key and returns how
+// many were there. An emptied sorted set is deleted.
+func (kv *KV) ZRem(key string, members []string) (int, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, member := range members {
+		if z.Remove(member) {
+			removed++
+		}
+	}
+	if z.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return removed, nil
+}
+
+// ZRemRange removes the members in the range from the sorted set stored at
+// key and returns how many there were. An emptied sorted set is deleted.
+func (kv *KV) ZRemRange(key string, spec ZRangeSpec) (int, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, err
+	}
+
+	members := z.Range(spec)
+	for _, m := range members {
+		z.Remove(m.Member)
+	}
+	if z.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return len(members), nil
+}
+
+// ZPop removes and returns up to count members of the sorted set stored at
+// key, those with the lowest scores or the highest ones if max is set. An
+// emptied sorted set is deleted.
+func (kv *KV) ZPop(key string, max bool, count int) ([]ZMember, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return []ZMember{}, err
+	}
+
+	popped := z.Pop(max, count)
+	if z.Len() == 0 {
+		kv.remove(key)
+	}
+
+	return popped, nil
+}
+
+// ZRandMember draws random members of the sorted set stored at key with
+// their scores, see randomSample for the meaning of count and fn.
+func (kv *KV) ZRandMember(key string, count int, fn func(n int, batch []ZMember) error) error {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil {
+		return err
+	}
+	if z == nil {
+		return fn(0, nil)
+	}
+
+	random := func() string {
+		return z.Random().Member
+	}
+	return randomSample(count, z.Len(), random, z.dict.Keys, func(n int, members []string) error {
+		batch := make([]ZMember, 0, len(members))
+		for _, member := range members {
+			score, _ := z.Score(member)
+			batch = append(batch, ZMember{Member: member, Score: score})
+		}
+		return fn(n, batch)
+	})
+}
+
+// ZSetOp runs the set algebra operation over the sorted sets stored at keys,
+// plain sets counting as sorted sets with all the scores set to 1. The scores
+// are multiplied by the weights, if any, and combined with aggregate.
+func (kv *KV) ZSetOp(op SetOp, keys []string, weights []float64, aggregate ZAggregate) ([]ZMember, error) {
+	defer kv.lock(keys...)()
+
+	res, err := kv.zsetOp(op, keys, weights, aggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Members(), nil
+}
+
+// ZSetOpStore is ZSetOp but stores the result at destination, replacing
+// whatever was there, and returns its size.
+func (kv *KV) ZSetOpStore(op SetOp, destination string, keys []string, weights []float64, aggregate ZAggregate) (int, error) {
+	defer kv.lock(append([]string{destination}, keys...)...)()
+
+	res, err := kv.zsetOp(op, keys, weights, aggregate)
+	if err != nil {
+		return 0, err
+	}
+
+	kv.discard(destination)
+	if res.Len() > 0 {
+		kv.add(destination, &object{typ: TypeZSet, value: res})
+	}
+
+	return res.Len(), nil
+}
+
+// zsetOperand is an input of the sorted set algebra, either a sorted set or a
+// set whose members all score 1. Both are nil for a missing key.
+type zsetOperand struct {
+	zset   *ZSet
+	set    *Set
+	weight float64
+}
+
+func (o zsetOperand) Len() int {
+	switch {
+	case o.zset != nil:
+		return o.zset.Len()
+	case o.set != nil:
+		return o.set.Len()
+	default:
+		return 0
+	}
+}
+
+// score returns the weighted score of the member, like redis a NaN coming
+// from 0 * inf is turned into 0.
+func (o zsetOperand) score(member string) (float64, bool) {
+	score, ok := 0.0, false
+	switch {
+	case o.zset != nil:
+		score, ok = o.zset.Score(member)
+	case o.set != nil:
+		score, ok = 1, o.set.Contains(member)
+	}
+	return weighted(score, o.weight), ok
+}
+
+// each calls fn for every member with its weighted score.
+func (o zsetOperand) each(fn func(member string, score float64)) {
+	switch {
+	case o.zset != nil:
+		for x := o.zset.zsl.first(); x != nil; x = x.level[0].forward {
+			fn(x.member, weighted(x.score, o.weight))
+		}
+	case o.set != nil:
+		o.set.Each(func(member string) bool {
+			fn(member, weighted(1, o.weight))
+			return true
+		})
+	}
+}
+
+func weighted(score, weight float64) float64 {
+	score *= weight
+	if math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+// apply combines the score of a member with another of its scores.
+func (a ZAggregate) apply(target, score float64) float64 {
+	switch a {
+	case AggregateMin:
+		if score < target {
+			return score
+		}
+		return target
+	case AggregateMax:
+		if score > target {
+			return score
+		}
+		return target
+	default:
+		// inf + -inf is NaN, redis makes it 0.
+		if target += score; math.IsNaN(target) {
+			return 0
+		}
+		return target
+	}
+}
+
+// zsetOp computes the sorted set algebra operation into a new sorted set, the
+// caller must hold the lock.
+func (kv *KV) zsetOp(op SetOp, keys []string, weights []float64, aggregate ZAggregate) (*ZSet, error) {
+	operands := make([]zsetOperand, len(keys))
+	for i, key := range keys {
+		o := kv.lookup(key)
+		operands[i].weight = 1
+		if weights != nil {
+			operands[i].weight = weights[i]
+		}
+		switch {
+		case o == nil:
+		case o.typ == TypeZSet:
+			operands[i].zset = o.value.(*ZSet)
+		case o.typ == TypeSet:
+			operands[i].set = o.value.(*Set)
+		default:
+			return nil, ErrWrongType
+		}
+	}
+
+	res := NewZSet()
+	switch op {
+	case SetUnion:
+		for _, operand := range operands {
+			operand.each(func(member string, score float64) {
+				if cur, ok := res.Score(member); ok {
+					score = aggregate.apply(cur, score)
+				}
+				res.Add(member, score)
+			})
+		}
+	case SetInter:
+		sorted := append([]zsetOperand{}, operands...)
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Len() < sorted[j].Len() })
+		if sorted[0].Len() == 0 {
+			break
+		}
+		sorted[0].each(func(member string, score float64) {
+			for _, other := range sorted[1:] {
+				s, ok := other.score(member)
+				if !ok {
+					return
+				}
+				score = aggregate.apply(score, s)
+			}
+			res.Add(member, score)
+		})
+	case SetDiff:
+		operands[0].each(func(member string, score float64) {
+			for _, other := range operands[1:] {
+				if _, ok := other.score(member); ok {
+					return
+				}
+			}
+			res.Add(member, score)
+		})
+	}
+
+	return res, nil
+}
+
+// ZScan runs one step of a ZSCAN iteration over the sorted set stored at key.
+// It returns the next cursor and the matching members with their scores.
+func (kv *KV) ZScan(key string, cursor uint64, match string, count int) (uint64, []ZMember, error) {
+	defer kv.lock(key)()
+
+	z, err := kv.zset(key)
+	if err != nil || z == nil {
+		return 0, []ZMember{}, err
+	}
+
+	ret := []ZMember{}
+	cursor = scanDict(z.dict, cursor, match, count, func(member string, score float64) {
+		ret = append(ret, ZMember{Member: member, Score: score})
+	})
+
+	return cursor, ret, nil
+}
+
+// storeZSet replaces whatever is stored at key by a sorted set of the
+// members, or deletes the key if there are none. The caller must hold the lock.
+func (kv *KV) storeZSet(key string, members []ZMember) {
+	kv.discard(key)
+	if len(members) == 0 {
+		return
+	}
+
+	o := newZSetObject()
+	z := o.value.(*ZSet)
+	for _, m := range members {
+		z.Add(m.Member, m.Score)
+	}
+	kv.add(key, o)
+}
+
+// zset returns the sorted set stored at key, the caller must hold the lock.
+func (kv *KV) zset(key string) (*ZSet, error) {
+	o, err := kv.lookupType(key, TypeZSet)
+	if err != nil || o == nil {
+		return nil, err
+	}
+	return o.value.(*ZSet), nil
+}
@@ -0,0 +1,63 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"redis-clone/serdes"
+)
+
+var (
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+	errSyntax     = errors.New("ERR syntax error")
+	errOutOfRange = errors.New("ERR value is out of range")
+)
+
+// errWrongArgs is the arity error redis replies with.
+func errWrongArgs(cmd string) error {
+	return fmt.Errorf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+}
+
+// parseTimeout parses the timeout of the blocking commands, given in seconds
+// with an optional fractional part.
+func parseTimeout(v serdes.Value) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(v.String(), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds*1000 > math.MaxInt64/float64(time.Millisecond) {
+		return 0, errors.New("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, errors.New("ERR timeout is negative")
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// parseInt parses a command argument the same way redis does for integers.
+func parseInt(v serdes.Value) (int64, error) {
+	n, err := strconv.ParseInt(v.String(), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+
+	return n, nil
+}
+
+// checkRandCount checks the count of the *RANDMEMBER commands the way redis
+// does: it must be in [-LONG_MAX, LONG_MAX], and not below -LONG_MAX/2 when
+// each member comes with its value, so that the length of the reply can't
+// overflow.
+func checkRandCount(count int64, withValues bool) error {
+	limit := int64(math.MaxInt64)
+	if withValues {
+		limit /= 2
+	}
+	if count < -limit {
+		return errOutOfRange
+	}
+
+	return nil
+}
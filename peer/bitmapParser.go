@@ -0,0 +1,276 @@
+package peer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// bitMaxOffset is the highest bit offset of a string, strings being at most 512MB.
+const bitMaxOffset = 512<<20*8 - 1
+
+var (
+	errBitOffset    = errors.New("ERR bit offset is not an integer or out of range")
+	errBitfieldType = errors.New("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+)
+
+// ParseSetbitCommand parses SETBIT key offset value
+func ParseSetbitCommand(v serdes.Value) (proto.SetbitCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.SetbitCommand{}, errWrongArgs(proto.CommandSETBIT)
+	}
+
+	offset, err := parseBitOffset(args[2], false, 0)
+	if err != nil {
+		return proto.SetbitCommand{}, err
+	}
+	bit := args[3].String()
+	if bit != "0" && bit != "1" {
+		return proto.SetbitCommand{}, errors.New("ERR bit is not an integer or out of range")
+	}
+	cmd := proto.SetbitCommand{
+		Key:    args[1].String(),
+		Offset: offset,
+		Bit:    int(bit[0] - '0'),
+	}
+
+	return cmd, nil
+}
+
+func ParseGetbitCommand(v serdes.Value) (proto.GetbitCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.GetbitCommand{}, errWrongArgs(proto.CommandGETBIT)
+	}
+
+	offset, err := parseBitOffset(args[2], false, 0)
+	if err != nil {
+		return proto.GetbitCommand{}, err
+	}
+	cmd := proto.GetbitCommand{
+		Key:    args[1].String(),
+		Offset: offset,
+	}
+
+	return cmd, nil
+}
+
+// ParseBitcountCommand parses BITCOUNT key [start end [BYTE | BIT]]
+func ParseBitcountCommand(v serdes.Value) (proto.BitcountCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.BitcountCommand{}, errWrongArgs(proto.CommandBITCOUNT)
+	}
+
+	cmd := proto.BitcountCommand{
+		Key: args[1].String(),
+	}
+	switch len(args) {
+	case 2:
+	case 4, 5:
+		var err error
+		if cmd.Start, err = parseInt(args[2]); err != nil {
+			return proto.BitcountCommand{}, err
+		}
+		if cmd.End, err = parseInt(args[3]); err != nil {
+			return proto.BitcountCommand{}, err
+		}
+		cmd.HasRange = true
+		if len(args) == 5 {
+			if cmd.Bit, err = parseBitUnit(args[4]); err != nil {
+				return proto.BitcountCommand{}, err
+			}
+		}
+	default:
+		return proto.BitcountCommand{}, errSyntax
+	}
+
+	return cmd, nil
+}
+
+// ParseBitposCommand parses BITPOS key bit [start [end [BYTE | BIT]]]
+func ParseBitposCommand(v serdes.Value) (proto.BitposCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.BitposCommand{}, errWrongArgs(proto.CommandBITPOS)
+	}
+	if len(args) > 6 {
+		return proto.BitposCommand{}, errSyntax
+	}
+
+	bit, err := parseInt(args[2])
+	if err != nil {
+		return proto.BitposCommand{}, err
+	}
+	if bit != 0 && bit != 1 {
+		return proto.BitposCommand{}, errors.New("ERR The bit argument must be 1 or 0.")
+	}
+	cmd := proto.BitposCommand{
+		Key:   args[1].String(),
+		Value: int(bit),
+	}
+	if len(args) > 3 {
+		if cmd.Start, err = parseInt(args[3]); err != nil {
+			return proto.BitposCommand{}, err
+		}
+		cmd.HasStart = true
+	}
+	if len(args) > 4 {
+		if cmd.End, err = parseInt(args[4]); err != nil {
+			return proto.BitposCommand{}, err
+		}
+		cmd.HasEnd = true
+	}
+	if len(args) > 5 {
+		if cmd.Bit, err = parseBitUnit(args[5]); err != nil {
+			return proto.BitposCommand{}, err
+		}
+	}
+
+	return cmd, nil
+}
+
+// ParseBitopCommand parses BITOP <AND | OR | XOR | NOT> destkey key [key ...]
+func ParseBitopCommand(v serdes.Value) (proto.BitopCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.BitopCommand{}, errWrongArgs(proto.CommandBITOP)
+	}
+
+	op := strings.ToUpper(args[1].String())
+	switch op {
+	case "AND", "OR", "XOR", "NOT":
+	default:
+		return proto.BitopCommand{}, errSyntax
+	}
+	cmd := proto.BitopCommand{
+		Op:          op,
+		Destination: args[2].String(),
+		Keys:        stringArgs(args[3:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseBitfieldCommand parses BITFIELD key [GET encoding offset | [OVERFLOW <WRAP | SAT | FAIL>]
+// <SET encoding offset value | INCRBY encoding offset increment> [GET encoding offset | ...]] and
+// BITFIELD_RO key [GET encoding offset [GET encoding offset ...]]
+func ParseBitfieldCommand(v serdes.Value, cmdType string) (proto.BitfieldCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.BitfieldCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.BitfieldCommand{
+		Key: args[1].String(),
+	}
+	overflow := "WRAP"
+	for i := 2; i < len(args); i++ {
+		sub := strings.ToUpper(args[i].String())
+		arity := 2
+		switch sub {
+		case "GET":
+		case "SET", "INCRBY":
+			arity = 3
+		case "OVERFLOW":
+			arity = 1
+		default:
+			return proto.BitfieldCommand{}, errSyntax
+		}
+		if i+arity >= len(args) {
+			return proto.BitfieldCommand{}, errSyntax
+		}
+		if sub != "GET" && cmdType == proto.CommandBITFIELD_RO {
+			return proto.BitfieldCommand{}, errors.New("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+
+		if sub == "OVERFLOW" {
+			overflow = strings.ToUpper(args[i+1].String())
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				return proto.BitfieldCommand{}, errors.New("ERR Invalid OVERFLOW type specified")
+			}
+			i++
+			continue
+		}
+
+		op := proto.BitfieldOp{
+			Set:      sub == "SET",
+			Incr:     sub == "INCRBY",
+			Overflow: overflow,
+		}
+		var err error
+		if op.Signed, op.Bits, err = parseBitfieldType(args[i+1]); err != nil {
+			return proto.BitfieldCommand{}, err
+		}
+		if op.Offset, err = parseBitOffset(args[i+2], true, op.Bits); err != nil {
+			return proto.BitfieldCommand{}, err
+		}
+		if arity == 3 {
+			if op.Value, err = parseInt(args[i+3]); err != nil {
+				return proto.BitfieldCommand{}, err
+			}
+		}
+		cmd.Ops = append(cmd.Ops, op)
+		i += arity
+	}
+
+	return cmd, nil
+}
+
+// parseBitfieldType parses a BITFIELD encoding, i1 to i64 or u1 to u63.
+func parseBitfieldType(v serdes.Value) (bool, int, error) {
+	s := v.String()
+	if len(s) < 2 || (s[0] != 'i' && s[0] != 'u') {
+		return false, 0, errBitfieldType
+	}
+	width, err := strconv.Atoi(s[1:])
+	signed := s[0] == 'i'
+	if err != nil || width < 1 || (signed && width > 64) || (!signed && width > 63) {
+		return false, 0, errBitfieldType
+	}
+
+	return signed, width, nil
+}
+
+// parseBitOffset parses a bit offset. BITFIELD offsets may be prefixed by #,
+// they are then multiplied by the width of the field, and the whole field
+// has to fit in a string.
+func parseBitOffset(v serdes.Value, bitfield bool, width int) (uint64, error) {
+	s := v.String()
+	multiply := bitfield && strings.HasPrefix(s, "#")
+	if multiply {
+		s = s[1:]
+	}
+	offset, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, errBitOffset
+	}
+	if multiply {
+		if offset > bitMaxOffset/int64(width) {
+			return 0, errBitOffset
+		}
+		offset *= int64(width)
+	}
+	// Compared without adding to the offset, which could overflow.
+	if offset > bitMaxOffset+1-int64(width) || (width == 0 && offset > bitMaxOffset) {
+		return 0, errBitOffset
+	}
+
+	return uint64(offset), nil
+}
+
+// parseBitUnit parses the BYTE or BIT unit of a range and reports whether it is BIT.
+func parseBitUnit(v serdes.Value) (bool, error) {
+	switch strings.ToUpper(v.String()) {
+	case "BYTE":
+		return false, nil
+	case "BIT":
+		return true, nil
+	default:
+		return false, errSyntax
+	}
+}
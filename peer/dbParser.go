@@ -0,0 +1,67 @@
+package peer
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func ParseSelectCommand(v serdes.Value) (proto.SelectCommand, error) {
+	args := v.Array()
+	if len(args) != 2 {
+		return proto.SelectCommand{}, errWrongArgs(proto.CommandSELECT)
+	}
+	db, err := parseInt(args[1])
+	if err != nil {
+		return proto.SelectCommand{}, err
+	}
+
+	return proto.SelectCommand{DB: db}, nil
+}
+
+func ParseSwapdbCommand(v serdes.Value) (proto.SwapdbCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.SwapdbCommand{}, errWrongArgs(proto.CommandSWAPDB)
+	}
+	db1, err := strconv.ParseInt(args[1].String(), 10, 64)
+	if err != nil {
+		return proto.SwapdbCommand{}, errors.New("ERR invalid first DB index")
+	}
+	db2, err := strconv.ParseInt(args[2].String(), 10, 64)
+	if err != nil {
+		return proto.SwapdbCommand{}, errors.New("ERR invalid second DB index")
+	}
+
+	return proto.SwapdbCommand{DB1: db1, DB2: db2}, nil
+}
+
+// ParseFlushCommand parses FLUSHDB and FLUSHALL [ASYNC | SYNC]
+func ParseFlushCommand(v serdes.Value, cmdType string) (proto.FlushCommand, error) {
+	args := v.Array()
+	cmd := proto.FlushCommand{
+		All: cmdType == proto.CommandFLUSHALL,
+	}
+	switch {
+	case len(args) == 1:
+	case len(args) == 2 && strings.EqualFold(args[1].String(), "ASYNC"):
+		cmd.Async = true
+	case len(args) == 2 && strings.EqualFold(args[1].String(), "SYNC"):
+	default:
+		return proto.FlushCommand{}, errSyntax
+	}
+
+	return cmd, nil
+}
+
+func ParseInfoCommand(v serdes.Value) (proto.InfoCommand, error) {
+	cmd := proto.InfoCommand{}
+	for _, arg := range v.Array()[1:] {
+		cmd.Sections = append(cmd.Sections, strings.ToLower(arg.String()))
+	}
+
+	return cmd, nil
+}
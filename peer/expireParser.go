@@ -0,0 +1,91 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// ParseExpireCommand parses EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT which all look like:
+// EXPIRE key value [NX | XX | GT | LT]
+func ParseExpireCommand(v serdes.Value, cmdType string) (proto.ExpireCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.ExpireCommand{}, errWrongArgs(cmdType)
+	}
+
+	value, err := parseInt(args[2])
+	if err != nil {
+		return proto.ExpireCommand{}, err
+	}
+
+	cmd := proto.ExpireCommand{
+		Name:   strings.ToLower(cmdType),
+		Key:    args[1].String(),
+		Value:  value,
+		Millis: cmdType == proto.CommandPEXPIRE || cmdType == proto.CommandPEXPIREAT,
+		At:     cmdType == proto.CommandEXPIREAT || cmdType == proto.CommandPEXPIREAT,
+	}
+
+	for _, arg := range args[3:] {
+		switch opt := strings.ToUpper(arg.String()); opt {
+		case "NX":
+			cmd.NX = true
+		case "XX":
+			cmd.XX = true
+		case "GT":
+			cmd.GT = true
+		case "LT":
+			cmd.LT = true
+		default:
+			return proto.ExpireCommand{}, fmt.Errorf("ERR Unsupported option %s", arg.String())
+		}
+	}
+
+	if cmd.NX && (cmd.XX || cmd.GT || cmd.LT) {
+		return proto.ExpireCommand{}, errors.New("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if cmd.GT && cmd.LT {
+		return proto.ExpireCommand{}, errors.New("ERR GT and LT options at the same time are not compatible")
+	}
+
+	return cmd, nil
+}
+
+func ParseTtlCommand(v serdes.Value, cmdType string) (proto.TtlCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.TtlCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.TtlCommand{
+		Key:    v.Array()[1].String(),
+		Millis: cmdType == proto.CommandPTTL,
+	}
+
+	return cmd, nil
+}
+
+func ParseExpireTimeCommand(v serdes.Value, cmdType string) (proto.ExpireTimeCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.ExpireTimeCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.ExpireTimeCommand{
+		Key:    v.Array()[1].String(),
+		Millis: cmdType == proto.CommandPEXPIRETIME,
+	}
+
+	return cmd, nil
+}
+
+func ParsePersistCommand(v serdes.Value) (proto.PersistCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.PersistCommand{}, errWrongArgs(proto.CommandPERSIST)
+	}
+	cmd := proto.PersistCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,259 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var errDBIndex = errors.New("ERR DB index is out of range")
+
+func ParseTypeCommand(v serdes.Value) (proto.TypeCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.TypeCommand{}, errWrongArgs(proto.CommandTYPE)
+	}
+	cmd := proto.TypeCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseExistCommand(v serdes.Value) (proto.ExistCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.ExistCommand{}, errWrongArgs(proto.CommandEXIST)
+	}
+	cmd := proto.ExistCommand{
+		Keys: stringArgs(args[1:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseDelCommand parses DEL and UNLINK key [key ...]
+func ParseDelCommand(v serdes.Value, cmdType string) (proto.DelCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.DelCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.DelCommand{
+		Keys:   stringArgs(args[1:]),
+		Unlink: cmdType == proto.CommandUNLINK,
+	}
+
+	return cmd, nil
+}
+
+func ParseTouchCommand(v serdes.Value) (proto.TouchCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.TouchCommand{}, errWrongArgs(proto.CommandTOUCH)
+	}
+	cmd := proto.TouchCommand{
+		Keys: stringArgs(args[1:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseRenameCommand parses RENAME and RENAMENX key newkey
+func ParseRenameCommand(v serdes.Value, cmdType string) (proto.RenameCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.RenameCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.RenameCommand{
+		Source:      args[1].String(),
+		Destination: args[2].String(),
+		NX:          cmdType == proto.CommandRENAMENX,
+	}
+
+	return cmd, nil
+}
+
+// ParseCopyCommand parses COPY source destination [DB destination-db] [REPLACE]
+func ParseCopyCommand(v serdes.Value) (proto.CopyCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.CopyCommand{}, errWrongArgs(proto.CommandCOPY)
+	}
+
+	cmd := proto.CopyCommand{
+		Source:      args[1].String(),
+		Destination: args[2].String(),
+		DB:          -1,
+	}
+	for i := 3; i < len(args); i++ {
+		switch arg := strings.ToUpper(args[i].String()); {
+		case arg == "REPLACE":
+			cmd.Replace = true
+		case arg == "DB" && i+1 < len(args):
+			i++
+			db, err := parseInt(args[i])
+			if err != nil {
+				return proto.CopyCommand{}, err
+			}
+			if db < 0 || db > math.MaxInt32 {
+				return proto.CopyCommand{}, errDBIndex
+			}
+			cmd.DB = int(db)
+		default:
+			return proto.CopyCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
+
+func ParseMoveCommand(v serdes.Value) (proto.MoveCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.MoveCommand{}, errWrongArgs(proto.CommandMOVE)
+	}
+	db, err := parseInt(args[2])
+	if err != nil {
+		return proto.MoveCommand{}, err
+	}
+	if db < 0 || db > math.MaxInt32 {
+		return proto.MoveCommand{}, errDBIndex
+	}
+	cmd := proto.MoveCommand{
+		Key: args[1].String(),
+		DB:  int(db),
+	}
+
+	return cmd, nil
+}
+
+// ParseObjectCommand parses OBJECT <subcommand> key, and OBJECT HELP.
+func ParseObjectCommand(v serdes.Value) (proto.ObjectCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.ObjectCommand{}, errWrongArgs(proto.CommandOBJECT)
+	}
+
+	sub := strings.ToUpper(args[1].String())
+	arity := 3
+	switch sub {
+	case "ENCODING", "IDLETIME", "FREQ", "REFCOUNT":
+	case "HELP":
+		arity = 2
+	default:
+		return proto.ObjectCommand{}, fmt.Errorf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[1].String())
+	}
+	if len(args) != arity {
+		return proto.ObjectCommand{}, fmt.Errorf("ERR wrong number of arguments for 'object|%s' command", strings.ToLower(sub))
+	}
+	cmd := proto.ObjectCommand{
+		Subcommand: sub,
+	}
+	if sub != "HELP" {
+		cmd.Key = args[2].String()
+	}
+
+	return cmd, nil
+}
+
+// ParseScanCommand parses SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func ParseScanCommand(v serdes.Value) (proto.ScanCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.ScanCommand{}, errWrongArgs(proto.CommandSCAN)
+	}
+
+	scan, rest, err := parseScanArgs(args[1:])
+	if err != nil {
+		return proto.ScanCommand{}, err
+	}
+	cmd := proto.ScanCommand{
+		ScanArgs: scan,
+	}
+	for i := 0; i < len(rest); i += 2 {
+		if strings.ToUpper(rest[i].String()) != "TYPE" || i+1 == len(rest) {
+			return proto.ScanCommand{}, errSyntax
+		}
+		cmd.Type = rest[i+1].String()
+	}
+
+	return cmd, nil
+}
+
+func ParseKeysCommand(v serdes.Value) (proto.KeysCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.KeysCommand{}, errWrongArgs(proto.CommandKEYS)
+	}
+	cmd := proto.KeysCommand{
+		Pattern: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseRandomkeyCommand(v serdes.Value) (proto.RandomkeyCommand, error) {
+	if len(v.Array()) != 1 {
+		return proto.RandomkeyCommand{}, errWrongArgs(proto.CommandRANDOMKEY)
+	}
+
+	return proto.RandomkeyCommand{}, nil
+}
+
+func ParseDbsizeCommand(v serdes.Value) (proto.DbsizeCommand, error) {
+	if len(v.Array()) != 1 {
+		return proto.DbsizeCommand{}, errWrongArgs(proto.CommandDBSIZE)
+	}
+
+	return proto.DbsizeCommand{}, nil
+}
+
+// ParseSortCommand parses SORT key [BY pattern] [LIMIT offset count]
+// [GET pattern [GET pattern ...]] [ASC | DESC] [ALPHA] [STORE destination],
+// SORT_RO being SORT without STORE.
+func ParseSortCommand(v serdes.Value, cmdType string) (proto.SortCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.SortCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.SortCommand{
+		Key:   args[1].String(),
+		Count: -1,
+	}
+	for i := 2; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "ASC":
+			cmd.Desc = false
+		case opt == "DESC":
+			cmd.Desc = true
+		case opt == "ALPHA":
+			cmd.Alpha = true
+		case opt == "LIMIT" && left >= 2:
+			var err error
+			if cmd.Offset, err = parseInt(args[i+1]); err != nil {
+				return proto.SortCommand{}, err
+			}
+			if cmd.Count, err = parseInt(args[i+2]); err != nil {
+				return proto.SortCommand{}, err
+			}
+			i += 2
+		case opt == "STORE" && left >= 1 && cmdType == proto.CommandSORT:
+			cmd.Store = args[i+1].String()
+			i++
+		case opt == "BY" && left >= 1:
+			cmd.By = args[i+1].String()
+			i++
+		case opt == "GET" && left >= 1:
+			cmd.Get = append(cmd.Get, args[i+1].String())
+			i++
+		default:
+			return proto.SortCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
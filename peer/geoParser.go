@@ -0,0 +1,266 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// The coordinates a geo index accepts, the latitudes being limited to what
+// the web mercator projection covers.
+const (
+	geoLongMin = -180
+	geoLongMax = 180
+	geoLatMin  = -85.05112878
+	geoLatMax  = 85.05112878
+)
+
+// geoUnits are the number of meters in each distance unit.
+var geoUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"ft": 0.3048,
+	"mi": 1609.34,
+}
+
+var errGeoUnit = errors.New("ERR unsupported unit provided. please use M, KM, FT, MI")
+
+// ParseGeoaddCommand parses GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func ParseGeoaddCommand(v serdes.Value) (proto.GeoaddCommand, error) {
+	args := v.Array()
+	if len(args) < 5 {
+		return proto.GeoaddCommand{}, errWrongArgs(proto.CommandGEOADD)
+	}
+
+	cmd := proto.GeoaddCommand{
+		Key: args[1].String(),
+	}
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "NX":
+			cmd.NX = true
+		case "XX":
+			cmd.XX = true
+		case "CH":
+			cmd.CH = true
+		default:
+			break flags
+		}
+	}
+
+	triples := args[i:]
+	if len(triples)%3 != 0 || (cmd.NX && cmd.XX) {
+		return proto.GeoaddCommand{}, errSyntax
+	}
+	for i := 0; i < len(triples); i += 3 {
+		long, lat, err := parseLongLat(triples[i], triples[i+1])
+		if err != nil {
+			return proto.GeoaddCommand{}, err
+		}
+		cmd.Points = append(cmd.Points, proto.GeoPoint{Longitude: long, Latitude: lat, Member: triples[i+2].String()})
+	}
+
+	return cmd, nil
+}
+
+// ParseGeodistCommand parses GEODIST key member1 member2 [M | KM | FT | MI]
+func ParseGeodistCommand(v serdes.Value) (proto.GeodistCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.GeodistCommand{}, errWrongArgs(proto.CommandGEODIST)
+	}
+	if len(args) > 5 {
+		return proto.GeodistCommand{}, errSyntax
+	}
+
+	cmd := proto.GeodistCommand{
+		Key:        args[1].String(),
+		Member1:    args[2].String(),
+		Member2:    args[3].String(),
+		Conversion: 1,
+	}
+	if len(args) == 5 {
+		conversion, err := parseGeoUnit(args[4])
+		if err != nil {
+			return proto.GeodistCommand{}, err
+		}
+		cmd.Conversion = conversion
+	}
+
+	return cmd, nil
+}
+
+func ParseGeoposCommand(v serdes.Value) (proto.GeoposCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.GeoposCommand{}, errWrongArgs(proto.CommandGEOPOS)
+	}
+	cmd := proto.GeoposCommand{
+		Key:     args[1].String(),
+		Members: stringArgs(args[2:]),
+	}
+
+	return cmd, nil
+}
+
+func ParseGeohashCommand(v serdes.Value) (proto.GeohashCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.GeohashCommand{}, errWrongArgs(proto.CommandGEOHASH)
+	}
+	cmd := proto.GeohashCommand{
+		Key:     args[1].String(),
+		Members: stringArgs(args[2:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseGeosearchCommand parses GEOSEARCH and GEOSEARCHSTORE:
+// GEOSEARCH key FROMMEMBER member | FROMLONLAT longitude latitude
+// BYRADIUS radius unit | BYBOX width height unit [ASC | DESC] [COUNT count [ANY]]
+// [WITHCOORD] [WITHDIST] [WITHHASH]
+// GEOSEARCHSTORE destination source ... [STOREDIST]
+func ParseGeosearchCommand(v serdes.Value, cmdType string) (proto.GeosearchCommand, error) {
+	args := v.Array()
+	store := cmdType == proto.CommandGEOSEARCHSTORE
+	if (!store && len(args) < 7) || (store && len(args) < 8) {
+		return proto.GeosearchCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.GeosearchCommand{Store: store}
+	i := 1
+	if store {
+		cmd.Destination = args[i].String()
+		i++
+	}
+	cmd.Key = args[i].String()
+
+	var from, by, count bool
+	for i++; i < len(args); i++ {
+		left := len(args) - i - 1
+		switch arg := strings.ToUpper(args[i].String()); {
+		case arg == "WITHDIST":
+			cmd.WithDist = true
+		case arg == "WITHHASH":
+			cmd.WithHash = true
+		case arg == "WITHCOORD":
+			cmd.WithCoord = true
+		case arg == "ANY":
+			cmd.Any = true
+		case arg == "ASC" || arg == "DESC":
+			cmd.Sort = arg
+		case arg == "COUNT" && left >= 1:
+			i++
+			n, err := strconv.ParseInt(args[i].String(), 10, 64)
+			if err != nil || n < 1 {
+				return proto.GeosearchCommand{}, errors.New("ERR COUNT must be > 0")
+			}
+			cmd.Count, count = int(min(n, math.MaxInt)), true
+		case arg == "STOREDIST" && store:
+			cmd.StoreDist = true
+		case arg == "FROMMEMBER" && left >= 1:
+			if from {
+				return proto.GeosearchCommand{}, errSyntax
+			}
+			cmd.FromMember, cmd.Member, from = true, args[i+1].String(), true
+			i++
+		case arg == "FROMLONLAT" && left >= 2:
+			if from {
+				return proto.GeosearchCommand{}, errSyntax
+			}
+			long, lat, err := parseLongLat(args[i+1], args[i+2])
+			if err != nil {
+				return proto.GeosearchCommand{}, err
+			}
+			cmd.Longitude, cmd.Latitude, from = long, lat, true
+			i += 2
+		case arg == "BYRADIUS" && left >= 2:
+			if by {
+				return proto.GeosearchCommand{}, errSyntax
+			}
+			radius, err := strconv.ParseFloat(args[i+1].String(), 64)
+			if err != nil || math.IsNaN(radius) {
+				return proto.GeosearchCommand{}, errors.New("ERR need numeric radius")
+			}
+			if radius < 0 {
+				return proto.GeosearchCommand{}, errors.New("ERR radius cannot be negative")
+			}
+			conversion, err := parseGeoUnit(args[i+2])
+			if err != nil {
+				return proto.GeosearchCommand{}, err
+			}
+			cmd.Radius, cmd.Conversion, by = radius, conversion, true
+			i += 2
+		case arg == "BYBOX" && left >= 3:
+			if by {
+				return proto.GeosearchCommand{}, errSyntax
+			}
+			width, err := strconv.ParseFloat(args[i+1].String(), 64)
+			if err != nil || math.IsNaN(width) {
+				return proto.GeosearchCommand{}, errors.New("ERR need numeric width")
+			}
+			height, err := strconv.ParseFloat(args[i+2].String(), 64)
+			if err != nil || math.IsNaN(height) {
+				return proto.GeosearchCommand{}, errors.New("ERR need numeric height")
+			}
+			if width < 0 || height < 0 {
+				return proto.GeosearchCommand{}, errors.New("ERR height or width cannot be negative")
+			}
+			conversion, err := parseGeoUnit(args[i+3])
+			if err != nil {
+				return proto.GeosearchCommand{}, err
+			}
+			cmd.ByBox, cmd.Width, cmd.Height, cmd.Conversion, by = true, width, height, conversion, true
+			i += 3
+		default:
+			return proto.GeosearchCommand{}, errSyntax
+		}
+	}
+
+	switch {
+	case store && (cmd.WithDist || cmd.WithHash || cmd.WithCoord):
+		return proto.GeosearchCommand{}, fmt.Errorf("ERR %s is not compatible with WITHDIST, WITHHASH and WITHCOORD options", cmdType)
+	case !from:
+		return proto.GeosearchCommand{}, fmt.Errorf("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for %s", args[0].String())
+	case !by:
+		return proto.GeosearchCommand{}, fmt.Errorf("ERR exactly one of BYRADIUS and BYBOX can be specified for %s", args[0].String())
+	case cmd.Any && !count:
+		return proto.GeosearchCommand{}, errors.New("ERR the ANY argument requires COUNT argument")
+	}
+
+	return cmd, nil
+}
+
+// parseLongLat parses a longitude and a latitude, checking they can be indexed.
+func parseLongLat(longArg, latArg serdes.Value) (float64, float64, error) {
+	long, err := parseFloat(longArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	lat, err := parseFloat(latArg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if long < geoLongMin || long > geoLongMax || lat < geoLatMin || lat > geoLatMax {
+		return 0, 0, fmt.Errorf("ERR invalid longitude,latitude pair %f,%f", long, lat)
+	}
+
+	return long, lat, nil
+}
+
+func parseGeoUnit(v serdes.Value) (float64, error) {
+	conversion, ok := geoUnits[strings.ToLower(v.String())]
+	if !ok {
+		return 0, errGeoUnit
+	}
+
+	return conversion, nil
+}
@@ -0,0 +1,280 @@
+package peer
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var errNotFloat = errors.New("ERR value is not a valid float")
+
+// ParseHsetCommand parses HSET and HMSET: HSET key field value [field value ...]
+func ParseHsetCommand(v serdes.Value, cmdType string) (proto.HsetCommand, error) {
+	args := v.Array()
+	if len(args) < 4 || len(args)%2 != 0 {
+		return proto.HsetCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.HsetCommand{
+		Key:   args[1].String(),
+		Pairs: stringArgs(args[2:]),
+		Multi: cmdType == proto.CommandHMSET,
+	}
+
+	return cmd, nil
+}
+
+func ParseHsetnxCommand(v serdes.Value) (proto.HsetnxCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.HsetnxCommand{}, errWrongArgs(proto.CommandHSETNX)
+	}
+	cmd := proto.HsetnxCommand{
+		Key:   v.Array()[1].String(),
+		Field: v.Array()[2].String(),
+		Value: v.Array()[3].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseHgetCommand(v serdes.Value) (proto.HgetCommand, error) {
+	if len(v.Array()) != 3 {
+		return proto.HgetCommand{}, errWrongArgs(proto.CommandHGET)
+	}
+	cmd := proto.HgetCommand{
+		Key:   v.Array()[1].String(),
+		Field: v.Array()[2].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseHmgetCommand(v serdes.Value) (proto.HmgetCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.HmgetCommand{}, errWrongArgs(proto.CommandHMGET)
+	}
+	cmd := proto.HmgetCommand{
+		Key:    v.Array()[1].String(),
+		Fields: stringArgs(v.Array()[2:]),
+	}
+
+	return cmd, nil
+}
+
+func ParseHdelCommand(v serdes.Value) (proto.HdelCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.HdelCommand{}, errWrongArgs(proto.CommandHDEL)
+	}
+	cmd := proto.HdelCommand{
+		Key:    v.Array()[1].String(),
+		Fields: stringArgs(v.Array()[2:]),
+	}
+
+	return cmd, nil
+}
+
+func ParseHexistsCommand(v serdes.Value) (proto.HexistsCommand, error) {
+	if len(v.Array()) != 3 {
+		return proto.HexistsCommand{}, errWrongArgs(proto.CommandHEXISTS)
+	}
+	cmd := proto.HexistsCommand{
+		Key:   v.Array()[1].String(),
+		Field: v.Array()[2].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseHlenCommand(v serdes.Value) (proto.HlenCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.HlenCommand{}, errWrongArgs(proto.CommandHLEN)
+	}
+	cmd := proto.HlenCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseHgetallCommand parses HGETALL, HKEYS and HVALS which all take a single key.
+func ParseHgetallCommand(v serdes.Value, cmdType string) (proto.HgetallCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.HgetallCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.HgetallCommand{
+		Key:    v.Array()[1].String(),
+		Fields: cmdType != proto.CommandHVALS,
+		Values: cmdType != proto.CommandHKEYS,
+	}
+
+	return cmd, nil
+}
+
+func ParseHincrbyCommand(v serdes.Value) (proto.HincrbyCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.HincrbyCommand{}, errWrongArgs(proto.CommandHINCRBY)
+	}
+	increment, err := parseInt(v.Array()[3])
+	if err != nil {
+		return proto.HincrbyCommand{}, err
+	}
+	cmd := proto.HincrbyCommand{
+		Key:       v.Array()[1].String(),
+		Field:     v.Array()[2].String(),
+		Increment: increment,
+	}
+
+	return cmd, nil
+}
+
+func ParseHincrbyfloatCommand(v serdes.Value) (proto.HincrbyfloatCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.HincrbyfloatCommand{}, errWrongArgs(proto.CommandHINCRBYFLOAT)
+	}
+	increment, err := parseFloat(v.Array()[3])
+	if err != nil {
+		return proto.HincrbyfloatCommand{}, err
+	}
+	cmd := proto.HincrbyfloatCommand{
+		Key:       v.Array()[1].String(),
+		Field:     v.Array()[2].String(),
+		Increment: increment,
+	}
+
+	return cmd, nil
+}
+
+func ParseHstrlenCommand(v serdes.Value) (proto.HstrlenCommand, error) {
+	if len(v.Array()) != 3 {
+		return proto.HstrlenCommand{}, errWrongArgs(proto.CommandHSTRLEN)
+	}
+	cmd := proto.HstrlenCommand{
+		Key:   v.Array()[1].String(),
+		Field: v.Array()[2].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseHrandfieldCommand parses HRANDFIELD key [count [WITHVALUES]]
+func ParseHrandfieldCommand(v serdes.Value) (proto.HrandfieldCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 4 {
+		return proto.HrandfieldCommand{}, errWrongArgs(proto.CommandHRANDFIELD)
+	}
+
+	cmd := proto.HrandfieldCommand{
+		Key: args[1].String(),
+	}
+	if len(args) > 2 {
+		count, err := parseInt(args[2])
+		if err != nil {
+			return proto.HrandfieldCommand{}, err
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+	if len(args) == 4 {
+		if strings.ToUpper(args[3].String()) != "WITHVALUES" {
+			return proto.HrandfieldCommand{}, errSyntax
+		}
+		cmd.WithValues = true
+	}
+
+	if err := checkRandCount(cmd.Count, cmd.WithValues); err != nil {
+		return proto.HrandfieldCommand{}, err
+	}
+
+	return cmd, nil
+}
+
+// ParseHscanCommand parses HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func ParseHscanCommand(v serdes.Value) (proto.HscanCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.HscanCommand{}, errWrongArgs(proto.CommandHSCAN)
+	}
+
+	cmd := proto.HscanCommand{
+		Key: args[1].String(),
+	}
+	var rest []serdes.Value
+	var err error
+	if cmd.ScanArgs, rest, err = parseScanArgs(args[2:]); err != nil {
+		return proto.HscanCommand{}, err
+	}
+	for _, arg := range rest {
+		if strings.ToUpper(arg.String()) != "NOVALUES" {
+			return proto.HscanCommand{}, errSyntax
+		}
+		cmd.NoValues = true
+	}
+
+	return cmd, nil
+}
+
+// parseScanArgs parses cursor [MATCH pattern] [COUNT count] and returns the
+// arguments it did not recognize, for the options specific to each command.
+func parseScanArgs(args []serdes.Value) (proto.ScanArgs, []serdes.Value, error) {
+	cursor, err := strconv.ParseUint(args[0].String(), 10, 64)
+	if err != nil {
+		return proto.ScanArgs{}, nil, errors.New("ERR invalid cursor")
+	}
+
+	scan := proto.ScanArgs{
+		Cursor: cursor,
+		Count:  10,
+	}
+	var rest []serdes.Value
+	for i := 1; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].String())
+		if (opt != "MATCH" && opt != "COUNT") || i+1 == len(args) {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		i++
+		if opt == "MATCH" {
+			scan.Match = args[i].String()
+			// Matching everything is what no pattern does, just faster.
+			if scan.Match == "*" {
+				scan.Match = ""
+			}
+			continue
+		}
+		count, err := parseInt(args[i])
+		if err != nil {
+			return proto.ScanArgs{}, nil, err
+		}
+		if count < 1 {
+			return proto.ScanArgs{}, nil, errSyntax
+		}
+		scan.Count = count
+	}
+
+	return scan, rest, nil
+}
+
+// parseFloat parses a float argument, refusing NaN like redis does.
+func parseFloat(v serdes.Value) (float64, error) {
+	f, err := strconv.ParseFloat(v.String(), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+
+	return f, nil
+}
+
+// stringArgs returns the arguments as strings.
+func stringArgs(args []serdes.Value) []string {
+	ret := make([]string, 0, len(args))
+	for _, arg := range args {
+		ret = append(ret, arg.String())
+	}
+
+	return ret
+}
@@ -0,0 +1,46 @@
+package peer
+
+import (
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// ParsePfaddCommand parses PFADD key [element [element ...]]
+func ParsePfaddCommand(v serdes.Value) (proto.PfaddCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.PfaddCommand{}, errWrongArgs(proto.CommandPFADD)
+	}
+	cmd := proto.PfaddCommand{
+		Key:      args[1].String(),
+		Elements: stringArgs(args[2:]),
+	}
+
+	return cmd, nil
+}
+
+func ParsePfcountCommand(v serdes.Value) (proto.PfcountCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.PfcountCommand{}, errWrongArgs(proto.CommandPFCOUNT)
+	}
+	cmd := proto.PfcountCommand{
+		Keys: stringArgs(args[1:]),
+	}
+
+	return cmd, nil
+}
+
+// ParsePfmergeCommand parses PFMERGE destkey [sourcekey [sourcekey ...]]
+func ParsePfmergeCommand(v serdes.Value) (proto.PfmergeCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.PfmergeCommand{}, errWrongArgs(proto.CommandPFMERGE)
+	}
+	cmd := proto.PfmergeCommand{
+		Destination: args[1].String(),
+		Keys:        stringArgs(args[2:]),
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,399 @@
+package peer
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// ParsePushCommand parses LPUSH, RPUSH, LPUSHX and RPUSHX: LPUSH key element [element ...]
+func ParsePushCommand(v serdes.Value, cmdType string) (proto.PushCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.PushCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.PushCommand{
+		Key:          v.Array()[1].String(),
+		Value:        getElement(v),
+		Left:         cmdType == proto.CommandLPUSH || cmdType == proto.CommandLPUSHX,
+		OnlyIfExists: cmdType == proto.CommandLPUSHX || cmdType == proto.CommandRPUSHX,
+	}
+
+	return cmd, nil
+}
+
+// ParsePopCommand parses LPOP and RPOP: LPOP key [count]
+func ParsePopCommand(v serdes.Value, cmdType string) (proto.PopCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 3 {
+		return proto.PopCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.PopCommand{
+		Key:   args[1].String(),
+		Left:  cmdType == proto.CommandLPOP,
+		Count: 1,
+	}
+	if len(args) == 3 {
+		count, err := parseInt(args[2])
+		if err != nil || count < 0 {
+			return proto.PopCommand{}, errors.New("ERR value is out of range, must be positive")
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+
+	return cmd, nil
+}
+
+func ParseLlenCommand(v serdes.Value) (proto.LlenCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.LlenCommand{}, errWrongArgs(proto.CommandLLEN)
+	}
+	cmd := proto.LlenCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseLindexCommand(v serdes.Value) (proto.LindexCommand, error) {
+	if len(v.Array()) != 3 {
+		return proto.LindexCommand{}, errWrongArgs(proto.CommandLINDEX)
+	}
+	index, err := parseInt(v.Array()[2])
+	if err != nil {
+		return proto.LindexCommand{}, err
+	}
+	cmd := proto.LindexCommand{
+		Key:   v.Array()[1].String(),
+		Index: index,
+	}
+
+	return cmd, nil
+}
+
+func ParseLsetCommand(v serdes.Value) (proto.LsetCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.LsetCommand{}, errWrongArgs(proto.CommandLSET)
+	}
+	index, err := parseInt(v.Array()[2])
+	if err != nil {
+		return proto.LsetCommand{}, err
+	}
+	cmd := proto.LsetCommand{
+		Key:   v.Array()[1].String(),
+		Index: index,
+		Value: v.Array()[3].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseLrangeCommand(v serdes.Value) (proto.LrangeCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.LrangeCommand{}, errWrongArgs(proto.CommandLRANGE)
+	}
+	start, stop, err := parseRange(v.Array()[2], v.Array()[3])
+	if err != nil {
+		return proto.LrangeCommand{}, err
+	}
+	cmd := proto.LrangeCommand{
+		Key:   v.Array()[1].String(),
+		Start: start,
+		Stop:  stop,
+	}
+
+	return cmd, nil
+}
+
+func ParseLremCommand(v serdes.Value) (proto.LremCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.LremCommand{}, errWrongArgs(proto.CommandLREM)
+	}
+	count, err := parseInt(v.Array()[2])
+	if err != nil {
+		return proto.LremCommand{}, err
+	}
+	cmd := proto.LremCommand{
+		Key:   v.Array()[1].String(),
+		Count: count,
+		Value: v.Array()[3].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseLtrimCommand(v serdes.Value) (proto.LtrimCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.LtrimCommand{}, errWrongArgs(proto.CommandLTRIM)
+	}
+	start, stop, err := parseRange(v.Array()[2], v.Array()[3])
+	if err != nil {
+		return proto.LtrimCommand{}, err
+	}
+	cmd := proto.LtrimCommand{
+		Key:   v.Array()[1].String(),
+		Start: start,
+		Stop:  stop,
+	}
+
+	return cmd, nil
+}
+
+// ParseLinsertCommand parses LINSERT key <BEFORE | AFTER> pivot element
+func ParseLinsertCommand(v serdes.Value) (proto.LinsertCommand, error) {
+	args := v.Array()
+	if len(args) != 5 {
+		return proto.LinsertCommand{}, errWrongArgs(proto.CommandLINSERT)
+	}
+
+	cmd := proto.LinsertCommand{
+		Key:   args[1].String(),
+		Pivot: args[3].String(),
+		Value: args[4].String(),
+	}
+	switch strings.ToUpper(args[2].String()) {
+	case "BEFORE":
+		cmd.Before = true
+	case "AFTER":
+	default:
+		return proto.LinsertCommand{}, errSyntax
+	}
+
+	return cmd, nil
+}
+
+// ParseLposCommand parses LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func ParseLposCommand(v serdes.Value) (proto.LposCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.LposCommand{}, errWrongArgs(proto.CommandLPOS)
+	}
+
+	cmd := proto.LposCommand{
+		Key:   args[1].String(),
+		Value: args[2].String(),
+		Rank:  1,
+	}
+	for i := 3; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return proto.LposCommand{}, errSyntax
+		}
+		n, err := parseInt(args[i+1])
+		if err != nil {
+			return proto.LposCommand{}, err
+		}
+
+		switch strings.ToUpper(args[i].String()) {
+		case "RANK":
+			// Like redis, so the rank can be negated.
+			if n == math.MinInt64 {
+				return proto.LposCommand{}, errors.New("ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807")
+			}
+			if n == 0 {
+				return proto.LposCommand{}, errors.New("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			cmd.Rank = n
+		case "COUNT":
+			if n < 0 {
+				return proto.LposCommand{}, errors.New("ERR COUNT can't be negative")
+			}
+			cmd.Count = n
+			cmd.HasCount = true
+		case "MAXLEN":
+			if n < 0 {
+				return proto.LposCommand{}, errors.New("ERR MAXLEN can't be negative")
+			}
+			cmd.MaxLen = n
+		default:
+			return proto.LposCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
+
+// ParseLmoveCommand parses LMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT>
+// and its older form RPOPLPUSH source destination.
+func ParseLmoveCommand(v serdes.Value, cmdType string) (proto.LmoveCommand, error) {
+	args := v.Array()
+	if cmdType == proto.CommandRPOPLPUSH {
+		if len(args) != 3 {
+			return proto.LmoveCommand{}, errWrongArgs(cmdType)
+		}
+		cmd := proto.LmoveCommand{
+			Source:      args[1].String(),
+			Destination: args[2].String(),
+			ToLeft:      true,
+		}
+		return cmd, nil
+	}
+
+	if len(args) != 5 {
+		return proto.LmoveCommand{}, errWrongArgs(cmdType)
+	}
+	from, err := parseSide(args[3])
+	if err != nil {
+		return proto.LmoveCommand{}, err
+	}
+	to, err := parseSide(args[4])
+	if err != nil {
+		return proto.LmoveCommand{}, err
+	}
+	cmd := proto.LmoveCommand{
+		Source:      args[1].String(),
+		Destination: args[2].String(),
+		FromLeft:    from,
+		ToLeft:      to,
+	}
+
+	return cmd, nil
+}
+
+// ParseLmpopCommand parses LMPOP numkeys key [key ...] <LEFT | RIGHT> [COUNT count]
+func ParseLmpopCommand(v serdes.Value) (proto.LmpopCommand, error) {
+	if len(v.Array()) < 4 {
+		return proto.LmpopCommand{}, errWrongArgs(proto.CommandLMPOP)
+	}
+
+	return parseMpopArgs(v.Array()[1:])
+}
+
+// parseMpopArgs parses what LMPOP and BLMPOP have in common, starting at numkeys.
+func parseMpopArgs(args []serdes.Value) (proto.LmpopCommand, error) {
+	numKeys, err := parseInt(args[0])
+	if err != nil || numKeys <= 0 {
+		return proto.LmpopCommand{}, errors.New("ERR numkeys should be greater than 0")
+	}
+	if numKeys >= int64(len(args)-1) {
+		return proto.LmpopCommand{}, errSyntax
+	}
+
+	cmd := proto.LmpopCommand{
+		Count: 1,
+	}
+	for _, key := range args[1 : numKeys+1] {
+		cmd.Keys = append(cmd.Keys, key.String())
+	}
+
+	rest := args[numKeys+1:]
+	if cmd.Left, err = parseSide(rest[0]); err != nil {
+		return proto.LmpopCommand{}, err
+	}
+	switch {
+	case len(rest) == 1:
+	case len(rest) == 3 && strings.ToUpper(rest[1].String()) == "COUNT":
+		count, err := parseInt(rest[2])
+		if err != nil || count <= 0 {
+			return proto.LmpopCommand{}, errors.New("ERR count should be greater than 0")
+		}
+		cmd.Count = count
+	default:
+		return proto.LmpopCommand{}, errSyntax
+	}
+
+	return cmd, nil
+}
+
+// parseSide parses the LEFT | RIGHT argument of the list commands, it reports whether it's LEFT.
+func parseSide(v serdes.Value) (bool, error) {
+	switch strings.ToUpper(v.String()) {
+	case "LEFT":
+		return true, nil
+	case "RIGHT":
+		return false, nil
+	default:
+		return false, errSyntax
+	}
+}
+
+// parseRange parses the start and stop indexes of LRANGE like commands.
+func parseRange(start, stop serdes.Value) (int64, int64, error) {
+	from, err := parseInt(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseInt(stop)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return from, to, nil
+}
+
+// ParseBpopCommand parses BLPOP and BRPOP: BLPOP key [key ...] timeout
+func ParseBpopCommand(v serdes.Value, cmdType string) (proto.BpopCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.BpopCommand{}, errWrongArgs(cmdType)
+	}
+
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return proto.BpopCommand{}, err
+	}
+	cmd := proto.BpopCommand{
+		Left:    cmdType == proto.CommandBLPOP,
+		Timeout: timeout,
+	}
+	for _, key := range args[1 : len(args)-1] {
+		cmd.Keys = append(cmd.Keys, key.String())
+	}
+
+	return cmd, nil
+}
+
+// ParseBlmoveCommand parses BLMOVE source destination <LEFT | RIGHT> <LEFT | RIGHT> timeout
+// and BRPOPLPUSH source destination timeout.
+func ParseBlmoveCommand(v serdes.Value, cmdType string) (proto.BlmoveCommand, error) {
+	args := v.Array()
+	nonBlocking, arity := proto.CommandLMOVE, 6
+	if cmdType == proto.CommandBRPOPLPUSH {
+		nonBlocking, arity = proto.CommandRPOPLPUSH, 4
+	}
+	if len(args) != arity {
+		return proto.BlmoveCommand{}, errWrongArgs(cmdType)
+	}
+
+	lmove, err := ParseLmoveCommand(serdes.ArrayValue(args[:arity-1]), nonBlocking)
+	if err != nil {
+		return proto.BlmoveCommand{}, err
+	}
+	timeout, err := parseTimeout(args[arity-1])
+	if err != nil {
+		return proto.BlmoveCommand{}, err
+	}
+	cmd := proto.BlmoveCommand{
+		LmoveCommand: lmove,
+		Timeout:      timeout,
+	}
+
+	return cmd, nil
+}
+
+// ParseBlmpopCommand parses BLMPOP timeout numkeys key [key ...] <LEFT | RIGHT> [COUNT count]
+func ParseBlmpopCommand(v serdes.Value) (proto.BlmpopCommand, error) {
+	args := v.Array()
+	if len(args) < 5 {
+		return proto.BlmpopCommand{}, errWrongArgs(proto.CommandBLMPOP)
+	}
+
+	timeout, err := parseTimeout(args[1])
+	if err != nil {
+		return proto.BlmpopCommand{}, err
+	}
+	lmpop, err := parseMpopArgs(args[2:])
+	if err != nil {
+		return proto.BlmpopCommand{}, err
+	}
+	cmd := proto.BlmpopCommand{
+		LmpopCommand: lmpop,
+		Timeout:      timeout,
+	}
+
+	return cmd, nil
+}
@@ -1,24 +1,40 @@
 package peer
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"redis-clone/proto"
-
-	"github.com/tidwall/resp"
+	"redis-clone/serdes"
 )
 
 type Peer struct {
+	// ID is the unique id of the connection, like the client id of redis.
+	ID        int64
 	Conn      net.Conn
 	msgCh     chan Message
 	errorsCh  chan Errors
 	delPeerCh chan *Peer
+	// Protocol is the RESP version negotiated with HELLO, 2 until then.
+	Protocol int
+	// DB is the index of the database selected with SELECT, 0 until then.
+	DB int
+	// Name is the name the client gave itself, empty until then.
+	Name string
 }
 
+// peerIDs numbers the connections from 1, like redis does.
+var peerIDs atomic.Int64
+
+// Message is a command sent by a peer. Args is the command line as read, an
+// array of bulk strings, and Cmd what the server parsed out of it.
 type Message struct {
+	Args serdes.Value
 	Cmd  proto.Command
 	Peer *Peer
 }
@@ -30,10 +46,12 @@ type Errors struct {
 
 func NewPeer(conn net.Conn, msgCh chan Message, delCh chan *Peer, errorsCh chan Errors) *Peer {
 	return &Peer{
+		ID:        peerIDs.Add(1),
 		Conn:      conn,
 		errorsCh:  errorsCh,
 		msgCh:     msgCh,
 		delPeerCh: delCh,
+		Protocol:  2,
 	}
 }
 
@@ -41,88 +59,66 @@ func (p *Peer) Send(msg []byte) (int, error) {
 	return p.Conn.Write(msg)
 }
 
+// Writer returns a writer of the replies to the peer, for the protocol it
+// negotiated.
+func (p *Peer) Writer() *serdes.Writer {
+	return serdes.NewWriter(p.Conn, p.Protocol)
+}
+
 // readLoop will read whatever we receive in the connection and
-// sends it to our server via the msg channel
+// sends it to our server via the msg channel, which parses and runs it
 func (p *Peer) ReadLoop() error {
-	rd := resp.NewReader(p.Conn)
+	rd := serdes.NewReader(p.Conn)
 
 	for {
-		v, _, err := rd.ReadValue()
+		v, err := rd.ReadCommand()
 		if err == io.EOF {
 			p.delPeerCh <- p
 			break
 		}
+		var protoErr *serdes.ProtocolError
+		if errors.As(err, &protoErr) {
+			// Like redis, the client learns what was wrong before the
+			// connection is closed.
+			p.errorsCh <- Errors{
+				Err:  fmt.Errorf("ERR %w", err),
+				Peer: p,
+			}
+		}
 		if err != nil {
+			// The server still has to forget about us, blocked commands included.
+			p.delPeerCh <- p
 			return err
 		}
 
-		if v.Type() == resp.Array {
-			cmd, err := parseCommand(v)
-			if err != nil {
-				fmt.Println("Error parsing command:", err)
-				p.errorsCh <- Errors{
-					Err:  err,
-					Peer: p,
-				}
-				continue
-			}
-
-			p.msgCh <- Message{
-				Cmd:  cmd,
-				Peer: p,
-			}
+		p.msgCh <- Message{
+			Args: v,
+			Peer: p,
 		}
 	}
 	return nil
 }
 
-func parseCommand(v resp.Value) (proto.Command, error) {
-	if len(v.Array()) < 1 {
-		return nil, fmt.Errorf("invalid command format: empty array")
+// ParseClientCommand parses the CLIENT subcommands: ID, GETNAME, SETNAME
+// connection-name and SETINFO <LIB-NAME libname | LIB-VER libver>.
+func ParseClientCommand(v serdes.Value) (proto.ClientCommand, error) {
+	args := v.Array()
+	cmd := proto.ClientCommand{
+		Subcommand: strings.ToUpper(args[1].String()),
 	}
-
-	rawCmd := v.Array()[0].String()
-	cmdType := strings.ToUpper(rawCmd)
-
-	switch cmdType {
-	case proto.CommandCLIENT:
-		return parseClientCommand(v)
-	case proto.CommandSET:
-		return parseSetCommand(v)
-	case proto.CommandGET:
-		return parseGetCommand(v)
-	case proto.CommandHELLO:
-		return parseHelloCommand(v)
-	case proto.CommandCOMMAND:
-		return parseCommandCommand(v)
-	case proto.CommandPING:
-		return parsePingCommand(v)
-	case proto.CommandCONFIG:
-		return parseConfigGetCommand(v)
-	case proto.CommandEXIST:
-		return parseExistCommand(v)
-	case proto.CommandDEL:
-		return parseDelCommand(v)
-	case proto.CommandINCR:
-		return parseIncrCommand(v)
-	case proto.CommandDECR:
-		return parseDecrCommand(v)
-	case proto.CommandLPUSH:
-		return parseLpushCommand(v)
-	default:
-		return nil, fmt.Errorf("unsupported command: %s", cmdType)
+	for _, arg := range args[2:] {
+		cmd.Args = append(cmd.Args, arg.String())
 	}
-}
-
-func parseClientCommand(v resp.Value) (proto.ClientCommand, error) {
-	cmd := proto.ClientCommand{
-		Value: v.Array()[1].String(),
+	if cmd.Subcommand == "SETINFO" {
+		if attr := strings.ToUpper(cmd.Args[0]); attr != "LIB-NAME" && attr != "LIB-VER" {
+			return proto.ClientCommand{}, fmt.Errorf("ERR Unrecognized option '%s'", cmd.Args[0])
+		}
 	}
 
 	return cmd, nil
 }
 
-// parseSetCommand parses the set command in redis
+// ParseSetCommand parses the set command in redis
 // basically something like this: "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n"
 // TL;DR this mainly means:
 // *3 => the number of arguments we are sending
@@ -133,142 +129,203 @@ func parseClientCommand(v resp.Value) (proto.ClientCommand, error) {
 // foo => the second argument
 // $3 => the length of the third argument
 // bar => the third argument.
-func parseSetCommand(v resp.Value) (proto.SetCommand, error) {
-	if len(v.Array()) != 3 {
-		return proto.SetCommand{}, fmt.Errorf("invalid number of variables for SET command")
+func ParseSetCommand(v serdes.Value) (proto.SetCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.SetCommand{}, errWrongArgs(proto.CommandSET)
 	}
 	cmd := proto.SetCommand{
-		Key:   v.Array()[1].Bytes(),
-		Value: v.Array()[2].Bytes(),
+		Name:  "set",
+		Key:   args[1].Bytes(),
+		Value: args[2].Bytes(),
 	}
-	return cmd, nil
-}
 
-// parseGetCommand is the same thing as parseSetCommand just the number of arguments that's different.
-func parseGetCommand(v resp.Value) (proto.GetCommand, error) {
-	if len(v.Array()) != 2 {
-		return proto.GetCommand{}, fmt.Errorf("invalid number of variables for GET command")
-	}
-	cmd := proto.GetCommand{
-		Key: v.Array()[1].Bytes(),
+	// Then come the options: [NX | XX] [GET] [EX seconds | PX milliseconds |
+	// EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+	hasExpire := false
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); opt {
+		case "NX":
+			if cmd.XX {
+				return proto.SetCommand{}, errSyntax
+			}
+			cmd.NX = true
+		case "XX":
+			if cmd.NX {
+				return proto.SetCommand{}, errSyntax
+			}
+			cmd.XX = true
+		case "GET":
+			cmd.Get = true
+		case "KEEPTTL":
+			if hasExpire {
+				return proto.SetCommand{}, errSyntax
+			}
+			cmd.KeepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if hasExpire || cmd.KeepTTL || i+1 == len(args) {
+				return proto.SetCommand{}, errSyntax
+			}
+			i++
+			expire, err := parseInt(args[i])
+			if err != nil {
+				return proto.SetCommand{}, err
+			}
+			if expire <= 0 {
+				return proto.SetCommand{}, fmt.Errorf("ERR invalid expire time in 'set' command")
+			}
+			hasExpire = true
+			cmd.Expire = expire
+			cmd.ExpireMillis = opt == "PX" || opt == "PXAT"
+			cmd.ExpireAt = opt == "EXAT" || opt == "PXAT"
+		default:
+			return proto.SetCommand{}, errSyntax
+		}
 	}
 
 	return cmd, nil
 }
 
-func parseHelloCommand(v resp.Value) (proto.HelloCommand, error) {
+// ParseGetCommand is the same thing as ParseSetCommand just the number of arguments that's different.
+func ParseGetCommand(v serdes.Value) (proto.GetCommand, error) {
 	if len(v.Array()) != 2 {
-		return proto.HelloCommand{}, fmt.Errorf("invalid number of variables for HELLO command")
+		return proto.GetCommand{}, errWrongArgs(proto.CommandGET)
 	}
-	cmd := proto.HelloCommand{
-		Value: v.Array()[0].String(),
+	cmd := proto.GetCommand{
+		Key: v.Array()[1].Bytes(),
 	}
 
 	return cmd, nil
 }
 
-func parseCommandCommand(v resp.Value) (proto.CommandCommand, error) {
-	if len(v.Array()) != 1 {
-		return proto.CommandCommand{}, fmt.Errorf("invalid number of variables for COMMAND command")
-	}
-	cmd := proto.CommandCommand{
-		Value: v.Array()[0].String(),
+func ParseHelloCommand(v serdes.Value) (proto.HelloCommand, error) {
+	args := v.Array()[1:]
+	cmd := proto.HelloCommand{}
+	if len(args) == 0 {
+		return cmd, nil
 	}
 
-	return cmd, nil
-}
-
-func parsePingCommand(v resp.Value) (proto.PingCommand, error) {
-	if len(v.Array()) > 2 {
-		return proto.PingCommand{}, fmt.Errorf("invalid number of variables for PING command")
+	protover, err := strconv.ParseInt(args[0].String(), 10, 64)
+	if err != nil {
+		return cmd, errors.New("ERR Protocol version is not an integer or out of range")
 	}
-	cmd := proto.PingCommand{
-		Value: v.Array()[0].String(),
+	cmd.Protover = protover
+	for i := 1; i < len(args); i++ {
+		switch opt := args[i].String(); {
+		case strings.EqualFold(opt, "AUTH") && i+2 < len(args):
+			cmd.Auth, cmd.Username, cmd.Password = true, args[i+1].String(), args[i+2].String()
+			i += 2
+		case strings.EqualFold(opt, "SETNAME") && i+1 < len(args):
+			cmd.SetName, cmd.ClientName = true, args[i+1].String()
+			i++
+		default:
+			return cmd, fmt.Errorf("ERR Syntax error in HELLO option '%s'", opt)
+		}
 	}
 
 	return cmd, nil
 }
 
-func parseExistCommand(v resp.Value) (proto.ExistCommand, error) {
-	if len(v.Array()) != 2 {
-		return proto.ExistCommand{}, fmt.Errorf("invalid number of variables for GET command")
-	}
-	cmd := proto.ExistCommand{
-		Key: v.Array()[1].String(),
+// ParseCommandCommand parses COMMAND and its subcommands: COUNT, INFO [command-name ...],
+// DOCS [command-name ...], LIST [FILTERBY <MODULE module-name | ACLCAT category | PATTERN pattern>]
+// and GETKEYS command [arg ...].
+func ParseCommandCommand(v serdes.Value) (proto.CommandCommand, error) {
+	args := v.Array()
+	cmd := proto.CommandCommand{}
+	if len(args) == 1 {
+		return cmd, nil
 	}
-
-	return cmd, nil
-}
-
-func parseConfigGetCommand(v resp.Value) (proto.ConfigGetCommand, error) {
-	if len(v.Array()) < 2 {
-		return proto.ConfigGetCommand{}, fmt.Errorf("invalid number of variables for CONFIG command")
+	cmd.Subcommand = strings.ToUpper(args[1].String())
+	for _, arg := range args[2:] {
+		cmd.Args = append(cmd.Args, arg.String())
 	}
-
-	var cmd proto.ConfigGetCommand
-
-	if len(v.Array()) == 2 {
-		cmd = proto.ConfigGetCommand{
-			Key:   v.Array()[1].String(),
-			Value: "",
+	if cmd.Subcommand == "LIST" && len(cmd.Args) > 0 {
+		if len(cmd.Args) != 3 || !strings.EqualFold(cmd.Args[0], "FILTERBY") {
+			return proto.CommandCommand{}, errSyntax
 		}
-	} else if len(v.Array()) > 2 {
-		cmd = proto.ConfigGetCommand{
-			Key:   v.Array()[1].String(),
-			Value: v.Array()[2].String(),
+		switch filter := strings.ToUpper(cmd.Args[1]); filter {
+		case "MODULE", "ACLCAT", "PATTERN":
+			cmd.FilterBy, cmd.Filter = filter, cmd.Args[2]
+		default:
+			return proto.CommandCommand{}, errSyntax
 		}
+		cmd.Args = nil
 	}
 
 	return cmd, nil
 }
 
-func parseDelCommand(v resp.Value) (proto.DelCommand, error) {
-	if len(v.Array()) != 2 {
-		return proto.DelCommand{}, fmt.Errorf("invalid number of variables for GET command")
+// ParsePingCommand parses PING [message]
+func ParsePingCommand(v serdes.Value) (proto.PingCommand, error) {
+	args := v.Array()
+	if len(args) > 2 {
+		return proto.PingCommand{}, errWrongArgs(proto.CommandPING)
 	}
-	cmd := proto.DelCommand{
-		Key: v.Array()[1].String(),
+	cmd := proto.PingCommand{}
+	if len(args) == 2 {
+		cmd.HasMessage, cmd.Message = true, args[1].Bytes()
 	}
 
 	return cmd, nil
 }
 
-func parseIncrCommand(v resp.Value) (proto.IncrCommand, error) {
-	if len(v.Array()) != 2 {
-		return proto.IncrCommand{}, fmt.Errorf("invalid number of variables for GET command")
+// ParseConfigGetCommand parses CONFIG GET parameter [parameter ...]
+func ParseConfigGetCommand(v serdes.Value) (proto.ConfigGetCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.ConfigGetCommand{}, fmt.Errorf("ERR wrong number of arguments for 'config|get' command")
 	}
-	cmd := proto.IncrCommand{
-		Key: v.Array()[1].String(),
+	cmd := proto.ConfigGetCommand{}
+	for _, arg := range args[2:] {
+		cmd.Patterns = append(cmd.Patterns, strings.ToLower(arg.String()))
 	}
 
 	return cmd, nil
 }
 
-func parseDecrCommand(v resp.Value) (proto.DecrCommand, error) {
-	if len(v.Array()) != 2 {
-		return proto.DecrCommand{}, fmt.Errorf("invalid number of variables for GET command")
+// ParseConfigSetCommand parses CONFIG SET parameter value [parameter value ...]
+func ParseConfigSetCommand(v serdes.Value) (proto.ConfigSetCommand, error) {
+	args := v.Array()
+	if len(args) < 4 || len(args)%2 != 0 {
+		return proto.ConfigSetCommand{}, fmt.Errorf("ERR wrong number of arguments for 'config|set' command")
 	}
-	cmd := proto.DecrCommand{
-		Key: v.Array()[1].String(),
+	cmd := proto.ConfigSetCommand{}
+	for i := 2; i < len(args); i += 2 {
+		cmd.Params = append(cmd.Params, strings.ToLower(args[i].String()), args[i+1].String())
 	}
 
 	return cmd, nil
 }
 
-func parseLpushCommand(v resp.Value) (proto.LpushCommand, error) {
-	if len(v.Array()) < 3 {
-		return proto.LpushCommand{}, fmt.Errorf("invalid number of arguments for LPUSH command")
+// ParseMemoryUsageCommand parses MEMORY USAGE key [SAMPLES count]
+func ParseMemoryUsageCommand(v serdes.Value) (proto.MemoryUsageCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.MemoryUsageCommand{}, fmt.Errorf("ERR wrong number of arguments for 'memory|usage' command")
 	}
-
-	cmd := proto.LpushCommand{
-		Key:   v.Array()[1].String(),
-		Value: getElement(v),
+	cmd := proto.MemoryUsageCommand{
+		Key:     args[2].String(),
+		Samples: 5,
+	}
+	for i := 3; i < len(args); i++ {
+		if !strings.EqualFold(args[i].String(), "SAMPLES") || i+1 == len(args) {
+			return proto.MemoryUsageCommand{}, errSyntax
+		}
+		i++
+		samples, err := parseInt(args[i])
+		if err != nil {
+			return proto.MemoryUsageCommand{}, err
+		}
+		if samples < 0 {
+			return proto.MemoryUsageCommand{}, errSyntax
+		}
+		cmd.Samples = samples
 	}
 
 	return cmd, nil
 }
 
-func getElement(v resp.Value) []string {
+func getElement(v serdes.Value) []string {
 	ret := make([]string, 0)
     for _, v := range v.Array()[2:] {
 		ret = append(ret, v.String())
@@ -0,0 +1,221 @@
+package peer
+
+import (
+	"errors"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func ParseSaddCommand(v serdes.Value) (proto.SaddCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.SaddCommand{}, errWrongArgs(proto.CommandSADD)
+	}
+	cmd := proto.SaddCommand{
+		Key:     v.Array()[1].String(),
+		Members: stringArgs(v.Array()[2:]),
+	}
+
+	return cmd, nil
+}
+
+func ParseSremCommand(v serdes.Value) (proto.SremCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.SremCommand{}, errWrongArgs(proto.CommandSREM)
+	}
+	cmd := proto.SremCommand{
+		Key:     v.Array()[1].String(),
+		Members: stringArgs(v.Array()[2:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseSismemberCommand parses SISMEMBER key member and SMISMEMBER key member [member ...]
+func ParseSismemberCommand(v serdes.Value, cmdType string) (proto.SismemberCommand, error) {
+	args := v.Array()
+	multi := cmdType == proto.CommandSMISMEMBER
+	if len(args) < 3 || (!multi && len(args) != 3) {
+		return proto.SismemberCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.SismemberCommand{
+		Key:     args[1].String(),
+		Members: stringArgs(args[2:]),
+		Multi:   multi,
+	}
+
+	return cmd, nil
+}
+
+func ParseSmembersCommand(v serdes.Value) (proto.SmembersCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.SmembersCommand{}, errWrongArgs(proto.CommandSMEMBERS)
+	}
+	cmd := proto.SmembersCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseScardCommand(v serdes.Value) (proto.ScardCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.ScardCommand{}, errWrongArgs(proto.CommandSCARD)
+	}
+	cmd := proto.ScardCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseSpopCommand parses SPOP key [count]
+func ParseSpopCommand(v serdes.Value) (proto.SpopCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 3 {
+		return proto.SpopCommand{}, errWrongArgs(proto.CommandSPOP)
+	}
+
+	cmd := proto.SpopCommand{
+		Key:   args[1].String(),
+		Count: 1,
+	}
+	if len(args) == 3 {
+		count, err := parseInt(args[2])
+		if err != nil || count < 0 {
+			return proto.SpopCommand{}, errors.New("ERR value is out of range, must be positive")
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+
+	return cmd, nil
+}
+
+// ParseSrandmemberCommand parses SRANDMEMBER key [count]
+func ParseSrandmemberCommand(v serdes.Value) (proto.SrandmemberCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 3 {
+		return proto.SrandmemberCommand{}, errWrongArgs(proto.CommandSRANDMEMBER)
+	}
+
+	cmd := proto.SrandmemberCommand{
+		Key:   args[1].String(),
+		Count: 1,
+	}
+	if len(args) == 3 {
+		count, err := parseInt(args[2])
+		if err != nil {
+			return proto.SrandmemberCommand{}, err
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+
+	if err := checkRandCount(cmd.Count, false); err != nil {
+		return proto.SrandmemberCommand{}, err
+	}
+
+	return cmd, nil
+}
+
+func ParseSmoveCommand(v serdes.Value) (proto.SmoveCommand, error) {
+	if len(v.Array()) != 4 {
+		return proto.SmoveCommand{}, errWrongArgs(proto.CommandSMOVE)
+	}
+	cmd := proto.SmoveCommand{
+		Source:      v.Array()[1].String(),
+		Destination: v.Array()[2].String(),
+		Member:      v.Array()[3].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseSetOpCommand parses SINTER key [key ...] and SINTERSTORE destination key [key ...],
+// as well as the SUNION and SDIFF variants.
+func ParseSetOpCommand(v serdes.Value, cmdType string) (proto.SetOpCommand, error) {
+	args := v.Array()
+	store := strings.HasSuffix(cmdType, "STORE")
+	if len(args) < 2 || (store && len(args) < 3) {
+		return proto.SetOpCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.SetOpCommand{
+		Op: strings.TrimSuffix(cmdType, "STORE"),
+	}
+	if store {
+		cmd.Destination = args[1].String()
+		args = args[1:]
+	}
+	cmd.Keys = stringArgs(args[1:])
+
+	return cmd, nil
+}
+
+// ParseSintercardCommand parses SINTERCARD numkeys key [key ...] [LIMIT limit]
+func ParseSintercardCommand(v serdes.Value) (proto.SintercardCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.SintercardCommand{}, errWrongArgs(proto.CommandSINTERCARD)
+	}
+
+	keys, rest, err := parseNumKeys(args[1:])
+	if err != nil {
+		return proto.SintercardCommand{}, err
+	}
+	cmd := proto.SintercardCommand{
+		Keys: keys,
+	}
+	for i := 0; i < len(rest); i += 2 {
+		if strings.ToUpper(rest[i].String()) != "LIMIT" || i+1 == len(rest) {
+			return proto.SintercardCommand{}, errSyntax
+		}
+		limit, err := parseInt(rest[i+1])
+		if err != nil {
+			return proto.SintercardCommand{}, err
+		}
+		if limit < 0 {
+			return proto.SintercardCommand{}, errors.New("ERR LIMIT can't be negative")
+		}
+		cmd.Limit = limit
+	}
+
+	return cmd, nil
+}
+
+func ParseSscanCommand(v serdes.Value) (proto.SscanCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.SscanCommand{}, errWrongArgs(proto.CommandSSCAN)
+	}
+
+	scan, rest, err := parseScanArgs(args[2:])
+	if err != nil {
+		return proto.SscanCommand{}, err
+	}
+	if len(rest) > 0 {
+		return proto.SscanCommand{}, errSyntax
+	}
+	cmd := proto.SscanCommand{
+		Key:      args[1].String(),
+		ScanArgs: scan,
+	}
+
+	return cmd, nil
+}
+
+// parseNumKeys parses numkeys key [key ...] and returns the keys and the
+// arguments following them.
+func parseNumKeys(args []serdes.Value) ([]string, []serdes.Value, error) {
+	numKeys, err := parseInt(args[0])
+	if err != nil || numKeys <= 0 {
+		return nil, nil, errors.New("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, nil, errors.New("ERR Number of keys can't be greater than number of args")
+	}
+
+	return stringArgs(args[1 : numKeys+1]), args[numKeys+1:], nil
+}
@@ -0,0 +1,726 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var errInvalidStreamID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+// ParseXaddCommand parses XADD key [NOMKSTREAM] [<MAXLEN | MINID> [= | ~] threshold [LIMIT count]] <* | id> field value [field value ...]
+func ParseXaddCommand(v serdes.Value) (proto.XaddCommand, error) {
+	args := v.Array()
+	if len(args) < 5 {
+		return proto.XaddCommand{}, errWrongArgs(proto.CommandXADD)
+	}
+
+	cmd := proto.XaddCommand{
+		Key: args[1].String(),
+	}
+	i := 2
+	if strings.ToUpper(args[i].String()) == "NOMKSTREAM" {
+		cmd.NoMkStream = true
+		i++
+	}
+	trim, i, err := parseStreamTrim(args, i)
+	if err != nil {
+		return proto.XaddCommand{}, err
+	}
+	cmd.Trim = trim
+
+	fields := args[min(i+1, len(args)):]
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return proto.XaddCommand{}, errWrongArgs(proto.CommandXADD)
+	}
+	switch id := args[i].String(); {
+	case id == "*":
+		cmd.AutoID = true
+	case strings.HasSuffix(id, "-*"):
+		ms, err := strconv.ParseUint(strings.TrimSuffix(id, "-*"), 10, 64)
+		if err != nil {
+			return proto.XaddCommand{}, errInvalidStreamID
+		}
+		cmd.ID = proto.StreamID{Ms: ms}
+		cmd.AutoSeq = true
+	default:
+		if cmd.ID, err = parseStreamID(args[i], 0); err != nil {
+			return proto.XaddCommand{}, err
+		}
+		if cmd.ID == (proto.StreamID{}) {
+			return proto.XaddCommand{}, errors.New("ERR The ID specified in XADD must be greater than 0-0")
+		}
+	}
+	cmd.Fields = stringArgs(fields)
+
+	return cmd, nil
+}
+
+// ParseXtrimCommand parses XTRIM key <MAXLEN | MINID> [= | ~] threshold [LIMIT count]
+func ParseXtrimCommand(v serdes.Value) (proto.XtrimCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.XtrimCommand{}, errWrongArgs(proto.CommandXTRIM)
+	}
+
+	trim, i, err := parseStreamTrim(args, 2)
+	if err != nil {
+		return proto.XtrimCommand{}, err
+	}
+	if trim == nil || i != len(args) {
+		return proto.XtrimCommand{}, errSyntax
+	}
+	cmd := proto.XtrimCommand{
+		Key:        args[1].String(),
+		StreamTrim: *trim,
+	}
+
+	return cmd, nil
+}
+
+// parseStreamTrim parses the trimming options starting at args[i] and
+// returns the index of the first argument that is not one of them.
+func parseStreamTrim(args []serdes.Value, i int) (*proto.StreamTrim, int, error) {
+	var trim *proto.StreamTrim
+	hasLimit := false
+	for ; i < len(args); i++ {
+		opt := strings.ToUpper(args[i].String())
+		switch {
+		case (opt == "MAXLEN" || opt == "MINID") && i+1 < len(args):
+			if trim != nil {
+				return nil, 0, errors.New("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			trim = &proto.StreamTrim{Limit: -1}
+			if op := args[i+1].String(); (op == "=" || op == "~") && i+2 < len(args) {
+				trim.Approx = op == "~"
+				i++
+			}
+			i++
+			if opt == "MINID" {
+				minID, err := parseStreamID(args[i], 0)
+				if err != nil {
+					return nil, 0, err
+				}
+				trim.ByMinID, trim.MinID = true, minID
+				continue
+			}
+			maxLen, err := parseInt(args[i])
+			if err != nil {
+				return nil, 0, err
+			}
+			if maxLen < 0 {
+				return nil, 0, errors.New("ERR The MAXLEN argument must be >= 0.")
+			}
+			trim.MaxLen = maxLen
+		case opt == "LIMIT" && i+1 < len(args):
+			limit, err := parseInt(args[i+1])
+			if err != nil {
+				return nil, 0, err
+			}
+			if limit < 0 {
+				return nil, 0, errors.New("ERR The LIMIT argument must be >= 0.")
+			}
+			if trim == nil {
+				trim = &proto.StreamTrim{}
+			}
+			trim.Limit = limit
+			hasLimit = true
+			i++
+		default:
+			if hasLimit && (trim == nil || !trim.Approx) {
+				return nil, 0, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+			}
+			return trim, i, nil
+		}
+	}
+	if hasLimit && (trim == nil || !trim.Approx) {
+		return nil, 0, errors.New("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+
+	return trim, i, nil
+}
+
+func ParseXlenCommand(v serdes.Value) (proto.XlenCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.XlenCommand{}, errWrongArgs(proto.CommandXLEN)
+	}
+	cmd := proto.XlenCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseXdelCommand(v serdes.Value) (proto.XdelCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.XdelCommand{}, errWrongArgs(proto.CommandXDEL)
+	}
+
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return proto.XdelCommand{}, err
+	}
+	cmd := proto.XdelCommand{
+		Key: args[1].String(),
+		IDs: ids,
+	}
+
+	return cmd, nil
+}
+
+// ParseXrangeCommand parses XRANGE key start end [COUNT count] and XREVRANGE key end start [COUNT count]
+func ParseXrangeCommand(v serdes.Value, cmdType string) (proto.XrangeCommand, error) {
+	args := v.Array()
+	if len(args) != 4 && len(args) != 6 {
+		if len(args) < 4 {
+			return proto.XrangeCommand{}, errWrongArgs(cmdType)
+		}
+		return proto.XrangeCommand{}, errSyntax
+	}
+
+	cmd := proto.XrangeCommand{
+		Key:   args[1].String(),
+		Rev:   cmdType == proto.CommandXREVRANGE,
+		Count: -1,
+	}
+	start, end := args[2], args[3]
+	if cmd.Rev {
+		start, end = end, start
+	}
+	var err error
+	if cmd.Start, err = parseRangeStreamID(start, false); err != nil {
+		return proto.XrangeCommand{}, err
+	}
+	if cmd.End, err = parseRangeStreamID(end, true); err != nil {
+		return proto.XrangeCommand{}, err
+	}
+	if len(args) == 6 {
+		if strings.ToUpper(args[4].String()) != "COUNT" {
+			return proto.XrangeCommand{}, errSyntax
+		}
+		if cmd.Count, err = parseInt(args[5]); err != nil {
+			return proto.XrangeCommand{}, err
+		}
+		cmd.Count = max(cmd.Count, 0)
+	}
+
+	return cmd, nil
+}
+
+// ParseXreadCommand parses XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+func ParseXreadCommand(v serdes.Value) (proto.XreadCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.XreadCommand{}, errWrongArgs(proto.CommandXREAD)
+	}
+
+	cmd := proto.XreadCommand{}
+	opts, err := parseXreadOptions(args[1:], false)
+	if err != nil {
+		return proto.XreadCommand{}, err
+	}
+	cmd.Count, cmd.Block, cmd.Timeout = opts.count, opts.block, opts.timeout
+
+	cmd.Keys = stringArgs(opts.streams[:len(opts.streams)/2])
+	for _, arg := range opts.streams[len(opts.streams)/2:] {
+		id := proto.StreamID{}
+		switch arg.String() {
+		case "$":
+		case ">":
+			return proto.XreadCommand{}, errors.New("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		default:
+			if id, err = parseStreamID(arg, 0); err != nil {
+				return proto.XreadCommand{}, err
+			}
+		}
+		cmd.IDs = append(cmd.IDs, id)
+		cmd.Last = append(cmd.Last, arg.String() == "$")
+	}
+
+	return cmd, nil
+}
+
+// ParseXreadgroupCommand parses XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func ParseXreadgroupCommand(v serdes.Value) (proto.XreadgroupCommand, error) {
+	args := v.Array()
+	if len(args) < 7 {
+		return proto.XreadgroupCommand{}, errWrongArgs(proto.CommandXREADGROUP)
+	}
+
+	opts, err := parseXreadOptions(args[1:], true)
+	if err != nil {
+		return proto.XreadgroupCommand{}, err
+	}
+	if opts.group == "" {
+		return proto.XreadgroupCommand{}, errors.New("ERR Missing GROUP option for XREADGROUP")
+	}
+	cmd := proto.XreadgroupCommand{
+		Group:    opts.group,
+		Consumer: opts.consumer,
+		Count:    opts.count,
+		Block:    opts.block,
+		Timeout:  opts.timeout,
+		NoAck:    opts.noAck,
+	}
+
+	cmd.Keys = stringArgs(opts.streams[:len(opts.streams)/2])
+	for _, arg := range opts.streams[len(opts.streams)/2:] {
+		id := proto.StreamID{}
+		switch arg.String() {
+		case ">":
+		case "$":
+			return proto.XreadgroupCommand{}, errors.New("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		default:
+			if id, err = parseStreamID(arg, 0); err != nil {
+				return proto.XreadgroupCommand{}, err
+			}
+		}
+		cmd.IDs = append(cmd.IDs, id)
+		cmd.NewOnly = append(cmd.NewOnly, arg.String() == ">")
+	}
+
+	return cmd, nil
+}
+
+type xreadOptions struct {
+	group, consumer string
+	count           int64
+	block           bool
+	timeout         time.Duration
+	noAck           bool
+	streams         []serdes.Value
+}
+
+// parseXreadOptions parses the options shared by XREAD and XREADGROUP, up to
+// and including the STREAMS keys and IDs.
+func parseXreadOptions(args []serdes.Value, group bool) (xreadOptions, error) {
+	cmdName := "xread"
+	if group {
+		cmdName = "xreadgroup"
+	}
+
+	opts := xreadOptions{}
+	for i := 0; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "COUNT" && i+1 < len(args):
+			count, err := parseInt(args[i+1])
+			if err != nil {
+				return opts, err
+			}
+			opts.count = max(count, 0)
+			i++
+		case opt == "BLOCK" && i+1 < len(args):
+			ms, err := strconv.ParseInt(args[i+1].String(), 10, 64)
+			if err != nil {
+				return opts, errors.New("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return opts, errors.New("ERR timeout is negative")
+			}
+			opts.block, opts.timeout = true, time.Duration(ms)*time.Millisecond
+			i++
+		case opt == "STREAMS":
+			opts.streams = args[i+1:]
+			if len(opts.streams) == 0 || len(opts.streams)%2 != 0 {
+				return opts, fmt.Errorf("ERR Unbalanced '%s' list of streams: for each stream key an ID or '$' must be specified.", cmdName)
+			}
+			return opts, nil
+		case opt == "GROUP" && i+2 < len(args):
+			if !group {
+				return opts, errors.New("ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead.")
+			}
+			opts.group, opts.consumer = args[i+1].String(), args[i+2].String()
+			i += 2
+		case opt == "NOACK" && group:
+			opts.noAck = true
+		default:
+			return opts, errSyntax
+		}
+	}
+
+	return opts, errSyntax
+}
+
+// ParseXgroupCommand parses the XGROUP subcommands:
+// CREATE key group <id | $> [MKSTREAM] [ENTRIESREAD entries-read], SETID key group <id | $> [ENTRIESREAD entries-read],
+// DESTROY key group, CREATECONSUMER key group consumer and DELCONSUMER key group consumer.
+func ParseXgroupCommand(v serdes.Value) (proto.XgroupCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.XgroupCommand{}, errWrongArgs(proto.CommandXGROUP)
+	}
+
+	sub := strings.ToUpper(args[1].String())
+	errArity := fmt.Errorf("ERR wrong number of arguments for 'xgroup|%s' command", strings.ToLower(sub))
+	cmd := proto.XgroupCommand{
+		Subcommand:  sub,
+		EntriesRead: -1,
+	}
+	switch sub {
+	case "CREATE", "SETID":
+		if len(args) < 5 {
+			return proto.XgroupCommand{}, errArity
+		}
+		for i := 5; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i].String()); {
+			case opt == "MKSTREAM" && sub == "CREATE":
+				cmd.MkStream = true
+			case opt == "ENTRIESREAD" && i+1 < len(args):
+				n, err := parseInt(args[i+1])
+				if err != nil {
+					return proto.XgroupCommand{}, err
+				}
+				if n < -1 {
+					return proto.XgroupCommand{}, errors.New("ERR value for ENTRIESREAD must be positive or -1")
+				}
+				cmd.EntriesRead = n
+				i++
+			default:
+				return proto.XgroupCommand{}, errSyntax
+			}
+		}
+		if args[4].String() == "$" {
+			cmd.UseLast = true
+		} else {
+			id, err := parseStreamID(args[4], 0)
+			if err != nil {
+				return proto.XgroupCommand{}, err
+			}
+			cmd.ID = id
+		}
+	case "DESTROY":
+		if len(args) != 4 {
+			return proto.XgroupCommand{}, errArity
+		}
+	case "CREATECONSUMER", "DELCONSUMER":
+		if len(args) != 5 {
+			return proto.XgroupCommand{}, errArity
+		}
+		cmd.Consumer = args[4].String()
+	default:
+		return proto.XgroupCommand{}, fmt.Errorf("ERR unknown subcommand '%s'. Try XGROUP HELP.", args[1].String())
+	}
+	cmd.Key = args[2].String()
+	cmd.Group = args[3].String()
+
+	return cmd, nil
+}
+
+func ParseXackCommand(v serdes.Value) (proto.XackCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.XackCommand{}, errWrongArgs(proto.CommandXACK)
+	}
+
+	ids, err := parseStreamIDs(args[3:])
+	if err != nil {
+		return proto.XackCommand{}, err
+	}
+	cmd := proto.XackCommand{
+		Key:   args[1].String(),
+		Group: args[2].String(),
+		IDs:   ids,
+	}
+
+	return cmd, nil
+}
+
+// ParseXpendingCommand parses XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func ParseXpendingCommand(v serdes.Value) (proto.XpendingCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.XpendingCommand{}, errWrongArgs(proto.CommandXPENDING)
+	}
+
+	cmd := proto.XpendingCommand{
+		Key:   args[1].String(),
+		Group: args[2].String(),
+	}
+	rest := args[3:]
+	if len(rest) == 0 {
+		return cmd, nil
+	}
+
+	cmd.Extended = true
+	if strings.ToUpper(rest[0].String()) == "IDLE" && len(rest) > 1 {
+		idle, err := parseInt(rest[1])
+		if err != nil {
+			return proto.XpendingCommand{}, err
+		}
+		cmd.MinIdle = idle
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		return proto.XpendingCommand{}, errSyntax
+	}
+
+	var err error
+	if cmd.Start, err = parseRangeStreamID(rest[0], false); err != nil {
+		return proto.XpendingCommand{}, err
+	}
+	if cmd.End, err = parseRangeStreamID(rest[1], true); err != nil {
+		return proto.XpendingCommand{}, err
+	}
+	if cmd.Count, err = parseInt(rest[2]); err != nil {
+		return proto.XpendingCommand{}, err
+	}
+	if len(rest) == 4 {
+		cmd.Consumer = rest[3].String()
+	}
+
+	return cmd, nil
+}
+
+// ParseXclaimCommand parses XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds]
+// [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func ParseXclaimCommand(v serdes.Value) (proto.XclaimCommand, error) {
+	args := v.Array()
+	if len(args) < 6 {
+		return proto.XclaimCommand{}, errWrongArgs(proto.CommandXCLAIM)
+	}
+
+	minIdle, err := parseInt(args[4])
+	if err != nil {
+		return proto.XclaimCommand{}, errors.New("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	cmd := proto.XclaimCommand{
+		Key:        args[1].String(),
+		Group:      args[2].String(),
+		Consumer:   args[3].String(),
+		MinIdle:    max(minIdle, 0),
+		Idle:       -1,
+		Time:       -1,
+		RetryCount: -1,
+	}
+
+	// The IDs go on until an argument is not one.
+	i := 5
+	for ; i < len(args); i++ {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		cmd.IDs = append(cmd.IDs, id)
+	}
+	if len(cmd.IDs) == 0 {
+		return proto.XclaimCommand{}, errInvalidStreamID
+	}
+
+	for ; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "FORCE":
+			cmd.Force = true
+		case opt == "JUSTID":
+			cmd.JustID = true
+		case opt == "IDLE" && i+1 < len(args):
+			if cmd.Idle, err = parseInt(args[i+1]); err != nil {
+				return proto.XclaimCommand{}, errors.New("ERR Invalid IDLE option argument for XCLAIM")
+			}
+			i++
+		case opt == "TIME" && i+1 < len(args):
+			if cmd.Time, err = parseInt(args[i+1]); err != nil {
+				return proto.XclaimCommand{}, errors.New("ERR Invalid TIME option argument for XCLAIM")
+			}
+			i++
+		case opt == "RETRYCOUNT" && i+1 < len(args):
+			if cmd.RetryCount, err = parseInt(args[i+1]); err != nil {
+				return proto.XclaimCommand{}, errors.New("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			i++
+		case opt == "LASTID" && i+1 < len(args):
+			lastID, err := parseStreamID(args[i+1], 0)
+			if err != nil {
+				return proto.XclaimCommand{}, err
+			}
+			cmd.LastID = &lastID
+			i++
+		default:
+			return proto.XclaimCommand{}, fmt.Errorf("ERR Unrecognized XCLAIM option '%s'", args[i].String())
+		}
+	}
+
+	return cmd, nil
+}
+
+// ParseXautoclaimCommand parses XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func ParseXautoclaimCommand(v serdes.Value) (proto.XautoclaimCommand, error) {
+	args := v.Array()
+	if len(args) < 6 {
+		return proto.XautoclaimCommand{}, errWrongArgs(proto.CommandXAUTOCLAIM)
+	}
+
+	minIdle, err := parseInt(args[4])
+	if err != nil {
+		return proto.XautoclaimCommand{}, errors.New("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	start, err := parseRangeStreamID(args[5], false)
+	if err != nil {
+		return proto.XautoclaimCommand{}, err
+	}
+	cmd := proto.XautoclaimCommand{
+		Key:      args[1].String(),
+		Group:    args[2].String(),
+		Consumer: args[3].String(),
+		MinIdle:  max(minIdle, 0),
+		Start:    start,
+		Count:    100,
+	}
+
+	for i := 6; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "COUNT" && i+1 < len(args):
+			count, err := parseInt(args[i+1])
+			if err != nil {
+				return proto.XautoclaimCommand{}, err
+			}
+			// The count is multiplied by 10 to get the number of PEL entries to scan.
+			if count < 1 || count > math.MaxInt64/10 {
+				return proto.XautoclaimCommand{}, errors.New("ERR COUNT must be > 0")
+			}
+			cmd.Count = count
+			i++
+		case opt == "JUSTID":
+			cmd.JustID = true
+		default:
+			return proto.XautoclaimCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
+
+// ParseXinfoCommand parses the XINFO subcommands: STREAM key [FULL [COUNT count]],
+// GROUPS key and CONSUMERS key group.
+func ParseXinfoCommand(v serdes.Value) (proto.XinfoCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.XinfoCommand{}, errWrongArgs(proto.CommandXINFO)
+	}
+
+	sub := strings.ToUpper(args[1].String())
+	errArity := fmt.Errorf("ERR wrong number of arguments for 'xinfo|%s' command", strings.ToLower(sub))
+	cmd := proto.XinfoCommand{
+		Subcommand: sub,
+		Count:      10,
+	}
+	switch sub {
+	case "STREAM":
+		if len(args) < 3 {
+			return proto.XinfoCommand{}, errArity
+		}
+		rest := args[3:]
+		if len(rest) > 0 {
+			if strings.ToUpper(rest[0].String()) != "FULL" {
+				return proto.XinfoCommand{}, errSyntax
+			}
+			cmd.Full = true
+			rest = rest[1:]
+		}
+		if len(rest) > 0 {
+			if len(rest) != 2 || strings.ToUpper(rest[0].String()) != "COUNT" {
+				return proto.XinfoCommand{}, errSyntax
+			}
+			count, err := parseInt(rest[1])
+			if err != nil {
+				return proto.XinfoCommand{}, err
+			}
+			cmd.Count = max(count, 0)
+		}
+	case "GROUPS":
+		if len(args) != 3 {
+			return proto.XinfoCommand{}, errArity
+		}
+	case "CONSUMERS":
+		if len(args) != 4 {
+			return proto.XinfoCommand{}, errArity
+		}
+		cmd.Group = args[3].String()
+	default:
+		return proto.XinfoCommand{}, fmt.Errorf("ERR unknown subcommand '%s'. Try XINFO HELP.", args[1].String())
+	}
+	cmd.Key = args[2].String()
+
+	return cmd, nil
+}
+
+// parseStreamID parses <ms>-<seq>, or <ms> alone in which case the sequence
+// number is missingSeq.
+func parseStreamID(v serdes.Value, missingSeq uint64) (proto.StreamID, error) {
+	s := v.String()
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return proto.StreamID{}, errInvalidStreamID
+	}
+	id := proto.StreamID{Ms: ms, Seq: missingSeq}
+	if hasSeq {
+		if id.Seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+			return proto.StreamID{}, errInvalidStreamID
+		}
+	}
+
+	return id, nil
+}
+
+// parseStreamIDs parses a list of stream IDs.
+func parseStreamIDs(args []serdes.Value) ([]proto.StreamID, error) {
+	ids := make([]proto.StreamID, 0, len(args))
+	for _, arg := range args {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// parseRangeStreamID parses an end of a range of stream IDs: - and + for the
+// smallest and greatest IDs, an ID exclusive when prefixed by (, and an ID
+// without sequence number standing for all of its sequence numbers.
+func parseRangeStreamID(v serdes.Value, end bool) (proto.StreamID, error) {
+	s := v.String()
+	switch s {
+	case "-":
+		return proto.StreamID{}, nil
+	case "+":
+		return proto.StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}, nil
+	}
+
+	missingSeq := uint64(0)
+	if end {
+		missingSeq = math.MaxUint64
+	}
+	if !strings.HasPrefix(s, "(") || len(s) == 1 {
+		return parseStreamID(v, missingSeq)
+	}
+
+	id, err := parseStreamID(serdes.StringValue(s[1:]), missingSeq)
+	if err != nil {
+		return proto.StreamID{}, err
+	}
+	switch {
+	case !end && id.Seq < math.MaxUint64:
+		id.Seq++
+	case !end && id.Ms < math.MaxUint64:
+		id = proto.StreamID{Ms: id.Ms + 1}
+	case !end:
+		return proto.StreamID{}, errors.New("ERR invalid start ID for the interval")
+	case id.Seq > 0:
+		id.Seq--
+	case id.Ms > 0:
+		id = proto.StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}
+	default:
+		return proto.StreamID{}, errors.New("ERR invalid end ID for the interval")
+	}
+
+	return id, nil
+}
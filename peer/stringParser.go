@@ -0,0 +1,302 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// ParseIncrbyCommand parses INCR key, DECR key, INCRBY key increment and DECRBY key decrement.
+func ParseIncrbyCommand(v serdes.Value, cmdType string) (proto.IncrbyCommand, error) {
+	args := v.Array()
+	withDelta := cmdType == proto.CommandINCRBY || cmdType == proto.CommandDECRBY
+	if (withDelta && len(args) != 3) || (!withDelta && len(args) != 2) {
+		return proto.IncrbyCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.IncrbyCommand{
+		Key:   args[1].String(),
+		Delta: 1,
+	}
+	if withDelta {
+		delta, err := parseInt(args[2])
+		if err != nil {
+			return proto.IncrbyCommand{}, err
+		}
+		cmd.Delta = delta
+	}
+	if cmdType == proto.CommandDECR || cmdType == proto.CommandDECRBY {
+		if cmd.Delta == math.MinInt64 {
+			return proto.IncrbyCommand{}, errors.New("ERR decrement would overflow")
+		}
+		cmd.Delta = -cmd.Delta
+	}
+
+	return cmd, nil
+}
+
+func ParseIncrbyfloatCommand(v serdes.Value) (proto.IncrbyfloatCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.IncrbyfloatCommand{}, errWrongArgs(proto.CommandINCRBYFLOAT)
+	}
+
+	delta, err := parseFloat(args[2])
+	if err != nil {
+		return proto.IncrbyfloatCommand{}, err
+	}
+	cmd := proto.IncrbyfloatCommand{
+		Key:   args[1].String(),
+		Delta: delta,
+	}
+
+	return cmd, nil
+}
+
+func ParseMgetCommand(v serdes.Value) (proto.MgetCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.MgetCommand{}, errWrongArgs(proto.CommandMGET)
+	}
+	cmd := proto.MgetCommand{
+		Keys: stringArgs(args[1:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseMsetCommand parses MSET and MSETNX: MSET key value [key value ...]
+func ParseMsetCommand(v serdes.Value, cmdType string) (proto.MsetCommand, error) {
+	args := v.Array()
+	if len(args) < 3 || len(args)%2 != 1 {
+		return proto.MsetCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.MsetCommand{
+		Pairs: stringArgs(args[1:]),
+		NX:    cmdType == proto.CommandMSETNX,
+	}
+
+	return cmd, nil
+}
+
+func ParseSetnxCommand(v serdes.Value) (proto.SetnxCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.SetnxCommand{}, errWrongArgs(proto.CommandSETNX)
+	}
+	cmd := proto.SetnxCommand{
+		Key:   args[1].Bytes(),
+		Value: args[2].Bytes(),
+	}
+
+	return cmd, nil
+}
+
+// ParseSetexCommand parses SETEX key seconds value and PSETEX key milliseconds
+// value, which are SET with the EX and PX options.
+func ParseSetexCommand(v serdes.Value, cmdType string) (proto.SetCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.SetCommand{}, errWrongArgs(cmdType)
+	}
+
+	expire, err := parseInt(args[2])
+	if err != nil {
+		return proto.SetCommand{}, err
+	}
+	name := strings.ToLower(cmdType)
+	if expire <= 0 {
+		return proto.SetCommand{}, fmt.Errorf("ERR invalid expire time in '%s' command", name)
+	}
+	cmd := proto.SetCommand{
+		Name:         name,
+		Key:          args[1].Bytes(),
+		Value:        args[3].Bytes(),
+		Expire:       expire,
+		ExpireMillis: cmdType == proto.CommandPSETEX,
+	}
+
+	return cmd, nil
+}
+
+// ParseGetsetCommand parses GETSET key value, which is SET with the GET option.
+func ParseGetsetCommand(v serdes.Value) (proto.SetCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.SetCommand{}, errWrongArgs(proto.CommandGETSET)
+	}
+	cmd := proto.SetCommand{
+		Name:  "getset",
+		Key:   args[1].Bytes(),
+		Value: args[2].Bytes(),
+		Get:   true,
+	}
+
+	return cmd, nil
+}
+
+func ParseGetdelCommand(v serdes.Value) (proto.GetdelCommand, error) {
+	args := v.Array()
+	if len(args) != 2 {
+		return proto.GetdelCommand{}, errWrongArgs(proto.CommandGETDEL)
+	}
+	cmd := proto.GetdelCommand{
+		Key: args[1].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseGetexCommand parses GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds |
+// PXAT unix-time-milliseconds | PERSIST]
+func ParseGetexCommand(v serdes.Value) (proto.GetexCommand, error) {
+	args := v.Array()
+	if len(args) < 2 {
+		return proto.GetexCommand{}, errWrongArgs(proto.CommandGETEX)
+	}
+
+	cmd := proto.GetexCommand{
+		Key: args[1].String(),
+	}
+	for i := 2; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); opt {
+		case "PERSIST":
+			if cmd.Expire != 0 {
+				return proto.GetexCommand{}, errSyntax
+			}
+			cmd.Persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if cmd.Expire != 0 || cmd.Persist || i+1 == len(args) {
+				return proto.GetexCommand{}, errSyntax
+			}
+			i++
+			expire, err := parseInt(args[i])
+			if err != nil {
+				return proto.GetexCommand{}, err
+			}
+			if expire <= 0 {
+				return proto.GetexCommand{}, errors.New("ERR invalid expire time in 'getex' command")
+			}
+			cmd.Expire = expire
+			cmd.ExpireMillis = opt == "PX" || opt == "PXAT"
+			cmd.ExpireAt = opt == "EXAT" || opt == "PXAT"
+		default:
+			return proto.GetexCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
+
+func ParseAppendCommand(v serdes.Value) (proto.AppendCommand, error) {
+	args := v.Array()
+	if len(args) != 3 {
+		return proto.AppendCommand{}, errWrongArgs(proto.CommandAPPEND)
+	}
+	cmd := proto.AppendCommand{
+		Key:   args[1].String(),
+		Value: args[2].Bytes(),
+	}
+
+	return cmd, nil
+}
+
+func ParseStrlenCommand(v serdes.Value) (proto.StrlenCommand, error) {
+	args := v.Array()
+	if len(args) != 2 {
+		return proto.StrlenCommand{}, errWrongArgs(proto.CommandSTRLEN)
+	}
+	cmd := proto.StrlenCommand{
+		Key: args[1].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseGetrangeCommand parses GETRANGE key start end
+func ParseGetrangeCommand(v serdes.Value) (proto.GetrangeCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.GetrangeCommand{}, errWrongArgs(proto.CommandGETRANGE)
+	}
+
+	start, err := parseInt(args[2])
+	if err != nil {
+		return proto.GetrangeCommand{}, err
+	}
+	end, err := parseInt(args[3])
+	if err != nil {
+		return proto.GetrangeCommand{}, err
+	}
+	cmd := proto.GetrangeCommand{
+		Key:   args[1].String(),
+		Start: start,
+		End:   end,
+	}
+
+	return cmd, nil
+}
+
+// ParseSetrangeCommand parses SETRANGE key offset value
+func ParseSetrangeCommand(v serdes.Value) (proto.SetrangeCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.SetrangeCommand{}, errWrongArgs(proto.CommandSETRANGE)
+	}
+
+	offset, err := parseInt(args[2])
+	if err != nil {
+		return proto.SetrangeCommand{}, err
+	}
+	if offset < 0 {
+		return proto.SetrangeCommand{}, errors.New("ERR offset is out of range")
+	}
+	cmd := proto.SetrangeCommand{
+		Key:    args[1].String(),
+		Offset: offset,
+		Value:  args[3].Bytes(),
+	}
+
+	return cmd, nil
+}
+
+// ParseLcsCommand parses LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func ParseLcsCommand(v serdes.Value) (proto.LcsCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.LcsCommand{}, errWrongArgs(proto.CommandLCS)
+	}
+
+	cmd := proto.LcsCommand{
+		Key1: args[1].String(),
+		Key2: args[2].String(),
+	}
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "LEN":
+			cmd.Len = true
+		case opt == "IDX":
+			cmd.Idx = true
+		case opt == "WITHMATCHLEN":
+			cmd.WithMatchLen = true
+		case opt == "MINMATCHLEN" && i+1 < len(args):
+			minLen, err := parseInt(args[i+1])
+			if err != nil {
+				return proto.LcsCommand{}, err
+			}
+			cmd.MinMatchLen = max(minLen, 0)
+			i++
+		default:
+			return proto.LcsCommand{}, errSyntax
+		}
+	}
+	if cmd.Len && cmd.Idx {
+		return proto.LcsCommand{}, errors.New("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	return cmd, nil
+}
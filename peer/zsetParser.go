@@ -0,0 +1,499 @@
+package peer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var (
+	errNotFloatRange = errors.New("ERR min or max is not a float")
+	errNotLexRange   = errors.New("ERR min or max not valid string range item")
+)
+
+// ParseZaddCommand parses ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func ParseZaddCommand(v serdes.Value) (proto.ZaddCommand, error) {
+	args := v.Array()
+	if len(args) < 4 {
+		return proto.ZaddCommand{}, errWrongArgs(proto.CommandZADD)
+	}
+
+	cmd := proto.ZaddCommand{
+		Key: args[1].String(),
+	}
+	i := 2
+flags:
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(args[i].String()) {
+		case "NX":
+			cmd.NX = true
+		case "XX":
+			cmd.XX = true
+		case "GT":
+			cmd.GT = true
+		case "LT":
+			cmd.LT = true
+		case "CH":
+			cmd.CH = true
+		case "INCR":
+			cmd.Incr = true
+		default:
+			break flags
+		}
+	}
+
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return proto.ZaddCommand{}, errSyntax
+	}
+	if cmd.NX && cmd.XX {
+		return proto.ZaddCommand{}, errors.New("ERR XX and NX options at the same time are not compatible")
+	}
+	if (cmd.GT && cmd.NX) || (cmd.LT && cmd.NX) || (cmd.GT && cmd.LT) {
+		return proto.ZaddCommand{}, errors.New("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	if cmd.Incr && len(pairs) > 2 {
+		return proto.ZaddCommand{}, errors.New("ERR INCR option supports a single increment-element pair")
+	}
+
+	for i := 0; i < len(pairs); i += 2 {
+		score, err := parseFloat(pairs[i])
+		if err != nil {
+			return proto.ZaddCommand{}, err
+		}
+		cmd.Members = append(cmd.Members, proto.ScoreMember{Score: score, Member: pairs[i+1].String()})
+	}
+
+	return cmd, nil
+}
+
+func ParseZincrbyCommand(v serdes.Value) (proto.ZincrbyCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.ZincrbyCommand{}, errWrongArgs(proto.CommandZINCRBY)
+	}
+
+	increment, err := parseFloat(args[2])
+	if err != nil {
+		return proto.ZincrbyCommand{}, err
+	}
+	cmd := proto.ZincrbyCommand{
+		Key:       args[1].String(),
+		Increment: increment,
+		Member:    args[3].String(),
+	}
+
+	return cmd, nil
+}
+
+func ParseZcardCommand(v serdes.Value) (proto.ZcardCommand, error) {
+	if len(v.Array()) != 2 {
+		return proto.ZcardCommand{}, errWrongArgs(proto.CommandZCARD)
+	}
+	cmd := proto.ZcardCommand{
+		Key: v.Array()[1].String(),
+	}
+
+	return cmd, nil
+}
+
+// ParseZscoreCommand parses ZSCORE key member and ZMSCORE key member [member ...]
+func ParseZscoreCommand(v serdes.Value, cmdType string) (proto.ZscoreCommand, error) {
+	args := v.Array()
+	multi := cmdType == proto.CommandZMSCORE
+	if len(args) < 3 || (!multi && len(args) != 3) {
+		return proto.ZscoreCommand{}, errWrongArgs(cmdType)
+	}
+	cmd := proto.ZscoreCommand{
+		Key:     args[1].String(),
+		Members: stringArgs(args[2:]),
+		Multi:   multi,
+	}
+
+	return cmd, nil
+}
+
+// ParseZrankCommand parses ZRANK key member [WITHSCORE] and ZREVRANK.
+func ParseZrankCommand(v serdes.Value, cmdType string) (proto.ZrankCommand, error) {
+	args := v.Array()
+	if len(args) < 3 || len(args) > 4 {
+		return proto.ZrankCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.ZrankCommand{
+		Key:    args[1].String(),
+		Member: args[2].String(),
+		Rev:    cmdType == proto.CommandZREVRANK,
+	}
+	if len(args) == 4 {
+		if strings.ToUpper(args[3].String()) != "WITHSCORE" {
+			return proto.ZrankCommand{}, errSyntax
+		}
+		cmd.WithScore = true
+	}
+
+	return cmd, nil
+}
+
+// ParseZrangeCommand parses ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES],
+// ZRANGESTORE dst src start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+// and the legacy ZREVRANGE, Z[REV]RANGEBYSCORE and Z[REV]RANGEBYLEX.
+func ParseZrangeCommand(v serdes.Value, cmdType string) (proto.ZrangeCommand, error) {
+	args := v.Array()
+	cmd := proto.ZrangeCommand{
+		ZrangeSpec: proto.ZrangeSpec{Count: -1},
+	}
+	if cmdType == proto.CommandZRANGESTORE {
+		if len(args) < 5 {
+			return proto.ZrangeCommand{}, errWrongArgs(cmdType)
+		}
+		cmd.Destination = args[1].String()
+		args = args[1:]
+	}
+	if len(args) < 4 {
+		return proto.ZrangeCommand{}, errWrongArgs(cmdType)
+	}
+	cmd.Key = args[1].String()
+
+	legacy := true
+	switch cmdType {
+	case proto.CommandZRANGE, proto.CommandZRANGESTORE:
+		legacy = false
+	case proto.CommandZREVRANGE:
+		cmd.Rev = true
+	case proto.CommandZRANGEBYSCORE:
+		cmd.By = "BYSCORE"
+	case proto.CommandZREVRANGEBYSCORE:
+		cmd.By, cmd.Rev = "BYSCORE", true
+	case proto.CommandZRANGEBYLEX:
+		cmd.By = "BYLEX"
+	case proto.CommandZREVRANGEBYLEX:
+		cmd.By, cmd.Rev = "BYLEX", true
+	}
+
+	hasLimit := false
+	for i := 4; i < len(args); i++ {
+		switch opt := strings.ToUpper(args[i].String()); {
+		case opt == "WITHSCORES" && cmd.Destination == "":
+			cmd.WithScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			offset, err := parseInt(args[i+1])
+			if err != nil {
+				return proto.ZrangeCommand{}, err
+			}
+			count, err := parseInt(args[i+2])
+			if err != nil {
+				return proto.ZrangeCommand{}, err
+			}
+			cmd.Offset, cmd.Count = offset, count
+			hasLimit = true
+			i += 2
+		case !legacy && (opt == "BYSCORE" || opt == "BYLEX") && cmd.By == "":
+			cmd.By = opt
+		case !legacy && opt == "REV":
+			cmd.Rev = true
+		default:
+			return proto.ZrangeCommand{}, errSyntax
+		}
+	}
+	if hasLimit && cmd.By == "" {
+		return proto.ZrangeCommand{}, errors.New("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if cmd.WithScores && cmd.By == "BYLEX" {
+		return proto.ZrangeCommand{}, errors.New("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+
+	// With REV the range is given from max to min.
+	start, stop := args[2], args[3]
+	if cmd.Rev && cmd.By != "" {
+		start, stop = stop, start
+	}
+	spec, err := parseZrangeEnds(cmd.ZrangeSpec, start, stop)
+	if err != nil {
+		return proto.ZrangeCommand{}, err
+	}
+	cmd.ZrangeSpec = spec
+
+	return cmd, nil
+}
+
+// ParseZcountCommand parses ZCOUNT key min max and ZLEXCOUNT key min max
+func ParseZcountCommand(v serdes.Value, cmdType string) (proto.ZcountCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.ZcountCommand{}, errWrongArgs(cmdType)
+	}
+
+	spec := proto.ZrangeSpec{By: "BYSCORE", Count: -1}
+	if cmdType == proto.CommandZLEXCOUNT {
+		spec.By = "BYLEX"
+	}
+	spec, err := parseZrangeEnds(spec, args[2], args[3])
+	if err != nil {
+		return proto.ZcountCommand{}, err
+	}
+	cmd := proto.ZcountCommand{
+		Key:        args[1].String(),
+		ZrangeSpec: spec,
+	}
+
+	return cmd, nil
+}
+
+func ParseZremCommand(v serdes.Value) (proto.ZremCommand, error) {
+	if len(v.Array()) < 3 {
+		return proto.ZremCommand{}, errWrongArgs(proto.CommandZREM)
+	}
+	cmd := proto.ZremCommand{
+		Key:     v.Array()[1].String(),
+		Members: stringArgs(v.Array()[2:]),
+	}
+
+	return cmd, nil
+}
+
+// ParseZremrangeCommand parses ZREMRANGEBYRANK key start stop, ZREMRANGEBYSCORE key min max
+// and ZREMRANGEBYLEX key min max
+func ParseZremrangeCommand(v serdes.Value, cmdType string) (proto.ZremrangeCommand, error) {
+	args := v.Array()
+	if len(args) != 4 {
+		return proto.ZremrangeCommand{}, errWrongArgs(cmdType)
+	}
+
+	spec := proto.ZrangeSpec{Count: -1}
+	switch cmdType {
+	case proto.CommandZREMRANGEBYSCORE:
+		spec.By = "BYSCORE"
+	case proto.CommandZREMRANGEBYLEX:
+		spec.By = "BYLEX"
+	}
+	spec, err := parseZrangeEnds(spec, args[2], args[3])
+	if err != nil {
+		return proto.ZremrangeCommand{}, err
+	}
+	cmd := proto.ZremrangeCommand{
+		Key:        args[1].String(),
+		ZrangeSpec: spec,
+	}
+
+	return cmd, nil
+}
+
+// ParseZpopCommand parses ZPOPMIN key [count] and ZPOPMAX key [count]
+func ParseZpopCommand(v serdes.Value, cmdType string) (proto.ZpopCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 3 {
+		return proto.ZpopCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.ZpopCommand{
+		Key:   args[1].String(),
+		Max:   cmdType == proto.CommandZPOPMAX,
+		Count: 1,
+	}
+	if len(args) == 3 {
+		count, err := parseInt(args[2])
+		if err != nil || count < 0 {
+			return proto.ZpopCommand{}, errors.New("ERR value is out of range, must be positive")
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+
+	return cmd, nil
+}
+
+// ParseBzpopCommand parses BZPOPMIN and BZPOPMAX: BZPOPMIN key [key ...] timeout
+func ParseBzpopCommand(v serdes.Value, cmdType string) (proto.BzpopCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.BzpopCommand{}, errWrongArgs(cmdType)
+	}
+
+	timeout, err := parseTimeout(args[len(args)-1])
+	if err != nil {
+		return proto.BzpopCommand{}, err
+	}
+	cmd := proto.BzpopCommand{
+		Keys:    stringArgs(args[1 : len(args)-1]),
+		Max:     cmdType == proto.CommandBZPOPMAX,
+		Timeout: timeout,
+	}
+
+	return cmd, nil
+}
+
+// ParseZrandmemberCommand parses ZRANDMEMBER key [count [WITHSCORES]]
+func ParseZrandmemberCommand(v serdes.Value) (proto.ZrandmemberCommand, error) {
+	args := v.Array()
+	if len(args) < 2 || len(args) > 4 {
+		return proto.ZrandmemberCommand{}, errWrongArgs(proto.CommandZRANDMEMBER)
+	}
+
+	cmd := proto.ZrandmemberCommand{
+		Key:   args[1].String(),
+		Count: 1,
+	}
+	if len(args) >= 3 {
+		count, err := parseInt(args[2])
+		if err != nil {
+			return proto.ZrandmemberCommand{}, err
+		}
+		cmd.Count = count
+		cmd.HasCount = true
+	}
+	if len(args) == 4 {
+		if strings.ToUpper(args[3].String()) != "WITHSCORES" {
+			return proto.ZrandmemberCommand{}, errSyntax
+		}
+		cmd.WithScores = true
+	}
+
+	if err := checkRandCount(cmd.Count, cmd.WithScores); err != nil {
+		return proto.ZrandmemberCommand{}, err
+	}
+
+	return cmd, nil
+}
+
+// ParseZsetOpCommand parses ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE <SUM | MIN | MAX>] [WITHSCORES],
+// the same for ZINTER, ZDIFF which takes no WEIGHTS nor AGGREGATE, and their
+// STORE variants which take a destination and no WITHSCORES.
+func ParseZsetOpCommand(v serdes.Value, cmdType string) (proto.ZsetOpCommand, error) {
+	args := v.Array()
+	store := strings.HasSuffix(cmdType, "STORE")
+	if len(args) < 3 || (store && len(args) < 4) {
+		return proto.ZsetOpCommand{}, errWrongArgs(cmdType)
+	}
+
+	cmd := proto.ZsetOpCommand{
+		Op:        strings.TrimSuffix(cmdType, "STORE"),
+		Aggregate: "SUM",
+	}
+	if store {
+		cmd.Destination = args[1].String()
+		args = args[1:]
+	}
+
+	numKeys, err := parseInt(args[1])
+	if err != nil {
+		return proto.ZsetOpCommand{}, err
+	}
+	if numKeys < 1 {
+		return proto.ZsetOpCommand{}, fmt.Errorf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(cmdType))
+	}
+	if numKeys > int64(len(args)-2) {
+		return proto.ZsetOpCommand{}, errSyntax
+	}
+	cmd.Keys = stringArgs(args[2 : 2+numKeys])
+
+	diff := cmd.Op == proto.CommandZDIFF
+	rest := args[2+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		switch opt := strings.ToUpper(rest[i].String()); {
+		case opt == "WEIGHTS" && !diff && len(rest)-i-1 >= len(cmd.Keys):
+			for _, arg := range rest[i+1 : i+1+len(cmd.Keys)] {
+				weight, err := strconv.ParseFloat(arg.String(), 64)
+				if err != nil || math.IsNaN(weight) {
+					return proto.ZsetOpCommand{}, errors.New("ERR weight value is not a float")
+				}
+				cmd.Weights = append(cmd.Weights, weight)
+			}
+			i += len(cmd.Keys)
+		case opt == "AGGREGATE" && !diff && i+1 < len(rest):
+			cmd.Aggregate = strings.ToUpper(rest[i+1].String())
+			if cmd.Aggregate != "SUM" && cmd.Aggregate != "MIN" && cmd.Aggregate != "MAX" {
+				return proto.ZsetOpCommand{}, errSyntax
+			}
+			i++
+		case opt == "WITHSCORES" && !store:
+			cmd.WithScores = true
+		default:
+			return proto.ZsetOpCommand{}, errSyntax
+		}
+	}
+
+	return cmd, nil
+}
+
+// parseZrangeEnds parses the two ends of the range according to its kind.
+func parseZrangeEnds(spec proto.ZrangeSpec, start, stop serdes.Value) (proto.ZrangeSpec, error) {
+	var err error
+	switch spec.By {
+	case "BYSCORE":
+		if spec.Min, err = parseScoreBound(start); err != nil {
+			return spec, err
+		}
+		spec.Max, err = parseScoreBound(stop)
+	case "BYLEX":
+		if spec.LexMin, err = parseLexBound(start); err != nil {
+			return spec, err
+		}
+		spec.LexMax, err = parseLexBound(stop)
+	default:
+		spec.Start, spec.Stop, err = parseRange(start, stop)
+	}
+
+	return spec, err
+}
+
+// parseScoreBound parses an end of a score range: a float, exclusive when prefixed by (.
+func parseScoreBound(v serdes.Value) (proto.ScoreBound, error) {
+	s := v.String()
+	bound := proto.ScoreBound{}
+	if strings.HasPrefix(s, "(") {
+		bound.Exclusive = true
+		s = s[1:]
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) {
+		return proto.ScoreBound{}, errNotFloatRange
+	}
+	bound.Value = f
+
+	return bound, nil
+}
+
+// parseLexBound parses an end of a lexicographical range: [member, (member, - or +.
+func parseLexBound(v serdes.Value) (proto.LexBound, error) {
+	s := v.String()
+	switch {
+	case s == "-":
+		return proto.LexBound{Inf: -1}, nil
+	case s == "+":
+		return proto.LexBound{Inf: 1}, nil
+	case strings.HasPrefix(s, "("):
+		return proto.LexBound{Value: s[1:], Exclusive: true}, nil
+	case strings.HasPrefix(s, "["):
+		return proto.LexBound{Value: s[1:]}, nil
+	default:
+		return proto.LexBound{}, errNotLexRange
+	}
+}
+
+func ParseZscanCommand(v serdes.Value) (proto.ZscanCommand, error) {
+	args := v.Array()
+	if len(args) < 3 {
+		return proto.ZscanCommand{}, errWrongArgs(proto.CommandZSCAN)
+	}
+
+	scan, rest, err := parseScanArgs(args[2:])
+	if err != nil {
+		return proto.ZscanCommand{}, err
+	}
+	if len(rest) > 0 {
+		return proto.ZscanCommand{}, errSyntax
+	}
+	cmd := proto.ZscanCommand{
+		Key:      args[1].String(),
+		ScanArgs: scan,
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,63 @@
+package proto
+
+const (
+	CommandSETBIT      = "SETBIT"
+	CommandGETBIT      = "GETBIT"
+	CommandBITCOUNT    = "BITCOUNT"
+	CommandBITPOS      = "BITPOS"
+	CommandBITOP       = "BITOP"
+	CommandBITFIELD    = "BITFIELD"
+	CommandBITFIELD_RO = "BITFIELD_RO"
+)
+
+type SetbitCommand struct {
+	Key    string
+	Offset uint64
+	Bit    int
+}
+
+type GetbitCommand struct {
+	Key    string
+	Offset uint64
+}
+
+// BitcountCommand is BITCOUNT, the range is in bytes unless Bit is set.
+type BitcountCommand struct {
+	Key        string
+	Start, End int64
+	HasRange   bool
+	Bit        bool
+}
+
+// BitposCommand is BITPOS, see BitcountCommand for the range.
+type BitposCommand struct {
+	Key              string
+	Value            int
+	Start, End       int64
+	HasStart, HasEnd bool
+	Bit              bool
+}
+
+// BitopCommand is BITOP, Op is upper cased.
+type BitopCommand struct {
+	Op          string
+	Destination string
+	Keys        []string
+}
+
+// BitfieldOp is a GET, SET or INCRBY of BITFIELD, Overflow is the upper cased
+// OVERFLOW mode in effect for it.
+type BitfieldOp struct {
+	Set, Incr bool
+	Signed    bool
+	Bits      int
+	Offset    uint64
+	Value     int64
+	Overflow  string
+}
+
+// BitfieldCommand covers BITFIELD and BITFIELD_RO.
+type BitfieldCommand struct {
+	Key string
+	Ops []BitfieldOp
+}
@@ -0,0 +1,28 @@
+package proto
+
+const (
+	CommandSELECT   = "SELECT"
+	CommandSWAPDB   = "SWAPDB"
+	CommandFLUSHDB  = "FLUSHDB"
+	CommandFLUSHALL = "FLUSHALL"
+	CommandINFO     = "INFO"
+)
+
+type SelectCommand struct {
+	DB int64
+}
+
+type SwapdbCommand struct {
+	DB1, DB2 int64
+}
+
+// FlushCommand is FLUSHDB, and FLUSHALL with All set.
+type FlushCommand struct {
+	All   bool
+	Async bool
+}
+
+// InfoCommand is INFO, Sections are lower cased and empty for the default ones.
+type InfoCommand struct {
+	Sections []string
+}
@@ -0,0 +1,40 @@
+package proto
+
+const (
+	CommandEXPIRE      = "EXPIRE"
+	CommandPEXPIRE     = "PEXPIRE"
+	CommandEXPIREAT    = "EXPIREAT"
+	CommandPEXPIREAT   = "PEXPIREAT"
+	CommandEXPIRETIME  = "EXPIRETIME"
+	CommandPEXPIRETIME = "PEXPIRETIME"
+	CommandTTL         = "TTL"
+	CommandPTTL        = "PTTL"
+	CommandPERSIST     = "PERSIST"
+)
+
+// ExpireCommand covers EXPIRE, PEXPIRE, EXPIREAT and PEXPIREAT.
+// Value is either relative (EXPIRE, PEXPIRE) or a unix timestamp (EXPIREAT, PEXPIREAT)
+// expressed in seconds, or in milliseconds when Millis is set.
+type ExpireCommand struct {
+	Name           string
+	Key            string
+	Value          int64
+	Millis, At     bool
+	NX, XX, GT, LT bool
+}
+
+// TtlCommand covers TTL and PTTL.
+type TtlCommand struct {
+	Key    string
+	Millis bool
+}
+
+// ExpireTimeCommand covers EXPIRETIME and PEXPIRETIME.
+type ExpireTimeCommand struct {
+	Key    string
+	Millis bool
+}
+
+type PersistCommand struct {
+	Key string
+}
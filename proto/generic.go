@@ -0,0 +1,77 @@
+package proto
+
+const (
+	CommandTYPE      = "TYPE"
+	CommandOBJECT    = "OBJECT"
+	CommandSCAN      = "SCAN"
+	CommandKEYS      = "KEYS"
+	CommandRANDOMKEY = "RANDOMKEY"
+	CommandDBSIZE    = "DBSIZE"
+	CommandUNLINK    = "UNLINK"
+	CommandTOUCH     = "TOUCH"
+	CommandRENAME    = "RENAME"
+	CommandRENAMENX  = "RENAMENX"
+	CommandCOPY      = "COPY"
+	CommandMOVE      = "MOVE"
+	CommandSORT      = "SORT"
+	CommandSORT_RO   = "SORT_RO"
+)
+
+type TypeCommand struct {
+	Key string
+}
+
+// ObjectCommand is the OBJECT container command, Subcommand is upper cased.
+type ObjectCommand struct {
+	Subcommand string
+	Key        string
+}
+
+// ScanCommand is SCAN, Type is the name of the type of the keys to return,
+// empty for all of them.
+type ScanCommand struct {
+	ScanArgs
+	Type string
+}
+
+type KeysCommand struct {
+	Pattern string
+}
+
+type RandomkeyCommand struct{}
+
+type DbsizeCommand struct{}
+
+type TouchCommand struct {
+	Keys []string
+}
+
+// RenameCommand is RENAME, and RENAMENX with NX set.
+type RenameCommand struct {
+	Source, Destination string
+	NX                  bool
+}
+
+// CopyCommand is COPY, DB is the index of the destination database, -1 for
+// the current one.
+type CopyCommand struct {
+	Source, Destination string
+	DB                  int
+	Replace             bool
+}
+
+type MoveCommand struct {
+	Key string
+	DB  int
+}
+
+// SortCommand is SORT and SORT_RO. Count is -1 without a LIMIT, and Store the
+// destination of the result, empty to reply with it.
+type SortCommand struct {
+	Key           string
+	By            string
+	Get           []string
+	Offset, Count int64
+	Desc, Alpha   bool
+	Store         string
+}
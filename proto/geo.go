@@ -0,0 +1,61 @@
+package proto
+
+const (
+	CommandGEOADD         = "GEOADD"
+	CommandGEODIST        = "GEODIST"
+	CommandGEOPOS         = "GEOPOS"
+	CommandGEOHASH        = "GEOHASH"
+	CommandGEOSEARCH      = "GEOSEARCH"
+	CommandGEOSEARCHSTORE = "GEOSEARCHSTORE"
+)
+
+type GeoPoint struct {
+	Longitude, Latitude float64
+	Member              string
+}
+
+type GeoaddCommand struct {
+	Key        string
+	NX, XX, CH bool
+	Points     []GeoPoint
+}
+
+// GeodistCommand is GEODIST, Conversion is the number of meters in its unit.
+type GeodistCommand struct {
+	Key              string
+	Member1, Member2 string
+	Conversion       float64
+}
+
+type GeoposCommand struct {
+	Key     string
+	Members []string
+}
+
+type GeohashCommand struct {
+	Key     string
+	Members []string
+}
+
+// GeosearchCommand covers GEOSEARCH and GEOSEARCHSTORE, which sets Store.
+// The center is Member with FromMember and the coordinates otherwise, the
+// shape is a circle of Radius or a Width by Height box with ByBox, in units
+// of Conversion meters. Sort is ASC, DESC or empty.
+type GeosearchCommand struct {
+	Destination         string
+	Store, StoreDist    bool
+	Key                 string
+	FromMember          bool
+	Member              string
+	Longitude, Latitude float64
+	ByBox               bool
+	Radius              float64
+	Width, Height       float64
+	Conversion          float64
+	Sort                string
+	Count               int
+	Any                 bool
+	WithCoord           bool
+	WithDist            bool
+	WithHash            bool
+}
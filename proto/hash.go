@@ -0,0 +1,96 @@
+package proto
+
+const (
+	CommandHSET         = "HSET"
+	CommandHMSET        = "HMSET"
+	CommandHSETNX       = "HSETNX"
+	CommandHGET         = "HGET"
+	CommandHMGET        = "HMGET"
+	CommandHDEL         = "HDEL"
+	CommandHEXISTS      = "HEXISTS"
+	CommandHLEN         = "HLEN"
+	CommandHKEYS        = "HKEYS"
+	CommandHVALS        = "HVALS"
+	CommandHGETALL      = "HGETALL"
+	CommandHINCRBY      = "HINCRBY"
+	CommandHINCRBYFLOAT = "HINCRBYFLOAT"
+	CommandHSTRLEN      = "HSTRLEN"
+	CommandHRANDFIELD   = "HRANDFIELD"
+	CommandHSCAN        = "HSCAN"
+)
+
+// HsetCommand covers HSET and the deprecated HMSET which only differs by its reply.
+type HsetCommand struct {
+	Key   string
+	Pairs []string
+	Multi bool
+}
+
+type HsetnxCommand struct {
+	Key, Field, Value string
+}
+
+type HgetCommand struct {
+	Key, Field string
+}
+
+type HmgetCommand struct {
+	Key    string
+	Fields []string
+}
+
+type HdelCommand struct {
+	Key    string
+	Fields []string
+}
+
+type HexistsCommand struct {
+	Key, Field string
+}
+
+type HlenCommand struct {
+	Key string
+}
+
+// HgetallCommand covers HGETALL, HKEYS (only Fields set) and HVALS (only Values set).
+type HgetallCommand struct {
+	Key            string
+	Fields, Values bool
+}
+
+type HincrbyCommand struct {
+	Key, Field string
+	Increment  int64
+}
+
+type HincrbyfloatCommand struct {
+	Key, Field string
+	Increment  float64
+}
+
+type HstrlenCommand struct {
+	Key, Field string
+}
+
+// HrandfieldCommand is HRANDFIELD key [count [WITHVALUES]].
+type HrandfieldCommand struct {
+	Key        string
+	Count      int64
+	HasCount   bool
+	WithValues bool
+}
+
+// HscanCommand is HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES].
+type HscanCommand struct {
+	Key string
+	ScanArgs
+	NoValues bool
+}
+
+// ScanArgs are the arguments shared by the SCAN family of commands, an empty
+// Match matches everything.
+type ScanArgs struct {
+	Cursor uint64
+	Match  string
+	Count  int64
+}
@@ -0,0 +1,21 @@
+package proto
+
+const (
+	CommandPFADD   = "PFADD"
+	CommandPFCOUNT = "PFCOUNT"
+	CommandPFMERGE = "PFMERGE"
+)
+
+type PfaddCommand struct {
+	Key      string
+	Elements []string
+}
+
+type PfcountCommand struct {
+	Keys []string
+}
+
+type PfmergeCommand struct {
+	Destination string
+	Keys        []string
+}
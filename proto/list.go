@@ -0,0 +1,123 @@
+package proto
+
+import "time"
+
+const (
+	CommandLPUSH     = "LPUSH"
+	CommandRPUSH     = "RPUSH"
+	CommandLPUSHX    = "LPUSHX"
+	CommandRPUSHX    = "RPUSHX"
+	CommandLPOP      = "LPOP"
+	CommandRPOP      = "RPOP"
+	CommandLLEN      = "LLEN"
+	CommandLINDEX    = "LINDEX"
+	CommandLSET      = "LSET"
+	CommandLRANGE    = "LRANGE"
+	CommandLREM      = "LREM"
+	CommandLTRIM     = "LTRIM"
+	CommandLINSERT   = "LINSERT"
+	CommandLPOS      = "LPOS"
+	CommandLMOVE     = "LMOVE"
+	CommandRPOPLPUSH = "RPOPLPUSH"
+	CommandLMPOP     = "LMPOP"
+
+	CommandBLPOP      = "BLPOP"
+	CommandBRPOP      = "BRPOP"
+	CommandBLMOVE     = "BLMOVE"
+	CommandBRPOPLPUSH = "BRPOPLPUSH"
+	CommandBLMPOP     = "BLMPOP"
+)
+
+// PushCommand covers LPUSH, RPUSH and their X variants that only push to existing lists.
+type PushCommand struct {
+	Key          string
+	Value        []string
+	Left         bool
+	OnlyIfExists bool
+}
+
+// PopCommand covers LPOP and RPOP, Count is only meaningful when HasCount is set.
+type PopCommand struct {
+	Key      string
+	Left     bool
+	Count    int64
+	HasCount bool
+}
+
+type LlenCommand struct {
+	Key string
+}
+
+type LindexCommand struct {
+	Key   string
+	Index int64
+}
+
+type LsetCommand struct {
+	Key   string
+	Index int64
+	Value string
+}
+
+type LrangeCommand struct {
+	Key         string
+	Start, Stop int64
+}
+
+type LremCommand struct {
+	Key   string
+	Count int64
+	Value string
+}
+
+type LtrimCommand struct {
+	Key         string
+	Start, Stop int64
+}
+
+type LinsertCommand struct {
+	Key          string
+	Before       bool
+	Pivot, Value string
+}
+
+// LposCommand is LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len],
+// the reply is an array only when HasCount is set.
+type LposCommand struct {
+	Key      string
+	Value    string
+	Rank     int64
+	Count    int64
+	HasCount bool
+	MaxLen   int64
+}
+
+// LmoveCommand covers LMOVE and RPOPLPUSH.
+type LmoveCommand struct {
+	Source, Destination string
+	FromLeft, ToLeft    bool
+}
+
+type LmpopCommand struct {
+	Keys  []string
+	Left  bool
+	Count int64
+}
+
+// BpopCommand covers BLPOP and BRPOP, a zero Timeout blocks forever.
+type BpopCommand struct {
+	Keys    []string
+	Left    bool
+	Timeout time.Duration
+}
+
+// BlmoveCommand covers BLMOVE and BRPOPLPUSH.
+type BlmoveCommand struct {
+	LmoveCommand
+	Timeout time.Duration
+}
+
+type BlmpopCommand struct {
+	LmpopCommand
+	Timeout time.Duration
+}
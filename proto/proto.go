@@ -1,12 +1,5 @@
 package proto
 
-import (
-	"bytes"
-	"fmt"
-
-	"github.com/tidwall/resp"
-)
-
 const (
 	CommandSET     = "SET"
 	CommandGET     = "GET"
@@ -15,18 +8,28 @@ const (
 	CommandCOMMAND = "COMMAND"
 	CommandPING    = "PING"
 	CommandCONFIG  = "CONFIG"
+	CommandMEMORY  = "MEMORY"
 	CommandEXIST   = "EXISTS"
 	CommandDEL     = "DEL"
 	CommandINCR    = "INCR"
 	CommandDECR    = "DECR"
-	CommandLPUSH   = "LPUSH"
 )
 
 type Command interface{}
 
-// SetCommand our basic representation for the SET command in Redis.
+// SetCommand our basic representation for the SET command in Redis, SETEX,
+// PSETEX and GETSET are parsed into it too and Name tells which one it was.
+// Expire is relative unless ExpireAt is set, in seconds unless ExpireMillis is set
+// and zero means no expiry at all.
 type SetCommand struct {
-	Key, Value []byte
+	Name         string
+	Key, Value   []byte
+	NX, XX       bool
+	Get          bool
+	KeepTTL      bool
+	Expire       int64
+	ExpireMillis bool
+	ExpireAt     bool
 }
 
 // GetCommand our basic representation for the GET command in redis
@@ -34,54 +37,62 @@ type GetCommand struct {
 	Key []byte
 }
 
+// HelloCommand is HELLO, Protover is zero when no version is given and the
+// protocol of the connection stays as it is.
 type HelloCommand struct {
-	Value string
+	Protover           int64
+	Auth               bool
+	Username, Password string
+	SetName            bool
+	ClientName         string
 }
 
+// ClientCommand is one of the CLIENT subcommands, with its arguments.
 type ClientCommand struct {
-	Value string
+	Subcommand string
+	Args       []string
 }
 
+// CommandCommand is COMMAND, Subcommand is empty for COMMAND alone. Args are
+// the command names of INFO and DOCS or the command line of GETKEYS, and
+// FilterBy and Filter the FILTERBY of LIST.
 type CommandCommand struct {
-	Value string
+	Subcommand       string
+	Args             []string
+	FilterBy, Filter string
 }
 
+// PingCommand is PING, Message is echoed back when HasMessage is set.
 type PingCommand struct {
-	Value string
+	HasMessage bool
+	Message    []byte
 }
 
+// ConfigGetCommand is CONFIG GET, Patterns are the parameter patterns, lower
+// cased.
 type ConfigGetCommand struct {
-	Key, Value string
+	Patterns []string
 }
 
-type ExistCommand struct {
-	Key string
+// ConfigSetCommand is CONFIG SET, Params are the parameters to set, lower
+// cased, interleaved with their value.
+type ConfigSetCommand struct {
+	Params []string
 }
 
-type DelCommand struct {
-	Key string
+// MemoryUsageCommand is MEMORY USAGE, Samples is how many elements of a
+// collection are looked at, zero for all of them.
+type MemoryUsageCommand struct {
+	Key     string
+	Samples int64
 }
 
-type IncrCommand struct {
-	Key string
-}
-
-type DecrCommand struct {
-	Key string
-}
-
-type LpushCommand struct {
-	Key   string
-	Value []string
+type ExistCommand struct {
+	Keys []string
 }
 
-func WriteRespMap(m map[string]string) []byte {
-	buf := &bytes.Buffer{}
-	buf.WriteString("%" + fmt.Sprintf("%d\r\n", len(m)))
-	rw := resp.NewWriter(buf)
-	for k, v := range m {
-		_ = rw.WriteString(k)
-		_ = rw.WriteString(":" + v)
-	}
-	return buf.Bytes()
+// DelCommand is DEL, and UNLINK with Unlink set.
+type DelCommand struct {
+	Keys   []string
+	Unlink bool
 }
@@ -0,0 +1,83 @@
+package proto
+
+const (
+	CommandSADD        = "SADD"
+	CommandSREM        = "SREM"
+	CommandSISMEMBER   = "SISMEMBER"
+	CommandSMISMEMBER  = "SMISMEMBER"
+	CommandSMEMBERS    = "SMEMBERS"
+	CommandSCARD       = "SCARD"
+	CommandSPOP        = "SPOP"
+	CommandSRANDMEMBER = "SRANDMEMBER"
+	CommandSMOVE       = "SMOVE"
+	CommandSINTER      = "SINTER"
+	CommandSUNION      = "SUNION"
+	CommandSDIFF       = "SDIFF"
+	CommandSINTERSTORE = "SINTERSTORE"
+	CommandSUNIONSTORE = "SUNIONSTORE"
+	CommandSDIFFSTORE  = "SDIFFSTORE"
+	CommandSINTERCARD  = "SINTERCARD"
+	CommandSSCAN       = "SSCAN"
+)
+
+type SaddCommand struct {
+	Key     string
+	Members []string
+}
+
+type SremCommand struct {
+	Key     string
+	Members []string
+}
+
+// SismemberCommand covers SISMEMBER and SMISMEMBER, the latter replying with an array.
+type SismemberCommand struct {
+	Key     string
+	Members []string
+	Multi   bool
+}
+
+type SmembersCommand struct {
+	Key string
+}
+
+type ScardCommand struct {
+	Key string
+}
+
+// SpopCommand is SPOP key [count], the reply is an array only when HasCount is set.
+type SpopCommand struct {
+	Key      string
+	Count    int64
+	HasCount bool
+}
+
+// SrandmemberCommand is SRANDMEMBER key [count], the reply is an array only when HasCount is set.
+type SrandmemberCommand struct {
+	Key      string
+	Count    int64
+	HasCount bool
+}
+
+type SmoveCommand struct {
+	Source, Destination string
+	Member              string
+}
+
+// SetOpCommand covers SINTER, SUNION, SDIFF and their STORE variants, which
+// have a Destination.
+type SetOpCommand struct {
+	Op          string
+	Destination string
+	Keys        []string
+}
+
+type SintercardCommand struct {
+	Keys  []string
+	Limit int64
+}
+
+type SscanCommand struct {
+	Key string
+	ScanArgs
+}
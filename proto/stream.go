@@ -0,0 +1,156 @@
+package proto
+
+import "time"
+
+const (
+	CommandXADD       = "XADD"
+	CommandXTRIM      = "XTRIM"
+	CommandXLEN       = "XLEN"
+	CommandXDEL       = "XDEL"
+	CommandXRANGE     = "XRANGE"
+	CommandXREVRANGE  = "XREVRANGE"
+	CommandXREAD      = "XREAD"
+	CommandXREADGROUP = "XREADGROUP"
+	CommandXGROUP     = "XGROUP"
+	CommandXACK       = "XACK"
+	CommandXPENDING   = "XPENDING"
+	CommandXCLAIM     = "XCLAIM"
+	CommandXAUTOCLAIM = "XAUTOCLAIM"
+	CommandXINFO      = "XINFO"
+)
+
+// StreamID is a stream entry ID, <Ms>-<Seq>.
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+// StreamTrim is the MAXLEN or MINID trimming of XADD and XTRIM. Limit is
+// negative when no LIMIT was given.
+type StreamTrim struct {
+	MaxLen  int64
+	ByMinID bool
+	MinID   StreamID
+	Approx  bool
+	Limit   int64
+}
+
+// XaddCommand is XADD, with AutoID for * and AutoSeq for <ms>-*.
+type XaddCommand struct {
+	Key        string
+	NoMkStream bool
+	Trim       *StreamTrim
+	ID         StreamID
+	AutoID     bool
+	AutoSeq    bool
+	Fields     []string
+}
+
+type XtrimCommand struct {
+	Key string
+	StreamTrim
+}
+
+type XlenCommand struct {
+	Key string
+}
+
+type XdelCommand struct {
+	Key string
+	IDs []StreamID
+}
+
+// XrangeCommand covers XRANGE and XREVRANGE, Count is negative without COUNT.
+type XrangeCommand struct {
+	Key        string
+	Start, End StreamID
+	Rev        bool
+	Count      int64
+}
+
+// XreadCommand is XREAD, Last tells which IDs were given as $.
+type XreadCommand struct {
+	Keys    []string
+	IDs     []StreamID
+	Last    []bool
+	Count   int64
+	Block   bool
+	Timeout time.Duration
+}
+
+// XreadgroupCommand is XREADGROUP, NewOnly tells which IDs were given as >.
+type XreadgroupCommand struct {
+	Group    string
+	Consumer string
+	Keys     []string
+	IDs      []StreamID
+	NewOnly  []bool
+	Count    int64
+	Block    bool
+	Timeout  time.Duration
+	NoAck    bool
+}
+
+// XgroupCommand is the XGROUP container command, Subcommand is upper cased.
+// UseLast is set when the ID was given as $ and EntriesRead is -1 without
+// ENTRIESREAD.
+type XgroupCommand struct {
+	Subcommand  string
+	Key         string
+	Group       string
+	Consumer    string
+	ID          StreamID
+	UseLast     bool
+	MkStream    bool
+	EntriesRead int64
+}
+
+type XackCommand struct {
+	Key   string
+	Group string
+	IDs   []StreamID
+}
+
+// XpendingCommand is XPENDING, only the summary is asked for unless Extended is set.
+type XpendingCommand struct {
+	Key        string
+	Group      string
+	Extended   bool
+	MinIdle    int64
+	Start, End StreamID
+	Count      int64
+	Consumer   string
+}
+
+// XclaimCommand is XCLAIM, Idle, Time and RetryCount are negative when not given.
+type XclaimCommand struct {
+	Key        string
+	Group      string
+	Consumer   string
+	MinIdle    int64
+	IDs        []StreamID
+	Idle       int64
+	Time       int64
+	RetryCount int64
+	Force      bool
+	JustID     bool
+	LastID     *StreamID
+}
+
+type XautoclaimCommand struct {
+	Key      string
+	Group    string
+	Consumer string
+	MinIdle  int64
+	Start    StreamID
+	Count    int64
+	JustID   bool
+}
+
+// XinfoCommand is the XINFO container command, Subcommand is upper cased.
+type XinfoCommand struct {
+	Subcommand string
+	Key        string
+	Group      string
+	Full       bool
+	Count      int64
+}
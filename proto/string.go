@@ -0,0 +1,90 @@
+package proto
+
+const (
+	CommandMGET        = "MGET"
+	CommandMSET        = "MSET"
+	CommandMSETNX      = "MSETNX"
+	CommandSETNX       = "SETNX"
+	CommandSETEX       = "SETEX"
+	CommandPSETEX      = "PSETEX"
+	CommandGETSET      = "GETSET"
+	CommandGETDEL      = "GETDEL"
+	CommandGETEX       = "GETEX"
+	CommandAPPEND      = "APPEND"
+	CommandSTRLEN      = "STRLEN"
+	CommandGETRANGE    = "GETRANGE"
+	CommandSETRANGE    = "SETRANGE"
+	CommandINCRBY      = "INCRBY"
+	CommandDECRBY      = "DECRBY"
+	CommandINCRBYFLOAT = "INCRBYFLOAT"
+	CommandLCS         = "LCS"
+)
+
+type MgetCommand struct {
+	Keys []string
+}
+
+// MsetCommand covers MSET and MSETNX, Pairs interleaves the keys and the values.
+type MsetCommand struct {
+	Pairs []string
+	NX    bool
+}
+
+type SetnxCommand struct {
+	Key, Value []byte
+}
+
+type GetdelCommand struct {
+	Key string
+}
+
+// GetexCommand is GETEX, see SetCommand for the meaning of Expire. Persist
+// removes the time to live instead.
+type GetexCommand struct {
+	Key          string
+	Expire       int64
+	ExpireMillis bool
+	ExpireAt     bool
+	Persist      bool
+}
+
+type AppendCommand struct {
+	Key   string
+	Value []byte
+}
+
+type StrlenCommand struct {
+	Key string
+}
+
+type GetrangeCommand struct {
+	Key        string
+	Start, End int64
+}
+
+type SetrangeCommand struct {
+	Key    string
+	Offset int64
+	Value  []byte
+}
+
+// IncrbyCommand covers INCR, DECR, INCRBY and DECRBY, decrements being
+// negative deltas.
+type IncrbyCommand struct {
+	Key   string
+	Delta int64
+}
+
+type IncrbyfloatCommand struct {
+	Key   string
+	Delta float64
+}
+
+// LcsCommand is LCS, it replies with the subsequence itself unless Len or Idx is set.
+type LcsCommand struct {
+	Key1, Key2   string
+	Len          bool
+	Idx          bool
+	MinMatchLen  int64
+	WithMatchLen bool
+}
@@ -0,0 +1,166 @@
+package proto
+
+import "time"
+
+const (
+	CommandZADD             = "ZADD"
+	CommandZINCRBY          = "ZINCRBY"
+	CommandZCARD            = "ZCARD"
+	CommandZSCORE           = "ZSCORE"
+	CommandZMSCORE          = "ZMSCORE"
+	CommandZRANK            = "ZRANK"
+	CommandZREVRANK         = "ZREVRANK"
+	CommandZRANGE           = "ZRANGE"
+	CommandZRANGESTORE      = "ZRANGESTORE"
+	CommandZREVRANGE        = "ZREVRANGE"
+	CommandZRANGEBYSCORE    = "ZRANGEBYSCORE"
+	CommandZREVRANGEBYSCORE = "ZREVRANGEBYSCORE"
+	CommandZRANGEBYLEX      = "ZRANGEBYLEX"
+	CommandZREVRANGEBYLEX   = "ZREVRANGEBYLEX"
+	CommandZCOUNT           = "ZCOUNT"
+	CommandZLEXCOUNT        = "ZLEXCOUNT"
+	CommandZREM             = "ZREM"
+	CommandZREMRANGEBYRANK  = "ZREMRANGEBYRANK"
+	CommandZREMRANGEBYSCORE = "ZREMRANGEBYSCORE"
+	CommandZREMRANGEBYLEX   = "ZREMRANGEBYLEX"
+	CommandZPOPMIN          = "ZPOPMIN"
+	CommandZPOPMAX          = "ZPOPMAX"
+	CommandBZPOPMIN         = "BZPOPMIN"
+	CommandBZPOPMAX         = "BZPOPMAX"
+	CommandZRANDMEMBER      = "ZRANDMEMBER"
+	CommandZUNION           = "ZUNION"
+	CommandZINTER           = "ZINTER"
+	CommandZDIFF            = "ZDIFF"
+	CommandZUNIONSTORE      = "ZUNIONSTORE"
+	CommandZINTERSTORE      = "ZINTERSTORE"
+	CommandZDIFFSTORE       = "ZDIFFSTORE"
+	CommandZSCAN            = "ZSCAN"
+)
+
+// ScoreMember is a member of a sorted set with its score, or with the increment with INCR.
+type ScoreMember struct {
+	Score  float64
+	Member string
+}
+
+// ScoreBound is an end of a score range: a float, -inf or +inf, exclusive when prefixed by (.
+type ScoreBound struct {
+	Value     float64
+	Exclusive bool
+}
+
+// LexBound is an end of a lexicographical range: [member, (member, - or +,
+// Inf being -1 for - and 1 for +.
+type LexBound struct {
+	Value     string
+	Exclusive bool
+	Inf       int
+}
+
+// ZrangeSpec is the range shared by ZRANGE, its variants and the ZREMRANGEBY*
+// commands. By is empty for a range of ranks, BYSCORE or BYLEX, and Count is
+// negative when there is no LIMIT.
+type ZrangeSpec struct {
+	By             string
+	Rev            bool
+	Start, Stop    int64
+	Min, Max       ScoreBound
+	LexMin, LexMax LexBound
+	Offset, Count  int64
+}
+
+type ZaddCommand struct {
+	Key                string
+	NX, XX, GT, LT, CH bool
+	Incr               bool
+	Members            []ScoreMember
+}
+
+type ZincrbyCommand struct {
+	Key       string
+	Increment float64
+	Member    string
+}
+
+type ZcardCommand struct {
+	Key string
+}
+
+// ZscoreCommand covers ZSCORE and ZMSCORE, the latter replying with an array.
+type ZscoreCommand struct {
+	Key     string
+	Members []string
+	Multi   bool
+}
+
+// ZrankCommand covers ZRANK and ZREVRANK.
+type ZrankCommand struct {
+	Key       string
+	Member    string
+	Rev       bool
+	WithScore bool
+}
+
+// ZrangeCommand covers ZRANGE and its legacy variants, as well as ZRANGESTORE
+// which has a Destination.
+type ZrangeCommand struct {
+	Key         string
+	Destination string
+	ZrangeSpec
+	WithScores bool
+}
+
+// ZcountCommand covers ZCOUNT and ZLEXCOUNT, the bounds are in the spec.
+type ZcountCommand struct {
+	Key string
+	ZrangeSpec
+}
+
+type ZremCommand struct {
+	Key     string
+	Members []string
+}
+
+// ZremrangeCommand covers ZREMRANGEBYRANK, ZREMRANGEBYSCORE and ZREMRANGEBYLEX.
+type ZremrangeCommand struct {
+	Key string
+	ZrangeSpec
+}
+
+// ZpopCommand covers ZPOPMIN and ZPOPMAX, the reply is nested only when HasCount is set.
+type ZpopCommand struct {
+	Key      string
+	Max      bool
+	Count    int64
+	HasCount bool
+}
+
+// BzpopCommand covers BZPOPMIN and BZPOPMAX, a zero Timeout blocks forever.
+type BzpopCommand struct {
+	Keys    []string
+	Max     bool
+	Timeout time.Duration
+}
+
+type ZrandmemberCommand struct {
+	Key        string
+	Count      int64
+	HasCount   bool
+	WithScores bool
+}
+
+// ZsetOpCommand covers ZUNION, ZINTER, ZDIFF and their STORE variants, which
+// have a Destination. Aggregate is SUM, MIN or MAX.
+type ZsetOpCommand struct {
+	Op          string
+	Destination string
+	Keys        []string
+	Weights     []float64
+	Aggregate   string
+	WithScores  bool
+}
+
+type ZscanCommand struct {
+	Key string
+	ScanArgs
+}
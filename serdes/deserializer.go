@@ -1 +1,288 @@
 package serdes
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+)
+
+// maxBulkLen is the longest bulk string the reader accepts, the default
+// proto-max-bulk-len of redis.
+const maxBulkLen = 512 << 20
+
+// ProtocolError is the error of a value that does not follow the protocol,
+// the connection it came from can't be read any further.
+type ProtocolError struct {
+	msg string
+}
+
+func (e *ProtocolError) Error() string { return "Protocol error: " + e.msg }
+
+func protocolError(msg string) error { return &ProtocolError{msg: msg} }
+
+// Reader reads RESP2 and RESP3 values.
+type Reader struct {
+	rd *bufio.Reader
+}
+
+// NewReader creates a reader of the values of r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{rd: bufio.NewReader(r)}
+}
+
+// ReadValue reads the next value. The attributes sent before it are attached
+// to it, streamed aggregates and strings are read whole, and the RESP2 null
+// bulk strings and arrays are read as nulls. It reads the replies of a
+// server, the commands of clients are read with ReadCommand.
+func (r *Reader) ReadValue() (Value, error) {
+	v, end, err := r.readValue()
+	if err == nil && end {
+		err = protocolError("unexpected end of streamed aggregate")
+	}
+
+	return v, err
+}
+
+// readValue reads a value, or the end of a streamed aggregate, end being set
+// then.
+func (r *Reader) readValue() (v Value, end bool, err error) {
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, false, err
+	}
+	if len(line) == 0 {
+		return Value{}, false, protocolError("empty line")
+	}
+
+	typ, rest := Type(line[0]), line[1:]
+	switch typ {
+	case '.':
+		if len(rest) != 0 {
+			return Value{}, false, protocolError("invalid end of streamed aggregate")
+		}
+		return Value{}, true, nil
+	case SimpleString, Error:
+		return Value{typ: typ, str: rest}, false, nil
+	case Integer:
+		n, err := parseLen(rest)
+		if err != nil {
+			return Value{}, false, protocolError("invalid integer")
+		}
+		return IntegerValue(n), false, nil
+	case Null:
+		if len(rest) != 0 {
+			return Value{}, false, protocolError("invalid null")
+		}
+		return NullValue(), false, nil
+	case Double:
+		f, err := parseDouble(rest)
+		if err != nil {
+			return Value{}, false, err
+		}
+		return DoubleValue(f), false, nil
+	case Boolean:
+		switch string(rest) {
+		case "t":
+			return BoolValue(true), false, nil
+		case "f":
+			return BoolValue(false), false, nil
+		}
+		return Value{}, false, protocolError("invalid boolean")
+	case BigNumber:
+		digits := bytes.TrimPrefix(rest, []byte{'-'})
+		if len(digits) == 0 || bytes.IndexFunc(digits, notDigit) >= 0 {
+			return Value{}, false, protocolError("invalid big number")
+		}
+		return Value{typ: BigNumber, str: rest}, false, nil
+	case BulkString, BlobError, VerbatimString:
+		v, err := r.readBlob(typ, rest)
+		return v, false, err
+	case Array, Map, Set, Attribute, Push:
+		v, err := r.readAggregate(typ, rest)
+		if err != nil || typ != Attribute {
+			return v, false, err
+		}
+		// The attributes describe the value that follows them.
+		next, end, err := r.readValue()
+		err = unexpectedEOF(err)
+		if err == nil && end {
+			err = protocolError("attribute without a value")
+		}
+		return next.WithAttributes(v.elems), false, err
+	default:
+		return Value{}, false, protocolError("unknown type " + strconv.QuoteRune(rune(typ)))
+	}
+}
+
+// readBlob reads a bulk string, a blob error or a verbatim string, header
+// being the rest of the line of its length.
+func (r *Reader) readBlob(typ Type, header []byte) (Value, error) {
+	if typ == BulkString && string(header) == "?" {
+		return r.readStreamedString()
+	}
+	n, err := parseLen(header)
+	if typ == BulkString && err == nil && n == -1 {
+		return NullValue(), nil
+	}
+	if err != nil || n < 0 || n > maxBulkLen {
+		return Value{}, protocolError("invalid bulk length")
+	}
+	s, err := r.readBytes(int(n))
+	if err != nil {
+		return Value{}, err
+	}
+
+	if typ != VerbatimString {
+		return Value{typ: typ, str: s}, nil
+	}
+	if len(s) < 4 || s[3] != ':' {
+		return Value{}, protocolError("invalid verbatim string")
+	}
+
+	return Value{typ: VerbatimString, format: string(s[:3]), str: s[4:]}, nil
+}
+
+// readStreamedString reads the chunks of a streamed string, up to the empty
+// one ending it.
+func (r *Reader) readStreamedString() (Value, error) {
+	s := []byte{}
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		if len(line) == 0 || line[0] != ';' {
+			return Value{}, protocolError("invalid streamed string chunk")
+		}
+		n, err := parseLen(line[1:])
+		if err != nil || n < 0 || int64(len(s))+n > maxBulkLen {
+			return Value{}, protocolError("invalid bulk length")
+		}
+		if n == 0 {
+			return BytesValue(s), nil
+		}
+		chunk, err := r.readBytes(int(n))
+		if err != nil {
+			return Value{}, err
+		}
+		s = append(s, chunk...)
+	}
+}
+
+// readAggregate reads the elements of an aggregate, header being the rest of
+// the line of its length.
+func (r *Reader) readAggregate(typ Type, header []byte) (Value, error) {
+	if string(header) == "?" {
+		return r.readStreamedAggregate(typ)
+	}
+	n, err := parseLen(header)
+	if typ == Array && err == nil && n == -1 {
+		return NullArrayValue(), nil
+	}
+	if err != nil || n < 0 || n > math.MaxInt32 {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+	if typ == Map || typ == Attribute {
+		n *= 2
+	}
+
+	// The length is not trusted for the allocation, the elements are.
+	elems := make([]Value, 0, min(n, 1024))
+	for i := int64(0); i < n; i++ {
+		v, end, err := r.readValue()
+		if err == nil && end {
+			err = protocolError("unexpected end of streamed aggregate")
+		}
+		if err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		elems = append(elems, v)
+	}
+
+	return Value{typ: typ, elems: elems}, nil
+}
+
+// readStreamedAggregate reads the elements of a streamed aggregate, up to the
+// end marking it.
+func (r *Reader) readStreamedAggregate(typ Type) (Value, error) {
+	elems := []Value{}
+	for {
+		v, end, err := r.readValue()
+		if err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		if end {
+			break
+		}
+		elems = append(elems, v)
+	}
+	if (typ == Map || typ == Attribute) && len(elems)%2 != 0 {
+		return Value{}, protocolError("streamed map without the value of a key")
+	}
+
+	return Value{typ: typ, elems: elems}, nil
+}
+
+// readLine reads a line without its CRLF.
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.rd.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("line not ending with CRLF")
+	}
+
+	return line[:len(line)-2], nil
+}
+
+// readBytes reads n bytes and the CRLF after them.
+func (r *Reader) readBytes(n int) ([]byte, error) {
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(r.rd, b); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if b[n] != '\r' || b[n+1] != '\n' {
+		return nil, protocolError("bulk string not ending with CRLF")
+	}
+
+	return b[:n], nil
+}
+
+// unexpectedEOF turns the end of the input in the middle of a value into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+func parseLen(b []byte) (int64, error) {
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+func parseDouble(b []byte) (float64, error) {
+	switch string(b) {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "-nan":
+		return math.NaN(), nil
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0, protocolError("invalid double")
+	}
+
+	return f, nil
+}
+
+func notDigit(c rune) bool { return c < '0' || c > '9' }
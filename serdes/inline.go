@@ -0,0 +1,229 @@
+package serdes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// maxInlineLen is the longest inline command the reader accepts, like
+// PROTO_INLINE_MAX_SIZE in redis.
+const maxInlineLen = 64 * 1024
+
+// ReadCommand reads the next command of a client: an array of bulk strings,
+// or an inline command, a line of arguments separated by spaces like the
+// ones typed in telnet, read as the same array. Empty lines and empty arrays
+// are skipped.
+func (r *Reader) ReadCommand() (Value, error) {
+	for {
+		b, err := r.rd.Peek(1)
+		if err != nil {
+			return Value{}, err
+		}
+		if Type(b[0]) == Array {
+			v, err := r.readMultibulk()
+			if err != nil || len(v.Array()) > 0 {
+				return v, err
+			}
+			continue
+		}
+
+		line, err := r.readInline()
+		if err != nil {
+			return Value{}, err
+		}
+		args, ok := splitArgs(line)
+		if !ok {
+			return Value{}, protocolError("unbalanced quotes in request")
+		}
+		if len(args) == 0 {
+			continue
+		}
+		elems := make([]Value, 0, len(args))
+		for _, arg := range args {
+			elems = append(elems, BytesValue(arg))
+		}
+		return ArrayValue(elems), nil
+	}
+}
+
+// readMultibulk reads a command sent as an array of bulk strings, the only
+// aggregate a client may send, like processMultibulkBuffer in redis. Unlike
+// ReadValue it never nests, so a client can't grow the stack or the memory
+// of the server beyond its arguments.
+func (r *Reader) readMultibulk() (Value, error) {
+	line, err := r.readCommandLine("too big mbulk count string")
+	if err != nil {
+		return Value{}, err
+	}
+	n, err := parseLen(line[1:])
+	if err != nil || n > math.MaxInt32 {
+		return Value{}, protocolError("invalid multibulk length")
+	}
+
+	// The length is not trusted for the allocation, the arguments are.
+	elems := make([]Value, 0, min(max(n, 0), 1024))
+	for i := int64(0); i < n; i++ {
+		line, err := r.readCommandLine("too big bulk count string")
+		if err != nil {
+			return Value{}, err
+		}
+		if len(line) == 0 || Type(line[0]) != BulkString {
+			got := byte('\r')
+			if len(line) > 0 {
+				got = line[0]
+			}
+			return Value{}, protocolError(fmt.Sprintf("expected '$', got '%c'", got))
+		}
+		size, err := parseLen(line[1:])
+		if err != nil || size < 0 || size > maxBulkLen {
+			return Value{}, protocolError("invalid bulk length")
+		}
+		arg, err := r.readBytes(int(size))
+		if err != nil {
+			return Value{}, err
+		}
+		elems = append(elems, BytesValue(arg))
+	}
+
+	return ArrayValue(elems), nil
+}
+
+// readCommandLine reads a line of a multibulk without its CRLF, failing with
+// tooBig when it is longer than an inline command.
+func (r *Reader) readCommandLine(tooBig string) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxInlineLen {
+			return nil, protocolError(tooBig)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		break
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("line not ending with CRLF")
+	}
+
+	return line[:len(line)-2], nil
+}
+
+// readInline reads the line of an inline command, which may end with a bare
+// LF, without its line ending.
+func (r *Reader) readInline() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxInlineLen {
+			return nil, protocolError("too big inline request")
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		break
+	}
+	line = bytes.TrimSuffix(line, []byte{'\n'})
+
+	return bytes.TrimSuffix(line, []byte{'\r'}), nil
+}
+
+// splitArgs splits an inline command into its arguments with the quoting
+// rules of sdssplitargs in redis. In double quotes \n, \r, \t, \b, \a and
+// \xHH are escapes, and in single quotes \' is the only one. A closing quote
+// must end its argument, ok is false otherwise or when a quote is left open.
+func splitArgs(line []byte) (args [][]byte, ok bool) {
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i == len(line) {
+			return args, true
+		}
+
+		arg := []byte{}
+		var quote byte
+	arg:
+		for ; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case quote == '"' && c == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHex(line[i+2]) && isHex(line[i+3]):
+				arg = append(arg, unhex(line[i+2])<<4|unhex(line[i+3]))
+				i += 3
+			case quote == '"' && c == '\\' && i+1 < len(line):
+				i++
+				arg = append(arg, unescape(line[i]))
+			case quote == '\'' && c == '\\' && i+1 < len(line) && line[i+1] == '\'':
+				i++
+				arg = append(arg, '\'')
+			case quote != 0 && c == quote:
+				if i+1 < len(line) && !isSpace(line[i+1]) {
+					return nil, false
+				}
+				quote = 0
+				i++
+				break arg
+			case quote != 0:
+				arg = append(arg, c)
+			case c == '"' || c == '\'':
+				quote = c
+			case isSpace(c):
+				break arg
+			default:
+				arg = append(arg, c)
+			}
+		}
+		if quote != 0 {
+			return nil, false
+		}
+		args = append(args, arg)
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f' || c == 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c >= 'a':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// unescape returns the character of the escape \c in double quotes.
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case 'b':
+		return '\b'
+	case 'a':
+		return '\a'
+	default:
+		return c
+	}
+}
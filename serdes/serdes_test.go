@@ -1,11 +1,370 @@
 package serdes
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
 
 func TestSerializer(t *testing.T) {
-    t.Skip()
+	n, _ := new(big.Int).SetString("3492890328409238509324850943850943825024385", 10)
+	tests := []struct {
+		name         string
+		value        Value
+		resp2, resp3 string
+	}{
+		{"simple string", SimpleStringValue("OK"), "+OK\r\n", "+OK\r\n"},
+		{"simple string with line breaks", SimpleStringValue("a\r\nb"), "+a  b\r\n", "+a  b\r\n"},
+		{"error", ErrorValue(errors.New("ERR unknown")), "-ERR unknown\r\n", "-ERR unknown\r\n"},
+		{"integer", IntegerValue(-42), ":-42\r\n", ":-42\r\n"},
+		{"bulk string", StringValue("hello"), "$5\r\nhello\r\n", "$5\r\nhello\r\n"},
+		{"empty bulk string", StringValue(""), "$0\r\n\r\n", "$0\r\n\r\n"},
+		{"binary bulk string", BytesValue([]byte("a\r\nb")), "$4\r\na\r\nb\r\n", "$4\r\na\r\nb\r\n"},
+		{"null", NullValue(), "$-1\r\n", "_\r\n"},
+		{"null array", NullArrayValue(), "*-1\r\n", "_\r\n"},
+		{"double", DoubleValue(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{"integral double", DoubleValue(3), "$1\r\n3\r\n", ",3\r\n"},
+		{"large double", DoubleValue(1e20), "$5\r\n1e+20\r\n", ",1e+20\r\n"},
+		{"small double", DoubleValue(0.00001), "$5\r\n1e-05\r\n", ",1e-05\r\n"},
+		{"infinite double", DoubleValue(math.Inf(-1)), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{"nan", DoubleValue(math.NaN()), "$3\r\nnan\r\n", ",nan\r\n"},
+		{"true", BoolValue(true), ":1\r\n", "#t\r\n"},
+		{"false", BoolValue(false), ":0\r\n", "#f\r\n"},
+		{"blob error", BlobErrorValue("SYNTAX invalid\r\nsyntax"), "-SYNTAX invalid  syntax\r\n", "!22\r\nSYNTAX invalid\r\nsyntax\r\n"},
+		{"verbatim string", VerbatimValue("txt", "Some string"), "$11\r\nSome string\r\n", "=15\r\ntxt:Some string\r\n"},
+		{
+			"big number", BigNumberValue(n),
+			"$43\r\n3492890328409238509324850943850943825024385\r\n",
+			"(3492890328409238509324850943850943825024385\r\n",
+		},
+		{"empty array", ArrayValue(nil), "*0\r\n", "*0\r\n"},
+		{
+			"array", ArrayValue([]Value{IntegerValue(1), StringValue("two"), NullValue()}),
+			"*3\r\n:1\r\n$3\r\ntwo\r\n$-1\r\n",
+			"*3\r\n:1\r\n$3\r\ntwo\r\n_\r\n",
+		},
+		{
+			"nested array", ArrayValue([]Value{ArrayValue([]Value{IntegerValue(1)}), NullArrayValue()}),
+			"*2\r\n*1\r\n:1\r\n*-1\r\n",
+			"*2\r\n*1\r\n:1\r\n_\r\n",
+		},
+		{
+			"map", MapValue([]Value{StringValue("first"), IntegerValue(1), StringValue("second"), DoubleValue(2.5)}),
+			"*4\r\n$5\r\nfirst\r\n:1\r\n$6\r\nsecond\r\n$3\r\n2.5\r\n",
+			"%2\r\n$5\r\nfirst\r\n:1\r\n$6\r\nsecond\r\n,2.5\r\n",
+		},
+		{
+			"set", SetValue([]Value{StringValue("a"), BoolValue(true)}),
+			"*2\r\n$1\r\na\r\n:1\r\n",
+			"~2\r\n$1\r\na\r\n#t\r\n",
+		},
+		{
+			"push", PushValue([]Value{StringValue("message"), StringValue("channel"), StringValue("hi")}),
+			"*3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$2\r\nhi\r\n",
+			">3\r\n$7\r\nmessage\r\n$7\r\nchannel\r\n$2\r\nhi\r\n",
+		},
+		{
+			"attributes", ArrayValue([]Value{IntegerValue(2039123)}).WithAttributes([]Value{StringValue("ttl"), IntegerValue(3600)}),
+			"*1\r\n:2039123\r\n",
+			"|1\r\n$3\r\nttl\r\n:3600\r\n*1\r\n:2039123\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(AppendValue(nil, 2, tt.value)); got != tt.resp2 {
+				t.Errorf("expected RESP2 %q but got %q", tt.resp2, got)
+			}
+			if got := string(AppendValue(nil, 3, tt.value)); got != tt.resp3 {
+				t.Errorf("expected RESP3 %q but got %q", tt.resp3, got)
+			}
+
+			var buf bytes.Buffer
+			if err := NewWriter(&buf, 3).WriteValue(tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.resp3 {
+				t.Errorf("expected the writer to write %q but got %q", tt.resp3, buf.String())
+			}
+		})
+	}
+}
+
+func TestWriterStreams(t *testing.T) {
+	write := func(w *Writer) error {
+		steps := []func() error{
+			func() error { return w.WriteStreamStart(Array) },
+			func() error { return w.WriteInteger(1) },
+			func() error { return w.WriteStreamStart(Map) },
+			func() error { return w.WriteString("key") },
+			func() error { return w.WriteDouble(0.5) },
+			w.WriteStreamEnd,
+			func() error { return w.WriteStreamStart(BulkString) },
+			func() error { return w.WriteString("Hell") },
+			func() error { return w.WriteString("o world") },
+			w.WriteStreamEnd,
+			func() error { return w.WriteAttribute([]Value{StringValue("a"), IntegerValue(1)}) },
+			w.WriteNull,
+			w.WriteStreamEnd,
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tests := []struct {
+		protocol int
+		expected string
+	}{
+		{2, "*4\r\n:1\r\n*2\r\n$3\r\nkey\r\n$3\r\n0.5\r\n$11\r\nHello world\r\n$-1\r\n"},
+		{3, "*?\r\n:1\r\n%?\r\n$3\r\nkey\r\n,0.5\r\n.\r\n$?\r\n;4\r\nHell\r\n;7\r\no world\r\n;0\r\n|1\r\n$1\r\na\r\n:1\r\n_\r\n.\r\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := write(NewWriter(&buf, tt.protocol)); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("expected RESP%d %q but got %q", tt.protocol, tt.expected, buf.String())
+		}
+	}
+
+	w := NewWriter(io.Discard, 2)
+	if err := w.WriteStreamEnd(); err == nil {
+		t.Error("expected an error ending a stream never started")
+	}
+	if err := w.WriteStreamStart(Integer); err == nil {
+		t.Error("expected an error streaming an integer")
+	}
+	w.WriteStreamStart(BulkString)
+	if err := w.WriteInteger(1); err == nil {
+		t.Error("expected an error writing an integer in a streamed string")
+	}
 }
 
 func TestDeserializer(t *testing.T) {
-    t.Skip()
+	tests := []struct {
+		name  string
+		input string
+		// expected is the value read encoded back as RESP3.
+		expected string
+	}{
+		{"simple string", "+OK\r\n", "+OK\r\n"},
+		{"error", "-ERR unknown\r\n", "-ERR unknown\r\n"},
+		{"integer", ":-42\r\n", ":-42\r\n"},
+		{"bulk string", "$4\r\na\r\nb\r\n", "$4\r\na\r\nb\r\n"},
+		{"RESP2 null", "$-1\r\n", "_\r\n"},
+		{"RESP2 null array", "*-1\r\n", "_\r\n"},
+		{"null", "_\r\n", "_\r\n"},
+		{"double", ",1.23\r\n", ",1.23\r\n"},
+		{"double with exponent", ",1.5e3\r\n", ",1500\r\n"},
+		{"infinite double", ",-inf\r\n", ",-inf\r\n"},
+		{"nan", ",nan\r\n", ",nan\r\n"},
+		{"boolean", "#t\r\n", "#t\r\n"},
+		{"blob error", "!21\r\nSYNTAX invalid syntax\r\n", "!21\r\nSYNTAX invalid syntax\r\n"},
+		{"verbatim string", "=15\r\ntxt:Some string\r\n", "=15\r\ntxt:Some string\r\n"},
+		{"big number", "(-3492890328409238509324850943850943825024385\r\n", "(-3492890328409238509324850943850943825024385\r\n"},
+		{"array", "*2\r\n:1\r\n*1\r\n+a\r\n", "*2\r\n:1\r\n*1\r\n+a\r\n"},
+		{"map", "%1\r\n+key\r\n#f\r\n", "%1\r\n+key\r\n#f\r\n"},
+		{"set", "~1\r\n:1\r\n", "~1\r\n:1\r\n"},
+		{"push", ">2\r\n+pubsub\r\n+message\r\n", ">2\r\n+pubsub\r\n+message\r\n"},
+		{"attributes", "|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n*1\r\n:1\r\n", "|1\r\n+key-popularity\r\n%1\r\n$1\r\na\r\n,0.1923\r\n*1\r\n:1\r\n"},
+		{"streamed string", "$?\r\n;4\r\nHell\r\n;5\r\no wor\r\n;1\r\nd\r\n;0\r\n", "$10\r\nHello word\r\n"},
+		{"streamed array", "*?\r\n:1\r\n$?\r\n;1\r\na\r\n;0\r\n*?\r\n.\r\n.\r\n", "*3\r\n:1\r\n$1\r\na\r\n*0\r\n"},
+		{"streamed map", "%?\r\n+a\r\n:1\r\n.\r\n", "%1\r\n+a\r\n:1\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewReader(strings.NewReader(tt.input)).ReadValue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(AppendValue(nil, 3, v)); got != tt.expected {
+				t.Errorf("expected %q but got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeserializerErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"unknown type", "?1\r\n"},
+		{"missing CR", "+OK\n"},
+		{"invalid integer", ":1.5\r\n"},
+		{"invalid bulk length", "$-2\r\n"},
+		{"bulk string too long", "$5\r\nhello!\r\n"},
+		{"invalid multibulk length", "*x\r\n"},
+		{"invalid boolean", "#x\r\n"},
+		{"invalid double", ",one\r\n"},
+		{"invalid big number", "(12a\r\n"},
+		{"invalid verbatim string", "=3\r\ntxt\r\n"},
+		{"stray end", ".\r\n"},
+		{"streamed map missing a value", "%?\r\n+a\r\n.\r\n"},
+		{"attribute without a value", "*?\r\n|1\r\n+a\r\n+b\r\n.\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.input)).ReadValue()
+			var protoErr *ProtocolError
+			if !errors.As(err, &protoErr) {
+				t.Fatalf("expected a protocol error but got %v", err)
+			}
+		})
+	}
+
+	for _, input := range []string{"$5\r\nhel", "*2\r\n:1\r\n", "+OK"} {
+		if _, err := NewReader(strings.NewReader(input)).ReadValue(); err != io.ErrUnexpectedEOF {
+			t.Errorf("expected %q to be cut short but got %v", input, err)
+		}
+	}
+	if _, err := NewReader(strings.NewReader("")).ReadValue(); err != io.EOF {
+		t.Errorf("expected EOF reading nothing but got %v", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	n, _ := new(big.Int).SetString("-18446744073709551617", 10)
+	values := []Value{
+		SimpleStringValue("OK"),
+		ErrorValue(errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")),
+		IntegerValue(math.MinInt64),
+		StringValue(""),
+		BytesValue([]byte{0, 255, '\r', '\n'}),
+		NullValue(),
+		DoubleValue(-0.1),
+		DoubleValue(math.Inf(1)),
+		BoolValue(true),
+		BlobErrorValue("ERR\r\nmultiline"),
+		VerbatimValue("mkd", "# title"),
+		BigNumberValue(n),
+		ArrayValue([]Value{StringValue("a"), ArrayValue([]Value{}), NullValue()}),
+		MapValue([]Value{StringValue("k"), SetValue([]Value{IntegerValue(1)})}),
+		PushValue([]Value{StringValue("invalidate"), ArrayValue([]Value{StringValue("key")})}),
+		StringValue("value").WithAttributes([]Value{SimpleStringValue("ttl"), IntegerValue(10)}),
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 3)
+	for _, v := range values {
+		if err := w.WriteValue(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd := NewReader(&buf)
+	for _, v := range values {
+		got, err := rd.ReadValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := AppendValue(nil, 3, v)
+		if encoded := AppendValue(nil, 3, got); !bytes.Equal(encoded, expected) {
+			t.Errorf("expected %q to read back the same but got %q", expected, encoded)
+		}
+	}
+	if _, err := rd.ReadValue(); err != io.EOF {
+		t.Errorf("expected EOF after the last value but got %v", err)
+	}
+
+	// RESP2 keeps what it can express.
+	buf.Reset()
+	NewWriter(&buf, 2).WriteValue(MapValue([]Value{StringValue("score"), DoubleValue(1.5)}))
+	got, err := NewReader(&buf).ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elems := got.Array(); got.Type() != Array || len(elems) != 2 || elems[0].String() != "score" || elems[1].Float() != 1.5 {
+		t.Errorf("expected a RESP2 map to read back as a flat array but got %q", AppendValue(nil, 3, got))
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"multibulk", "*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n", []string{"GET", "key"}},
+		{"inline", "PING\r\n", []string{"PING"}},
+		{"bare LF", "SET key value\n", []string{"SET", "key", "value"}},
+		{"empty lines skipped", "\r\n  \r\nPING\r\n", []string{"PING"}},
+		{"extra spaces", "  SET \t key   value  \r\n", []string{"SET", "key", "value"}},
+		{"double quotes", `SET "my key" "a \"quoted\" value"` + "\r\n", []string{"SET", "my key", `a "quoted" value`}},
+		{"escapes", `SET k "\x41\x7a\n\t\\"` + "\r\n", []string{"SET", "k", "Az\n\t\\"}},
+		{"invalid hex escape", `SET k "\x4g"` + "\r\n", []string{"SET", "k", "x4g"}},
+		{"single quotes", `SET k 'it\'s "raw" \n'` + "\r\n", []string{"SET", "k", `it's "raw" \n`}},
+		{"empty quotes", `SET k ""` + "\r\n", []string{"SET", "k", ""}},
+		{"quote inside an argument", `SET k"ey" v` + "\r\n", []string{"SET", "key", "v"}},
+		{"empty arrays skipped", "*0\r\n*-1\r\n*1\r\n$4\r\nPING\r\n", []string{"PING"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewReader(strings.NewReader(tt.input)).ReadCommand()
+			if err != nil {
+				t.Fatal(err)
+			}
+			args := []string{}
+			for _, arg := range v.Array() {
+				args = append(args, arg.String())
+			}
+			if v.Type() != Array || strings.Join(args, "|") != strings.Join(tt.expected, "|") || len(args) != len(tt.expected) {
+				t.Errorf("expected %q but got %q", tt.expected, args)
+			}
+		})
+	}
+
+	for _, input := range []string{
+		`SET k "value` + "\r\n",
+		`SET k 'value` + "\r\n",
+		`SET k "value"x` + "\r\n",
+		`SET k 'value'x` + "\r\n",
+		strings.Repeat("a", maxInlineLen+1) + "\r\n",
+	} {
+		_, err := NewReader(strings.NewReader(input)).ReadCommand()
+		var protoErr *ProtocolError
+		if !errors.As(err, &protoErr) {
+			t.Errorf("expected a protocol error reading %.20q but got %v", input, err)
+		}
+	}
+
+	// Commands are flat arrays of bulk strings, whatever else RESP allows.
+	multibulkErrors := []struct {
+		input    string
+		expected string
+	}{
+		{strings.Repeat("*1\r\n", 1<<20), "Protocol error: expected '$', got '*'"},
+		{"*2\r\n$3\r\nGET\r\n:1\r\n", "Protocol error: expected '$', got ':'"},
+		{"*1\r\n%1\r\n$1\r\na\r\n$1\r\nb\r\n", "Protocol error: expected '$', got '%'"},
+		{"*1\r\n\r\n", "Protocol error: expected '$', got '\r'"},
+		{"*?\r\n$4\r\nPING\r\n.\r\n", "Protocol error: invalid multibulk length"},
+		{"*2147483648\r\n", "Protocol error: invalid multibulk length"},
+		{"*1\r\n$-1\r\n", "Protocol error: invalid bulk length"},
+		{"*1\r\n$?\r\n", "Protocol error: invalid bulk length"},
+		{"*" + strings.Repeat("1", maxInlineLen+1) + "\r\n", "Protocol error: too big mbulk count string"},
+		{"*1\r\n$" + strings.Repeat("1", maxInlineLen+1) + "\r\n", "Protocol error: too big bulk count string"},
+	}
+	for _, tt := range multibulkErrors {
+		_, err := NewReader(strings.NewReader(tt.input)).ReadCommand()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("expected reading %.20q to fail with %q but got %v", tt.input, tt.expected, err)
+		}
+	}
+
+	rd := NewReader(strings.NewReader("PING\r\n*1\r\n$4\r\nPING\r\nPING"))
+	for i := 0; i < 2; i++ {
+		if _, err := rd.ReadCommand(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := rd.ReadCommand(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected a line cut short to be an unexpected EOF but got %v", err)
+	}
 }
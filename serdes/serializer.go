@@ -1,2 +1,363 @@
 package serdes
 
+import (
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var errNoStream = errors.New("serdes: no streamed value to end")
+
+// Writer writes RESP values for a client of a protocol version, 2 or 3. The
+// RESP3 types are downgraded the way redis does for the RESP2 clients: maps
+// and sets become arrays, doubles and big numbers bulk strings, booleans
+// integers, and attributes are left out.
+type Writer struct {
+	w        io.Writer
+	protocol int
+
+	// streams are the streamed aggregates and strings being written,
+	// innermost last.
+	streams []stream
+	// buf holds what RESP2 streams are made of until their length is known,
+	// the protocol can't express a length that is not.
+	buf []byte
+}
+
+// stream is a value written piece by piece.
+type stream struct {
+	typ Type
+	// start is where the stream begins in buf, and n the number of elements,
+	// or bytes of a string, written so far. Only RESP2 uses them.
+	start, n int
+}
+
+// NewWriter creates a writer for a client of the protocol version.
+func NewWriter(w io.Writer, protocol int) *Writer {
+	return &Writer{w: w, protocol: protocol}
+}
+
+// WriteValue writes the value.
+func (w *Writer) WriteValue(v Value) error {
+	if len(w.streams) == 0 {
+		_, err := w.w.Write(AppendValue(nil, w.protocol, v))
+		return err
+	}
+
+	top := &w.streams[len(w.streams)-1]
+	if top.typ == BulkString {
+		if v.typ != BulkString && v.typ != SimpleString {
+			return errors.New("serdes: a streamed string is made of strings")
+		}
+		if w.protocol >= 3 {
+			_, err := w.w.Write(appendChunk(nil, v.str))
+			return err
+		}
+		w.buf = append(w.buf, v.str...)
+		top.n += len(v.str)
+		return nil
+	}
+
+	if w.protocol >= 3 {
+		_, err := w.w.Write(AppendValue(nil, w.protocol, v))
+		return err
+	}
+	if v.typ != Attribute {
+		top.n++
+	}
+	w.buf = AppendValue(w.buf, w.protocol, v)
+
+	return nil
+}
+
+// WriteStreamStart starts a streamed value of the type, an aggregate or a
+// bulk string whose length is not known upfront. Its elements, or the
+// chunks of the string, are the values written until WriteStreamEnd.
+func (w *Writer) WriteStreamStart(typ Type) error {
+	if typ != BulkString && !typ.aggregate() {
+		return errors.New("serdes: a " + typ.String() + " can't be streamed")
+	}
+	if len(w.streams) > 0 && w.streams[len(w.streams)-1].typ == BulkString {
+		return errors.New("serdes: a streamed string is made of strings")
+	}
+	if w.protocol >= 3 {
+		w.streams = append(w.streams, stream{typ: typ})
+		_, err := w.w.Write([]byte{byte(typ), '?', '\r', '\n'})
+		return err
+	}
+
+	if len(w.streams) > 0 && typ != Attribute {
+		w.streams[len(w.streams)-1].n++
+	}
+	w.streams = append(w.streams, stream{typ: typ, start: len(w.buf)})
+
+	return nil
+}
+
+// WriteStreamEnd ends the innermost streamed value.
+func (w *Writer) WriteStreamEnd() error {
+	if len(w.streams) == 0 {
+		return errNoStream
+	}
+	s := w.streams[len(w.streams)-1]
+	w.streams = w.streams[:len(w.streams)-1]
+
+	if w.protocol >= 3 {
+		end := ".\r\n"
+		if s.typ == BulkString {
+			end = ";0\r\n"
+		}
+		_, err := io.WriteString(w.w, end)
+		return err
+	}
+
+	// Now that the length is known, the header goes before the content.
+	var header []byte
+	switch s.typ {
+	case BulkString:
+		header = appendLen(nil, BulkString, s.n)
+		w.buf = append(w.buf, '\r', '\n')
+	case Attribute:
+		w.buf = w.buf[:s.start]
+	default:
+		header = appendLen(nil, Array, s.n)
+	}
+	w.buf = append(w.buf[:s.start], append(header, w.buf[s.start:]...)...)
+	if len(w.streams) > 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.buf)
+	w.buf = w.buf[:0]
+
+	return err
+}
+
+func (w *Writer) WriteString(s string) error       { return w.WriteValue(StringValue(s)) }
+func (w *Writer) WriteBytes(b []byte) error        { return w.WriteValue(BytesValue(b)) }
+func (w *Writer) WriteSimpleString(s string) error { return w.WriteValue(SimpleStringValue(s)) }
+func (w *Writer) WriteError(err error) error       { return w.WriteValue(ErrorValue(err)) }
+func (w *Writer) WriteInteger(n int) error         { return w.WriteValue(IntegerValue(int64(n))) }
+func (w *Writer) WriteDouble(f float64) error      { return w.WriteValue(DoubleValue(f)) }
+func (w *Writer) WriteBool(b bool) error           { return w.WriteValue(BoolValue(b)) }
+func (w *Writer) WriteBigNumber(n *big.Int) error  { return w.WriteValue(BigNumberValue(n)) }
+func (w *Writer) WriteNull() error                 { return w.WriteValue(NullValue()) }
+func (w *Writer) WriteNullArray() error            { return w.WriteValue(NullArrayValue()) }
+func (w *Writer) WriteArray(elems []Value) error   { return w.WriteValue(ArrayValue(elems)) }
+func (w *Writer) WriteSet(elems []Value) error     { return w.WriteValue(SetValue(elems)) }
+func (w *Writer) WritePush(elems []Value) error    { return w.WriteValue(PushValue(elems)) }
+func (w *Writer) WriteMap(pairs []Value) error     { return w.WriteValue(MapValue(pairs)) }
+func (w *Writer) WriteVerbatim(format, text string) error {
+	return w.WriteValue(VerbatimValue(format, text))
+}
+
+// WriteAttribute writes the attributes of the value written next.
+func (w *Writer) WriteAttribute(pairs []Value) error {
+	return w.WriteValue(Value{typ: Attribute, elems: pairs})
+}
+
+// AppendValue appends the encoding of the value for a client of the protocol
+// version.
+func AppendValue(b []byte, protocol int, v Value) []byte {
+	if protocol >= 3 && len(v.attrs) > 0 {
+		b = appendLen(b, Attribute, len(v.attrs)/2)
+		for _, attr := range v.attrs {
+			b = AppendValue(b, protocol, attr)
+		}
+	}
+
+	switch v.typ {
+	case SimpleString:
+		return appendLine(b, SimpleString, v.str)
+	case Error:
+		return appendLine(b, Error, v.str)
+	case BlobError:
+		if protocol < 3 {
+			return appendLine(b, Error, v.str)
+		}
+		return appendBlob(b, BlobError, v.str)
+	case Integer:
+		return AppendInteger(b, v.integer)
+	case BulkString:
+		return appendBlob(b, BulkString, v.str)
+	case Null:
+		if v.array {
+			return AppendNullArray(b, protocol)
+		}
+		return AppendNull(b, protocol)
+	case Double:
+		return AppendDouble(b, protocol, v.double)
+	case Boolean:
+		return AppendBool(b, protocol, v.boolean)
+	case VerbatimString:
+		if protocol < 3 {
+			return appendBlob(b, BulkString, v.str)
+		}
+		b = appendLen(b, VerbatimString, len(v.format)+1+len(v.str))
+		b = append(append(append(b, v.format...), ':'), v.str...)
+		return append(b, '\r', '\n')
+	case BigNumber:
+		if protocol < 3 {
+			return appendBlob(b, BulkString, v.str)
+		}
+		return appendLine(b, BigNumber, v.str)
+	case Attribute:
+		if protocol < 3 {
+			return b
+		}
+		b = appendLen(b, Attribute, len(v.elems)/2)
+	case Map:
+		b = AppendMapLen(b, protocol, len(v.elems)/2)
+	case Set:
+		b = AppendSetLen(b, protocol, len(v.elems))
+	case Push:
+		if protocol < 3 {
+			b = AppendArrayLen(b, len(v.elems))
+		} else {
+			b = appendLen(b, Push, len(v.elems))
+		}
+	default:
+		b = AppendArrayLen(b, len(v.elems))
+	}
+	for _, elem := range v.elems {
+		b = AppendValue(b, protocol, elem)
+	}
+
+	return b
+}
+
+// AppendBulk appends a bulk string.
+func AppendBulk(b []byte, s string) []byte {
+	b = appendLen(b, BulkString, len(s))
+	return append(append(b, s...), '\r', '\n')
+}
+
+// AppendInteger appends an integer.
+func AppendInteger(b []byte, n int64) []byte {
+	b = strconv.AppendInt(append(b, byte(Integer)), n, 10)
+	return append(b, '\r', '\n')
+}
+
+// AppendDouble appends a double, a bulk string for RESP2 clients.
+func AppendDouble(b []byte, protocol int, f float64) []byte {
+	if protocol < 3 {
+		return AppendBulk(b, FormatDouble(f))
+	}
+	if math.IsNaN(f) {
+		return append(b, ",nan\r\n"...)
+	}
+
+	return appendLine(b, Double, []byte(FormatDouble(f)))
+}
+
+// AppendBool appends a boolean, the integer 1 or 0 for RESP2 clients.
+func AppendBool(b []byte, protocol int, t bool) []byte {
+	switch {
+	case protocol < 3 && t:
+		return append(b, ":1\r\n"...)
+	case protocol < 3:
+		return append(b, ":0\r\n"...)
+	case t:
+		return append(b, "#t\r\n"...)
+	default:
+		return append(b, "#f\r\n"...)
+	}
+}
+
+// AppendNull appends a null, a null bulk string for RESP2 clients.
+func AppendNull(b []byte, protocol int) []byte {
+	if protocol < 3 {
+		return append(b, "$-1\r\n"...)
+	}
+
+	return append(b, "_\r\n"...)
+}
+
+// AppendNullArray appends a null standing for a missing array, a null
+// array for RESP2 clients.
+func AppendNullArray(b []byte, protocol int) []byte {
+	if protocol < 3 {
+		return append(b, "*-1\r\n"...)
+	}
+
+	return append(b, "_\r\n"...)
+}
+
+// AppendArrayLen appends the header of an array of n elements.
+func AppendArrayLen(b []byte, n int) []byte {
+	return appendLen(b, Array, n)
+}
+
+// AppendMapLen appends the header of a map of n pairs, a flat array of the
+// keys and values for RESP2 clients.
+func AppendMapLen(b []byte, protocol, n int) []byte {
+	if protocol < 3 {
+		return appendLen(b, Array, n*2)
+	}
+
+	return appendLen(b, Map, n)
+}
+
+// AppendSetLen appends the header of a set of n elements, an array for RESP2
+// clients.
+func AppendSetLen(b []byte, protocol, n int) []byte {
+	if protocol < 3 {
+		return appendLen(b, Array, n)
+	}
+
+	return appendLen(b, Set, n)
+}
+
+// FormatDouble formats a double like redis: the shortest representation that
+// reads back as the same float, using an exponent only where %.17g would.
+func FormatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	exp, _ := strconv.Atoi(s[strings.IndexByte(s, 'e')+1:])
+	if exp < -4 || exp >= 17 {
+		return s
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func appendLen(b []byte, typ Type, n int) []byte {
+	b = strconv.AppendInt(append(b, byte(typ)), int64(n), 10)
+	return append(b, '\r', '\n')
+}
+
+// appendLine appends a value on a single line, like simple strings and
+// errors. Line breaks would end it early, they are replaced by spaces.
+func appendLine(b []byte, typ Type, s []byte) []byte {
+	b = append(b, byte(typ))
+	for _, c := range s {
+		if c == '\r' || c == '\n' {
+			c = ' '
+		}
+		b = append(b, c)
+	}
+
+	return append(b, '\r', '\n')
+}
+
+func appendBlob(b []byte, typ Type, s []byte) []byte {
+	b = appendLen(b, typ, len(s))
+	return append(append(b, s...), '\r', '\n')
+}
+
+// appendChunk appends a chunk of a streamed string.
+func appendChunk(b []byte, s []byte) []byte {
+	b = strconv.AppendInt(append(b, ';'), int64(len(s)), 10)
+	b = append(append(b, '\r', '\n'), s...)
+	return append(b, '\r', '\n')
+}
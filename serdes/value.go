@@ -0,0 +1,285 @@
+// Package serdes encodes and decodes the RESP2 and RESP3 protocols redis
+// speaks with its clients.
+package serdes
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Type is the type of a RESP value, its first byte on the wire.
+type Type byte
+
+const (
+	SimpleString   Type = '+'
+	Error          Type = '-'
+	Integer        Type = ':'
+	BulkString     Type = '$'
+	Array          Type = '*'
+	Null           Type = '_'
+	Double         Type = ','
+	Boolean        Type = '#'
+	BlobError      Type = '!'
+	VerbatimString Type = '='
+	BigNumber      Type = '('
+	Map            Type = '%'
+	Set            Type = '~'
+	Attribute      Type = '|'
+	Push           Type = '>'
+)
+
+func (t Type) String() string {
+	switch t {
+	case SimpleString:
+		return "simple string"
+	case Error:
+		return "error"
+	case Integer:
+		return "integer"
+	case BulkString:
+		return "bulk string"
+	case Array:
+		return "array"
+	case Null:
+		return "null"
+	case Double:
+		return "double"
+	case Boolean:
+		return "boolean"
+	case BlobError:
+		return "blob error"
+	case VerbatimString:
+		return "verbatim string"
+	case BigNumber:
+		return "big number"
+	case Map:
+		return "map"
+	case Set:
+		return "set"
+	case Attribute:
+		return "attribute"
+	case Push:
+		return "push"
+	default:
+		return fmt.Sprintf("unknown type %q", byte(t))
+	}
+}
+
+// aggregate reports whether the values of the type hold other values.
+func (t Type) aggregate() bool {
+	return t == Array || t == Map || t == Set || t == Attribute || t == Push
+}
+
+// Value is a RESP value of any type.
+type Value struct {
+	typ Type
+	// str is the content of the strings and errors, and the digits of a big
+	// number.
+	str     []byte
+	integer int64
+	double  float64
+	boolean bool
+	// elems are the elements of an array, a set or a push, and the keys and
+	// values of a map or an attribute interleaved.
+	elems []Value
+	// format is the three letters format of a verbatim string.
+	format string
+	// array marks the null that stands for a missing array, *-1 in RESP2.
+	array bool
+	// attrs are the attributes sent before the value, interleaved keys and
+	// values.
+	attrs []Value
+}
+
+func SimpleStringValue(s string) Value { return Value{typ: SimpleString, str: []byte(s)} }
+func StringValue(s string) Value       { return Value{typ: BulkString, str: []byte(s)} }
+func BytesValue(b []byte) Value        { return Value{typ: BulkString, str: b} }
+func IntegerValue(n int64) Value       { return Value{typ: Integer, integer: n} }
+func DoubleValue(f float64) Value      { return Value{typ: Double, double: f} }
+func BoolValue(b bool) Value           { return Value{typ: Boolean, boolean: b} }
+func ArrayValue(elems []Value) Value   { return Value{typ: Array, elems: elems} }
+func SetValue(elems []Value) Value     { return Value{typ: Set, elems: elems} }
+func PushValue(elems []Value) Value    { return Value{typ: Push, elems: elems} }
+
+// ErrorValue is the simple error of err, its message starting with the
+// error code like ERR or WRONGTYPE.
+func ErrorValue(err error) Value { return Value{typ: Error, str: []byte(err.Error())} }
+
+// BlobErrorValue is an error whose message may hold any byte.
+func BlobErrorValue(msg string) Value { return Value{typ: BlobError, str: []byte(msg)} }
+
+// NullValue is the null, a null bulk string for RESP2 clients.
+func NullValue() Value { return Value{typ: Null} }
+
+// NullArrayValue is the null standing for a missing array, a null array for
+// RESP2 clients.
+func NullArrayValue() Value { return Value{typ: Null, array: true} }
+
+// BigNumberValue is an integer of any size.
+func BigNumberValue(n *big.Int) Value { return Value{typ: BigNumber, str: []byte(n.String())} }
+
+// VerbatimValue is a string of a format, txt for plain text or mkd for
+// markdown, meant to be shown as is.
+func VerbatimValue(format, text string) Value {
+	return Value{typ: VerbatimString, format: format, str: []byte(text)}
+}
+
+// MapValue is the map of the interleaved keys and values.
+func MapValue(pairs []Value) Value { return Value{typ: Map, elems: pairs} }
+
+// AnyValue converts a Go value to the RESP value holding it, anything unknown
+// being formatted as a bulk string.
+func AnyValue(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return NullValue()
+	case Value:
+		return v
+	case error:
+		return ErrorValue(v)
+	case string:
+		return StringValue(v)
+	case []byte:
+		return BytesValue(v)
+	case bool:
+		return BoolValue(v)
+	case int:
+		return IntegerValue(int64(v))
+	case int64:
+		return IntegerValue(v)
+	case int32:
+		return IntegerValue(int64(v))
+	case uint32:
+		return IntegerValue(int64(v))
+	case float64:
+		return DoubleValue(v)
+	case float32:
+		return DoubleValue(float64(v))
+	case *big.Int:
+		return BigNumberValue(v)
+	case []Value:
+		return ArrayValue(v)
+	default:
+		return StringValue(fmt.Sprint(v))
+	}
+}
+
+// WithAttributes returns the value preceded by the attributes, interleaved
+// keys and values.
+func (v Value) WithAttributes(attrs []Value) Value {
+	v.attrs = attrs
+	return v
+}
+
+// Type returns the type of the value.
+func (v Value) Type() Type { return v.typ }
+
+// IsNull reports whether the value is the null, or a RESP2 null bulk string
+// or array.
+func (v Value) IsNull() bool { return v.typ == Null }
+
+// String returns the value as a string: the content of the strings and
+// errors, and the text of the numbers and booleans. It is empty for the
+// nulls and the aggregates.
+func (v Value) String() string {
+	switch v.typ {
+	case Integer:
+		return strconv.FormatInt(v.integer, 10)
+	case Double:
+		return FormatDouble(v.double)
+	case Boolean:
+		if v.boolean {
+			return "1"
+		}
+		return "0"
+	default:
+		return string(v.str)
+	}
+}
+
+// Bytes is String without the copy for the strings.
+func (v Value) Bytes() []byte {
+	switch v.typ {
+	case Integer, Double, Boolean:
+		return []byte(v.String())
+	default:
+		return v.str
+	}
+}
+
+// Integer returns the value of an integer or a boolean, or the value parsed
+// from a string, zero if it is not a number.
+func (v Value) Integer() int64 {
+	switch v.typ {
+	case Integer:
+		return v.integer
+	case Boolean:
+		if v.boolean {
+			return 1
+		}
+		return 0
+	case Double:
+		return int64(v.double)
+	default:
+		n, _ := strconv.ParseInt(string(v.str), 10, 64)
+		return n
+	}
+}
+
+// Float returns the value of a number, or the value parsed from a string,
+// zero if it is not a number.
+func (v Value) Float() float64 {
+	switch v.typ {
+	case Double:
+		return v.double
+	case Integer, Boolean:
+		return float64(v.Integer())
+	default:
+		f, _ := strconv.ParseFloat(string(v.str), 64)
+		return f
+	}
+}
+
+// Bool returns the value of a boolean, an integer being true unless it is zero.
+func (v Value) Bool() bool {
+	if v.typ == Boolean {
+		return v.boolean
+	}
+
+	return v.Integer() != 0
+}
+
+// BigInt returns the value of a big number or an integer, nil for the others.
+func (v Value) BigInt() *big.Int {
+	switch v.typ {
+	case Integer:
+		return big.NewInt(v.integer)
+	case BigNumber:
+		n, _ := new(big.Int).SetString(string(v.str), 10)
+		return n
+	default:
+		return nil
+	}
+}
+
+// Format returns the format of a verbatim string.
+func (v Value) Format() string { return v.format }
+
+// Array returns the elements of an array, a set or a push, and the keys and
+// values of a map or an attribute interleaved.
+func (v Value) Array() []Value { return v.elems }
+
+// Attributes returns the attributes that came before the value, interleaved
+// keys and values.
+func (v Value) Attributes() []Value { return v.attrs }
+
+// Error returns the error of an error value, nil for the others.
+func (v Value) Error() error {
+	if v.typ != Error && v.typ != BlobError {
+		return nil
+	}
+
+	return errors.New(string(v.str))
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var (
+	bitOps = map[string]keyval.BitOp{
+		"AND": keyval.BitAnd,
+		"OR":  keyval.BitOr,
+		"XOR": keyval.BitXor,
+		"NOT": keyval.BitNot,
+	}
+	bitfieldOverflows = map[string]keyval.BitFieldOverflow{
+		"WRAP": keyval.OverflowWrap,
+		"SAT":  keyval.OverflowSat,
+		"FAIL": keyval.OverflowFail,
+	}
+)
+
+func setbitCommandHandler(s *Server, v proto.SetbitCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SetBit(v.Key, v.Offset, v.Bit)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func getbitCommandHandler(s *Server, v proto.GetbitCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).GetBit(v.Key, v.Offset)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func bitcountCommandHandler(s *Server, v proto.BitcountCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).BitCount(v.Key, v.Start, v.End, v.HasRange, v.Bit)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(res))
+}
+
+func bitposCommandHandler(s *Server, v proto.BitposCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).BitPos(v.Key, v.Value, v.Start, v.End, v.HasStart, v.HasEnd, v.Bit)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(res))
+}
+
+func bitopCommandHandler(s *Server, v proto.BitopCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).BitOp(bitOps[v.Op], v.Destination, v.Keys)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+// bitfieldCommandHandler replies with the result of each operation, null for
+// the ones that failed because of an overflow.
+func bitfieldCommandHandler(s *Server, v proto.BitfieldCommand, msg peer.Message) error {
+	ops := make([]keyval.BitFieldOp, 0, len(v.Ops))
+	for _, op := range v.Ops {
+		ops = append(ops, keyval.BitFieldOp{
+			Set:      op.Set,
+			Incr:     op.Incr,
+			Signed:   op.Signed,
+			Bits:     op.Bits,
+			Offset:   op.Offset,
+			Value:    op.Value,
+			Overflow: bitfieldOverflows[op.Overflow],
+		})
+	}
+	results, ok, err := s.db(msg.Peer).BitField(v.Key, ops)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	values := make([]serdes.Value, 0, len(results))
+	for i, res := range results {
+		if !ok[i] {
+			values = append(values, serdes.NullValue())
+		} else {
+			values = append(values, serdes.IntegerValue(res))
+		}
+	}
+
+	return msg.Peer.Writer().WriteArray(values)
+}
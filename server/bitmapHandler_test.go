@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBitmapCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if old := rdb.SetBit(ctx, "bitmap:users", 7, 1).Val(); old != 0 {
+		t.Fatalf("expected the previous bit to be 0 but got %d", old)
+	}
+	if bit := rdb.GetBit(ctx, "bitmap:users", 7).Val(); bit != 1 {
+		t.Fatalf("expected bit 7 to be set but got %d", bit)
+	}
+	if bit := rdb.GetBit(ctx, "bitmap:users", 1000).Val(); bit != 0 {
+		t.Fatalf("expected a bit past the end to be 0 but got %d", bit)
+	}
+	if s := rdb.Get(ctx, "bitmap:users").Val(); s != "\x01" {
+		t.Fatalf("expected \\x01 but got %q", s)
+	}
+	if err := rdb.Do(ctx, "SETBIT", "bitmap:users", "4294967296", "1").Err(); err == nil || err.Error() != "ERR bit offset is not an integer or out of range" {
+		t.Fatalf("expected an out of range offset error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "SETBIT", "bitmap:users", "1", "2").Err(); err == nil || err.Error() != "ERR bit is not an integer or out of range" {
+		t.Fatalf("expected an invalid bit error but got %v", err)
+	}
+
+	rdb.Set(ctx, "bitmap:foobar", "foobar", 0)
+	for _, tc := range []struct {
+		args     []any
+		expected int64
+	}{
+		{nil, 26},
+		{[]any{0, 0}, 4},
+		{[]any{1, 1}, 6},
+		{[]any{1, 1, "BYTE"}, 6},
+		{[]any{5, 30, "BIT"}, 17},
+		{[]any{-1, -2}, 0},
+	} {
+		args := append([]any{"BITCOUNT", "bitmap:foobar"}, tc.args...)
+		if n, err := rdb.Do(ctx, args...).Int64(); err != nil || n != tc.expected {
+			t.Fatalf("%v: expected %d but got %d (%v)", args, tc.expected, n, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		value    string
+		args     []any
+		expected int64
+	}{
+		{"\xff\xf0\x00", []any{0}, 12},
+		{"\x00\xff\xf0", []any{1, 0}, 8},
+		{"\x00\xff\xf0", []any{1, 2}, 16},
+		{"\x00\xff\xf0", []any{1, 2, -1, "BYTE"}, 16},
+		{"\x00\xff\xf0", []any{1, 7, 15, "BIT"}, 8},
+		{"\x00\x00\x00", []any{1}, -1},
+		{"\xff\xff\xff", []any{0}, 24},
+		{"\xff\xff\xff", []any{0, 0, -1}, -1},
+	} {
+		rdb.Set(ctx, "bitmap:pos", tc.value, 0)
+		args := append([]any{"BITPOS", "bitmap:pos"}, tc.args...)
+		if n, err := rdb.Do(ctx, args...).Int64(); err != nil || n != tc.expected {
+			t.Fatalf("%q %v: expected %d but got %d (%v)", tc.value, args, tc.expected, n, err)
+		}
+	}
+	if n := rdb.BitPos(ctx, "bitmap:missing", 0).Val(); n != 0 {
+		t.Fatalf("expected BITPOS 0 on a missing key to give 0 but got %d", n)
+	}
+
+	rdb.Set(ctx, "bitmap:abcdef", "abcdef", 0)
+	if n := rdb.BitOpAnd(ctx, "bitmap:and", "bitmap:foobar", "bitmap:abcdef").Val(); n != 6 {
+		t.Fatalf("expected a 6 bytes result but got %d", n)
+	}
+	if s := rdb.Get(ctx, "bitmap:and").Val(); s != "`bc`ab" {
+		t.Fatalf("expected `bc`ab but got %q", s)
+	}
+	rdb.BitOpNot(ctx, "bitmap:not", "bitmap:users")
+	if s := rdb.Get(ctx, "bitmap:not").Val(); s != "\xfe" {
+		t.Fatalf("expected \\xfe but got %q", s)
+	}
+	if err := rdb.Do(ctx, "BITOP", "NOT", "bitmap:not", "bitmap:users", "bitmap:and").Err(); err == nil || err.Error() != "ERR BITOP NOT must be called with a single source key." {
+		t.Fatalf("expected a single source key error but got %v", err)
+	}
+	rdb.BitOpOr(ctx, "bitmap:and", "bitmap:missing")
+	if rdb.Exists(ctx, "bitmap:and").Val() != 0 {
+		t.Fatal("expected an empty BITOP result to delete the destination")
+	}
+}
+
+func TestBitfield(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if res := rdb.BitField(ctx, "bitfield:k", "INCRBY", "i5", 100, 1, "GET", "u4", 0).Val(); !reflect.DeepEqual(res, []int64{1, 0}) {
+		t.Fatalf("unexpected BITFIELD reply: %v", res)
+	}
+
+	expected := [][]int64{{1, 1}, {2, 2}, {3, 3}, {0, 3}}
+	for i, exp := range expected {
+		res := rdb.BitField(ctx, "bitfield:k", "INCRBY", "u2", 102, 1, "OVERFLOW", "SAT", "INCRBY", "u2", 110, 1).Val()
+		if !reflect.DeepEqual(res, exp) {
+			t.Fatalf("step %d: expected %v but got %v", i, exp, res)
+		}
+	}
+
+	res := rdb.BitField(ctx, "bitfield:signed", "SET", "i8", "#0", 127, "INCRBY", "i8", "#0", 1, "OVERFLOW", "SAT", "INCRBY", "i8", "#0", -200).Val()
+	if !reflect.DeepEqual(res, []int64{0, -128, -128}) {
+		t.Fatalf("unexpected signed BITFIELD reply: %v", res)
+	}
+	if res := rdb.BitField(ctx, "bitfield:signed", "SET", "u8", "#1", -1, "GET", "u8", 8).Val(); !reflect.DeepEqual(res, []int64{0, 255}) {
+		t.Fatalf("expected SET to wrap -1 to 255 but got %v", res)
+	}
+
+	values, err := rdb.Do(ctx, "BITFIELD", "bitfield:fail", "OVERFLOW", "FAIL", "INCRBY", "u2", 0, 4, "GET", "u2", 0).Slice()
+	if err != nil || !reflect.DeepEqual(values, []any{nil, int64(0)}) {
+		t.Fatalf("expected FAIL to reply nil but got %v (%v)", values, err)
+	}
+
+	if res := rdb.BitFieldRO(ctx, "bitfield:signed", "i8", 0).Val(); !reflect.DeepEqual(res, []int64{-128}) {
+		t.Fatalf("unexpected BITFIELD_RO reply: %v", res)
+	}
+	if err := rdb.Do(ctx, "BITFIELD_RO", "bitfield:signed", "SET", "i8", 0, 1).Err(); err == nil || err.Error() != "ERR BITFIELD_RO only supports the GET subcommand" {
+		t.Fatalf("expected a GET only error but got %v", err)
+	}
+	if err := rdb.BitField(ctx, "bitfield:signed", "GET", "u64", 0).Err(); err == nil ||
+		err.Error() != "ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is." {
+		t.Fatalf("expected an invalid type error but got %v", err)
+	}
+	for _, offset := range []string{"9223372036854775807", "9223372036854775800", "#9223372036854775807", "#1152921504606846975", "#536870912"} {
+		for _, args := range [][]interface{}{{"GET", "i8", offset}, {"SET", "i8", offset, 1}, {"INCRBY", "i8", offset, 1}} {
+			if err := rdb.BitField(ctx, "bitfield:huge", args...).Err(); err == nil || err.Error() != "ERR bit offset is not an integer or out of range" {
+				t.Fatalf("expected BITFIELD %v to be out of range but got %v", args, err)
+			}
+		}
+	}
+	if rdb.BitField(ctx, "bitfield:missing", "GET", "u8", 0).Err() != nil || rdb.Exists(ctx, "bitfield:missing").Val() != 0 {
+		t.Fatal("expected a BITFIELD GET not to create the key")
+	}
+
+	rdb.RPush(ctx, "bitfield:list", "x")
+	if err := rdb.BitField(ctx, "bitfield:list", "GET", "u8", 0).Err(); err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Fatalf("expected a WRONGTYPE error but got %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// dbKey is a key of one of the databases.
+type dbKey struct {
+	db  int
+	key string
+}
+
+// blockedClient is a peer parked by a blocking command until one of the keys
+// it waits on can serve it, or until its timeout fires. The server loop keeps
+// running meanwhile, only that peer waits.
+type blockedClient struct {
+	peer *peer.Peer
+	cmd  proto.Command
+	// db is the database the keys are in, the one selected when it blocked.
+	db    int
+	keys  []string
+	timer *time.Timer
+	// pending holds what the peer sent while blocked (peer.Message and
+	// peer.Errors), it is replayed in order once the peer is unblocked.
+	pending []any
+}
+
+// blockingCommandHandler serves a blocking command right away if any of its
+// keys allows it, otherwise it parks the peer on all of them.
+func blockingCommandHandler(s *Server, msg peer.Message, keys []string, timeout time.Duration) error {
+	for _, key := range keys {
+		if served, err := s.serveBlocked(msg.Peer, msg.Cmd, key); served {
+			return err
+		}
+	}
+	s.block(msg, keys, timeout)
+
+	return nil
+}
+
+// bpopCommandHandler and the three below run the blocking commands with
+// their keys and timeout.
+func bpopCommandHandler(s *Server, v proto.BpopCommand, msg peer.Message) error {
+	return blockingCommandHandler(s, msg, v.Keys, v.Timeout)
+}
+
+func blmoveCommandHandler(s *Server, v proto.BlmoveCommand, msg peer.Message) error {
+	return blockingCommandHandler(s, msg, []string{v.Source}, v.Timeout)
+}
+
+func blmpopCommandHandler(s *Server, v proto.BlmpopCommand, msg peer.Message) error {
+	return blockingCommandHandler(s, msg, v.Keys, v.Timeout)
+}
+
+func bzpopCommandHandler(s *Server, v proto.BzpopCommand, msg peer.Message) error {
+	return blockingCommandHandler(s, msg, v.Keys, v.Timeout)
+}
+
+// block parks the peer of the message on the keys, waiters of a key are served
+// in the order they blocked. A zero timeout blocks forever.
+func (s *Server) block(msg peer.Message, keys []string, timeout time.Duration) {
+	bc := &blockedClient{
+		peer: msg.Peer,
+		cmd:  msg.Cmd,
+		db:   msg.Peer.DB,
+		keys: keys,
+	}
+	s.blocked[msg.Peer] = bc
+	for _, key := range keys {
+		k := dbKey{bc.db, key}
+		s.blockingKeys[k] = append(s.blockingKeys[k], bc)
+	}
+	if timeout > 0 {
+		bc.timer = time.AfterFunc(timeout, func() {
+			s.timeoutCh <- bc
+		})
+	}
+}
+
+// unblock forgets everything about the blocked client.
+func (s *Server) unblock(bc *blockedClient) {
+	delete(s.blocked, bc.peer)
+	for _, key := range bc.keys {
+		k := dbKey{bc.db, key}
+		waiters := s.blockingKeys[k][:0]
+		for _, waiter := range s.blockingKeys[k] {
+			if waiter != bc {
+				waiters = append(waiters, waiter)
+			}
+		}
+		if len(waiters) == 0 {
+			delete(s.blockingKeys, k)
+		} else {
+			s.blockingKeys[k] = waiters
+		}
+	}
+	if bc.timer != nil {
+		bc.timer.Stop()
+	}
+}
+
+// resume replays what the peer sent while it was blocked, stopping early if
+// one of those commands blocks it again.
+func (s *Server) resume(bc *blockedClient) {
+	for i, item := range bc.pending {
+		s.process(item)
+		if again := s.blocked[bc.peer]; again != nil {
+			again.pending = append(again.pending, bc.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// handleTimeout replies with a null to a client whose blocking command timed
+// out, like redis a null array unless the command replies with a single element.
+func (s *Server) handleTimeout(bc *blockedClient) {
+	// The client may have been served, or may have disconnected, while the timer fired.
+	if s.blocked[bc.peer] != bc {
+		return
+	}
+	s.unblock(bc)
+	reply := serdes.NullArrayValue()
+	if _, ok := bc.cmd.(proto.BlmoveCommand); ok {
+		reply = serdes.NullValue()
+	}
+	if err := bc.peer.Writer().WriteValue(reply); err != nil {
+		log.Println("Error handling message:", err)
+	}
+	s.resume(bc)
+}
+
+// handleReadyKeys serves, in order, the clients blocked on the keys the last
+// command signaled, lists and sorted sets when created and streams on every
+// new entry, until the keys run dry.
+func (s *Server) handleReadyKeys() {
+	for ready := true; ready; {
+		ready = false
+		for db, kv := range s.DBs {
+			for _, key := range kv.ReadyKeys() {
+				s.serveKey(dbKey{db, key})
+				ready = true
+			}
+		}
+	}
+}
+
+// serveKey serves, in order, the clients blocked on the key until it runs dry.
+func (s *Server) serveKey(k dbKey) {
+	for _, bc := range append([]*blockedClient{}, s.blockingKeys[k]...) {
+		// Like redis, a client keeps waiting if the key got a value of another type.
+		if s.blocked[bc.peer] != bc || s.DBs[k.db].Type(k.key) != blockedType(bc.cmd) {
+			continue
+		}
+		served, err := s.serveBlocked(bc.peer, bc.cmd, k.key)
+		if err != nil {
+			log.Println("Error handling message:", err)
+		}
+		if !served {
+			break
+		}
+		s.unblock(bc)
+		s.resume(bc)
+	}
+}
+
+// blockedType returns the type of value the blocking command waits for.
+func blockedType(cmd proto.Command) keyval.Type {
+	switch cmd.(type) {
+	case proto.BzpopCommand:
+		return keyval.TypeZSet
+	case proto.XreadCommand, proto.XreadgroupCommand:
+		return keyval.TypeStream
+	default:
+		return keyval.TypeList
+	}
+}
+
+// serveBlocked tries to run the blocking command against the key and reports
+// whether the peer got its reply, which can be an error.
+func (s *Server) serveBlocked(p *peer.Peer, cmd proto.Command, key string) (bool, error) {
+	switch v := cmd.(type) {
+	case proto.BpopCommand:
+		values, err := s.db(p).Pop(key, listSide(v.Left), 1)
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if values == nil {
+			return false, nil
+		}
+		return true, p.Writer().WriteArray(stringValues([]string{key, values[0]}))
+	case proto.BlmoveCommand:
+		value, ok, err := s.db(p).LMove(key, v.Destination, listSide(v.FromLeft), listSide(v.ToLeft))
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if !ok {
+			return false, nil
+		}
+		return true, p.Writer().WriteString(value)
+	case proto.BlmpopCommand:
+		values, err := s.db(p).Pop(key, listSide(v.Left), int(v.Count))
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if values == nil {
+			return false, nil
+		}
+		return true, p.Writer().WriteArray([]serdes.Value{
+			serdes.StringValue(key),
+			serdes.ArrayValue(stringValues(values)),
+		})
+	case proto.BzpopCommand:
+		members, err := s.db(p).ZPop(key, v.Max, 1)
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if len(members) == 0 {
+			return false, nil
+		}
+		b := serdes.AppendBulk(serdes.AppendBulk(serdes.AppendArrayLen(nil, 3), key), members[0].Member)
+		_, err = p.Send(serdes.AppendDouble(b, p.Protocol, members[0].Score))
+		return true, err
+	case proto.XreadCommand:
+		i := indexOf(v.Keys, key)
+		reads, err := s.db(p).XRead([]string{key}, streamIDs(v.IDs[i:i+1]), int(v.Count))
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if len(reads) == 0 {
+			return false, nil
+		}
+		return true, writeStreamReads(p, reads)
+	case proto.XreadgroupCommand:
+		i := indexOf(v.Keys, key)
+		reads, err := s.db(p).XReadGroup(v.Group, v.Consumer, []string{key}, streamIDs(v.IDs[i:i+1]), v.NewOnly[i:i+1], int(v.Count), v.NoAck)
+		if err != nil {
+			return true, p.Writer().WriteError(err)
+		}
+		if len(reads) == 0 {
+			return false, nil
+		}
+		return true, writeStreamReads(p, reads)
+	default:
+		return false, nil
+	}
+}
+
+// indexOf returns the position of the key among the keys of a command.
+func indexOf(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestBlockingPopTimesOut(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	start := time.Now()
+	if err := rdb.BLPop(ctx, 150*time.Millisecond, "blocking:empty").Err(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("BLPOP returned after %v, before its timeout", elapsed)
+	}
+
+	// The connection is still usable once the timeout fired.
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockingPopServesWaitersInOrder(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	type result struct {
+		values []string
+		err    error
+	}
+	first, second := make(chan result), make(chan result)
+	for _, ch := range []chan result{first, second} {
+		waiter := newTestClient(t)
+		go func(ch chan result) {
+			values, err := waiter.BLPop(ctx, 0, "blocking:queue").Result()
+			ch <- result{values, err}
+		}(ch)
+		// Give the waiter the time to block so the order is deterministic.
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := rdb.RPush(ctx, "blocking:queue", "job1", "job2").Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i, ch := range []chan result{first, second} {
+		select {
+		case res := <-ch:
+			expected := []string{"blocking:queue", []string{"job1", "job2"}[i]}
+			if res.err != nil || !reflect.DeepEqual(res.values, expected) {
+				t.Fatalf("waiter %d: expected %v but got %v (%v)", i, expected, res.values, res.err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("waiter %d was never served", i)
+		}
+	}
+}
+
+func TestBlockingMove(t *testing.T) {
+	rdb := newTestClient(t)
+	waiter := newTestClient(t)
+	ctx := context.Background()
+
+	done := make(chan string)
+	go func() {
+		done <- waiter.BLMove(ctx, "blocking:src", "blocking:dst", "LEFT", "RIGHT", time.Second).Val()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := rdb.LPush(ctx, "blocking:src", "item").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if value := <-done; value != "item" {
+		t.Fatalf("expected item but got %q", value)
+	}
+	if values := rdb.LRange(ctx, "blocking:dst", 0, -1).Val(); !reflect.DeepEqual(values, []string{"item"}) {
+		t.Fatalf("unexpected destination list: %v", values)
+	}
+}
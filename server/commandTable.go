@@ -0,0 +1,947 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// commandFlags are the flags of a command, as reported by COMMAND.
+type commandFlags uint32
+
+const (
+	flagWrite commandFlags = 1 << iota
+	flagReadonly
+	flagDenyOOM
+	flagAdmin
+	flagNoscript
+	flagBlocking
+	flagLoading
+	flagStale
+	flagFast
+	flagNoAuth
+	flagMayReplicate
+)
+
+// flagNames are the names of the flags, in the order of their bits.
+var flagNames = []string{"write", "readonly", "denyoom", "admin", "noscript", "blocking", "loading", "stale", "fast", "no_auth", "may_replicate"}
+
+// aclCategories are the ACL categories of a command.
+type aclCategories uint32
+
+const (
+	catKeyspace aclCategories = 1 << iota
+	catRead
+	catWrite
+	catSet
+	catSortedSet
+	catList
+	catHash
+	catString
+	catBitmap
+	catHyperLogLog
+	catGeo
+	catStream
+	catAdmin
+	catFast
+	catSlow
+	catBlocking
+	catDangerous
+	catConnection
+)
+
+// aclCategoryNames are the names of the categories, in the order of their bits
+// which is the order redis reports them in.
+var aclCategoryNames = []string{
+	"keyspace", "read", "write", "set", "sortedset", "list", "hash", "string", "bitmap",
+	"hyperloglog", "geo", "stream", "admin", "fast", "slow", "blocking", "dangerous", "connection",
+}
+
+// keySpec is where the keys are in the command line: from first to last, a
+// negative last counting from the end, every step arguments. All zero means
+// the command has no keys, or keys that only getKeys can find.
+type keySpec struct {
+	first, last, step int
+}
+
+// callFunc parses the command line of the message and runs the command. The
+// name is the one of the spec, for the parsers shared by several commands.
+type callFunc func(s *Server, name string, msg peer.Message) error
+
+// commandSpec describes a command, it drives the parsing, the arity check and
+// the dispatch of the command, and what COMMAND replies about it.
+type commandSpec struct {
+	name string
+	// arity is the number of arguments, the command name included, or the
+	// minimum number when negative.
+	arity int
+	flags commandFlags
+	keys  keySpec
+	// getKeys returns the position of the keys of the commands keySpec can't
+	// describe, the ones redis flags movablekeys.
+	getKeys func(args []string) []int
+	// acl are the categories of the command, besides the ones its flags imply.
+	acl                   aclCategories
+	group, since, summary string
+	call                  callFunc
+	// subcommands are the subcommands of a container command, call is only
+	// used for the container when it is sent alone.
+	subcommands []*commandSpec
+
+	// fullName is the lower cased name, container|subcommand for subcommands.
+	fullName string
+}
+
+// parsed makes the callFunc of a parser and the handler of what it parses.
+func parsed[T proto.Command](parse func(serdes.Value) (T, error), handle func(*Server, T, peer.Message) error) callFunc {
+	return func(s *Server, _ string, msg peer.Message) error {
+		cmd, err := parse(msg.Args)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		msg.Cmd = cmd
+
+		return handle(s, cmd, msg)
+	}
+}
+
+// parsedAs is parsed for the parsers shared by several commands, which are
+// given the name of the command.
+func parsedAs[T proto.Command](parse func(serdes.Value, string) (T, error), handle func(*Server, T, peer.Message) error) callFunc {
+	return func(s *Server, name string, msg peer.Message) error {
+		return parsed(func(v serdes.Value) (T, error) {
+			return parse(v, name)
+		}, handle)(s, name, msg)
+	}
+}
+
+// commands are the commands the server knows, and commandTable the same by
+// lower cased name. Adding a command is adding its spec here.
+var (
+	commands     []*commandSpec
+	commandTable = map[string]*commandSpec{}
+)
+
+func init() {
+	commands = []*commandSpec{
+		// connection and server
+		{name: proto.CommandCLIENT, arity: -2,
+			group: "connection", since: "2.4.0", summary: "A container for client connection commands.",
+			subcommands: []*commandSpec{
+				{name: "GETNAME", arity: 2, flags: flagNoscript | flagLoading | flagStale, acl: catConnection,
+					group: "connection", since: "2.6.9", summary: "Returns the name of the connection.",
+					call: parsed(peer.ParseClientCommand, clientCommandHandler)},
+				{name: "ID", arity: 2, flags: flagNoscript | flagLoading | flagStale, acl: catConnection,
+					group: "connection", since: "5.0.0", summary: "Returns the unique client ID of the connection.",
+					call: parsed(peer.ParseClientCommand, clientCommandHandler)},
+				{name: "SETINFO", arity: 4, flags: flagNoscript | flagLoading | flagStale, acl: catConnection,
+					group: "connection", since: "7.2.0", summary: "Sets information specific to the client or connection.",
+					call: parsed(peer.ParseClientCommand, clientCommandHandler)},
+				{name: "SETNAME", arity: 3, flags: flagNoscript | flagLoading | flagStale, acl: catConnection,
+					group: "connection", since: "2.6.9", summary: "Sets the connection name.",
+					call: parsed(peer.ParseClientCommand, clientCommandHandler)},
+			}},
+		{name: proto.CommandCOMMAND, arity: -1, flags: flagLoading | flagStale, acl: catConnection,
+			group: "server", since: "2.8.13", summary: "Returns detailed information about all commands.",
+			call: parsed(peer.ParseCommandCommand, commandCommandHandler),
+			subcommands: []*commandSpec{
+				{name: "COUNT", arity: 2, flags: flagLoading | flagStale, acl: catConnection,
+					group: "server", since: "2.8.13", summary: "Returns a count of commands."},
+				{name: "DOCS", arity: -2, flags: flagLoading | flagStale, acl: catConnection,
+					group: "server", since: "7.0.0", summary: "Returns documentary information about one, multiple or all commands."},
+				{name: "GETKEYS", arity: -3, flags: flagLoading | flagStale, acl: catConnection,
+					group: "server", since: "2.8.13", summary: "Extracts the key names from an arbitrary command."},
+				{name: "INFO", arity: -2, flags: flagLoading | flagStale, acl: catConnection,
+					group: "server", since: "2.8.13", summary: "Returns information about one, multiple or all commands."},
+				{name: "LIST", arity: -2, flags: flagLoading | flagStale, acl: catConnection,
+					group: "server", since: "7.0.0", summary: "Returns a list of command names."},
+			}},
+		{name: proto.CommandCONFIG, arity: -2,
+			group: "server", since: "2.0.0", summary: "A container for server configuration commands.",
+			subcommands: []*commandSpec{
+				{name: "GET", arity: -3, flags: flagAdmin | flagNoscript | flagLoading | flagStale,
+					group: "server", since: "2.0.0", summary: "Returns the effective values of configuration parameters.",
+					call: parsed(peer.ParseConfigGetCommand, configCommandGetHandler)},
+				{name: "SET", arity: -4, flags: flagAdmin | flagNoscript | flagLoading | flagStale,
+					group: "server", since: "2.0.0", summary: "Sets configuration parameters in-flight.",
+					call: parsed(peer.ParseConfigSetCommand, configCommandSetHandler)},
+			}},
+		{name: proto.CommandMEMORY, arity: -2,
+			group: "server", since: "4.0.0", summary: "A container for memory diagnostics commands.",
+			subcommands: []*commandSpec{
+				{name: "USAGE", arity: -3, flags: flagReadonly, keys: keySpec{2, 2, 1},
+					group: "server", since: "4.0.0", summary: "Estimates the memory usage of a key.",
+					call: parsed(peer.ParseMemoryUsageCommand, memoryUsageCommandHandler)},
+			}},
+		{name: proto.CommandHELLO, arity: -1, flags: flagNoscript | flagLoading | flagStale | flagFast | flagNoAuth, acl: catConnection,
+			group: "connection", since: "6.0.0", summary: "Handshakes with the Redis server.",
+			call: parsed(peer.ParseHelloCommand, helloCommandHandler)},
+		{name: proto.CommandPING, arity: -1, flags: flagFast, acl: catConnection,
+			group: "connection", since: "1.0.0", summary: "Returns the server's liveliness response.",
+			call: parsed(peer.ParsePingCommand, pingCommandHandler)},
+		{name: proto.CommandINFO, arity: -1, flags: flagLoading | flagStale, acl: catDangerous,
+			group: "server", since: "1.0.0", summary: "Returns information and statistics about the server.",
+			call: parsed(peer.ParseInfoCommand, infoCommandHandler)},
+		{name: proto.CommandSELECT, arity: 2, flags: flagLoading | flagStale | flagFast, acl: catConnection,
+			group: "connection", since: "1.0.0", summary: "Changes the selected database.",
+			call: parsed(peer.ParseSelectCommand, selectCommandHandler)},
+		{name: proto.CommandSWAPDB, arity: 3, flags: flagWrite | flagFast, acl: catKeyspace | catDangerous,
+			group: "server", since: "4.0.0", summary: "Swaps two Redis databases.",
+			call: parsed(peer.ParseSwapdbCommand, swapdbCommandHandler)},
+		{name: proto.CommandFLUSHDB, arity: -1, flags: flagWrite, acl: catKeyspace | catDangerous,
+			group: "server", since: "1.0.0", summary: "Remove all keys from the current database.",
+			call: parsedAs(peer.ParseFlushCommand, flushCommandHandler)},
+		{name: proto.CommandFLUSHALL, arity: -1, flags: flagWrite, acl: catKeyspace | catDangerous,
+			group: "server", since: "1.0.0", summary: "Removes all keys from all databases.",
+			call: parsedAs(peer.ParseFlushCommand, flushCommandHandler)},
+		{name: proto.CommandDBSIZE, arity: 1, flags: flagReadonly | flagFast, acl: catKeyspace,
+			group: "server", since: "1.0.0", summary: "Returns the number of keys in the database.",
+			call: parsed(peer.ParseDbsizeCommand, dbsizeCommandHandler)},
+
+		// generic
+		{name: proto.CommandDEL, arity: -2, flags: flagWrite, keys: keySpec{1, -1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Deletes one or more keys.",
+			call: parsedAs(peer.ParseDelCommand, delCommandHandler)},
+		{name: proto.CommandUNLINK, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, -1, 1}, acl: catKeyspace,
+			group: "generic", since: "4.0.0", summary: "Asynchronously deletes one or more keys.",
+			call: parsedAs(peer.ParseDelCommand, delCommandHandler)},
+		{name: proto.CommandEXIST, arity: -2, flags: flagReadonly | flagFast, keys: keySpec{1, -1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Determines whether one or more keys exist.",
+			call: parsed(peer.ParseExistCommand, existCommandHandler)},
+		{name: proto.CommandEXPIRE, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Sets the expiration time of a key in seconds.",
+			call: parsedAs(peer.ParseExpireCommand, expireCommandHandler)},
+		{name: proto.CommandPEXPIRE, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "2.6.0", summary: "Sets the expiration time of a key in milliseconds.",
+			call: parsedAs(peer.ParseExpireCommand, expireCommandHandler)},
+		{name: proto.CommandEXPIREAT, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.2.0", summary: "Sets the expiration time of a key to a Unix timestamp.",
+			call: parsedAs(peer.ParseExpireCommand, expireCommandHandler)},
+		{name: proto.CommandPEXPIREAT, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "2.6.0", summary: "Sets the expiration time of a key to a Unix milliseconds timestamp.",
+			call: parsedAs(peer.ParseExpireCommand, expireCommandHandler)},
+		{name: proto.CommandTTL, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Returns the expiration time in seconds of a key.",
+			call: parsedAs(peer.ParseTtlCommand, ttlCommandHandler)},
+		{name: proto.CommandPTTL, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "2.6.0", summary: "Returns the expiration time in milliseconds of a key.",
+			call: parsedAs(peer.ParseTtlCommand, ttlCommandHandler)},
+		{name: proto.CommandEXPIRETIME, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "7.0.0", summary: "Returns the expiration time of a key as a Unix timestamp.",
+			call: parsedAs(peer.ParseExpireTimeCommand, expireTimeCommandHandler)},
+		{name: proto.CommandPEXPIRETIME, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "7.0.0", summary: "Returns the expiration time of a key as a Unix milliseconds timestamp.",
+			call: parsedAs(peer.ParseExpireTimeCommand, expireTimeCommandHandler)},
+		{name: proto.CommandPERSIST, arity: 2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "2.2.0", summary: "Removes the expiration time of a key.",
+			call: parsed(peer.ParsePersistCommand, persistCommandHandler)},
+		{name: proto.CommandTYPE, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Determines the type of value stored at a key.",
+			call: parsed(peer.ParseTypeCommand, typeCommandHandler)},
+		{name: proto.CommandOBJECT, arity: -2,
+			group: "generic", since: "2.2.3", summary: "A container for object introspection commands.",
+			subcommands: []*commandSpec{
+				{name: "ENCODING", arity: 3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catKeyspace,
+					group: "generic", since: "2.2.3", summary: "Returns the internal encoding of a Redis object.",
+					call: parsed(peer.ParseObjectCommand, objectCommandHandler)},
+				{name: "FREQ", arity: 3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catKeyspace,
+					group: "generic", since: "4.0.0", summary: "Returns the logarithmic access frequency counter of a Redis object.",
+					call: parsed(peer.ParseObjectCommand, objectCommandHandler)},
+				{name: "HELP", arity: 2, flags: flagLoading | flagStale, acl: catKeyspace,
+					group: "generic", since: "6.2.0", summary: "Returns helpful text about the different subcommands.",
+					call: parsed(peer.ParseObjectCommand, objectCommandHandler)},
+				{name: "IDLETIME", arity: 3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catKeyspace,
+					group: "generic", since: "2.2.3", summary: "Returns the time since the last access to a Redis object.",
+					call: parsed(peer.ParseObjectCommand, objectCommandHandler)},
+				{name: "REFCOUNT", arity: 3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catKeyspace,
+					group: "generic", since: "2.2.3", summary: "Returns the reference count of a value of a key.",
+					call: parsed(peer.ParseObjectCommand, objectCommandHandler)},
+			}},
+		{name: proto.CommandSCAN, arity: -2, flags: flagReadonly, acl: catKeyspace,
+			group: "generic", since: "2.8.0", summary: "Iterates over the key names in the database.",
+			call: parsed(peer.ParseScanCommand, scanCommandHandler)},
+		{name: proto.CommandKEYS, arity: 2, flags: flagReadonly, acl: catKeyspace | catDangerous,
+			group: "generic", since: "1.0.0", summary: "Returns all key names that match a pattern.",
+			call: parsed(peer.ParseKeysCommand, keysCommandHandler)},
+		{name: proto.CommandRANDOMKEY, arity: 1, flags: flagReadonly, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Returns a random key name from the database.",
+			call: parsed(peer.ParseRandomkeyCommand, randomkeyCommandHandler)},
+		{name: proto.CommandTOUCH, arity: -2, flags: flagReadonly | flagFast, keys: keySpec{1, -1, 1}, acl: catKeyspace,
+			group: "generic", since: "3.2.1", summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+			call: parsed(peer.ParseTouchCommand, touchCommandHandler)},
+		{name: proto.CommandRENAME, arity: 3, flags: flagWrite, keys: keySpec{1, 2, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Renames a key and overwrites the destination.",
+			call: parsedAs(peer.ParseRenameCommand, renameCommandHandler)},
+		{name: proto.CommandRENAMENX, arity: 3, flags: flagWrite | flagFast, keys: keySpec{1, 2, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Renames a key only when the target key name doesn't exist.",
+			call: parsedAs(peer.ParseRenameCommand, renameCommandHandler)},
+		{name: proto.CommandCOPY, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 2, 1}, acl: catKeyspace,
+			group: "generic", since: "6.2.0", summary: "Copies the value of a key to a new key.",
+			call: parsed(peer.ParseCopyCommand, copyCommandHandler)},
+		{name: proto.CommandMOVE, arity: 3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catKeyspace,
+			group: "generic", since: "1.0.0", summary: "Moves a key to another database.",
+			call: parsed(peer.ParseMoveCommand, moveCommandHandler)},
+		{name: proto.CommandSORT, arity: -2, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, getKeys: sortKeys, acl: catSet | catSortedSet | catList | catDangerous,
+			group: "generic", since: "1.0.0", summary: "Sorts the elements in a list, a set, or a sorted set, optionally storing the result.",
+			call: parsedAs(peer.ParseSortCommand, sortCommandHandler)},
+		{name: proto.CommandSORT_RO, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSet | catSortedSet | catList | catDangerous,
+			group: "generic", since: "7.0.0", summary: "Returns the sorted elements of a list, a set, or a sorted set.",
+			call: parsedAs(peer.ParseSortCommand, sortCommandHandler)},
+
+		// string
+		{name: proto.CommandSET, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+			call: parsed(peer.ParseSetCommand, setCommandHandler)},
+		{name: proto.CommandGET, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Returns the string value of a key.",
+			call: parsed(peer.ParseGetCommand, getCommandHandler)},
+		{name: proto.CommandINCR, arity: 2, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+			call: parsedAs(peer.ParseIncrbyCommand, incrbyCommandHandler)},
+		{name: proto.CommandDECR, arity: 2, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+			call: parsedAs(peer.ParseIncrbyCommand, incrbyCommandHandler)},
+		{name: proto.CommandINCRBY, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+			call: parsedAs(peer.ParseIncrbyCommand, incrbyCommandHandler)},
+		{name: proto.CommandDECRBY, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.",
+			call: parsedAs(peer.ParseIncrbyCommand, incrbyCommandHandler)},
+		{name: proto.CommandINCRBYFLOAT, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.6.0", summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+			call: parsed(peer.ParseIncrbyfloatCommand, incrbyfloatCommandHandler)},
+		{name: proto.CommandMGET, arity: -2, flags: flagReadonly | flagFast, keys: keySpec{1, -1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Atomically returns the string values of one or more keys.",
+			call: parsed(peer.ParseMgetCommand, mgetCommandHandler)},
+		{name: proto.CommandMSET, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 2}, acl: catString,
+			group: "string", since: "1.0.1", summary: "Atomically creates or modifies the string values of one or more keys.",
+			call: parsedAs(peer.ParseMsetCommand, msetCommandHandler)},
+		{name: proto.CommandMSETNX, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 2}, acl: catString,
+			group: "string", since: "1.0.1", summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.",
+			call: parsedAs(peer.ParseMsetCommand, msetCommandHandler)},
+		{name: proto.CommandSETNX, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Set the string value of a key only when the key doesn't exist.",
+			call: parsed(peer.ParseSetnxCommand, setnxCommandHandler)},
+		{name: proto.CommandSETEX, arity: 4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.0.0", summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.",
+			call: parsedAs(peer.ParseSetexCommand, setCommandHandler)},
+		{name: proto.CommandPSETEX, arity: 4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.6.0", summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.",
+			call: parsedAs(peer.ParseSetexCommand, setCommandHandler)},
+		{name: proto.CommandGETSET, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "1.0.0", summary: "Returns the previous string value of a key after setting it to a new value.",
+			call: parsed(peer.ParseGetsetCommand, setCommandHandler)},
+		{name: proto.CommandGETDEL, arity: 2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "6.2.0", summary: "Returns the string value of a key after deleting the key.",
+			call: parsed(peer.ParseGetdelCommand, getdelCommandHandler)},
+		{name: proto.CommandGETEX, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "6.2.0", summary: "Returns the string value of a key after setting its expiration time.",
+			call: parsed(peer.ParseGetexCommand, getexCommandHandler)},
+		{name: proto.CommandAPPEND, arity: 3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.0.0", summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseAppendCommand, appendCommandHandler)},
+		{name: proto.CommandSTRLEN, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.2.0", summary: "Returns the length of a string value.",
+			call: parsed(peer.ParseStrlenCommand, strlenCommandHandler)},
+		{name: proto.CommandGETRANGE, arity: 4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.4.0", summary: "Returns a substring of the string stored at a key.",
+			call: parsed(peer.ParseGetrangeCommand, getrangeCommandHandler)},
+		{name: proto.CommandSETRANGE, arity: 4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catString,
+			group: "string", since: "2.2.0", summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseSetrangeCommand, setrangeCommandHandler)},
+		{name: proto.CommandLCS, arity: -3, flags: flagReadonly, keys: keySpec{1, 2, 1}, acl: catString,
+			group: "string", since: "7.0.0", summary: "Finds the longest common substring.",
+			call: parsed(peer.ParseLcsCommand, lcsCommandHandler)},
+
+		// bitmap
+		{name: proto.CommandSETBIT, arity: 4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "2.2.0", summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseSetbitCommand, setbitCommandHandler)},
+		{name: proto.CommandGETBIT, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "2.2.0", summary: "Returns a bit value by offset.",
+			call: parsed(peer.ParseGetbitCommand, getbitCommandHandler)},
+		{name: proto.CommandBITCOUNT, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "2.6.0", summary: "Counts the number of set bits (population counting) in a string.",
+			call: parsed(peer.ParseBitcountCommand, bitcountCommandHandler)},
+		{name: proto.CommandBITPOS, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "2.8.7", summary: "Finds the first set (1) or clear (0) bit in a string.",
+			call: parsed(peer.ParseBitposCommand, bitposCommandHandler)},
+		{name: proto.CommandBITOP, arity: -4, flags: flagWrite | flagDenyOOM, keys: keySpec{2, -1, 1}, acl: catBitmap,
+			group: "bitmap", since: "2.6.0", summary: "Performs bitwise operations on multiple strings, and stores the result.",
+			call: parsed(peer.ParseBitopCommand, bitopCommandHandler)},
+		{name: proto.CommandBITFIELD, arity: -2, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "3.2.0", summary: "Performs arbitrary bitfield integer operations on strings.",
+			call: parsedAs(peer.ParseBitfieldCommand, bitfieldCommandHandler)},
+		{name: proto.CommandBITFIELD_RO, arity: -2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catBitmap,
+			group: "bitmap", since: "6.0.0", summary: "Performs arbitrary read-only bitfield integer operations on strings.",
+			call: parsedAs(peer.ParseBitfieldCommand, bitfieldCommandHandler)},
+
+		// hyperloglog
+		{name: proto.CommandPFADD, arity: -2, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHyperLogLog,
+			group: "hyperloglog", since: "2.8.9", summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParsePfaddCommand, pfaddCommandHandler)},
+		{name: proto.CommandPFCOUNT, arity: -2, flags: flagReadonly | flagMayReplicate, keys: keySpec{1, -1, 1}, acl: catHyperLogLog,
+			group: "hyperloglog", since: "2.8.9", summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).",
+			call: parsed(peer.ParsePfcountCommand, pfcountCommandHandler)},
+		{name: proto.CommandPFMERGE, arity: -2, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 1}, acl: catHyperLogLog,
+			group: "hyperloglog", since: "2.8.9", summary: "Merges one or more HyperLogLog values into a single key.",
+			call: parsed(peer.ParsePfmergeCommand, pfmergeCommandHandler)},
+
+		// geo
+		{name: proto.CommandGEOADD, arity: -5, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catGeo,
+			group: "geo", since: "3.2.0", summary: "Adds one or more members to a geospatial index. The key is created if it doesn't exist.",
+			call: parsed(peer.ParseGeoaddCommand, geoaddCommandHandler)},
+		{name: proto.CommandGEODIST, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catGeo,
+			group: "geo", since: "3.2.0", summary: "Returns the distance between two members of a geospatial index.",
+			call: parsed(peer.ParseGeodistCommand, geodistCommandHandler)},
+		{name: proto.CommandGEOPOS, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catGeo,
+			group: "geo", since: "3.2.0", summary: "Returns the longitude and latitude of members from a geospatial index.",
+			call: parsed(peer.ParseGeoposCommand, geoposCommandHandler)},
+		{name: proto.CommandGEOHASH, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catGeo,
+			group: "geo", since: "3.2.0", summary: "Returns members from a geospatial index as geohash strings.",
+			call: parsed(peer.ParseGeohashCommand, geohashCommandHandler)},
+		{name: proto.CommandGEOSEARCH, arity: -7, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catGeo,
+			group: "geo", since: "6.2.0", summary: "Queries a geospatial index for members inside an area of a box or a circle.",
+			call: parsedAs(peer.ParseGeosearchCommand, geosearchCommandHandler)},
+		{name: proto.CommandGEOSEARCHSTORE, arity: -8, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 2, 1}, acl: catGeo,
+			group: "geo", since: "6.2.0", summary: "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result.",
+			call: parsedAs(peer.ParseGeosearchCommand, geosearchCommandHandler)},
+
+		// list
+		{name: proto.CommandLPUSH, arity: -3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.",
+			call: parsedAs(peer.ParsePushCommand, pushCommandHandler)},
+		{name: proto.CommandRPUSH, arity: -3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.",
+			call: parsedAs(peer.ParsePushCommand, pushCommandHandler)},
+		{name: proto.CommandLPUSHX, arity: -3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "2.2.0", summary: "Prepends one or more elements to a list only when the list exists.",
+			call: parsedAs(peer.ParsePushCommand, pushCommandHandler)},
+		{name: proto.CommandRPUSHX, arity: -3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "2.2.0", summary: "Appends an element to a list only when the list exists.",
+			call: parsedAs(peer.ParsePushCommand, pushCommandHandler)},
+		{name: proto.CommandLPOP, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Returns the first elements in a list after removing it. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParsePopCommand, popCommandHandler)},
+		{name: proto.CommandRPOP, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Returns and removes the last elements of a list. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParsePopCommand, popCommandHandler)},
+		{name: proto.CommandLLEN, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Returns the length of a list.",
+			call: parsed(peer.ParseLlenCommand, llenCommandHandler)},
+		{name: proto.CommandLINDEX, arity: 3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Returns an element from a list by its index.",
+			call: parsed(peer.ParseLindexCommand, lindexCommandHandler)},
+		{name: proto.CommandLSET, arity: 4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Sets the value of an element in a list by its index.",
+			call: parsed(peer.ParseLsetCommand, lsetCommandHandler)},
+		{name: proto.CommandLRANGE, arity: 4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Returns a range of elements from a list.",
+			call: parsed(peer.ParseLrangeCommand, lrangeCommandHandler)},
+		{name: proto.CommandLREM, arity: 4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Removes elements from a list. Deletes the list if the last element was removed.",
+			call: parsed(peer.ParseLremCommand, lremCommandHandler)},
+		{name: proto.CommandLTRIM, arity: 4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "1.0.0", summary: "Removes elements from both ends a list. Deletes the list if all elements were trimmed.",
+			call: parsed(peer.ParseLtrimCommand, ltrimCommandHandler)},
+		{name: proto.CommandLINSERT, arity: 5, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "2.2.0", summary: "Inserts an element before or after another element in a list.",
+			call: parsed(peer.ParseLinsertCommand, linsertCommandHandler)},
+		{name: proto.CommandLPOS, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catList,
+			group: "list", since: "6.0.6", summary: "Returns the index of matching elements in a list.",
+			call: parsed(peer.ParseLposCommand, lposCommandHandler)},
+		{name: proto.CommandLMOVE, arity: 5, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 2, 1}, acl: catList,
+			group: "list", since: "6.2.0", summary: "Returns an element after popping it from one list and pushing it to another. Deletes the list if the last element was moved.",
+			call: parsedAs(peer.ParseLmoveCommand, lmoveCommandHandler)},
+		{name: proto.CommandRPOPLPUSH, arity: 3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 2, 1}, acl: catList,
+			group: "list", since: "1.2.0", summary: "Returns the last element of a list after removing and pushing it to another list. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParseLmoveCommand, lmoveCommandHandler)},
+		{name: proto.CommandLMPOP, arity: -4, flags: flagWrite, getKeys: numkeysKeys(1), acl: catList,
+			group: "list", since: "7.0.0", summary: "Returns multiple elements from a list after removing them. Deletes the list if the last element was popped.",
+			call: parsed(peer.ParseLmpopCommand, lmpopCommandHandler)},
+		{name: proto.CommandBLPOP, arity: -3, flags: flagWrite | flagBlocking, keys: keySpec{1, -2, 1}, acl: catList,
+			group: "list", since: "2.0.0", summary: "Removes and returns the first element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParseBpopCommand, bpopCommandHandler)},
+		{name: proto.CommandBRPOP, arity: -3, flags: flagWrite | flagBlocking, keys: keySpec{1, -2, 1}, acl: catList,
+			group: "list", since: "2.0.0", summary: "Removes and returns the last element in a list. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParseBpopCommand, bpopCommandHandler)},
+		{name: proto.CommandBLMOVE, arity: 6, flags: flagWrite | flagDenyOOM | flagBlocking, keys: keySpec{1, 2, 1}, acl: catList,
+			group: "list", since: "6.2.0", summary: "Pops an element from a list, pushes it to another list and returns it. Blocks until an element is available otherwise. Deletes the list if the last element was moved.",
+			call: parsedAs(peer.ParseBlmoveCommand, blmoveCommandHandler)},
+		{name: proto.CommandBRPOPLPUSH, arity: 4, flags: flagWrite | flagDenyOOM | flagBlocking, keys: keySpec{1, 2, 1}, acl: catList,
+			group: "list", since: "2.2.0", summary: "Pops an element from a list, pushes it to another list and returns it. Block until an element is available otherwise. Deletes the list if the last element was popped.",
+			call: parsedAs(peer.ParseBlmoveCommand, blmoveCommandHandler)},
+		{name: proto.CommandBLMPOP, arity: -5, flags: flagWrite | flagBlocking, getKeys: numkeysKeys(2), acl: catList,
+			group: "list", since: "7.0.0", summary: "Pops the first element from one of multiple lists. Blocks until an element is available otherwise. Deletes the list if the last element was popped.",
+			call: parsed(peer.ParseBlmpopCommand, blmpopCommandHandler)},
+
+		// hash
+		{name: proto.CommandHSET, arity: -4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Creates or modifies the value of a field in a hash.",
+			call: parsedAs(peer.ParseHsetCommand, hsetCommandHandler)},
+		{name: proto.CommandHMSET, arity: -4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Sets the values of multiple fields.",
+			call: parsedAs(peer.ParseHsetCommand, hsetCommandHandler)},
+		{name: proto.CommandHSETNX, arity: 4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Sets the value of a field in a hash only when the field doesn't exist.",
+			call: parsed(peer.ParseHsetnxCommand, hsetnxCommandHandler)},
+		{name: proto.CommandHGET, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns the value of a field in a hash.",
+			call: parsed(peer.ParseHgetCommand, hgetCommandHandler)},
+		{name: proto.CommandHMGET, arity: -3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns the values of all fields in a hash.",
+			call: parsed(peer.ParseHmgetCommand, hmgetCommandHandler)},
+		{name: proto.CommandHDEL, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.",
+			call: parsed(peer.ParseHdelCommand, hdelCommandHandler)},
+		{name: proto.CommandHEXISTS, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Determines whether a field exists in a hash.",
+			call: parsed(peer.ParseHexistsCommand, hexistsCommandHandler)},
+		{name: proto.CommandHLEN, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns the number of fields in a hash.",
+			call: parsed(peer.ParseHlenCommand, hlenCommandHandler)},
+		{name: proto.CommandHKEYS, arity: 2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns all fields in a hash.",
+			call: parsedAs(peer.ParseHgetallCommand, hgetallCommandHandler)},
+		{name: proto.CommandHVALS, arity: 2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns all values in a hash.",
+			call: parsedAs(peer.ParseHgetallCommand, hgetallCommandHandler)},
+		{name: proto.CommandHGETALL, arity: 2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Returns all fields and values in a hash.",
+			call: parsedAs(peer.ParseHgetallCommand, hgetallCommandHandler)},
+		{name: proto.CommandHINCRBY, arity: 4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.0.0", summary: "Increments the integer value of a field in a hash by a number. Uses 0 as initial value if the field doesn't exist.",
+			call: parsed(peer.ParseHincrbyCommand, hincrbyCommandHandler)},
+		{name: proto.CommandHINCRBYFLOAT, arity: 4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.6.0", summary: "Increments the floating point value of a field by a number. Uses 0 as initial value if the field doesn't exist.",
+			call: parsed(peer.ParseHincrbyfloatCommand, hincrbyfloatCommandHandler)},
+		{name: proto.CommandHSTRLEN, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "3.2.0", summary: "Returns the length of the value of a field.",
+			call: parsed(peer.ParseHstrlenCommand, hstrlenCommandHandler)},
+		{name: proto.CommandHRANDFIELD, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "6.2.0", summary: "Returns one or more random fields from a hash.",
+			call: parsed(peer.ParseHrandfieldCommand, hrandfieldCommandHandler)},
+		{name: proto.CommandHSCAN, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catHash,
+			group: "hash", since: "2.8.0", summary: "Iterates over fields and values of a hash.",
+			call: parsed(peer.ParseHscanCommand, hscanCommandHandler)},
+
+		// set
+		{name: proto.CommandSADD, arity: -3, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Adds one or more members to a set. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseSaddCommand, saddCommandHandler)},
+		{name: proto.CommandSREM, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Removes one or more members from a set. Deletes the set if the last member was removed.",
+			call: parsed(peer.ParseSremCommand, sremCommandHandler)},
+		{name: proto.CommandSISMEMBER, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Determines whether a member belongs to a set.",
+			call: parsedAs(peer.ParseSismemberCommand, sismemberCommandHandler)},
+		{name: proto.CommandSMISMEMBER, arity: -3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "6.2.0", summary: "Determines whether multiple members belong to a set.",
+			call: parsedAs(peer.ParseSismemberCommand, sismemberCommandHandler)},
+		{name: proto.CommandSMEMBERS, arity: 2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns all members of a set.",
+			call: parsed(peer.ParseSmembersCommand, smembersCommandHandler)},
+		{name: proto.CommandSCARD, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns the number of members in a set.",
+			call: parsed(peer.ParseScardCommand, scardCommandHandler)},
+		{name: proto.CommandSPOP, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns one or more random members from a set after removing them. Deletes the set if the last member was popped.",
+			call: parsed(peer.ParseSpopCommand, spopCommandHandler)},
+		{name: proto.CommandSRANDMEMBER, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Get one or multiple random members from a set",
+			call: parsed(peer.ParseSrandmemberCommand, srandmemberCommandHandler)},
+		{name: proto.CommandSMOVE, arity: 4, flags: flagWrite | flagFast, keys: keySpec{1, 2, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Moves a member from one set to another.",
+			call: parsed(peer.ParseSmoveCommand, smoveCommandHandler)},
+		{name: proto.CommandSINTER, arity: -2, flags: flagReadonly, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns the intersect of multiple sets.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSUNION, arity: -2, flags: flagReadonly, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns the union of multiple sets.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSDIFF, arity: -2, flags: flagReadonly, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Returns the difference of multiple sets.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSINTERSTORE, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Stores the intersect of multiple sets in a key.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSUNIONSTORE, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Stores the union of multiple sets in a key.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSDIFFSTORE, arity: -3, flags: flagWrite | flagDenyOOM, keys: keySpec{1, -1, 1}, acl: catSet,
+			group: "set", since: "1.0.0", summary: "Stores the difference of multiple sets in a key.",
+			call: parsedAs(peer.ParseSetOpCommand, setOpCommandHandler)},
+		{name: proto.CommandSINTERCARD, arity: -3, flags: flagReadonly, getKeys: numkeysKeys(1), acl: catSet,
+			group: "set", since: "7.0.0", summary: "Returns the number of members of the intersect of multiple sets.",
+			call: parsed(peer.ParseSintercardCommand, sintercardCommandHandler)},
+		{name: proto.CommandSSCAN, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSet,
+			group: "set", since: "2.8.0", summary: "Iterates over members of a set.",
+			call: parsed(peer.ParseSscanCommand, sscanCommandHandler)},
+
+		// sorted set
+		{name: proto.CommandZADD, arity: -4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseZaddCommand, zaddCommandHandler)},
+		{name: proto.CommandZINCRBY, arity: 4, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Increments the score of a member in a sorted set.",
+			call: parsed(peer.ParseZincrbyCommand, zincrbyCommandHandler)},
+		{name: proto.CommandZCARD, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Returns the number of members in a sorted set.",
+			call: parsed(peer.ParseZcardCommand, zcardCommandHandler)},
+		{name: proto.CommandZSCORE, arity: 3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Returns the score of a member in a sorted set.",
+			call: parsedAs(peer.ParseZscoreCommand, zscoreCommandHandler)},
+		{name: proto.CommandZMSCORE, arity: -3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Returns the score of one or more members in a sorted set.",
+			call: parsedAs(peer.ParseZscoreCommand, zscoreCommandHandler)},
+		{name: proto.CommandZRANK, arity: -3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the index of a member in a sorted set ordered by ascending scores.",
+			call: parsedAs(peer.ParseZrankCommand, zrankCommandHandler)},
+		{name: proto.CommandZREVRANK, arity: -3, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the index of a member in a sorted set ordered by descending scores.",
+			call: parsedAs(peer.ParseZrankCommand, zrankCommandHandler)},
+		{name: proto.CommandZRANGE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Returns members in a sorted set within a range of indexes.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZRANGESTORE, arity: -5, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 2, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Stores a range of members from sorted set in a key.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZREVRANGE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Returns members in a sorted set within a range of indexes in reverse order.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZRANGEBYSCORE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.0.5", summary: "Returns members in a sorted set within a range of scores.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZREVRANGEBYSCORE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.2.0", summary: "Returns members in a sorted set within a range of scores in reverse order.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZRANGEBYLEX, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.8.9", summary: "Returns members in a sorted set within a lexicographical range.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZREVRANGEBYLEX, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.8.9", summary: "Returns members in a sorted set within a lexicographical range in reverse order.",
+			call: parsedAs(peer.ParseZrangeCommand, zrangeCommandHandler)},
+		{name: proto.CommandZCOUNT, arity: 4, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Returns the count of members in a sorted set that have scores within a range.",
+			call: parsedAs(peer.ParseZcountCommand, zcountCommandHandler)},
+		{name: proto.CommandZLEXCOUNT, arity: 4, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.8.9", summary: "Returns the number of members in a sorted set within a lexicographical range.",
+			call: parsedAs(peer.ParseZcountCommand, zcountCommandHandler)},
+		{name: proto.CommandZREM, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Removes one or more members from a sorted set. Deletes the sorted set if all members were removed.",
+			call: parsed(peer.ParseZremCommand, zremCommandHandler)},
+		{name: proto.CommandZREMRANGEBYRANK, arity: 4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Removes members in a sorted set within a range of indexes. Deletes the sorted set if all members were removed.",
+			call: parsedAs(peer.ParseZremrangeCommand, zremrangeCommandHandler)},
+		{name: proto.CommandZREMRANGEBYSCORE, arity: 4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "1.2.0", summary: "Removes members in a sorted set within a range of scores. Deletes the sorted set if all members were removed.",
+			call: parsedAs(peer.ParseZremrangeCommand, zremrangeCommandHandler)},
+		{name: proto.CommandZREMRANGEBYLEX, arity: 4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.8.9", summary: "Removes members in a sorted set within a lexicographical range. Deletes the sorted set if all members were removed.",
+			call: parsedAs(peer.ParseZremrangeCommand, zremrangeCommandHandler)},
+		{name: proto.CommandZPOPMIN, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "5.0.0", summary: "Returns the lowest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+			call: parsedAs(peer.ParseZpopCommand, zpopCommandHandler)},
+		{name: proto.CommandZPOPMAX, arity: -2, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "5.0.0", summary: "Returns the highest-scoring members from a sorted set after removing them. Deletes the sorted set if the last member was popped.",
+			call: parsedAs(peer.ParseZpopCommand, zpopCommandHandler)},
+		{name: proto.CommandBZPOPMIN, arity: -3, flags: flagWrite | flagBlocking | flagFast, keys: keySpec{1, -2, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "5.0.0", summary: "Removes and returns the member with the lowest score from one or more sorted sets. Blocks until a member is available otherwise. Deletes the sorted set if the last element was popped.",
+			call: parsedAs(peer.ParseBzpopCommand, bzpopCommandHandler)},
+		{name: proto.CommandBZPOPMAX, arity: -3, flags: flagWrite | flagBlocking | flagFast, keys: keySpec{1, -2, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "5.0.0", summary: "Removes and returns the member with the highest score from one or more sorted sets. Blocks until a member available otherwise. Deletes the sorted set if the last element was popped.",
+			call: parsedAs(peer.ParseBzpopCommand, bzpopCommandHandler)},
+		{name: proto.CommandZRANDMEMBER, arity: -2, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Returns one or more random members from a sorted set.",
+			call: parsed(peer.ParseZrandmemberCommand, zrandmemberCommandHandler)},
+		{name: proto.CommandZUNION, arity: -3, flags: flagReadonly, getKeys: numkeysKeys(1), acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Returns the union of multiple sorted sets.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZINTER, arity: -3, flags: flagReadonly, getKeys: numkeysKeys(1), acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Returns the intersect of multiple sorted sets.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZDIFF, arity: -3, flags: flagReadonly, getKeys: numkeysKeys(1), acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Returns the difference between multiple sorted sets.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZUNIONSTORE, arity: -4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, getKeys: storeNumkeysKeys, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Stores the union of multiple sorted sets in a key.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZINTERSTORE, arity: -4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, getKeys: storeNumkeysKeys, acl: catSortedSet,
+			group: "sorted-set", since: "2.0.0", summary: "Stores the intersect of multiple sorted sets in a key.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZDIFFSTORE, arity: -4, flags: flagWrite | flagDenyOOM, keys: keySpec{1, 1, 1}, getKeys: storeNumkeysKeys, acl: catSortedSet,
+			group: "sorted-set", since: "6.2.0", summary: "Stores the difference of multiple sorted sets in a key.",
+			call: parsedAs(peer.ParseZsetOpCommand, zsetOpCommandHandler)},
+		{name: proto.CommandZSCAN, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catSortedSet,
+			group: "sorted-set", since: "2.8.0", summary: "Iterates over members and scores of a sorted set.",
+			call: parsed(peer.ParseZscanCommand, zscanCommandHandler)},
+
+		// stream
+		{name: proto.CommandXADD, arity: -5, flags: flagWrite | flagDenyOOM | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Appends a new message to a stream. Creates the key if it doesn't exist.",
+			call: parsed(peer.ParseXaddCommand, xaddCommandHandler)},
+		{name: proto.CommandXTRIM, arity: -4, flags: flagWrite, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Deletes messages from the beginning of a stream.",
+			call: parsed(peer.ParseXtrimCommand, xtrimCommandHandler)},
+		{name: proto.CommandXLEN, arity: 2, flags: flagReadonly | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Return the number of messages in a stream.",
+			call: parsed(peer.ParseXlenCommand, xlenCommandHandler)},
+		{name: proto.CommandXDEL, arity: -3, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns the number of messages after removing them from a stream.",
+			call: parsed(peer.ParseXdelCommand, xdelCommandHandler)},
+		{name: proto.CommandXRANGE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs.",
+			call: parsedAs(peer.ParseXrangeCommand, xrangeCommandHandler)},
+		{name: proto.CommandXREVRANGE, arity: -4, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs in reverse order.",
+			call: parsedAs(peer.ParseXrangeCommand, xrangeCommandHandler)},
+		{name: proto.CommandXREAD, arity: -4, flags: flagReadonly | flagBlocking, getKeys: streamsKeys, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.",
+			call: parsed(peer.ParseXreadCommand, xreadCommandHandler)},
+		{name: proto.CommandXREADGROUP, arity: -7, flags: flagWrite | flagBlocking, getKeys: streamsKeys, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.",
+			call: parsed(peer.ParseXreadgroupCommand, xreadgroupCommandHandler)},
+		{name: proto.CommandXGROUP, arity: -2,
+			group: "stream", since: "5.0.0", summary: "A container for consumer groups commands.",
+			subcommands: []*commandSpec{
+				{name: "CREATE", arity: -5, flags: flagWrite | flagDenyOOM, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Creates a consumer group.",
+					call: parsed(peer.ParseXgroupCommand, xgroupCommandHandler)},
+				{name: "CREATECONSUMER", arity: 5, flags: flagWrite | flagDenyOOM, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "6.2.0", summary: "Creates a consumer in a consumer group.",
+					call: parsed(peer.ParseXgroupCommand, xgroupCommandHandler)},
+				{name: "DELCONSUMER", arity: 5, flags: flagWrite, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Deletes a consumer from a consumer group.",
+					call: parsed(peer.ParseXgroupCommand, xgroupCommandHandler)},
+				{name: "DESTROY", arity: 4, flags: flagWrite, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Destroys a consumer group.",
+					call: parsed(peer.ParseXgroupCommand, xgroupCommandHandler)},
+				{name: "SETID", arity: -5, flags: flagWrite, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Sets the last-delivered ID of a consumer group.",
+					call: parsed(peer.ParseXgroupCommand, xgroupCommandHandler)},
+			}},
+		{name: proto.CommandXACK, arity: -4, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.",
+			call: parsed(peer.ParseXackCommand, xackCommandHandler)},
+		{name: proto.CommandXPENDING, arity: -3, flags: flagReadonly, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Returns the information and entries from a stream consumer group's pending entries list.",
+			call: parsed(peer.ParseXpendingCommand, xpendingCommandHandler)},
+		{name: proto.CommandXCLAIM, arity: -6, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "5.0.0", summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.",
+			call: parsed(peer.ParseXclaimCommand, xclaimCommandHandler)},
+		{name: proto.CommandXAUTOCLAIM, arity: -6, flags: flagWrite | flagFast, keys: keySpec{1, 1, 1}, acl: catStream,
+			group: "stream", since: "6.2.0", summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.",
+			call: parsed(peer.ParseXautoclaimCommand, xautoclaimCommandHandler)},
+		{name: proto.CommandXINFO, arity: -2,
+			group: "stream", since: "5.0.0", summary: "A container for stream introspection commands.",
+			subcommands: []*commandSpec{
+				{name: "CONSUMERS", arity: 4, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Returns a list of the consumers in a consumer group.",
+					call: parsed(peer.ParseXinfoCommand, xinfoCommandHandler)},
+				{name: "GROUPS", arity: 3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Returns a list of the consumer groups of a stream.",
+					call: parsed(peer.ParseXinfoCommand, xinfoCommandHandler)},
+				{name: "STREAM", arity: -3, flags: flagReadonly, keys: keySpec{2, 2, 1}, acl: catStream,
+					group: "stream", since: "5.0.0", summary: "Returns information about a stream.",
+					call: parsed(peer.ParseXinfoCommand, xinfoCommandHandler)},
+			}},
+	}
+
+	for _, spec := range commands {
+		spec.init("")
+		commandTable[spec.fullName] = spec
+	}
+	// The COMMAND subcommands are all run by the handler of COMMAND.
+	for _, sub := range commandTable["command"].subcommands {
+		sub.call = commandTable["command"].call
+	}
+}
+
+// init sets what the spec derives from its fields, like redis the flags imply
+// some of the ACL categories.
+func (spec *commandSpec) init(container string) {
+	spec.fullName = strings.ToLower(spec.name)
+	if container != "" {
+		spec.fullName = container + "|" + spec.fullName
+	}
+	if spec.flags&flagWrite != 0 {
+		spec.acl |= catWrite
+	}
+	if spec.flags&flagReadonly != 0 {
+		spec.acl |= catRead
+	}
+	if spec.flags&flagAdmin != 0 {
+		spec.acl |= catAdmin | catDangerous
+	}
+	if spec.flags&flagBlocking != 0 {
+		spec.acl |= catBlocking
+	}
+	if spec.flags&flagFast != 0 {
+		spec.acl |= catFast
+	} else {
+		spec.acl |= catSlow
+	}
+	for _, sub := range spec.subcommands {
+		sub.init(spec.fullName)
+	}
+}
+
+// lookupCommand returns the spec of a command line, the one of the
+// subcommand for containers, with the errors redis replies when the command
+// is unknown or has the wrong number of arguments.
+func lookupCommand(args []serdes.Value) (*commandSpec, error) {
+	spec := commandTable[strings.ToLower(args[0].String())]
+	if spec == nil {
+		return nil, errUnknownCommand(args)
+	}
+	if len(spec.subcommands) > 0 && len(args) > 1 {
+		sub := spec.subcommand(args[1].String())
+		if sub == nil {
+			return nil, fmt.Errorf("ERR unknown subcommand '%.128s'. Try %s HELP.", args[1].String(), strings.ToUpper(args[0].String()))
+		}
+		spec = sub
+	}
+	if !spec.arityOK(len(args)) {
+		return nil, fmt.Errorf("ERR wrong number of arguments for '%s' command", spec.fullName)
+	}
+
+	return spec, nil
+}
+
+// lookupCommandName returns the spec of a command name, container|subcommand
+// for a subcommand, or nil.
+func lookupCommandName(name string) *commandSpec {
+	name, subName, isSub := strings.Cut(strings.ToLower(name), "|")
+	spec := commandTable[name]
+	if spec == nil || !isSub {
+		return spec
+	}
+
+	return spec.subcommand(subName)
+}
+
+// errUnknownCommand is the error of an unknown command, with its first
+// arguments the way redis quotes them.
+func errUnknownCommand(args []serdes.Value) error {
+	var quoted strings.Builder
+	for _, arg := range args[1:] {
+		if quoted.Len() >= 128 {
+			break
+		}
+		fmt.Fprintf(&quoted, "'%.*s' ", 128-quoted.Len(), arg.String())
+	}
+
+	return fmt.Errorf("ERR unknown command '%.128s', with args beginning with: %s", args[0].String(), quoted.String())
+}
+
+func (spec *commandSpec) subcommand(name string) *commandSpec {
+	for _, sub := range spec.subcommands {
+		if strings.EqualFold(sub.name, name) {
+			return sub
+		}
+	}
+
+	return nil
+}
+
+func (spec *commandSpec) arityOK(argc int) bool {
+	return spec.arity == argc || spec.arity < 0 && argc >= -spec.arity
+}
+
+// keyPositions returns the position of the keys in the command line.
+func (spec *commandSpec) keyPositions(args []string) []int {
+	if spec.getKeys != nil {
+		return spec.getKeys(args)
+	}
+	if spec.keys.first == 0 {
+		return nil
+	}
+	last := spec.keys.last
+	if last < 0 {
+		last += len(args)
+	}
+	var positions []int
+	for i := spec.keys.first; i <= last && i < len(args); i += spec.keys.step {
+		positions = append(positions, i)
+	}
+
+	return positions
+}
+
+// numkeysKeys returns the getKeys of the commands whose numkeys argument, at
+// position i, is followed by the keys.
+func numkeysKeys(i int) func(args []string) []int {
+	return func(args []string) []int {
+		if i >= len(args) {
+			return nil
+		}
+		n, err := strconv.Atoi(args[i])
+		if err != nil || n <= 0 {
+			return nil
+		}
+		var positions []int
+		for pos := i + 1; pos <= i+n && pos < len(args); pos++ {
+			positions = append(positions, pos)
+		}
+
+		return positions
+	}
+}
+
+// storeNumkeysKeys is the getKeys of ZUNIONSTORE and the like, a destination
+// followed by numkeys and the keys.
+func storeNumkeysKeys(args []string) []int {
+	return append([]int{1}, numkeysKeys(2)(args)...)
+}
+
+// streamsKeys is the getKeys of XREAD and XREADGROUP, the first half of what
+// follows STREAMS.
+func streamsKeys(args []string) []int {
+	for i := 1; i < len(args); i++ {
+		if strings.EqualFold(args[i], "STREAMS") {
+			n := (len(args) - i - 1) / 2
+			var positions []int
+			for pos := i + 1; pos <= i+n; pos++ {
+				positions = append(positions, pos)
+			}
+			return positions
+		}
+	}
+
+	return nil
+}
+
+// sortKeys is the getKeys of SORT, the sorted key and the STORE destination.
+func sortKeys(args []string) []int {
+	positions := []int{1}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "LIMIT":
+			i += 2
+		case "BY", "GET":
+			i++
+		case "STORE":
+			if i+1 < len(args) {
+				positions = append(positions, i+1)
+				i++
+			}
+		}
+	}
+
+	return positions
+}
+
+// sortedCommands returns the commands sorted by name.
+func sortedCommands() []*commandSpec {
+	sorted := append([]*commandSpec{}, commands...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].fullName < sorted[j].fullName
+	})
+
+	return sorted
+}
@@ -1,136 +1,301 @@
 package server
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
+	"redis-clone/keyval"
 	"redis-clone/peer"
 	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// serverVersion is the version of redis the server reports, the first one
+// with HELLO and RESP3.
+const serverVersion = "6.0.0"
 
-	"github.com/tidwall/resp"
+var (
+	errNoProto   = errors.New("NOPROTO unsupported protocol version")
+	errWrongPass = errors.New("WRONGPASS invalid username-password pair or user is disabled.")
 )
 
-func unhandledCommand(msg peer.Message) error {
-	return resp.
-		NewWriter(msg.Peer.Conn).
-		WriteString("This is not yet handled in our redis")
-}
+// clientCommandHandler replies with the id or the name of the connection, or
+// names it. Like redis the library details of SETINFO are only checked.
+func clientCommandHandler(s *Server, v proto.ClientCommand, msg peer.Message) error {
+	p := msg.Peer
+	switch v.Subcommand {
+	case "ID":
+		return p.Writer().WriteInteger(int(p.ID))
+	case "GETNAME":
+		if p.Name == "" {
+			return p.Writer().WriteNull()
+		}
+		return p.Writer().WriteString(p.Name)
+	case "SETNAME":
+		if err := validateClientName(v.Args[0]); err != nil {
+			return p.Writer().WriteError(err)
+		}
+		p.Name = v.Args[0]
+	case "SETINFO":
+		if err := validateClientName(v.Args[1]); err != nil {
+			return p.Writer().WriteError(fmt.Errorf("ERR %s cannot contain spaces, newlines or special characters.", v.Args[0]))
+		}
+	}
 
-func clientCommandHandler(msg peer.Message) error {
-	return resp.
-		NewWriter(msg.Peer.Conn).
-		WriteString("OK")
+	return p.Writer().WriteSimpleString("OK")
 }
 
-func commandCommandHandler(msg peer.Message) error {
-	spec := map[string]string{
-		"server":  "redis",
-		"role":    "master",
-		"version": "6.0.0",
-		"mode":    "standalone",
-		"proto":   "3",
-		"Author":  "Otmane",
-	}
-	resMap := proto.WriteRespMap(spec)
-	_, err := msg.Peer.Send(resMap)
-	if err != nil {
-		return fmt.Errorf("error sending response to peer: %s", err)
+// commandCommandHandler replies with what the command table knows: COMMAND
+// and COMMAND INFO the details of the commands, COMMAND DOCS their docs,
+// COMMAND COUNT and COMMAND LIST their number and names, and COMMAND GETKEYS
+// the keys of a command line.
+func commandCommandHandler(s *Server, v proto.CommandCommand, msg peer.Message) error {
+	w := msg.Peer.Writer()
+	switch v.Subcommand {
+	case "":
+		infos := []serdes.Value{}
+		for _, spec := range sortedCommands() {
+			infos = append(infos, commandInfo(spec))
+		}
+		return w.WriteArray(infos)
+	case "COUNT":
+		return w.WriteInteger(len(commands))
+	case "INFO":
+		if len(v.Args) == 0 {
+			return commandCommandHandler(s, proto.CommandCommand{}, msg)
+		}
+		infos := []serdes.Value{}
+		for _, name := range v.Args {
+			if spec := lookupCommandName(name); spec != nil {
+				infos = append(infos, commandInfo(spec))
+			} else {
+				infos = append(infos, serdes.NullValue())
+			}
+		}
+		return w.WriteArray(infos)
+	case "DOCS":
+		specs := sortedCommands()
+		if len(v.Args) > 0 {
+			specs = nil
+			for _, name := range v.Args {
+				if spec := lookupCommandName(name); spec != nil {
+					specs = append(specs, spec)
+				}
+			}
+		}
+		docs := []serdes.Value{}
+		for _, spec := range specs {
+			docs = append(docs, serdes.StringValue(spec.fullName), commandDocs(spec))
+		}
+		return w.WriteMap(docs)
+	case "LIST":
+		names := []serdes.Value{}
+		for _, spec := range sortedCommands() {
+			for _, spec := range append([]*commandSpec{spec}, spec.subcommands...) {
+				if commandMatches(spec, v.FilterBy, v.Filter) {
+					names = append(names, serdes.StringValue(spec.fullName))
+				}
+			}
+		}
+		return w.WriteArray(names)
+	case "GETKEYS":
+		args := make([]serdes.Value, 0, len(v.Args))
+		for _, arg := range v.Args {
+			args = append(args, serdes.StringValue(arg))
+		}
+		spec, err := lookupCommand(args)
+		if err != nil {
+			if commandTable[strings.ToLower(v.Args[0])] == nil {
+				return w.WriteError(errors.New("ERR Invalid command specified"))
+			}
+			return w.WriteError(errors.New("ERR Invalid number of arguments specified for command"))
+		}
+		keys := []string{}
+		for _, pos := range spec.keyPositions(v.Args) {
+			keys = append(keys, v.Args[pos])
+		}
+		if len(keys) == 0 {
+			return w.WriteError(errors.New("ERR The command has no key arguments"))
+		}
+		return w.WriteArray(stringValues(keys))
+	default:
+		return w.WriteError(fmt.Errorf("ERR unknown subcommand '%s'. Try COMMAND HELP.", v.Subcommand))
 	}
-	return nil
 }
 
-func helloCommandHandler(msg peer.Message) error {
-	spec := map[string]string{
-		"server":  "redis",
-		"role":    "master",
-		"version": "6.0.0",
-		"mode":    "standalone",
-		"proto":   "3",
-		"Author":  "Otmane",
-	}
-	resMap := proto.WriteRespMap(spec)
-	_, err := msg.Peer.Send(resMap)
-	if err != nil {
-		return fmt.Errorf("error sending response to peer: %s", err)
+// commandInfo is what COMMAND INFO replies about a command: its name, arity,
+// flags, key positions, ACL categories, tips, key specifications and
+// subcommands. Tips and key specifications are always empty.
+func commandInfo(spec *commandSpec) serdes.Value {
+	flags := []serdes.Value{}
+	for i, name := range flagNames {
+		if spec.flags&(1<<i) != 0 {
+			flags = append(flags, serdes.SimpleStringValue(name))
+		}
+	}
+	if spec.getKeys != nil {
+		flags = append(flags, serdes.SimpleStringValue("movablekeys"))
+	}
+	categories := []serdes.Value{}
+	for i, name := range aclCategoryNames {
+		if spec.acl&(1<<i) != 0 {
+			categories = append(categories, serdes.SimpleStringValue("@"+name))
+		}
+	}
+	subcommands := []serdes.Value{}
+	for _, sub := range spec.subcommands {
+		subcommands = append(subcommands, commandInfo(sub))
 	}
-	return nil
-}
 
-func pingCommandHandler(msg peer.Message) error {
-	return resp.NewWriter(msg.Peer.Conn).WriteString("PONG")
+	return serdes.ArrayValue([]serdes.Value{
+		serdes.StringValue(spec.fullName),
+		serdes.IntegerValue(int64(spec.arity)),
+		serdes.SetValue(flags),
+		serdes.IntegerValue(int64(spec.keys.first)),
+		serdes.IntegerValue(int64(spec.keys.last)),
+		serdes.IntegerValue(int64(spec.keys.step)),
+		serdes.SetValue(categories),
+		serdes.ArrayValue([]serdes.Value{}),
+		serdes.ArrayValue([]serdes.Value{}),
+		serdes.ArrayValue(subcommands),
+	})
 }
 
-func getCommandHandler(s *Server, v proto.GetCommand, msg peer.Message) error {
-	val, ok := s.Kv.Get(v.Key)
-	if !ok {
-		return resp.
-			NewWriter(msg.Peer.Conn).
-			WriteString("Key not found Timmy")
+// commandDocs is what COMMAND DOCS replies about a command.
+func commandDocs(spec *commandSpec) serdes.Value {
+	docs := []serdes.Value{
+		serdes.StringValue("summary"), serdes.StringValue(spec.summary),
+		serdes.StringValue("since"), serdes.StringValue(spec.since),
+		serdes.StringValue("group"), serdes.StringValue(spec.group),
+	}
+	if len(spec.subcommands) > 0 {
+		subcommands := []serdes.Value{}
+		for _, sub := range spec.subcommands {
+			subcommands = append(subcommands, serdes.StringValue(sub.fullName), commandDocs(sub))
+		}
+		docs = append(docs, serdes.StringValue("subcommands"), serdes.MapValue(subcommands))
 	}
 
-	return resp.
-		NewWriter(msg.Peer.Conn).
-		WriteString(string(val))
+	return serdes.MapValue(docs)
 }
 
-func setCommandHandler(s *Server, v proto.SetCommand, msg peer.Message) error {
-	if err := s.Kv.Set(v.Key, v.Value); err != nil {
-		return resp.
-			NewWriter(msg.Peer.Conn).
-			WriteString(err.Error())
-	}
-	// FIXME: We have a bug with our OWN WRITTEN CLIENT here
-	// When we send get request to get the value associated with the key
-	// we get the OK message which is not fine we have to send the value
-	// but with the official redis client this is working fine
-	return resp.
-		NewWriter(msg.Peer.Conn).
-		WriteString("OK")
+// commandMatches reports whether the command passes the FILTERBY of COMMAND
+// LIST, there are no modules so none passes MODULE.
+func commandMatches(spec *commandSpec, filterBy, filter string) bool {
+	switch filterBy {
+	case "":
+		return true
+	case "ACLCAT":
+		for i, name := range aclCategoryNames {
+			if strings.EqualFold(name, filter) {
+				return spec.acl&(1<<i) != 0
+			}
+		}
+		return false
+	case "PATTERN":
+		return keyval.GlobMatch(strings.ToLower(filter), spec.fullName)
+	default:
+		return false
+	}
 }
 
-func configCommandGetHandler(msg peer.Message) error {
-	return resp.NewWriter(msg.Peer.Conn).WriteArray([]resp.Value{
-		resp.StringValue("save"),
-		resp.StringValue("3600 1 300 100 60 10000"),
+// helloCommandHandler switches the connection to the protocol version asked
+// for, authenticates it and names it, then replies with the server details.
+func helloCommandHandler(s *Server, v proto.HelloCommand, msg peer.Message) error {
+	p := msg.Peer
+	if v.Protover != 0 && (v.Protover < 2 || v.Protover > 3) {
+		return p.Writer().WriteError(errNoProto)
+	}
+	// Like the default user of redis without requirepass, any password is
+	// good but there are no other users.
+	if v.Auth && v.Username != "default" {
+		return p.Writer().WriteError(errWrongPass)
+	}
+	if v.SetName {
+		if err := validateClientName(v.ClientName); err != nil {
+			return p.Writer().WriteError(err)
+		}
+		p.Name = v.ClientName
+	}
+	if v.Protover != 0 {
+		p.Protocol = int(v.Protover)
+	}
+
+	return p.Writer().WriteMap([]serdes.Value{
+		serdes.StringValue("server"), serdes.StringValue("redis"),
+		serdes.StringValue("version"), serdes.StringValue(serverVersion),
+		serdes.StringValue("proto"), serdes.IntegerValue(int64(p.Protocol)),
+		serdes.StringValue("id"), serdes.IntegerValue(p.ID),
+		serdes.StringValue("mode"), serdes.StringValue("standalone"),
+		serdes.StringValue("role"), serdes.StringValue("master"),
+		serdes.StringValue("modules"), serdes.ArrayValue([]serdes.Value{}),
 	})
 }
 
-func existCommandHandler(s *Server, v proto.ExistCommand, msg peer.Message) error {
-	_, ok := s.Kv.Get([]byte(v.Key))
-	if !ok {
-		return resp.NewWriter(msg.Peer.Conn).WriteInteger(0)
+// validateClientName checks the name is made of printable characters
+// without spaces, like redis requires.
+func validateClientName(name string) error {
+	for i := 0; i < len(name); i++ {
+		if name[i] < '!' || name[i] > '~' {
+			return errors.New("ERR Client names cannot contain spaces, newlines or special characters.")
+		}
 	}
 
-	return resp.NewWriter(msg.Peer.Conn).WriteInteger(1)
-}
-
-func delCommandHandler(s *Server, v proto.DelCommand, msg peer.Message) error {
-	s.Kv.Del([]byte(v.Key))
-	return resp.NewWriter(msg.Peer.Conn).WriteString("OK")
+	return nil
 }
 
-func decrCommandHandler(s *Server, v proto.DecrCommand, msg peer.Message) error {
-	res, err :=s.Kv.Decr([]byte(v.Key))
-	if err != nil {
-		return resp.NewWriter(msg.Peer.Conn).WriteError(err)
+// pingCommandHandler replies PONG, or echoes the message as a bulk string.
+func pingCommandHandler(s *Server, v proto.PingCommand, msg peer.Message) error {
+	if v.HasMessage {
+		return msg.Peer.Writer().WriteBytes(v.Message)
 	}
 
-	return resp.NewWriter(msg.Peer.Conn).WriteInteger(res)
+	return msg.Peer.Writer().WriteSimpleString("PONG")
 }
-	
-func incrCommandHandler(s *Server, v proto.IncrCommand, msg peer.Message) error {
-	res, err := s.Kv.Incr([]byte(v.Key))
+
+func getCommandHandler(s *Server, v proto.GetCommand, msg peer.Message) error {
+	val, ok, err := s.db(msg.Peer).Get(v.Key)
 	if err != nil {
-		return resp.NewWriter(msg.Peer.Conn).WriteError(err)
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
 	}
 
-	return resp.NewWriter(msg.Peer.Conn).WriteInteger(res)
+	return msg.Peer.Writer().WriteBytes(val)
 }
 
-func lpushCommandHandler(s *Server, v proto.LpushCommand, msg peer.Message) error {
-    res, err := s.Kv.Push(v.Key, v.Value)
+func setCommandHandler(s *Server, v proto.SetCommand, msg peer.Message) error {
+	opts := keyval.SetOptions{
+		NX:      v.NX,
+		XX:      v.XX,
+		Get:     v.Get,
+		KeepTTL: v.KeepTTL,
+	}
+	if v.Expire != 0 {
+		at, ok := absoluteExpireTime(v.Expire, v.ExpireMillis, v.ExpireAt)
+		if !ok {
+			return msg.Peer.Writer().
+				WriteError(fmt.Errorf("ERR invalid expire time in '%s' command", v.Name))
+		}
+		opts.ExpireAt = at
+	}
+
+	old, hadOld, written, err := s.db(msg.Peer).SetWithOptions(v.Key, v.Value, opts)
 	if err != nil {
-		return resp.NewWriter(msg.Peer.Conn).WriteError(err)
+		return msg.Peer.Writer().
+			WriteError(err)
 	}
 
-    return resp.NewWriter(msg.Peer.Conn).WriteInteger(res)
+	if v.Get {
+		if !hadOld {
+			return msg.Peer.Writer().WriteNull()
+		}
+		return msg.Peer.Writer().WriteBytes(old)
+	}
+	if !written {
+		return msg.Peer.Writer().WriteNull()
+	}
+	return msg.Peer.Writer().WriteSimpleString("OK")
 }
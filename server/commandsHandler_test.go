@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"redis-clone/serdes"
+)
+
+// rawConn is a connection speaking RESP to the test server without a client
+// library, so the tests see the replies exactly as sent.
+type rawConn struct {
+	conn net.Conn
+	rd   *serdes.Reader
+}
+
+func newRawConn(t *testing.T) *rawConn {
+	t.Helper()
+	newTestClient(t)
+	conn, err := net.Dial("tcp", "localhost"+testListenAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &rawConn{conn: conn, rd: serdes.NewReader(conn)}
+}
+
+// do sends the command and returns the reply.
+func (c *rawConn) do(t *testing.T, args ...string) serdes.Value {
+	t.Helper()
+	if err := serdes.NewWriter(c.conn, 2).WriteArray(stringValues(args)); err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.rd.ReadValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestHello(t *testing.T) {
+	rdb := newTestClient(t)
+	rdb.HSet(context.Background(), "hello:hash", "field", "value")
+	c := newRawConn(t)
+
+	// The connection speaks RESP2 until HELLO says otherwise.
+	if v := c.do(t, "HGETALL", "hello:hash"); v.Type() != serdes.Array {
+		t.Fatalf("expected an array before HELLO but got a %s", v.Type())
+	}
+
+	hello := c.do(t, "HELLO", "3", "SETNAME", "hello-client")
+	if hello.Type() != serdes.Map {
+		t.Fatalf("expected HELLO 3 to reply with a map but got a %s", hello.Type())
+	}
+	fields := map[string]serdes.Value{}
+	for i := 0; i+1 < len(hello.Array()); i += 2 {
+		fields[hello.Array()[i].String()] = hello.Array()[i+1]
+	}
+	for _, name := range []string{"server", "version", "proto", "id", "mode", "role", "modules"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("expected the HELLO reply to have %s", name)
+		}
+	}
+	if proto := fields["proto"]; proto.Type() != serdes.Integer || proto.Integer() != 3 {
+		t.Errorf("expected proto to be the integer 3 but got %q", proto.String())
+	}
+	if id := fields["id"]; id.Integer() <= 0 {
+		t.Errorf("expected a positive id but got %q", id.String())
+	}
+	if modules := fields["modules"]; modules.Type() != serdes.Array || len(modules.Array()) != 0 {
+		t.Error("expected no modules")
+	}
+
+	if v := c.do(t, "HGETALL", "hello:hash"); v.Type() != serdes.Map {
+		t.Fatalf("expected a map after HELLO 3 but got a %s", v.Type())
+	}
+	if v := c.do(t, "HGET", "hello:hash", "missing"); v.Type() != serdes.Null {
+		t.Fatalf("expected a RESP3 null but got a %s", v.Type())
+	}
+
+	// HELLO without a version keeps the protocol, HELLO 2 goes back.
+	if v := c.do(t, "HELLO"); v.Type() != serdes.Map {
+		t.Fatalf("expected HELLO to keep RESP3 but got a %s", v.Type())
+	}
+	if v := c.do(t, "HELLO", "2"); v.Type() != serdes.Array || len(v.Array()) != 14 {
+		t.Fatalf("expected HELLO 2 to reply with a flat array of 14 elements but got a %s", v.Type())
+	}
+	if v := c.do(t, "HGETALL", "hello:hash"); v.Type() != serdes.Array {
+		t.Fatalf("expected an array after HELLO 2 but got a %s", v.Type())
+	}
+
+	errorTests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"HELLO", "4"}, "NOPROTO"},
+		{[]string{"HELLO", "1"}, "NOPROTO"},
+		{[]string{"HELLO", "three"}, "ERR Protocol version is not an integer or out of range"},
+		{[]string{"HELLO", "3", "AUTH", "default"}, "ERR Syntax error in HELLO option 'AUTH'"},
+		{[]string{"HELLO", "3", "FOO"}, "ERR Syntax error in HELLO option 'FOO'"},
+		{[]string{"HELLO", "3", "AUTH", "someone", "secret"}, "WRONGPASS"},
+		{[]string{"HELLO", "3", "SETNAME", "with space"}, "ERR Client names cannot contain spaces"},
+	}
+	for _, tt := range errorTests {
+		v := c.do(t, tt.args...)
+		if v.Type() != serdes.Error || !strings.HasPrefix(v.String(), tt.expected) {
+			t.Errorf("expected %v to fail with %q but got %q", tt.args, tt.expected, v.String())
+		}
+	}
+	// A failed HELLO leaves the protocol alone.
+	if v := c.do(t, "HGETALL", "hello:hash"); v.Type() != serdes.Array {
+		t.Fatalf("expected a failed HELLO to keep RESP2 but got a %s", v.Type())
+	}
+
+	if v := c.do(t, "HELLO", "3", "AUTH", "default", "anything"); v.Type() != serdes.Map {
+		t.Fatalf("expected the default user to authenticate but got %q", v.String())
+	}
+}
+
+func TestCommand(t *testing.T) {
+	c := newRawConn(t)
+
+	if v := c.do(t, "COMMAND", "COUNT"); v.Integer() != int64(len(commands)) {
+		t.Fatalf("expected COMMAND COUNT to be %d but got %q", len(commands), v.String())
+	}
+	if v := c.do(t, "COMMAND"); len(v.Array()) != len(commands) {
+		t.Fatalf("expected COMMAND to describe %d commands but got %d", len(commands), len(v.Array()))
+	}
+
+	info := c.do(t, "COMMAND", "INFO", "get", "nosuchcommand", "config|get")
+	if len(info.Array()) != 3 {
+		t.Fatalf("expected 3 replies but got %d", len(info.Array()))
+	}
+	get := info.Array()[0].Array()
+	if len(get) != 10 || get[0].String() != "get" || get[1].Integer() != 2 ||
+		get[3].Integer() != 1 || get[4].Integer() != 1 || get[5].Integer() != 1 {
+		t.Fatalf("unexpected GET info %v", get)
+	}
+	if flags := strings.Join(valueStrings(get[2].Array()), " "); flags != "readonly fast" {
+		t.Errorf("expected GET to be readonly fast but got %q", flags)
+	}
+	if categories := strings.Join(valueStrings(get[6].Array()), " "); categories != "@read @string @fast" {
+		t.Errorf("unexpected GET categories %q", categories)
+	}
+	if !info.Array()[1].IsNull() {
+		t.Errorf("expected an unknown command to be null but got %q", info.Array()[1].String())
+	}
+	if name := info.Array()[2].Array()[0].String(); name != "config|get" {
+		t.Errorf("expected config|get but got %q", name)
+	}
+
+	docs := c.do(t, "COMMAND", "DOCS", "lpush")
+	if len(docs.Array()) != 2 || docs.Array()[0].String() != "lpush" {
+		t.Fatalf("unexpected docs %v", docs.Array())
+	}
+	fields := valueStrings(docs.Array()[1].Array())
+	if strings.Join(fields[2:], " ") != "since 1.0.0 group list" {
+		t.Errorf("unexpected LPUSH docs %v", fields)
+	}
+
+	listTests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"FILTERBY", "PATTERN", "xinfo*"}, "xinfo xinfo|consumers xinfo|groups xinfo|stream"},
+		{[]string{"FILTERBY", "ACLCAT", "hyperloglog"}, "pfadd pfcount pfmerge"},
+		{[]string{"FILTERBY", "MODULE", "json"}, ""},
+	}
+	for _, tt := range listTests {
+		v := c.do(t, append([]string{"COMMAND", "LIST"}, tt.args...)...)
+		if names := strings.Join(valueStrings(v.Array()), " "); names != tt.expected {
+			t.Errorf("expected COMMAND LIST %v to be %q but got %q", tt.args, tt.expected, names)
+		}
+	}
+
+	getkeysTests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"SET", "a", "b"}, "a"},
+		{[]string{"MSET", "a", "1", "b", "2"}, "a b"},
+		{[]string{"BLPOP", "a", "b", "0"}, "a b"},
+		{[]string{"ZUNIONSTORE", "dst", "2", "a", "b", "WEIGHTS", "1", "2"}, "dst a b"},
+		{[]string{"XREAD", "COUNT", "2", "STREAMS", "s1", "s2", "0", "0"}, "s1 s2"},
+		{[]string{"SORT", "k", "BY", "w", "GET", "g", "STORE", "dst"}, "k dst"},
+		{[]string{"OBJECT", "ENCODING", "k"}, "k"},
+		{[]string{"PING"}, "ERR The command has no key arguments"},
+		{[]string{"NOPE", "a"}, "ERR Invalid command specified"},
+		{[]string{"GET"}, "ERR Invalid number of arguments specified for command"},
+	}
+	for _, tt := range getkeysTests {
+		v := c.do(t, append([]string{"COMMAND", "GETKEYS"}, tt.args...)...)
+		got := v.String()
+		if v.Type() == serdes.Array {
+			got = strings.Join(valueStrings(v.Array()), " ")
+		}
+		if got != tt.expected {
+			t.Errorf("expected COMMAND GETKEYS %v to be %q but got %q", tt.args, tt.expected, got)
+		}
+	}
+
+	errorTests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"NOPE", "a", "b"}, "ERR unknown command 'NOPE', with args beginning with: 'a' 'b' "},
+		{[]string{"GET"}, "ERR wrong number of arguments for 'get' command"},
+		{[]string{"CONFIG"}, "ERR wrong number of arguments for 'config' command"},
+		{[]string{"CONFIG", "GET"}, "ERR wrong number of arguments for 'config|get' command"},
+		{[]string{"config", "nope"}, "ERR unknown subcommand 'nope'. Try CONFIG HELP."},
+		{[]string{"COMMAND", "LIST", "FILTERBY", "NAME", "x"}, "ERR syntax error"},
+	}
+	for _, tt := range errorTests {
+		if v := c.do(t, tt.args...); v.Type() != serdes.Error || v.String() != tt.expected {
+			t.Errorf("expected %v to fail with %q but got %q", tt.args, tt.expected, v.String())
+		}
+	}
+}
+
+// valueStrings returns the values as strings.
+func valueStrings(values []serdes.Value) []string {
+	ret := make([]string, 0, len(values))
+	for _, v := range values {
+		ret = append(ret, v.String())
+	}
+
+	return ret
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// memoryUnits are the suffixes a memory value can have, like in redis the
+// ones without a b are powers of ten.
+var memoryUnits = map[string]int64{
+	"":   1,
+	"b":  1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// configParams returns the parameters CONFIG GET knows and their value, in order.
+func (s *Server) configParams() []string {
+	return []string{
+		"save", "3600 1 300 100 60 10000",
+		"databases", strconv.Itoa(len(s.DBs)),
+		"maxmemory", strconv.FormatInt(s.evictor.Limit, 10),
+		"maxmemory-policy", evictionPolicyName(s.evictor.Policy),
+		"maxmemory-samples", strconv.Itoa(s.evictor.Samples),
+		"lazyfree-lazy-eviction", yesNo(s.lazyfree.Eviction.Load()),
+		"lazyfree-lazy-expire", yesNo(s.lazyfree.Expire.Load()),
+		"lazyfree-lazy-server-del", yesNo(s.lazyfree.ServerDel.Load()),
+	}
+}
+
+// lazyfreeParams maps the lazyfree-lazy-* parameters to their option.
+func (s *Server) lazyfreeParams() map[string]*atomic.Bool {
+	return map[string]*atomic.Bool{
+		"lazyfree-lazy-eviction":   &s.lazyfree.Eviction,
+		"lazyfree-lazy-expire":     &s.lazyfree.Expire,
+		"lazyfree-lazy-server-del": &s.lazyfree.ServerDel,
+	}
+}
+
+// yesNo formats a boolean parameter.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// parseYesNo parses a boolean parameter.
+func parseYesNo(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes":
+		return true, nil
+	case "no":
+		return false, nil
+	default:
+		return false, errors.New("argument must be 'yes' or 'no'")
+	}
+}
+
+// configCommandGetHandler replies with the parameters matching any of the
+// patterns of CONFIG GET.
+func configCommandGetHandler(s *Server, v proto.ConfigGetCommand, msg peer.Message) error {
+	params := s.configParams()
+	pairs := []serdes.Value{}
+	for i := 0; i < len(params); i += 2 {
+		for _, pattern := range v.Patterns {
+			if keyval.GlobMatch(pattern, params[i]) {
+				pairs = append(pairs, serdes.StringValue(params[i]), serdes.StringValue(params[i+1]))
+				break
+			}
+		}
+	}
+
+	return writeMap(msg.Peer, pairs)
+}
+
+// configCommandSetHandler sets the parameters, only the maxmemory and
+// lazyfree ones can be changed at runtime. Like redis, nothing is set if one
+// of them is invalid.
+func configCommandSetHandler(s *Server, v proto.ConfigSetCommand, msg peer.Message) error {
+	limit, policy, samples := s.evictor.Limit, s.evictor.Policy, s.evictor.Samples
+	lazyfree := map[string]bool{}
+	for i := 0; i < len(v.Params); i += 2 {
+		name, value := v.Params[i], v.Params[i+1]
+		var err error
+		switch name {
+		case "lazyfree-lazy-eviction", "lazyfree-lazy-expire", "lazyfree-lazy-server-del":
+			lazyfree[name], err = parseYesNo(value)
+		case "maxmemory":
+			limit, err = parseMemory(value)
+		case "maxmemory-policy":
+			var ok bool
+			if policy, ok = evictionPolicies[strings.ToLower(value)]; !ok {
+				err = errors.New("argument(s) must be one of the following: volatile-lru, volatile-lfu, volatile-random, volatile-ttl, allkeys-lru, allkeys-lfu, allkeys-random, noeviction")
+			}
+		case "maxmemory-samples":
+			if samples, err = strconv.Atoi(value); err != nil || samples < 1 || samples > 64 {
+				err = errors.New("argument must be between 1 and 64 inclusive")
+			}
+		default:
+			return msg.Peer.Writer().WriteError(fmt.Errorf("ERR Unknown option or number of arguments for CONFIG SET - '%s'", name))
+		}
+		if err != nil {
+			return msg.Peer.Writer().WriteError(fmt.Errorf("ERR CONFIG SET failed (possibly related to argument '%s') - %s", name, err))
+		}
+	}
+	s.evictor.Limit, s.evictor.Policy, s.evictor.Samples = limit, policy, samples
+	for name, on := range lazyfree {
+		s.lazyfreeParams()[name].Store(on)
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+// memoryUsageCommandHandler replies with the estimated memory of the key in
+// bytes, or a null when it doesn't exist.
+func memoryUsageCommandHandler(s *Server, v proto.MemoryUsageCommand, msg peer.Message) error {
+	size, ok := s.db(msg.Peer).MemoryUsage(v.Key, int(v.Samples))
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(size))
+}
+
+// parseMemory parses a memory value in bytes, like 100mb.
+func parseMemory(value string) (int64, error) {
+	value = strings.ToLower(value)
+	digits := strings.TrimRight(value, "bkmg")
+	unit, ok := memoryUnits[value[len(digits):]]
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, errors.New("argument must be a memory value")
+	}
+
+	return n * unit, nil
+}
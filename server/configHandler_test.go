@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// usedMemory returns the used_memory INFO reports.
+func usedMemory(t *testing.T, rdb *redis.Client) int64 {
+	t.Helper()
+	m := regexp.MustCompile(`used_memory:(\d+)`).FindStringSubmatch(rdb.Info(context.Background(), "memory").Val())
+	if m == nil {
+		t.Fatal("used_memory is missing from INFO memory")
+	}
+	used, _ := strconv.ParseInt(m[1], 10, 64)
+
+	return used
+}
+
+func TestMaxMemory(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		rdb.ConfigSet(ctx, "maxmemory", "0")
+		rdb.ConfigSet(ctx, "maxmemory-policy", "noeviction")
+	})
+
+	if v := rdb.ConfigGet(ctx, "maxmemory*").Val(); v["maxmemory"] != "0" || v["maxmemory-policy"] != "noeviction" || v["maxmemory-samples"] != "5" {
+		t.Fatalf("unexpected maxmemory configuration %v", v)
+	}
+	if err := rdb.ConfigSet(ctx, "maxmemory-policy", "nope").Err(); err == nil || !strings.HasPrefix(err.Error(), "ERR CONFIG SET failed (possibly related to argument 'maxmemory-policy')") {
+		t.Fatalf("expected an invalid policy error but got %v", err)
+	}
+	if err := rdb.ConfigSet(ctx, "maxmemory", "12parsecs").Err(); err == nil || !strings.Contains(err.Error(), "argument must be a memory value") {
+		t.Fatalf("expected an invalid memory error but got %v", err)
+	}
+	if err := rdb.ConfigSet(ctx, "maxmemory", "1mb").Err(); err != nil || rdb.ConfigGet(ctx, "maxmemory").Val()["maxmemory"] != "1048576" {
+		t.Fatalf("expected maxmemory to be set to 1mb but got %v", err)
+	}
+
+	rdb.Set(ctx, "maxmemory:before", "value", 0)
+	limit := usedMemory(t, rdb) + 10000
+	rdb.ConfigSet(ctx, "maxmemory", strconv.FormatInt(limit, 10))
+	big := strings.Repeat("x", 20000)
+	if err := rdb.Set(ctx, "maxmemory:big", big, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	err := rdb.Set(ctx, "maxmemory:other", "value", 0).Err()
+	if err == nil || err.Error() != "OOM command not allowed when used memory > 'maxmemory'." {
+		t.Fatalf("expected an OOM error under noeviction but got %v", err)
+	}
+	if v := rdb.Get(ctx, "maxmemory:before").Val(); v != "value" {
+		t.Fatalf("expected reads to still work but got %q", v)
+	}
+	if n := rdb.Del(ctx, "maxmemory:big").Val(); n != 1 {
+		t.Fatal("expected deletes to still work")
+	}
+
+	rdb.ConfigSet(ctx, "maxmemory-policy", "allkeys-lru")
+	for i := 0; i < 10; i++ {
+		if err := rdb.Set(ctx, "maxmemory:key:"+strconv.Itoa(i), big, 0).Err(); err != nil {
+			t.Fatalf("expected allkeys-lru to make room but got %v", err)
+		}
+	}
+	if used := usedMemory(t, rdb); used > limit+30000 {
+		t.Fatalf("expected the memory to stay around %d but got %d", limit, used)
+	}
+	if !regexp.MustCompile(`evicted_keys:[1-9]`).MatchString(rdb.Info(ctx, "stats").Val()) {
+		t.Fatal("expected keys to have been evicted")
+	}
+
+	rdb.ConfigSet(ctx, "maxmemory", "0")
+	rdb.ConfigSet(ctx, "maxmemory-policy", "allkeys-lfu")
+	rdb.Set(ctx, "maxmemory:freq", "v", 0)
+	if freq, err := rdb.ObjectFreq(ctx, "maxmemory:freq").Result(); err != nil || freq < 5 {
+		t.Fatalf("expected an access counter under allkeys-lfu but got %d (%v)", freq, err)
+	}
+	if err := rdb.ObjectIdleTime(ctx, "maxmemory:freq").Err(); err == nil || !strings.HasPrefix(err.Error(), "ERR An LFU maxmemory policy is selected") {
+		t.Fatalf("expected OBJECT IDLETIME to fail under allkeys-lfu but got %v", err)
+	}
+}
+
+func TestLazyFree(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+	t.Cleanup(func() {
+		rdb.ConfigSet(ctx, "lazyfree-lazy-server-del", "no")
+	})
+
+	if v := rdb.ConfigGet(ctx, "lazyfree-*").Val(); len(v) != 3 || v["lazyfree-lazy-expire"] != "no" {
+		t.Fatalf("unexpected lazyfree configuration %v", v)
+	}
+	if err := rdb.ConfigSet(ctx, "lazyfree-lazy-server-del", "maybe").Err(); err == nil || !strings.Contains(err.Error(), "argument must be 'yes' or 'no'") {
+		t.Fatalf("expected an invalid boolean error but got %v", err)
+	}
+	if err := rdb.ConfigSet(ctx, "lazyfree-lazy-server-del", "yes").Err(); err != nil || rdb.ConfigGet(ctx, "lazyfree-lazy-server-del").Val()["lazyfree-lazy-server-del"] != "yes" {
+		t.Fatalf("expected lazyfree-lazy-server-del to be set but got %v", err)
+	}
+
+	members := make([]interface{}, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		members = append(members, "member:"+strconv.Itoa(i))
+	}
+	rdb.SAdd(ctx, "lazyfree:big", members...)
+	if n := rdb.Unlink(ctx, "lazyfree:big").Val(); n != 1 || rdb.Exists(ctx, "lazyfree:big").Val() != 0 {
+		t.Fatal("expected UNLINK to delete the key at once")
+	}
+	if !regexp.MustCompile(`lazyfree_pending_objects:\d+`).MatchString(rdb.Info(ctx, "memory").Val()) {
+		t.Fatal("expected INFO memory to report the pending lazy frees")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !regexp.MustCompile(`lazyfreed_objects:[1-9]`).MatchString(rdb.Info(ctx, "stats").Val()) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the unlinked value to be released in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rdb.Set(ctx, "lazyfree:key", "value", 0)
+	if err := rdb.FlushAllAsync(ctx).Err(); err != nil || rdb.DBSize(ctx).Val() != 0 {
+		t.Fatalf("expected FLUSHALL ASYNC to empty the databases but got %v", err)
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.Set(ctx, "memory:string", strings.Repeat("x", 1000), 0)
+	if n := rdb.MemoryUsage(ctx, "memory:string").Val(); n < 1000 {
+		t.Fatalf("expected a 1000 bytes string to use at least as much but got %d", n)
+	}
+	if err := rdb.MemoryUsage(ctx, "memory:missing").Err(); err != redis.Nil {
+		t.Fatalf("expected a missing key to be nil but got %v", err)
+	}
+
+	// Only the first element is small, looking at all of them sees the others.
+	rdb.RPush(ctx, "memory:list", "a")
+	for i := 0; i < 20; i++ {
+		rdb.RPush(ctx, "memory:list", strings.Repeat("x", 1000))
+	}
+	sampled, all := rdb.MemoryUsage(ctx, "memory:list", 1).Val(), rdb.MemoryUsage(ctx, "memory:list", 0).Val()
+	if sampled >= all || all < 20000 {
+		t.Fatalf("expected SAMPLES 0 to estimate more than SAMPLES 1 but got %d and %d", all, sampled)
+	}
+
+	errorTests := []struct {
+		args     []interface{}
+		expected string
+	}{
+		{[]interface{}{"MEMORY", "USAGE", "memory:list", "SAMPLES"}, "ERR syntax error"},
+		{[]interface{}{"MEMORY", "USAGE", "memory:list", "SAMPLES", "-1"}, "ERR syntax error"},
+		{[]interface{}{"MEMORY", "USAGE", "memory:list", "SAMPLES", "x"}, "ERR value is not an integer or out of range"},
+		{[]interface{}{"MEMORY", "USAGE"}, "ERR wrong number of arguments for 'memory|usage' command"},
+		{[]interface{}{"MEMORY", "NOPE"}, "ERR unknown subcommand 'NOPE'. Try MEMORY HELP."},
+	}
+	for _, tt := range errorTests {
+		if err := rdb.Do(ctx, tt.args...).Err(); err == nil || err.Error() != tt.expected {
+			t.Errorf("expected %v to fail with %q but got %v", tt.args, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+)
+
+func selectCommandHandler(s *Server, v proto.SelectCommand, msg peer.Message) error {
+	if v.DB < 0 || v.DB >= int64(len(s.DBs)) {
+		return msg.Peer.Writer().WriteError(errDBIndex)
+	}
+	msg.Peer.DB = int(v.DB)
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+// swapdbCommandHandler swaps the contents of two databases, the peers that
+// selected one of them now see the other one's keys. Like redis, the clients
+// blocked on keys of the two databases are served if they now can be.
+func swapdbCommandHandler(s *Server, v proto.SwapdbCommand, msg peer.Message) error {
+	n := int64(len(s.DBs))
+	if v.DB1 < 0 || v.DB1 >= n || v.DB2 < 0 || v.DB2 >= n {
+		return msg.Peer.Writer().WriteError(errDBIndex)
+	}
+	if err := msg.Peer.Writer().WriteSimpleString("OK"); err != nil {
+		return err
+	}
+
+	db1, db2 := int(v.DB1), int(v.DB2)
+	s.DBs[db1], s.DBs[db2] = s.DBs[db2], s.DBs[db1]
+	var waited []dbKey
+	for k := range s.blockingKeys {
+		if k.db == db1 || k.db == db2 {
+			waited = append(waited, k)
+		}
+	}
+	for _, k := range waited {
+		s.serveKey(k)
+	}
+
+	return nil
+}
+
+// flushCommandHandler empties the selected database, or all of them with
+// FLUSHALL. With ASYNC the old keys are released in the background.
+func flushCommandHandler(s *Server, v proto.FlushCommand, msg peer.Message) error {
+	if v.All {
+		for _, kv := range s.DBs {
+			kv.Flush(v.Async)
+		}
+	} else {
+		s.db(msg.Peer).Flush(v.Async)
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+// infoSections are the sections INFO knows, in the order redis lists them.
+var infoSections = []string{"memory", "stats", "keyspace"}
+
+// infoCommandHandler replies with the sections asked for, all the known ones
+// by default, as a verbatim string to the peers that negotiated protocol 3.
+func infoCommandHandler(s *Server, v proto.InfoCommand, msg peer.Message) error {
+	all := len(v.Sections) == 0 || slices.ContainsFunc(v.Sections, func(section string) bool {
+		return section == "all" || section == "default" || section == "everything"
+	})
+	info := ""
+	for _, section := range infoSections {
+		if all || slices.Contains(v.Sections, section) {
+			if info != "" {
+				info += "\r\n"
+			}
+			info += s.infoSection(section)
+		}
+	}
+
+	return msg.Peer.Writer().WriteVerbatim("txt", info)
+}
+
+func (s *Server) infoSection(section string) string {
+	switch section {
+	case "memory":
+		used := s.evictor.Used(s.DBs)
+		return fmt.Sprintf("# Memory\r\nused_memory:%d\r\nused_memory_human:%s\r\nmaxmemory:%d\r\nmaxmemory_human:%s\r\nmaxmemory_policy:%s\r\nlazyfree_pending_objects:%d\r\n",
+			used, bytesToHuman(used), s.evictor.Limit, bytesToHuman(s.evictor.Limit), evictionPolicyName(s.evictor.Policy), keyval.LazyFreePending())
+	case "stats":
+		return fmt.Sprintf("# Stats\r\nevicted_keys:%d\r\nlazyfreed_objects:%d\r\n", s.evictor.Evicted, keyval.LazyFreed())
+	default:
+		// A line per non empty database with its number of keys, of volatile
+		// keys and their average time to live in milliseconds.
+		info := "# Keyspace\r\n"
+		for i, kv := range s.DBs {
+			if stats := kv.Stats(); stats.Keys > 0 {
+				info += fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=%d\r\n", i, stats.Keys, stats.Expires, stats.AvgTTL)
+			}
+		}
+		return info
+	}
+}
+
+// bytesToHuman formats a number of bytes like redis does, 1.50M.
+func bytesToHuman(n int64) string {
+	units := []string{"B", "K", "M", "G", "T"}
+	f, i := float64(n), 0
+	for ; f >= 1024 && i < len(units)-1; i++ {
+		f /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	return fmt.Sprintf("%.2f%s", f, units[i])
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSelectDatabases(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	db5 := redis.NewClient(&redis.Options{Addr: rdb.Options().Addr, DB: 5})
+	defer db5.Close()
+	if err := db5.Set(ctx, "db:key", "five", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rdb.Exists(ctx, "db:key").Val(); n != 0 {
+		t.Fatal("expected db:key to only be set in database 5")
+	}
+	if v := db5.Get(ctx, "db:key").Val(); v != "five" {
+		t.Fatalf("expected five but got %q", v)
+	}
+	if err := rdb.Do(ctx, "SELECT", 16).Err(); err == nil || err.Error() != "ERR DB index is out of range" {
+		t.Fatalf("expected a DB index error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "SWAPDB", "a", 1).Err(); err == nil || err.Error() != "ERR invalid first DB index" {
+		t.Fatalf("expected an invalid index error but got %v", err)
+	}
+
+	// MOVE and COPY go from the selected database to another one.
+	if ok := db5.Move(ctx, "db:key", 6).Val(); !ok {
+		t.Fatal("expected db:key to be moved to database 6")
+	}
+	if n := db5.Exists(ctx, "db:key").Val(); n != 0 {
+		t.Fatal("expected db:key to be gone from database 5")
+	}
+	db6 := redis.NewClient(&redis.Options{Addr: rdb.Options().Addr, DB: 6})
+	defer db6.Close()
+	if n := db6.Copy(ctx, "db:key", "db:key", 5, false).Val(); n != 1 {
+		t.Fatal("expected db:key to be copied back to database 5")
+	}
+	db6.Set(ctx, "db:key", "six", 0)
+	if v := db5.Get(ctx, "db:key").Val(); v != "five" {
+		t.Fatalf("expected the copy to be independent but got %q", v)
+	}
+	if ok := db5.Move(ctx, "db:key", 6).Val(); ok {
+		t.Fatal("expected MOVE not to overwrite db:key in database 6")
+	}
+
+	if err := db5.Do(ctx, "SWAPDB", 5, 6).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v := db5.Get(ctx, "db:key").Val(); v != "six" {
+		t.Fatalf("expected the swapped value six but got %q", v)
+	}
+
+	db5.Expire(ctx, "db:key", time.Hour)
+	info := db5.Info(ctx, "keyspace").Val()
+	if !strings.Contains(info, "db5:keys=1,expires=1,avg_ttl=") || !strings.Contains(info, "db6:keys=1,expires=0,avg_ttl=0") {
+		t.Fatalf("unexpected keyspace info %q", info)
+	}
+
+	if err := db5.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := db5.DBSize(ctx).Val(); n != 0 {
+		t.Fatalf("expected database 5 to be empty but got %d keys", n)
+	}
+	if n := db6.DBSize(ctx).Val(); n != 1 {
+		t.Fatalf("expected FLUSHDB to leave database 6 alone but got %d keys", n)
+	}
+	if err := db6.Do(ctx, "FLUSHDB", "NOW").Err(); err == nil || err.Error() != "ERR syntax error" {
+		t.Fatalf("expected a syntax error but got %v", err)
+	}
+}
+
+func TestSwapdbServesBlockedClients(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	waiter := redis.NewClient(&redis.Options{Addr: rdb.Options().Addr, DB: 7})
+	defer waiter.Close()
+	db8 := redis.NewClient(&redis.Options{Addr: rdb.Options().Addr, DB: 8})
+	defer db8.Close()
+
+	done := make(chan []string)
+	go func() {
+		done <- waiter.BLPop(ctx, time.Second, "db:queue").Val()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	db8.RPush(ctx, "db:queue", "job")
+	if err := db8.Do(ctx, "SWAPDB", 7, 8).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if values := <-done; len(values) != 2 || values[1] != "job" {
+		t.Fatalf("expected the waiter to get job but got %v", values)
+	}
+}
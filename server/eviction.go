@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+
+	"redis-clone/keyval"
+)
+
+// evictionPolicies are the values of maxmemory-policy.
+var evictionPolicies = map[string]keyval.EvictionPolicy{
+	"noeviction":      keyval.NoEviction,
+	"allkeys-lru":     keyval.AllKeysLRU,
+	"allkeys-lfu":     keyval.AllKeysLFU,
+	"allkeys-random":  keyval.AllKeysRandom,
+	"volatile-lru":    keyval.VolatileLRU,
+	"volatile-lfu":    keyval.VolatileLFU,
+	"volatile-random": keyval.VolatileRandom,
+	"volatile-ttl":    keyval.VolatileTTL,
+}
+
+var (
+	errOOM = errors.New("OOM command not allowed when used memory > 'maxmemory'.")
+	// errNoLFU and errLFU are the OBJECT FREQ and OBJECT IDLETIME errors when
+	// the policy does not track what they report.
+	errNoLFU = errors.New("ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+	errLFU   = errors.New("ERR An LFU maxmemory policy is selected, idle time not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+)
+
+// evictionPolicyName returns the maxmemory-policy value of the policy.
+func evictionPolicyName(policy keyval.EvictionPolicy) string {
+	for name, p := range evictionPolicies {
+		if p == policy {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// performEvictions evicts keys, like redis does before running each command,
+// until the memory is under maxmemory. When it can't, the commands flagged
+// denyoom, the ones that may grow the memory, are refused with an OOM error.
+func (s *Server) performEvictions(spec *commandSpec) error {
+	if !s.evictor.Evict(s.DBs) && spec.flags&flagDenyOOM != 0 {
+		return errOOM
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+)
+
+// activeExpireInterval is how often the server loop runs the expiry sweeper,
+// that's redis default hz of 10.
+const activeExpireInterval = 100 * time.Millisecond
+
+func expireCommandHandler(s *Server, v proto.ExpireCommand, msg peer.Message) error {
+	at, ok := absoluteExpireTime(v.Value, v.Millis, v.At)
+	if !ok {
+		return msg.Peer.Writer().
+			WriteError(fmt.Errorf("ERR invalid expire time in '%s' command", v.Name))
+	}
+
+	var flags keyval.ExpireFlags
+	if v.NX {
+		flags |= keyval.ExpireNX
+	}
+	if v.XX {
+		flags |= keyval.ExpireXX
+	}
+	if v.GT {
+		flags |= keyval.ExpireGT
+	}
+	if v.LT {
+		flags |= keyval.ExpireLT
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(s.db(msg.Peer).Expire(v.Key, at, flags)))
+}
+
+// absoluteExpireTime turns the argument of any EXPIRE variant (and of SET's
+// expiry options) into a unix time in milliseconds, it reports false when the
+// computation overflows.
+func absoluteExpireTime(value int64, millis, absolute bool) (int64, bool) {
+	at := value
+	if !millis {
+		if at > math.MaxInt64/1000 || at < math.MinInt64/1000 {
+			return 0, false
+		}
+		at *= 1000
+	}
+	if !absolute {
+		now := keyval.Now()
+		if at > math.MaxInt64-now {
+			return 0, false
+		}
+		at += now
+	}
+
+	return at, true
+}
+
+func ttlCommandHandler(s *Server, v proto.TtlCommand, msg peer.Message) error {
+	ttl := s.db(msg.Peer).TTL(v.Key)
+	if ttl >= 0 && !v.Millis {
+		ttl = (ttl + 500) / 1000
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(ttl))
+}
+
+func expireTimeCommandHandler(s *Server, v proto.ExpireTimeCommand, msg peer.Message) error {
+	at := s.db(msg.Peer).ExpireTime(v.Key)
+	if at >= 0 && !v.Millis {
+		at /= 1000
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(at))
+}
+
+func persistCommandHandler(s *Server, v proto.PersistCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteValue(integerBool(s.db(msg.Peer).Persist(v.Key)))
+}
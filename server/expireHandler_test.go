@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if err := rdb.Set(ctx, "expire:key", "value", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.TTL(ctx, "expire:key").Val(); ttl != -1 {
+		t.Fatalf("expected no ttl but got %v", ttl)
+	}
+	if ttl := rdb.TTL(ctx, "expire:missing").Val(); ttl != -2 {
+		t.Fatalf("expected a missing key but got %v", ttl)
+	}
+
+	if !rdb.ExpireNX(ctx, "expire:key", time.Minute).Val() {
+		t.Fatal("expected EXPIRE NX to set the ttl")
+	}
+	if rdb.ExpireNX(ctx, "expire:key", time.Hour).Val() {
+		t.Fatal("expected EXPIRE NX to fail on a volatile key")
+	}
+	if rdb.ExpireLT(ctx, "expire:key", time.Hour).Val() {
+		t.Fatal("expected EXPIRE LT to fail with a greater ttl")
+	}
+	if !rdb.ExpireGT(ctx, "expire:key", time.Hour).Val() {
+		t.Fatal("expected EXPIRE GT to succeed with a greater ttl")
+	}
+	if ttl := rdb.TTL(ctx, "expire:key").Val(); ttl != time.Hour {
+		t.Fatalf("expected a ttl of one hour but got %v", ttl)
+	}
+	if at := rdb.ExpireTime(ctx, "expire:key").Val(); at <= time.Duration(time.Now().Unix())*time.Second {
+		t.Fatalf("expected an expire time in the future but got %v", at)
+	}
+
+	if !rdb.Persist(ctx, "expire:key").Val() {
+		t.Fatal("expected PERSIST to remove the ttl")
+	}
+	if ttl := rdb.PTTL(ctx, "expire:key").Val(); ttl != -1 {
+		t.Fatalf("expected no ttl after PERSIST but got %v", ttl)
+	}
+
+	if !rdb.PExpire(ctx, "expire:key", 50*time.Millisecond).Val() {
+		t.Fatal("expected PEXPIRE to set the ttl")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := rdb.Exists(ctx, "expire:key").Val(); n != 0 {
+		t.Fatal("expected the key to be expired")
+	}
+
+	if err := rdb.Set(ctx, "expire:past", "value", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !rdb.ExpireAt(ctx, "expire:past", time.Now().Add(-time.Hour)).Val() {
+		t.Fatal("expected EXPIREAT in the past to succeed")
+	}
+	if n := rdb.Exists(ctx, "expire:past").Val(); n != 0 {
+		t.Fatal("expected EXPIREAT in the past to delete the key")
+	}
+}
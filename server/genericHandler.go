@@ -0,0 +1,216 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+// keyTypes are the types SCAN can filter the keys on.
+var keyTypes = map[string]keyval.Type{
+	"string": keyval.TypeString,
+	"list":   keyval.TypeList,
+	"hash":   keyval.TypeHash,
+	"set":    keyval.TypeSet,
+	"zset":   keyval.TypeZSet,
+	"stream": keyval.TypeStream,
+}
+
+func typeCommandHandler(s *Server, v proto.TypeCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteSimpleString(s.db(msg.Peer).Type(v.Key).String())
+}
+
+// objectHelp is what OBJECT HELP replies with.
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// errDBIndex is returned for the indexes past the number of databases.
+var errDBIndex = errors.New("ERR DB index is out of range")
+
+func existCommandHandler(s *Server, v proto.ExistCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteInteger(s.db(msg.Peer).Exists(v.Keys))
+}
+
+// delCommandHandler replies with the number of keys DEL or UNLINK deleted,
+// UNLINK releasing the large values in the background.
+func delCommandHandler(s *Server, v proto.DelCommand, msg peer.Message) error {
+	if v.Unlink {
+		return msg.Peer.Writer().WriteInteger(s.db(msg.Peer).Unlink(v.Keys))
+	}
+
+	return msg.Peer.Writer().WriteInteger(s.db(msg.Peer).Del(v.Keys))
+}
+
+func touchCommandHandler(s *Server, v proto.TouchCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteInteger(s.db(msg.Peer).Touch(v.Keys))
+}
+
+// renameCommandHandler replies OK to RENAME and whether the key was renamed
+// to RENAMENX.
+func renameCommandHandler(s *Server, v proto.RenameCommand, msg peer.Message) error {
+	renamed, err := s.db(msg.Peer).Rename(v.Source, v.Destination, v.NX)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if v.NX {
+		return msg.Peer.Writer().WriteValue(integerBool(renamed))
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+func copyCommandHandler(s *Server, v proto.CopyCommand, msg peer.Message) error {
+	to := s.db(msg.Peer)
+	if v.DB >= 0 {
+		if v.DB >= len(s.DBs) {
+			return msg.Peer.Writer().WriteError(errDBIndex)
+		}
+		to = s.DBs[v.DB]
+	}
+	copied, err := s.db(msg.Peer).Copy(v.Source, to, v.Destination, v.Replace)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(copied))
+}
+
+func moveCommandHandler(s *Server, v proto.MoveCommand, msg peer.Message) error {
+	if v.DB >= len(s.DBs) {
+		return msg.Peer.Writer().WriteError(errDBIndex)
+	}
+	moved, err := s.db(msg.Peer).Move(v.Key, s.DBs[v.DB])
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(moved))
+}
+
+func objectCommandHandler(s *Server, v proto.ObjectCommand, msg peer.Message) error {
+	w := msg.Peer.Writer()
+	switch v.Subcommand {
+	case "HELP":
+		values := make([]serdes.Value, 0, len(objectHelp))
+		for _, line := range objectHelp {
+			values = append(values, serdes.SimpleStringValue(line))
+		}
+		return w.WriteArray(values)
+	case "IDLETIME":
+		idle, ok := s.db(msg.Peer).IdleTime(v.Key)
+		if !ok {
+			return w.WriteNull()
+		}
+		if s.evictor.Policy.LFU() {
+			return w.WriteError(errLFU)
+		}
+		return w.WriteInteger(int(idle))
+	case "REFCOUNT":
+		refs, ok := s.db(msg.Peer).RefCount(v.Key)
+		if !ok {
+			return w.WriteNull()
+		}
+		return w.WriteInteger(int(refs))
+	case "FREQ":
+		freq, ok := s.db(msg.Peer).Freq(v.Key)
+		if !ok {
+			return w.WriteNull()
+		}
+		if !s.evictor.Policy.LFU() {
+			return w.WriteError(errNoLFU)
+		}
+		return w.WriteInteger(int(freq))
+	}
+
+	encoding, ok := s.db(msg.Peer).Encoding(v.Key)
+	if !ok {
+		return w.WriteNull()
+	}
+
+	return w.WriteString(encoding)
+}
+
+func scanCommandHandler(s *Server, v proto.ScanCommand, msg peer.Message) error {
+	typ := keyval.TypeNone
+	if v.Type != "" {
+		var ok bool
+		if typ, ok = keyTypes[strings.ToLower(v.Type)]; !ok {
+			return msg.Peer.Writer().WriteError(fmt.Errorf("ERR unknown type name '%s'", v.Type))
+		}
+	}
+	cursor, keys := s.db(msg.Peer).Scan(v.Cursor, v.Match, int(v.Count), typ)
+
+	return writeScanReply(msg.Peer, cursor, keys)
+}
+
+func keysCommandHandler(s *Server, v proto.KeysCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteArray(stringValues(s.db(msg.Peer).Keys(v.Pattern)))
+}
+
+func randomkeyCommandHandler(s *Server, v proto.RandomkeyCommand, msg peer.Message) error {
+	key, ok := s.db(msg.Peer).RandomKey()
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteString(key)
+}
+
+func dbsizeCommandHandler(s *Server, v proto.DbsizeCommand, msg peer.Message) error {
+	return msg.Peer.Writer().WriteInteger(s.db(msg.Peer).DBSize())
+}
+
+// sortCommandHandler replies with the sorted elements, or the values of the
+// GET patterns, or with the length of the list stored with STORE.
+func sortCommandHandler(s *Server, v proto.SortCommand, msg peer.Message) error {
+	opts := keyval.SortOptions{
+		By:     v.By,
+		Get:    v.Get,
+		Offset: int(v.Offset),
+		Count:  int(v.Count),
+		Desc:   v.Desc,
+		Alpha:  v.Alpha,
+	}
+	if v.Store != "" {
+		n, err := s.db(msg.Peer).SortStore(v.Key, v.Store, opts)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		return msg.Peer.Writer().WriteInteger(n)
+	}
+
+	values, err := s.db(msg.Peer).Sort(v.Key, opts)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	ret := make([]serdes.Value, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			ret = append(ret, serdes.NullValue())
+		} else {
+			ret = append(ret, serdes.BytesValue(value))
+		}
+	}
+
+	return msg.Peer.Writer().WriteArray(ret)
+}
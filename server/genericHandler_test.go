@@ -0,0 +1,314 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestTypedKeyspace(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if err := rdb.Set(ctx, "typed:string", "12", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.LPush(ctx, "typed:list", "a").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := rdb.Type(ctx, "typed:string").Val(); typ != "string" {
+		t.Fatalf("expected string but got %s", typ)
+	}
+	if typ := rdb.Type(ctx, "typed:list").Val(); typ != "list" {
+		t.Fatalf("expected list but got %s", typ)
+	}
+	if typ := rdb.Type(ctx, "typed:missing").Val(); typ != "none" {
+		t.Fatalf("expected none but got %s", typ)
+	}
+
+	if enc := rdb.ObjectEncoding(ctx, "typed:string").Val(); enc != "int" {
+		t.Fatalf("expected int encoding but got %s", enc)
+	}
+	if err := rdb.Set(ctx, "typed:string", "not a number", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if enc := rdb.ObjectEncoding(ctx, "typed:string").Val(); enc != "embstr" {
+		t.Fatalf("expected embstr encoding but got %s", enc)
+	}
+
+	wrongType := []error{
+		rdb.Get(ctx, "typed:list").Err(),
+		rdb.Incr(ctx, "typed:list").Err(),
+		rdb.LPush(ctx, "typed:string", "a").Err(),
+	}
+	for _, err := range wrongType {
+		if err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE ") {
+			t.Fatalf("expected a WRONGTYPE error but got %v", err)
+		}
+	}
+
+	if err := rdb.Set(ctx, "typed:list", "now a string", 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if typ := rdb.Type(ctx, "typed:list").Val(); typ != "string" {
+		t.Fatalf("expected SET to overwrite the list but got %s", typ)
+	}
+}
+
+func TestKeyspaceScan(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	size := rdb.DBSize(ctx).Val()
+	for i := 0; i < 300; i++ {
+		rdb.Set(ctx, "scan:key:"+strconv.Itoa(i), i, 0)
+	}
+	if n := rdb.DBSize(ctx).Val(); n != size+300 {
+		t.Fatalf("expected %d keys but got %d", size+300, n)
+	}
+
+	// Every key present for the whole iteration is returned, even though the
+	// keyspace grows while it runs.
+	seen := map[string]bool{}
+	var cursor uint64
+	for i := 0; ; i++ {
+		keys, next, err := rdb.Scan(ctx, cursor, "scan:key:*", 20).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			seen[key] = true
+		}
+		rdb.Set(ctx, "scan:new:"+strconv.Itoa(i), i, 0)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	for i := 0; i < 300; i++ {
+		if !seen["scan:key:"+strconv.Itoa(i)] {
+			t.Fatalf("scan:key:%d was not returned", i)
+		}
+	}
+
+	rdb.LPush(ctx, "scan:list", "a")
+	var lists []string
+	for cursor = 0; ; {
+		keys, next, err := rdb.ScanType(ctx, cursor, "scan:*", 100, "list").Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lists = append(lists, keys...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	if len(lists) != 1 || lists[0] != "scan:list" {
+		t.Fatalf("expected only scan:list but got %v", lists)
+	}
+	if err := rdb.Do(ctx, "SCAN", 0, "TYPE", "nope").Err(); err == nil || err.Error() != "ERR unknown type name 'nope'" {
+		t.Fatalf("expected an unknown type error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "SCAN", "abc").Err(); err == nil || err.Error() != "ERR invalid cursor" {
+		t.Fatalf("expected an invalid cursor error but got %v", err)
+	}
+
+	keys := rdb.Keys(ctx, "scan:key:2?").Val()
+	sort.Strings(keys)
+	expected := []string{"scan:key:20", "scan:key:21", "scan:key:22", "scan:key:23", "scan:key:24",
+		"scan:key:25", "scan:key:26", "scan:key:27", "scan:key:28", "scan:key:29"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected %v but got %v", expected, keys)
+	}
+
+	rdb.Set(ctx, "scan:expired", "x", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if keys := rdb.Keys(ctx, "scan:expired").Val(); len(keys) != 0 {
+		t.Fatalf("expected expired keys to be skipped but got %v", keys)
+	}
+
+	if key := rdb.RandomKey(ctx).Val(); rdb.Exists(ctx, key).Val() != 1 {
+		t.Fatalf("RANDOMKEY returned %q which does not exist", key)
+	}
+}
+
+func TestGenericKeyCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.Set(ctx, "generic:a", "1", 0)
+	rdb.Set(ctx, "generic:b", "2", 0)
+	if n := rdb.Exists(ctx, "generic:a", "generic:a", "generic:b", "generic:missing").Val(); n != 3 {
+		t.Fatalf("expected EXISTS to count 3 keys but got %d", n)
+	}
+	if n := rdb.Touch(ctx, "generic:a", "generic:missing").Val(); n != 1 {
+		t.Fatalf("expected TOUCH to count 1 key but got %d", n)
+	}
+	if n := rdb.Del(ctx, "generic:a", "generic:b", "generic:missing").Val(); n != 2 {
+		t.Fatalf("expected DEL to delete 2 keys but got %d", n)
+	}
+	rdb.Set(ctx, "generic:a", "1", 0)
+	if n := rdb.Unlink(ctx, "generic:a", "generic:a").Val(); n != 1 {
+		t.Fatalf("expected UNLINK to delete 1 key but got %d", n)
+	}
+
+	rdb.RPush(ctx, "generic:list", "a", "b")
+	rdb.Expire(ctx, "generic:list", time.Hour)
+	if err := rdb.Rename(ctx, "generic:list", "generic:renamed").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if v := rdb.LRange(ctx, "generic:renamed", 0, -1).Val(); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Fatalf("expected the renamed list but got %v", v)
+	}
+	if ttl := rdb.TTL(ctx, "generic:renamed").Val(); ttl <= 0 {
+		t.Fatalf("expected RENAME to keep the time to live but got %v", ttl)
+	}
+	if err := rdb.Rename(ctx, "generic:list", "generic:x").Err(); err == nil || err.Error() != "ERR no such key" {
+		t.Fatalf("expected a no such key error but got %v", err)
+	}
+	rdb.Set(ctx, "generic:s", "v", 0)
+	if ok := rdb.RenameNX(ctx, "generic:s", "generic:renamed").Val(); ok {
+		t.Fatal("expected RENAMENX not to overwrite an existing key")
+	}
+	if ok := rdb.RenameNX(ctx, "generic:s", "generic:s2").Val(); !ok {
+		t.Fatal("expected RENAMENX to rename")
+	}
+
+	// COPY makes deep copies, changing the copy leaves the source alone.
+	rdb.HSet(ctx, "generic:hash", "f", "v")
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "generic:stream", ID: "1-1", Values: []string{"f", "v"}})
+	rdb.XGroupCreate(ctx, "generic:stream", "g", "0")
+	rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "c", Streams: []string{"generic:stream", ">"}})
+	for _, key := range []string{"generic:renamed", "generic:hash", "generic:stream"} {
+		if n := rdb.Copy(ctx, key, key+":copy", 0, false).Val(); n != 1 {
+			t.Fatalf("expected %s to be copied", key)
+		}
+	}
+	rdb.RPush(ctx, "generic:renamed:copy", "c")
+	rdb.HSet(ctx, "generic:hash:copy", "f", "changed")
+	rdb.XAck(ctx, "generic:stream:copy", "g", "1-1")
+	if n := rdb.LLen(ctx, "generic:renamed").Val(); n != 2 {
+		t.Fatalf("expected the source list to keep 2 elements but got %d", n)
+	}
+	if v := rdb.HGet(ctx, "generic:hash", "f").Val(); v != "v" {
+		t.Fatalf("expected the source hash to be unchanged but got %s", v)
+	}
+	if n := rdb.XPending(ctx, "generic:stream", "g").Val().Count; n != 1 {
+		t.Fatalf("expected the source PEL to keep 1 entry but got %d", n)
+	}
+	if n := rdb.XPending(ctx, "generic:stream:copy", "g").Val().Count; n != 0 {
+		t.Fatalf("expected the copied PEL to be empty but got %d", n)
+	}
+	if n := rdb.Copy(ctx, "generic:hash", "generic:renamed", 0, false).Val(); n != 0 {
+		t.Fatal("expected COPY not to overwrite without REPLACE")
+	}
+	if n := rdb.Copy(ctx, "generic:hash", "generic:renamed", 0, true).Val(); n != 1 || rdb.Type(ctx, "generic:renamed").Val() != "hash" {
+		t.Fatal("expected COPY REPLACE to overwrite")
+	}
+	if err := rdb.Copy(ctx, "generic:hash", "generic:hash", 0, false).Err(); err == nil || err.Error() != "ERR source and destination objects are the same" {
+		t.Fatalf("expected a same object error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "COPY", "generic:hash", "generic:x", "DB", "-1").Err(); err == nil || err.Error() != "ERR DB index is out of range" {
+		t.Fatalf("expected a DB index error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "COPY", "generic:hash", "generic:x", "NOPE").Err(); err == nil || err.Error() != "ERR syntax error" {
+		t.Fatalf("expected a syntax error but got %v", err)
+	}
+	if err := rdb.Move(ctx, "generic:hash", 0).Err(); err == nil || err.Error() != "ERR source and destination objects are the same" {
+		t.Fatalf("expected a same object error but got %v", err)
+	}
+}
+
+func TestObjectCommand(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.Set(ctx, "object:int", "42", 0)
+	rdb.Set(ctx, "object:big", "123456", 0)
+	rdb.SAdd(ctx, "object:set", "a")
+	if n := rdb.ObjectRefCount(ctx, "object:int").Val(); n != 2147483647 {
+		t.Fatalf("expected a shared integer but got refcount %d", n)
+	}
+	for _, key := range []string{"object:big", "object:set"} {
+		if n := rdb.ObjectRefCount(ctx, key).Val(); n != 1 {
+			t.Fatalf("expected %s to have refcount 1 but got %d", key, n)
+		}
+	}
+	if idle := rdb.ObjectIdleTime(ctx, "object:set").Val(); idle != 0 {
+		t.Fatalf("expected a fresh key to be idle for 0s but got %v", idle)
+	}
+	if err := rdb.ObjectIdleTime(ctx, "object:missing").Err(); err != redis.Nil {
+		t.Fatalf("expected a null reply for a missing key but got %v", err)
+	}
+	if err := rdb.ObjectFreq(ctx, "object:set").Err(); err == nil || !strings.HasPrefix(err.Error(), "ERR An LFU maxmemory policy is not selected") {
+		t.Fatalf("expected a no LFU error but got %v", err)
+	}
+	if help := rdb.Do(ctx, "OBJECT", "HELP").Val(); len(help.([]interface{})) == 0 {
+		t.Fatal("expected OBJECT HELP to reply with the subcommands")
+	}
+	if err := rdb.Do(ctx, "OBJECT", "IDLETIME").Err(); err == nil || err.Error() != "ERR wrong number of arguments for 'object|idletime' command" {
+		t.Fatalf("expected an arity error but got %v", err)
+	}
+}
+
+func TestSort(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.RPush(ctx, "sort:ids", "3", "1", "10", "2")
+	if v := rdb.Sort(ctx, "sort:ids", &redis.Sort{}).Val(); !reflect.DeepEqual(v, []string{"1", "2", "3", "10"}) {
+		t.Fatalf("expected a numeric sort but got %v", v)
+	}
+	if v := rdb.Sort(ctx, "sort:ids", &redis.Sort{Alpha: true, Order: "DESC", Offset: 1, Count: 2}).Val(); !reflect.DeepEqual(v, []string{"2", "10"}) {
+		t.Fatalf("expected a limited alpha sort but got %v", v)
+	}
+
+	// The external weights, and the names the listing renders.
+	for id, weight := range map[string]string{"1": "30", "2": "10", "3": "20"} {
+		rdb.Set(ctx, "sort:weight_"+id, weight, 0)
+		rdb.HSet(ctx, "sort:user_"+id, "name", "user"+id)
+	}
+	v := rdb.Sort(ctx, "sort:ids", &redis.Sort{By: "sort:weight_*", Get: []string{"#", "sort:user_*->name"}}).Val()
+	// 10 has no weight and weighs 0, nor a name.
+	if want := []string{"10", "", "2", "user2", "3", "user3", "1", "user1"}; !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected the ids sorted by weight with their names %v but got %v", want, v)
+	}
+	if v := rdb.Do(ctx, "SORT", "sort:ids", "BY", "sort:user_*->name", "ALPHA", "GET", "sort:user_*->missing").Val(); !reflect.DeepEqual(v, []interface{}{nil, nil, nil, nil}) {
+		t.Fatalf("expected missing hash fields to be nil but got %v", v)
+	}
+	if v := rdb.Sort(ctx, "sort:ids", &redis.Sort{By: "nosort"}).Val(); !reflect.DeepEqual(v, []string{"3", "1", "10", "2"}) {
+		t.Fatalf("expected nosort to keep the list order but got %v", v)
+	}
+
+	rdb.ZAdd(ctx, "sort:zset", redis.Z{Score: 1, Member: "b"}, redis.Z{Score: 2, Member: "a"})
+	if v := rdb.Sort(ctx, "sort:zset", &redis.Sort{By: "nosort", Order: "DESC"}).Val(); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Fatalf("expected nosort DESC to reverse the sorted set but got %v", v)
+	}
+	rdb.SAdd(ctx, "sort:set", "b", "c", "a")
+	if n := rdb.SortStore(ctx, "sort:set", "sort:stored", &redis.Sort{By: "nosort"}).Val(); n != 3 {
+		t.Fatalf("expected 3 stored elements but got %d", n)
+	}
+	if v := rdb.LRange(ctx, "sort:stored", 0, -1).Val(); !reflect.DeepEqual(v, []string{"a", "b", "c"}) {
+		t.Fatalf("expected a stored set to be sorted anyway but got %v", v)
+	}
+
+	if err := rdb.Sort(ctx, "sort:set", &redis.Sort{}).Err(); err == nil || err.Error() != "ERR One or more scores can't be converted into double" {
+		t.Fatalf("expected a score error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "SORT_RO", "sort:ids", "STORE", "sort:stored").Err(); err == nil || err.Error() != "ERR syntax error" {
+		t.Fatalf("expected SORT_RO to refuse STORE but got %v", err)
+	}
+	if v := rdb.SortRO(ctx, "sort:ids", &redis.Sort{Order: "DESC"}).Val(); !reflect.DeepEqual(v, []string{"10", "3", "2", "1"}) {
+		t.Fatalf("expected SORT_RO to sort but got %v", v)
+	}
+	rdb.Set(ctx, "sort:string", "x", 0)
+	if err := rdb.Sort(ctx, "sort:string", &redis.Sort{}).Err(); err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Fatalf("expected a WRONGTYPE error but got %v", err)
+	}
+}
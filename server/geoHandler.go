@@ -0,0 +1,170 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var geoSorts = map[string]keyval.GeoSort{
+	"":     keyval.GeoUnsorted,
+	"ASC":  keyval.GeoAsc,
+	"DESC": keyval.GeoDesc,
+}
+
+func geoaddCommandHandler(s *Server, v proto.GeoaddCommand, msg peer.Message) error {
+	members := make([]keyval.GeoMember, 0, len(v.Points))
+	for _, p := range v.Points {
+		members = append(members, keyval.GeoMember{Member: p.Member, Longitude: p.Longitude, Latitude: p.Latitude})
+	}
+	res, err := s.db(msg.Peer).GeoAdd(v.Key, keyval.ZAddOptions{NX: v.NX, XX: v.XX, CH: v.CH}, members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+// geodistCommandHandler replies with the distance as a bulk string with four
+// decimals, null when one of the members is missing.
+func geodistCommandHandler(s *Server, v proto.GeodistCommand, msg peer.Message) error {
+	scores, found, err := s.db(msg.Peer).ZMScore(v.Key, []string{v.Member1, v.Member2})
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !found[0] || !found[1] {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	long1, lat1 := keyval.GeoDecode(scores[0])
+	long2, lat2 := keyval.GeoDecode(scores[1])
+	dist := keyval.GeoDistance(long1, lat1, long2, lat2) / v.Conversion
+
+	return msg.Peer.Writer().WriteString(formatDistance(dist))
+}
+
+// geoposCommandHandler replies with the longitude and the latitude of each
+// member, a null array for the missing ones.
+func geoposCommandHandler(s *Server, v proto.GeoposCommand, msg peer.Message) error {
+	scores, found, err := s.db(msg.Peer).ZMScore(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	b := serdes.AppendArrayLen(nil, len(v.Members))
+	for i := range v.Members {
+		if !found[i] {
+			b = serdes.AppendNullArray(b, msg.Peer.Protocol)
+			continue
+		}
+		long, lat := keyval.GeoDecode(scores[i])
+		b = appendCoordinates(b, msg.Peer.Protocol, long, lat)
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+func geohashCommandHandler(s *Server, v proto.GeohashCommand, msg peer.Message) error {
+	scores, found, err := s.db(msg.Peer).ZMScore(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	values := make([]serdes.Value, 0, len(v.Members))
+	for i := range v.Members {
+		if !found[i] {
+			values = append(values, serdes.NullValue())
+		} else {
+			values = append(values, serdes.StringValue(keyval.GeoHash(scores[i])))
+		}
+	}
+
+	return msg.Peer.Writer().WriteArray(values)
+}
+
+// geosearchCommandHandler replies with the members found, each one in an
+// array followed by its distance, its geohash and its coordinates when they
+// are asked for. GEOSEARCHSTORE replies with the number of members stored.
+func geosearchCommandHandler(s *Server, v proto.GeosearchCommand, msg peer.Message) error {
+	q := keyval.GeoQuery{
+		FromMember: v.FromMember,
+		Member:     v.Member,
+		Longitude:  v.Longitude,
+		Latitude:   v.Latitude,
+		ByBox:      v.ByBox,
+		Radius:     v.Radius,
+		Width:      v.Width,
+		Height:     v.Height,
+		Conversion: v.Conversion,
+		Sort:       geoSorts[v.Sort],
+		Count:      v.Count,
+		Any:        v.Any,
+	}
+	if v.Store {
+		res, err := s.db(msg.Peer).GeoSearchStore(v.Destination, v.Key, q, v.StoreDist)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		return msg.Peer.Writer().WriteInteger(res)
+	}
+
+	found, err := s.db(msg.Peer).GeoSearch(v.Key, q)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	options := 0
+	for _, with := range []bool{v.WithDist, v.WithHash, v.WithCoord} {
+		if with {
+			options++
+		}
+	}
+	b := serdes.AppendArrayLen(nil, len(found))
+	for _, m := range found {
+		if options > 0 {
+			b = serdes.AppendArrayLen(b, options+1)
+		}
+		b = serdes.AppendBulk(b, m.Member)
+		if v.WithDist {
+			b = serdes.AppendBulk(b, formatDistance(m.Dist))
+		}
+		if v.WithHash {
+			b = serdes.AppendInteger(b, int64(m.Hash))
+		}
+		if v.WithCoord {
+			b = appendCoordinates(b, msg.Peer.Protocol, m.Longitude, m.Latitude)
+		}
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+// formatDistance formats a distance with four decimals like redis does.
+func formatDistance(dist float64) string {
+	return strconv.FormatFloat(dist, 'f', 4, 64)
+}
+
+// appendCoordinates appends the longitude and the latitude, as doubles for
+// the peers that negotiated protocol 3 and as bulk strings for the others.
+func appendCoordinates(b []byte, protocol int, long, lat float64) []byte {
+	b = serdes.AppendArrayLen(b, 2)
+	for _, coord := range []float64{long, lat} {
+		// Like redis, 17 decimals without the trailing zeros.
+		s := strings.TrimRight(strconv.FormatFloat(coord, 'f', 17, 64), "0")
+		s = strings.TrimSuffix(s, ".")
+		if protocol >= 3 {
+			b = fmt.Appendf(b, ",%s\r\n", s)
+		} else {
+			b = serdes.AppendBulk(b, s)
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestGeoCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	n, err := rdb.GeoAdd(ctx, "geo:sicily",
+		&redis.GeoLocation{Name: "Palermo", Longitude: 13.361389, Latitude: 38.115556},
+		&redis.GeoLocation{Name: "Catania", Longitude: 15.087269, Latitude: 37.502669},
+	).Result()
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 members added but got %d (%v)", n, err)
+	}
+	if n := rdb.Do(ctx, "GEOADD", "geo:sicily", "XX", "CH", 13.361389, 38.115556, "Palermo", 1, 1, "Nowhere").Val(); n != int64(0) {
+		t.Fatalf("expected XX CH to change nothing but got %v", n)
+	}
+	if err := rdb.Do(ctx, "GEOADD", "geo:sicily", 13.361389, 86, "North").Err(); err == nil || err.Error() != "ERR invalid longitude,latitude pair 13.361389,86.000000" {
+		t.Fatalf("expected an invalid pair error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "GEOADD", "geo:sicily", "NX", "XX", 1, 1, "x").Err(); err == nil || err.Error() != "ERR syntax error" {
+		t.Fatalf("expected a syntax error but got %v", err)
+	}
+
+	if d := rdb.Do(ctx, "GEODIST", "geo:sicily", "Palermo", "Catania").Val(); d != "166274.1516" {
+		t.Fatalf("expected 166274.1516 but got %v", d)
+	}
+	if d := rdb.GeoDist(ctx, "geo:sicily", "Palermo", "Catania", "km").Val(); d != 166.2742 {
+		t.Fatalf("expected 166.2742 but got %v", d)
+	}
+	if err := rdb.GeoDist(ctx, "geo:sicily", "Palermo", "Missing", "km").Err(); err != redis.Nil {
+		t.Fatalf("expected a null distance but got %v", err)
+	}
+	if err := rdb.Do(ctx, "GEODIST", "geo:sicily", "Palermo", "Catania", "yd").Err(); err == nil || err.Error() != "ERR unsupported unit provided. please use M, KM, FT, MI" {
+		t.Fatalf("expected an unsupported unit error but got %v", err)
+	}
+
+	if h := rdb.GeoHash(ctx, "geo:sicily", "Palermo", "Catania").Val(); !reflect.DeepEqual(h, []string{"sqc8b49rny0", "sqdtr74hyu0"}) {
+		t.Fatalf("unexpected geohashes %v", h)
+	}
+	pos := rdb.Do(ctx, "GEOPOS", "geo:sicily", "Palermo", "Missing").Val()
+	expected := []any{[]any{13.361389338970184, 38.1155563954963}, nil}
+	if !reflect.DeepEqual(pos, expected) {
+		t.Fatalf("expected %v but got %v", expected, pos)
+	}
+
+	rdb.GeoAdd(ctx, "geo:sicily",
+		&redis.GeoLocation{Name: "edge1", Longitude: 12.758489, Latitude: 38.788135},
+		&redis.GeoLocation{Name: "edge2", Longitude: 17.241510, Latitude: 38.788135},
+	)
+	names := rdb.GeoSearch(ctx, "geo:sicily", &redis.GeoSearchQuery{
+		Longitude: 15, Latitude: 37, Radius: 200, RadiusUnit: "km", Sort: "ASC",
+	}).Val()
+	if !reflect.DeepEqual(names, []string{"Catania", "Palermo"}) {
+		t.Fatalf("unexpected radius search result %v", names)
+	}
+
+	res := rdb.Do(ctx, "GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "ASC", "WITHDIST").Val()
+	expected = []any{
+		[]any{"Catania", "56.4413"},
+		[]any{"Palermo", "190.4424"},
+		[]any{"edge2", "279.7403"},
+		[]any{"edge1", "279.7405"},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v but got %v", expected, res)
+	}
+
+	res = rdb.Do(ctx, "GEOSEARCH", "geo:sicily", "FROMMEMBER", "Palermo", "BYRADIUS", 200, "km", "DESC", "COUNT", 1, "WITHHASH").Val()
+	expected = []any{[]any{"Catania", int64(3479447370796909)}}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v but got %v", expected, res)
+	}
+	if n := len(rdb.Do(ctx, "GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "BYBOX", 400, 400, "km", "COUNT", 1, "ANY").Val().([]any)); n != 1 {
+		t.Fatalf("expected COUNT ANY to stop at one member but got %d", n)
+	}
+	if err := rdb.Do(ctx, "GEOSEARCH", "geo:sicily", "FROMMEMBER", "Missing", "BYRADIUS", 1, "km").Err(); err == nil || err.Error() != "ERR could not decode requested zset member" {
+		t.Fatalf("expected a missing member error but got %v", err)
+	}
+	if res := rdb.Do(ctx, "GEOSEARCH", "geo:missing", "FROMMEMBER", "Missing", "BYRADIUS", 1, "km").Val(); !reflect.DeepEqual(res, []any{}) {
+		t.Fatalf("expected an empty result for a missing key but got %v", res)
+	}
+
+	for _, tc := range []struct {
+		args     []any
+		expected string
+	}{
+		{[]any{"GEOSEARCH", "geo:sicily", "BYRADIUS", 1, "km", "ASC", "WITHDIST"}, "ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for GEOSEARCH"},
+		{[]any{"GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "ASC", "WITHDIST"}, "ERR exactly one of BYRADIUS and BYBOX can be specified for GEOSEARCH"},
+		{[]any{"GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "FROMMEMBER", "Palermo", "BYRADIUS", 1, "km"}, "ERR syntax error"},
+		{[]any{"GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km", "ANY"}, "ERR the ANY argument requires COUNT argument"},
+		{[]any{"GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km", "COUNT", 0}, "ERR COUNT must be > 0"},
+		{[]any{"GEOSEARCH", "geo:sicily", "FROMLONLAT", 15, 37, "BYRADIUS", -1, "km"}, "ERR radius cannot be negative"},
+		{[]any{"GEOSEARCHSTORE", "geo:dest", "geo:sicily", "FROMLONLAT", 15, 37, "BYRADIUS", 1, "km", "WITHDIST"}, "ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options"},
+	} {
+		if err := rdb.Do(ctx, tc.args...).Err(); err == nil || err.Error() != tc.expected {
+			t.Fatalf("%v: expected %q but got %v", tc.args, tc.expected, err)
+		}
+	}
+
+	if n := rdb.GeoSearchStore(ctx, "geo:sicily", "geo:near", &redis.GeoSearchStoreQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{Longitude: 15, Latitude: 37, Radius: 200, RadiusUnit: "km"},
+		StoreDist:      true,
+	}).Val(); n != 2 {
+		t.Fatalf("expected 2 members stored but got %d", n)
+	}
+	if scores := rdb.ZRangeWithScores(ctx, "geo:near", 0, -1).Val(); len(scores) != 2 || scores[0].Member != "Catania" || scores[0].Score < 56.44 || scores[0].Score > 56.45 {
+		t.Fatalf("expected the distances to be stored but got %v", scores)
+	}
+	if n := rdb.GeoSearchStore(ctx, "geo:sicily", "geo:near", &redis.GeoSearchStoreQuery{
+		GeoSearchQuery: redis.GeoSearchQuery{Longitude: 0, Latitude: 0, Radius: 1, RadiusUnit: "m"},
+	}).Val(); n != 0 || rdb.Exists(ctx, "geo:near").Val() != 0 {
+		t.Fatal("expected an empty search to delete the destination")
+	}
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"strconv"
+
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func hsetCommandHandler(s *Server, v proto.HsetCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HSet(v.Key, v.Pairs)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if v.Multi {
+		return msg.Peer.Writer().WriteSimpleString("OK")
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func hsetnxCommandHandler(s *Server, v proto.HsetnxCommand, msg peer.Message) error {
+	ok, err := s.db(msg.Peer).HSetNX(v.Key, v.Field, v.Value)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(ok))
+}
+
+func hgetCommandHandler(s *Server, v proto.HgetCommand, msg peer.Message) error {
+	value, ok, err := s.db(msg.Peer).HGet(v.Key, v.Field)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteString(value)
+}
+
+func hmgetCommandHandler(s *Server, v proto.HmgetCommand, msg peer.Message) error {
+	values, found, err := s.db(msg.Peer).HMGet(v.Key, v.Fields)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	ret := make([]serdes.Value, 0, len(values))
+	for i, value := range values {
+		if !found[i] {
+			ret = append(ret, serdes.NullValue())
+			continue
+		}
+		ret = append(ret, serdes.StringValue(value))
+	}
+
+	return msg.Peer.Writer().WriteArray(ret)
+}
+
+func hdelCommandHandler(s *Server, v proto.HdelCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HDel(v.Key, v.Fields)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func hexistsCommandHandler(s *Server, v proto.HexistsCommand, msg peer.Message) error {
+	ok, err := s.db(msg.Peer).HExists(v.Key, v.Field)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(ok))
+}
+
+func hlenCommandHandler(s *Server, v proto.HlenCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HLen(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func hgetallCommandHandler(s *Server, v proto.HgetallCommand, msg peer.Message) error {
+	values, err := s.db(msg.Peer).HGetAll(v.Key, v.Fields, v.Values)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if v.Fields && v.Values {
+		return writeMap(msg.Peer, stringValues(values))
+	}
+
+	return msg.Peer.Writer().WriteArray(stringValues(values))
+}
+
+func hincrbyCommandHandler(s *Server, v proto.HincrbyCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HIncrBy(v.Key, v.Field, v.Increment)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(res))
+}
+
+func hincrbyfloatCommandHandler(s *Server, v proto.HincrbyfloatCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HIncrByFloat(v.Key, v.Field, v.Increment)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteString(res)
+}
+
+func hstrlenCommandHandler(s *Server, v proto.HstrlenCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).HStrLen(v.Key, v.Field)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func hrandfieldCommandHandler(s *Server, v proto.HrandfieldCommand, msg peer.Message) error {
+	count := int(v.Count)
+	if !v.HasCount {
+		count = 1
+	}
+
+	// The fields come by batches, the reply is streamed as they do. WITHVALUES
+	// the peers that negotiated protocol 3 get an array of [field, value]
+	// pairs, and the others a flat array.
+	replied := false
+	err := s.db(msg.Peer).HRandField(v.Key, count, v.WithValues, func(n int, values []string) error {
+		if !v.HasCount {
+			replied = true
+			if n == 0 {
+				return msg.Peer.Writer().WriteNull()
+			}
+			return msg.Peer.Writer().WriteString(values[0])
+		}
+
+		nested := v.WithValues && msg.Peer.Protocol >= 3
+		var b []byte
+		if !replied {
+			if v.WithValues && !nested {
+				n *= 2
+			}
+			b = serdes.AppendArrayLen(b, n)
+			replied = true
+		}
+		for i, value := range values {
+			if nested && i%2 == 0 {
+				b = serdes.AppendArrayLen(b, 2)
+			}
+			b = serdes.AppendBulk(b, value)
+		}
+		_, err := msg.Peer.Send(b)
+		return err
+	})
+	if err != nil && !replied {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return err
+}
+
+func hscanCommandHandler(s *Server, v proto.HscanCommand, msg peer.Message) error {
+	cursor, values, err := s.db(msg.Peer).HScan(v.Key, v.Cursor, v.Match, int(v.Count), !v.NoValues)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeScanReply(msg.Peer, cursor, values)
+}
+
+// writeScanReply writes the reply shared by the SCAN family: the next cursor and the elements.
+func writeScanReply(p *peer.Peer, cursor uint64, values []string) error {
+	return p.Writer().WriteArray([]serdes.Value{
+		serdes.StringValue(strconv.FormatUint(cursor, 10)),
+		serdes.ArrayValue(stringValues(values)),
+	})
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestHashCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if n := rdb.HSet(ctx, "hash:user", "name", "timmy", "age", "12").Val(); n != 2 {
+		t.Fatalf("expected 2 new fields but got %d", n)
+	}
+	if ok := rdb.HSetNX(ctx, "hash:user", "name", "tommy").Val(); ok {
+		t.Fatal("expected HSETNX to fail on an existing field")
+	}
+	if name := rdb.HGet(ctx, "hash:user", "name").Val(); name != "timmy" {
+		t.Fatalf("expected timmy but got %s", name)
+	}
+	if err := rdb.HGet(ctx, "hash:user", "missing").Err(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+	if values := rdb.HMGet(ctx, "hash:user", "name", "missing").Val(); !reflect.DeepEqual(values, []interface{}{"timmy", nil}) {
+		t.Fatalf("unexpected HMGET reply: %v", values)
+	}
+
+	// go-redis negotiated RESP3 so HGETALL replies with a map.
+	if all := rdb.HGetAll(ctx, "hash:user").Val(); !reflect.DeepEqual(all, map[string]string{"name": "timmy", "age": "12"}) {
+		t.Fatalf("unexpected HGETALL reply: %v", all)
+	}
+	if age := rdb.HIncrBy(ctx, "hash:user", "age", 30).Val(); age != 42 {
+		t.Fatalf("expected 42 but got %d", age)
+	}
+	if err := rdb.HIncrBy(ctx, "hash:user", "name", 1).Err(); err == nil || err.Error() != "ERR hash value is not an integer" {
+		t.Fatalf("expected a not an integer error but got %v", err)
+	}
+	if score := rdb.HIncrByFloat(ctx, "hash:user", "score", 10.5).Val(); score != 10.5 {
+		t.Fatalf("expected 10.5 but got %v", score)
+	}
+	if n := rdb.HStrLen(ctx, "hash:user", "name").Val(); n != 5 {
+		t.Fatalf("expected 5 but got %d", n)
+	}
+
+	fields := rdb.HRandField(ctx, "hash:user", -10).Val()
+	if len(fields) != 10 {
+		t.Fatalf("expected 10 random fields but got %d", len(fields))
+	}
+	if pairs := rdb.HRandFieldWithValues(ctx, "hash:user", 10).Val(); len(pairs) != 3 {
+		t.Fatalf("expected the 3 fields but got %v", pairs)
+	}
+
+	if n := rdb.HDel(ctx, "hash:user", "name", "age", "score").Val(); n != 3 {
+		t.Fatalf("expected 3 deleted fields but got %d", n)
+	}
+	if n := rdb.Exists(ctx, "hash:user").Val(); n != 0 {
+		t.Fatal("expected the emptied hash to be deleted")
+	}
+}
+
+func TestHashScan(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 500; i++ {
+		rdb.HSet(ctx, "hash:scan", "field:"+strconv.Itoa(i), i)
+	}
+
+	seen := map[string]bool{}
+	var cursor uint64
+	for {
+		keys, next, err := rdb.HScan(ctx, "hash:scan", cursor, "field:1*", 50).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(keys); i += 2 {
+			seen[keys[i]] = true
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	// field:1, field:10-19 and field:100-199.
+	if len(seen) != 111 {
+		t.Fatalf("expected 111 matching fields but got %d", len(seen))
+	}
+
+	// A COUNT too big for the bucket budget returns everything at once.
+	rdb.ZAdd(ctx, "hash:scan:zset", redis.Z{Score: 1, Member: "a"}, redis.Z{Score: 2, Member: "b"})
+	hugeTests := []struct {
+		cmd      *redis.ScanCmd
+		expected int
+	}{
+		{rdb.Scan(ctx, 0, "hash:scan*", math.MaxInt64), 2},
+		{rdb.HScan(ctx, "hash:scan", 0, "", 4611686018427387904), 1000},
+		{rdb.ZScan(ctx, "hash:scan:zset", 0, "", math.MaxInt64), 4},
+	}
+	for _, tt := range hugeTests {
+		values, cursor, err := tt.cmd.Result()
+		if err != nil || cursor != 0 || len(values) != tt.expected {
+			t.Errorf("expected %v to return %d elements at once but got %d, cursor %d, %v", tt.cmd.Args(), tt.expected, len(values), cursor, err)
+		}
+	}
+}
+
+func TestRandCounts(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+	rdb.HSet(ctx, "rand:hash", "a", "1", "b", "2")
+	rdb.SAdd(ctx, "rand:set", "a", "b")
+	rdb.ZAdd(ctx, "rand:zset", redis.Z{Score: 1, Member: "a"}, redis.Z{Score: 2, Member: "b"})
+
+	errorTests := [][]interface{}{
+		{"SRANDMEMBER", "rand:set", "-9223372036854775808"},
+		{"HRANDFIELD", "rand:hash", "-9223372036854775808"},
+		{"ZRANDMEMBER", "rand:zset", "-9223372036854775808", "WITHSCORES"},
+		{"HRANDFIELD", "rand:hash", "-4611686018427387904", "WITHVALUES"},
+		{"ZRANDMEMBER", "rand:zset", "-4611686018427387904", "WITHSCORES"},
+	}
+	for _, args := range errorTests {
+		if err := rdb.Do(ctx, args...).Err(); err == nil || err.Error() != "ERR value is out of range" {
+			t.Errorf("expected %v to be out of range but got %v", args, err)
+		}
+	}
+
+	// A huge negative count is streamed rather than drawn whole, the client
+	// can read as much as it wants of it and hang up.
+	hugeTests := []struct {
+		args   []string
+		header string
+	}{
+		{[]string{"SRANDMEMBER", "rand:set", "-100000000000"}, "*100000000000\r\n"},
+		{[]string{"HRANDFIELD", "rand:hash", "-100000000000", "WITHVALUES"}, "*200000000000\r\n"},
+		{[]string{"ZRANDMEMBER", "rand:zset", "-100000000000", "WITHSCORES"}, "*200000000000\r\n"},
+	}
+	for _, tt := range hugeTests {
+		conn, err := net.Dial("tcp", "localhost"+testListenAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		send(t, conn, tt.args...)
+		rd := bufio.NewReader(conn)
+		if header, err := rd.ReadString('\n'); err != nil || header != tt.header {
+			t.Fatalf("expected %v to reply %q but got %q, %v", tt.args, tt.header, header, err)
+		}
+		if _, err := io.ReadFull(rd, make([]byte, 1<<16)); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			t.Fatalf("expected the server to outlive %v but got %v", tt.args, err)
+		}
+	}
+}
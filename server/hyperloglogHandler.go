@@ -0,0 +1,32 @@
+package server
+
+import (
+	"redis-clone/peer"
+	"redis-clone/proto"
+)
+
+func pfaddCommandHandler(s *Server, v proto.PfaddCommand, msg peer.Message) error {
+	updated, err := s.db(msg.Peer).PFAdd(v.Key, v.Elements)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(updated))
+}
+
+func pfcountCommandHandler(s *Server, v proto.PfcountCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).PFCount(v.Keys)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(res))
+}
+
+func pfmergeCommandHandler(s *Server, v proto.PfmergeCommand, msg peer.Message) error {
+	if err := s.db(msg.Peer).PFMerge(v.Destination, v.Keys); err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if n := rdb.PFAdd(ctx, "hll:empty").Val(); n != 1 {
+		t.Fatalf("expected PFADD without elements to create the key but got %d", n)
+	}
+	if n := rdb.PFCount(ctx, "hll:empty").Val(); n != 0 {
+		t.Fatalf("expected an empty HyperLogLog to count 0 but got %d", n)
+	}
+	if n := rdb.PFAdd(ctx, "hll:empty").Val(); n != 0 {
+		t.Fatalf("expected PFADD without elements on an existing key to give 0 but got %d", n)
+	}
+
+	if n := rdb.PFAdd(ctx, "hll:a", 1, 2, 3, 4, 5).Val(); n != 1 {
+		t.Fatalf("expected the registers to change but got %d", n)
+	}
+	if n := rdb.PFAdd(ctx, "hll:a", 1, 2, 3).Val(); n != 0 {
+		t.Fatalf("expected no register to change but got %d", n)
+	}
+	if n := rdb.PFCount(ctx, "hll:a").Val(); n != 5 {
+		t.Fatalf("expected 5 but got %d", n)
+	}
+	rdb.PFAdd(ctx, "hll:a", 6, 7, 8, 9, 10)
+	if n := rdb.PFCount(ctx, "hll:a").Val(); n != 10 {
+		t.Fatalf("expected 10 but got %d", n)
+	}
+	if n := rdb.PFAdd(ctx, "hll:a", "").Val(); n != 1 {
+		t.Fatalf("expected the empty string to be counted but got %d", n)
+	}
+
+	value := rdb.Get(ctx, "hll:a").Val()
+	if !strings.HasPrefix(value, "HYLL\x01") {
+		t.Fatalf("expected a sparse HyperLogLog string but got %q", value)
+	}
+	rdb.Set(ctx, "hll:copy", value, 0)
+	if n := rdb.PFCount(ctx, "hll:copy").Val(); n != 11 {
+		t.Fatalf("expected a copied HyperLogLog to count 11 but got %d", n)
+	}
+
+	rdb.PFAdd(ctx, "hll:b", 8, 9, 10, 11, 12)
+	if n := rdb.PFCount(ctx, "hll:a", "hll:b", "hll:missing").Val(); n != 13 {
+		t.Fatalf("expected the union to count 13 but got %d", n)
+	}
+	if err := rdb.PFMerge(ctx, "hll:union", "hll:a", "hll:b").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := rdb.PFCount(ctx, "hll:union").Val(); n != 13 {
+		t.Fatalf("expected the merge to count 13 but got %d", n)
+	}
+
+	elements := make([]any, 0, 5000)
+	for i := 0; i < 5000; i++ {
+		elements = append(elements, "element:"+strconv.Itoa(i))
+	}
+	rdb.PFAdd(ctx, "hll:big", elements...)
+	if value := rdb.Get(ctx, "hll:big").Val(); !strings.HasPrefix(value, "HYLL\x00") || len(value) != 12304 {
+		t.Fatalf("expected the HyperLogLog to be promoted to dense, got %d bytes", len(value))
+	}
+	if n := rdb.PFCount(ctx, "hll:big").Val(); n < 4900 || n > 5100 {
+		t.Fatalf("estimate %d is too far from 5000", n)
+	}
+	rdb.PFMerge(ctx, "hll:union", "hll:big")
+	if value := rdb.Get(ctx, "hll:union").Val(); value[4] != 0 {
+		t.Fatal("expected merging a dense HyperLogLog to make the destination dense")
+	}
+
+	rdb.Set(ctx, "hll:string", "bar", 0)
+	if err := rdb.PFAdd(ctx, "hll:string", "a").Err(); err == nil || err.Error() != "WRONGTYPE Key is not a valid HyperLogLog string value." {
+		t.Fatalf("expected an invalid HyperLogLog error but got %v", err)
+	}
+	rdb.RPush(ctx, "hll:list", "a")
+	if err := rdb.PFCount(ctx, "hll:list").Err(); err == nil || !strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		t.Fatalf("expected a WRONGTYPE error but got %v", err)
+	}
+	rdb.Append(ctx, "hll:b", "hello")
+	if err := rdb.PFCount(ctx, "hll:b").Err(); err == nil || err.Error() != "INVALIDOBJ Corrupted HLL object detected" {
+		t.Fatalf("expected a corrupted HyperLogLog error but got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func pushCommandHandler(s *Server, v proto.PushCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).Push(v.Key, v.Value, listSide(v.Left), v.OnlyIfExists)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func popCommandHandler(s *Server, v proto.PopCommand, msg peer.Message) error {
+	values, err := s.db(msg.Peer).Pop(v.Key, listSide(v.Left), int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if values == nil && v.HasCount {
+		return msg.Peer.Writer().WriteNullArray()
+	}
+	if values == nil {
+		return msg.Peer.Writer().WriteNull()
+	}
+	if !v.HasCount {
+		return msg.Peer.Writer().WriteString(values[0])
+	}
+
+	return msg.Peer.Writer().WriteArray(stringValues(values))
+}
+
+func llenCommandHandler(s *Server, v proto.LlenCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).LLen(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func lindexCommandHandler(s *Server, v proto.LindexCommand, msg peer.Message) error {
+	value, ok, err := s.db(msg.Peer).LIndex(v.Key, int(v.Index))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteString(value)
+}
+
+func lsetCommandHandler(s *Server, v proto.LsetCommand, msg peer.Message) error {
+	if err := s.db(msg.Peer).LSet(v.Key, int(v.Index), v.Value); err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+func lrangeCommandHandler(s *Server, v proto.LrangeCommand, msg peer.Message) error {
+	values, err := s.db(msg.Peer).LRange(v.Key, int(v.Start), int(v.Stop))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteArray(stringValues(values))
+}
+
+func lremCommandHandler(s *Server, v proto.LremCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).LRem(v.Key, int(v.Count), v.Value)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func ltrimCommandHandler(s *Server, v proto.LtrimCommand, msg peer.Message) error {
+	if err := s.db(msg.Peer).LTrim(v.Key, int(v.Start), int(v.Stop)); err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+func linsertCommandHandler(s *Server, v proto.LinsertCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).LInsert(v.Key, v.Before, v.Pivot, v.Value)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func lposCommandHandler(s *Server, v proto.LposCommand, msg peer.Message) error {
+	count := int(v.Count)
+	if !v.HasCount {
+		count = 1
+	}
+	matches, err := s.db(msg.Peer).LPos(v.Key, v.Value, int(v.Rank), count, int(v.MaxLen))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	if !v.HasCount {
+		if len(matches) == 0 {
+			return msg.Peer.Writer().WriteNull()
+		}
+		return msg.Peer.Writer().WriteInteger(matches[0])
+	}
+
+	values := make([]serdes.Value, 0, len(matches))
+	for _, index := range matches {
+		values = append(values, serdes.IntegerValue(int64(index)))
+	}
+
+	return msg.Peer.Writer().WriteArray(values)
+}
+
+func lmoveCommandHandler(s *Server, v proto.LmoveCommand, msg peer.Message) error {
+	value, ok, err := s.db(msg.Peer).LMove(v.Source, v.Destination, listSide(v.FromLeft), listSide(v.ToLeft))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteString(value)
+}
+
+func lmpopCommandHandler(s *Server, v proto.LmpopCommand, msg peer.Message) error {
+	key, values, err := s.db(msg.Peer).LMPop(v.Keys, listSide(v.Left), int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if values == nil {
+		return msg.Peer.Writer().WriteNullArray()
+	}
+
+	return msg.Peer.Writer().WriteArray([]serdes.Value{
+		serdes.StringValue(key),
+		serdes.ArrayValue(stringValues(values)),
+	})
+}
+
+func listSide(left bool) keyval.ListSide {
+	if left {
+		return keyval.Left
+	}
+	return keyval.Right
+}
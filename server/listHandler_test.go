@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestListCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if n := rdb.RPush(ctx, "list:a", "b", "c").Val(); n != 2 {
+		t.Fatalf("expected a length of 2 but got %d", n)
+	}
+	if n := rdb.LPush(ctx, "list:a", "a", "z").Val(); n != 4 {
+		t.Fatalf("expected a length of 4 but got %d", n)
+	}
+	if values := rdb.LRange(ctx, "list:a", 0, -1).Val(); !reflect.DeepEqual(values, []string{"z", "a", "b", "c"}) {
+		t.Fatalf("LPUSH should push to the head, got %v", values)
+	}
+	if values := rdb.LRange(ctx, "list:a", -2, 100).Val(); !reflect.DeepEqual(values, []string{"b", "c"}) {
+		t.Fatalf("unexpected LRANGE -2 100: %v", values)
+	}
+	if values := rdb.LRange(ctx, "list:a", 3, 1).Val(); len(values) != 0 {
+		t.Fatalf("expected an empty range but got %v", values)
+	}
+	if value := rdb.LIndex(ctx, "list:a", -1).Val(); value != "c" {
+		t.Fatalf("expected c but got %s", value)
+	}
+	if n := rdb.LPushX(ctx, "list:missing", "a").Val(); n != 0 {
+		t.Fatalf("expected LPUSHX on a missing key to do nothing, got %d", n)
+	}
+
+	if err := rdb.LSet(ctx, "list:a", 0, "y").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.LSet(ctx, "list:a", 10, "y").Err(); err == nil || err.Error() != "ERR index out of range" {
+		t.Fatalf("expected an out of range error but got %v", err)
+	}
+	if n := rdb.LInsertBefore(ctx, "list:a", "b", "x").Val(); n != 5 {
+		t.Fatalf("expected a length of 5 but got %d", n)
+	}
+	if n := rdb.LInsertAfter(ctx, "list:a", "nope", "x").Val(); n != -1 {
+		t.Fatalf("expected -1 for a missing pivot but got %d", n)
+	}
+	if pos := rdb.LPos(ctx, "list:a", "x", redis.LPosArgs{Rank: -1}).Val(); pos != 2 {
+		t.Fatalf("expected x at 2 but got %d", pos)
+	}
+	if err := rdb.Do(ctx, "LPOS", "list:a", "x", "RANK", "-9223372036854775808").Err(); err == nil || err.Error() != "ERR value is out of range, value must between -9223372036854775807 and 9223372036854775807" {
+		t.Fatalf("expected an out of range rank error but got %v", err)
+	}
+	if n := rdb.LRem(ctx, "list:a", 0, "x").Val(); n != 1 {
+		t.Fatalf("expected one removal but got %d", n)
+	}
+	if err := rdb.LTrim(ctx, "list:a", 1, -1).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if values := rdb.LRange(ctx, "list:a", 0, -1).Val(); !reflect.DeepEqual(values, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected list after LTRIM: %v", values)
+	}
+
+	if value := rdb.LMove(ctx, "list:a", "list:b", "RIGHT", "LEFT").Val(); value != "c" {
+		t.Fatalf("expected to move c but got %s", value)
+	}
+	if values := rdb.LPopCount(ctx, "list:a", 5).Val(); !reflect.DeepEqual(values, []string{"a", "b"}) {
+		t.Fatalf("unexpected LPOP count: %v", values)
+	}
+	if n := rdb.Exists(ctx, "list:a").Val(); n != 0 {
+		t.Fatal("expected the emptied list to be deleted")
+	}
+
+	key, values, err := rdb.LMPop(ctx, "left", 10, "list:a", "list:b").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "list:b" || !reflect.DeepEqual(values, []string{"c"}) {
+		t.Fatalf("unexpected LMPOP reply: %s %v", key, values)
+	}
+}
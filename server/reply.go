@@ -0,0 +1,79 @@
+package server
+
+import (
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/serdes"
+)
+
+// stringValues turns the strings into an array of bulk strings.
+func stringValues(values []string) []serdes.Value {
+	ret := make([]serdes.Value, 0, len(values))
+	for _, value := range values {
+		ret = append(ret, serdes.StringValue(value))
+	}
+
+	return ret
+}
+
+// writeMap writes the interleaved keys and values as a RESP3 map to the peers
+// that negotiated protocol 3 and as a flat array to the others.
+func writeMap(p *peer.Peer, pairs []serdes.Value) error {
+	return p.Writer().WriteMap(pairs)
+}
+
+// writeSet writes the values as a RESP3 set to the peers that negotiated
+// protocol 3 and as an array to the others.
+func writeSet(p *peer.Peer, values []serdes.Value) error {
+	return p.Writer().WriteSet(values)
+}
+
+// integerBool is the integer 1 or 0 redis replies with for a boolean, even
+// to the peers that negotiated protocol 3.
+func integerBool(b bool) serdes.Value {
+	if b {
+		return serdes.IntegerValue(1)
+	}
+	return serdes.IntegerValue(0)
+}
+
+// writeScore writes a single score.
+func writeScore(p *peer.Peer, score float64) error {
+	return p.Writer().WriteDouble(score)
+}
+
+// writeScoredMembers writes the members of a sorted set, followed by their
+// scores with withScores. Like redis, the peers that negotiated protocol 3
+// get an array of [member, score] pairs, and the others a flat array.
+func writeScoredMembers(p *peer.Peer, members []keyval.ZMember, withScores bool) error {
+	b := appendScoredMembersLen(nil, p.Protocol, len(members), withScores)
+	_, err := p.Send(appendScoredMembers(b, p.Protocol, members, withScores))
+
+	return err
+}
+
+// appendScoredMembersLen appends the header of the reply of writeScoredMembers
+// for n members.
+func appendScoredMembersLen(b []byte, protocol, n int, withScores bool) []byte {
+	if withScores && protocol < 3 {
+		n *= 2
+	}
+	return serdes.AppendArrayLen(b, n)
+}
+
+// appendScoredMembers appends the members of the reply of writeScoredMembers,
+// without its header.
+func appendScoredMembers(b []byte, protocol int, members []keyval.ZMember, withScores bool) []byte {
+	for _, m := range members {
+		switch {
+		case !withScores:
+			b = serdes.AppendBulk(b, m.Member)
+		case protocol >= 3:
+			b = serdes.AppendDouble(serdes.AppendBulk(serdes.AppendArrayLen(b, 2), m.Member), protocol, m.Score)
+		default:
+			b = serdes.AppendDouble(serdes.AppendBulk(b, m.Member), protocol, m.Score)
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"redis-clone/serdes"
+)
+
+// goldenReplies are the exact bytes redis replies to each command, for
+// RESP2 and RESP3 connections.
+var goldenReplies = []struct {
+	args         []string
+	resp2, resp3 string
+}{
+	{[]string{"PING"}, "+PONG\r\n", "+PONG\r\n"},
+	{[]string{"PING", "hi"}, "$2\r\nhi\r\n", "$2\r\nhi\r\n"},
+	{[]string{"SET", "golden:key", "v"}, "+OK\r\n", "+OK\r\n"},
+	{[]string{"GET", "golden:key"}, "$1\r\nv\r\n", "$1\r\nv\r\n"},
+	{[]string{"GET", "golden:missing"}, "$-1\r\n", "_\r\n"},
+	{[]string{"SET", "golden:key", "v", "NX"}, "$-1\r\n", "_\r\n"},
+	{[]string{"SETNX", "golden:key", "v"}, ":0\r\n", ":0\r\n"},
+	{[]string{"EXISTS", "golden:key", "golden:missing"}, ":1\r\n", ":1\r\n"},
+	{[]string{"INCR", "golden:str"}, "-ERR value is not an integer or out of range\r\n", "-ERR value is not an integer or out of range\r\n"},
+	{[]string{"LPUSH", "golden:str", "x"}, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n", "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+	{[]string{"NOPE", "a"}, "-ERR unknown command 'NOPE', with args beginning with: 'a' \r\n", "-ERR unknown command 'NOPE', with args beginning with: 'a' \r\n"},
+	{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command\r\n", "-ERR wrong number of arguments for 'get' command\r\n"},
+	{[]string{"PING", "a", "b"}, "-ERR wrong number of arguments for 'ping' command\r\n", "-ERR wrong number of arguments for 'ping' command\r\n"},
+	{[]string{"OBJECT", "nope"}, "-ERR unknown subcommand 'nope'. Try OBJECT HELP.\r\n", "-ERR unknown subcommand 'nope'. Try OBJECT HELP.\r\n"},
+	{[]string{"TYPE", "golden:hash"}, "+hash\r\n", "+hash\r\n"},
+	{[]string{"LRANGE", "golden:list", "0", "-1"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+	{[]string{"LPOP", "golden:missing"}, "$-1\r\n", "_\r\n"},
+	{[]string{"LPOP", "golden:missing", "2"}, "*-1\r\n", "_\r\n"},
+	{[]string{"LMPOP", "1", "golden:missing", "LEFT"}, "*-1\r\n", "_\r\n"},
+	{[]string{"BLPOP", "golden:missing", "0.01"}, "*-1\r\n", "_\r\n"},
+	{[]string{"BLMOVE", "golden:missing", "golden:dst", "LEFT", "LEFT", "0.01"}, "$-1\r\n", "_\r\n"},
+	{[]string{"HGETALL", "golden:hash"}, "*2\r\n$1\r\nf\r\n$1\r\nv\r\n", "%1\r\n$1\r\nf\r\n$1\r\nv\r\n"},
+	{[]string{"HGET", "golden:hash", "missing"}, "$-1\r\n", "_\r\n"},
+	{[]string{"SMEMBERS", "golden:set"}, "*1\r\n$1\r\nx\r\n", "~1\r\n$1\r\nx\r\n"},
+	{[]string{"SISMEMBER", "golden:set", "x"}, ":1\r\n", ":1\r\n"},
+	{[]string{"ZSCORE", "golden:zset", "m"}, "$3\r\n1.5\r\n", ",1.5\r\n"},
+	{[]string{"ZRANK", "golden:zset", "missing", "WITHSCORE"}, "*-1\r\n", "_\r\n"},
+	{[]string{"SET", "golden:float", "10.5"}, "+OK\r\n", "+OK\r\n"},
+	{[]string{"INCRBYFLOAT", "golden:float", "0.1"}, "$4\r\n10.6\r\n", "$4\r\n10.6\r\n"},
+	{[]string{"XREAD", "STREAMS", "golden:missing", "0"}, "*-1\r\n", "_\r\n"},
+	{[]string{"CLIENT", "GETNAME"}, "$-1\r\n", "_\r\n"},
+	{[]string{"CLIENT", "SETINFO", "LIB-NAME", "golden"}, "+OK\r\n", "+OK\r\n"},
+}
+
+func TestGoldenReplies(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+	rdb.Del(ctx, "golden:key")
+	rdb.Set(ctx, "golden:str", "hello", 0)
+	rdb.RPush(ctx, "golden:list", "a", "b")
+	rdb.HSet(ctx, "golden:hash", "f", "v")
+	rdb.SAdd(ctx, "golden:set", "x")
+	rdb.ZAdd(ctx, "golden:zset", redis.Z{Score: 1.5, Member: "m"})
+
+	for _, protocol := range []int{2, 3} {
+		conn, err := net.Dial("tcp", "localhost"+testListenAddr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		if protocol == 3 {
+			send(t, conn, "HELLO", "3")
+			if _, err := serdes.NewReader(rd).ReadValue(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for _, tt := range goldenReplies {
+			expected := tt.resp2
+			if protocol == 3 {
+				expected = tt.resp3
+			}
+			send(t, conn, tt.args...)
+			got := make([]byte, len(expected))
+			if _, err := io.ReadFull(rd, got); err != nil {
+				t.Fatalf("RESP%d %v: %v", protocol, tt.args, err)
+			}
+			if string(got) != expected {
+				t.Fatalf("expected RESP%d %v to reply %q but got %q", protocol, tt.args, expected, got)
+			}
+		}
+	}
+
+	// What go-redis makes of them.
+	if err := rdb.Get(ctx, "golden:missing").Err(); err != redis.Nil {
+		t.Fatalf("expected a missing key to be redis.Nil but got %v", err)
+	}
+	if v := rdb.Set(ctx, "golden:key", "v", 0).Val(); v != "OK" {
+		t.Fatalf("expected SET to reply OK but got %q", v)
+	}
+}
+
+// send writes the command to the connection.
+func send(t *testing.T, conn net.Conn, args ...string) {
+	t.Helper()
+	if err := serdes.NewWriter(conn, 2).WriteArray(stringValues(args)); err != nil {
+		t.Fatal(err)
+	}
+}
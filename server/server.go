@@ -3,18 +3,25 @@ package server
 import (
 	"log"
 	"net"
+	"time"
 
 	"redis-clone/keyval"
 	"redis-clone/peer"
-	"redis-clone/proto"
-
-	"github.com/tidwall/resp"
 )
 
-const DefaultConfigAddr = ":5001"
+const (
+	DefaultConfigAddr = ":5001"
+	DefaultDatabases  = 16
+)
 
 type Config struct {
 	ListenAddress string
+	// Databases is the number of databases SELECT can choose from.
+	Databases int
+	// MaxMemory is the memory limit in bytes, zero means none, past which
+	// keys are evicted according to MaxMemoryPolicy, noeviction by default.
+	MaxMemory       int64
+	MaxMemoryPolicy keyval.EvictionPolicy
 }
 
 type Server struct {
@@ -26,13 +33,35 @@ type Server struct {
 	DoneCh       chan struct{}
 	ErrorsCh     chan peer.Errors
 	MsgCh        chan peer.Message
-	Kv           *keyval.KV
+	// DBs are the databases, peers use the one they selected, 0 by default.
+	DBs     []*keyval.KV
+	evictor *keyval.Evictor
+	// lazyfree are the lazyfree-lazy-* options, shared by the databases.
+	lazyfree *keyval.LazyFree
+
+	// blocked maps the peers parked by a blocking command to their state, and
+	// blockingKeys the keys they wait on to the waiters in arrival order.
+	blocked      map[*peer.Peer]*blockedClient
+	blockingKeys map[dbKey][]*blockedClient
+	timeoutCh    chan *blockedClient
 }
 
 func NewServer(cfg Config) *Server {
 	if cfg.ListenAddress == "" {
 		cfg.ListenAddress = DefaultConfigAddr
 	}
+	if cfg.Databases <= 0 {
+		cfg.Databases = DefaultDatabases
+	}
+	lazyfree := &keyval.LazyFree{}
+	dbs := make([]*keyval.KV, cfg.Databases)
+	for i := range dbs {
+		dbs[i] = keyval.NewKeyVal()
+		dbs[i].SetLazyFree(lazyfree)
+	}
+
+	evictor := keyval.NewEvictor()
+	evictor.Limit, evictor.Policy = cfg.MaxMemory, cfg.MaxMemoryPolicy
 
 	return &Server{
 		Config:       cfg,
@@ -42,7 +71,12 @@ func NewServer(cfg Config) *Server {
 		ErrorsCh:     make(chan peer.Errors),
 		MsgCh:        make(chan peer.Message),
 		DoneCh:       make(chan struct{}),
-		Kv:           keyval.NewKeyVal(),
+		DBs:          dbs,
+		evictor:      evictor,
+		lazyfree:     lazyfree,
+		blocked:      make(map[*peer.Peer]*blockedClient),
+		blockingKeys: make(map[dbKey][]*blockedClient),
+		timeoutCh:    make(chan *blockedClient),
 	}
 }
 
@@ -62,27 +96,45 @@ func (s *Server) Start() error {
 }
 
 // loop continuously listens for messages, adds or removes peers, or exits.
+// It also drives the active expiry of volatile keys.
 func (s *Server) loop() {
+	expireTicker := time.NewTicker(activeExpireInterval)
+	defer expireTicker.Stop()
+
 	for {
 		select {
 		case msg := <-s.MsgCh:
-			if err := s.handleMessage(msg); err != nil {
-				log.Println("Error handling message:", err)
-			}
+			s.process(msg)
+			s.handleReadyKeys()
 		case peer := <-s.AddPeerCh:
 			s.Peers[peer] = true
 			log.Println("New peer connected:", peer.Conn.RemoteAddr())
 		case peerToRemove := <-s.RemovePeerCh:
+			if bc := s.blocked[peerToRemove]; bc != nil {
+				s.unblock(bc)
+			}
 			delete(s.Peers, peerToRemove)
 			log.Println("Peer disconnected:", peerToRemove.Conn.RemoteAddr())
 		case err := <-s.ErrorsCh:
-			_ = s.handleErrors(err)
+			s.process(err)
+		case bc := <-s.timeoutCh:
+			s.handleTimeout(bc)
+			s.handleReadyKeys()
+		case <-expireTicker.C:
+			for _, kv := range s.DBs {
+				kv.ActiveExpireCycle()
+			}
 		case <-s.DoneCh:
 			return
 		}
 	}
 }
 
+// db returns the database the peer selected.
+func (s *Server) db(p *peer.Peer) *keyval.KV {
+	return s.DBs[p.DB]
+}
+
 // acceptLoop accepts incoming connections and handles them.
 func (s *Server) acceptLoop() error {
 	for {
@@ -96,39 +148,42 @@ func (s *Server) acceptLoop() error {
 	}
 }
 
+// process handles a message or an error sent by a peer, unless that peer is
+// blocked in which case it is queued until the peer is unblocked.
+func (s *Server) process(item any) {
+	switch v := item.(type) {
+	case peer.Message:
+		if bc := s.blocked[v.Peer]; bc != nil {
+			bc.pending = append(bc.pending, v)
+			return
+		}
+		if err := s.handleMessage(v); err != nil {
+			log.Println("Error handling message:", err)
+		}
+	case peer.Errors:
+		if bc := s.blocked[v.Peer]; bc != nil {
+			bc.pending = append(bc.pending, v)
+			return
+		}
+		_ = s.handleErrors(v)
+	}
+}
+
 func (s *Server) handleErrors(err peer.Errors) error {
-	return resp.NewWriter(err.Peer.Conn).WriteError(err.Err)
+	return err.Peer.Writer().WriteError(err.Err)
 }
 
+// handleMessage looks the command up in the command table and runs it.
 func (s *Server) handleMessage(msg peer.Message) error {
-	switch v := msg.Cmd.(type) {
-	case proto.ClientCommand:
-		return clientCommandHandler(msg)
-	case proto.SetCommand:
-		return setCommandHandler(s, v, msg)
-	case proto.GetCommand:
-		return getCommandHandler(s, v, msg)
-	case proto.HelloCommand:
-		return helloCommandHandler(msg)
-	case proto.CommandCommand:
-		return commandCommandHandler(msg)
-	case proto.PingCommand:
-		return pingCommandHandler(msg)
-	case proto.ConfigGetCommand:
-		return configCommandGetHandler(msg)
-	case proto.ExistCommand:
-		return existCommandHandler(s, v, msg)
-	case proto.DelCommand:
-		return delCommandHandler(s, v, msg)
-	case proto.IncrCommand:
-		return incrCommandHandler(s, v, msg)
-	case proto.DecrCommand:
-		return decrCommandHandler(s, v, msg)
-	case proto.LpushCommand:
-		return lpushCommandHandler(s, v, msg)
-	default:
-		return unhandledCommand(msg)
+	spec, err := lookupCommand(msg.Args.Array())
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
 	}
+	if err := s.performEvictions(spec); err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return spec.call(s, spec.name, msg)
 }
 
 // handleConn handles incoming connections.
@@ -3,28 +3,47 @@ package server
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"redis-clone/serdes"
 )
 
-func TestWithRedisGoClient(t *testing.T) {
-	listenAddr := ":5001"
-	s := NewServer(Config{
-		ListenAddress: listenAddr,
+const testListenAddr = ":5001"
+
+var startTestServer sync.Once
+
+// newTestClient returns a go-redis client connected to a server shared by all the tests.
+func newTestClient(t *testing.T) *redis.Client {
+	t.Helper()
+	startTestServer.Do(func() {
+		s := NewServer(Config{
+			ListenAddress: testListenAddr,
+		})
+		go func() {
+			log.Fatal(s.Start())
+		}()
+		time.Sleep(time.Millisecond * 400)
 	})
-	go func() {
-		log.Fatal(s.Start())
-	}()
-	time.Sleep(time.Millisecond * 400)
 
 	rdb := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("localhost%s", ":5001"),
+		Addr:     fmt.Sprintf("localhost%s", testListenAddr),
 		Password: "",
 		DB:       0,
 	})
+	t.Cleanup(func() { rdb.Close() })
+
+	return rdb
+}
+
+func TestWithRedisGoClient(t *testing.T) {
+	rdb := newTestClient(t)
 
 	testCases := map[string]string{
 		"foo":    "bar",
@@ -39,8 +58,113 @@ func TestWithRedisGoClient(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-        if newVal != val {
+		if newVal != val {
 			t.Fatalf("expected %s but got %s", val, newVal)
 		}
 	}
 }
+
+func TestSetOptions(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if err := rdb.Set(ctx, "set:ttl", "v1", time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.TTL(ctx, "set:ttl").Val(); ttl != time.Minute {
+		t.Fatalf("expected a ttl of one minute but got %v", ttl)
+	}
+
+	if ok := rdb.SetNX(ctx, "set:ttl", "v2", 0).Val(); ok {
+		t.Fatal("expected SET NX to fail on an existing key")
+	}
+	if ok := rdb.SetXX(ctx, "set:missing", "v2", 0).Val(); ok {
+		t.Fatal("expected SET XX to fail on a missing key")
+	}
+
+	if err := rdb.SetArgs(ctx, "set:ttl", "v2", redis.SetArgs{KeepTTL: true}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.TTL(ctx, "set:ttl").Val(); ttl <= 0 {
+		t.Fatalf("expected KEEPTTL to retain the ttl but got %v", ttl)
+	}
+
+	old, err := rdb.SetArgs(ctx, "set:ttl", "v3", redis.SetArgs{Get: true}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old != "v2" {
+		t.Fatalf("expected the old value v2 but got %s", old)
+	}
+	if ttl := rdb.TTL(ctx, "set:ttl").Val(); ttl != -1 {
+		t.Fatalf("expected a plain SET to discard the ttl but got %v", ttl)
+	}
+
+	if _, err := rdb.SetArgs(ctx, "set:get-missing", "v", redis.SetArgs{Get: true}).Result(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+
+	if err := rdb.Do(ctx, "SET", "set:bad", "v", "EX", "0").Err(); err == nil {
+		t.Fatal("expected an invalid expire time error")
+	}
+	if err := rdb.Do(ctx, "SET", "set:bad", "v", "NX", "XX").Err(); err == nil {
+		t.Fatal("expected a syntax error")
+	}
+}
+
+func TestInlineCommands(t *testing.T) {
+	c := newRawConn(t)
+
+	if _, err := c.conn.Write([]byte("PING\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.rd.ReadValue(); err != nil || v.String() != "PONG" {
+		t.Fatalf("expected PONG to an inline PING but got %q, %v", v.String(), err)
+	}
+	if _, err := c.conn.Write([]byte("SET inline:key \"hello \\x77orld\"\nGET 'inline:key'\n")); err != nil {
+		t.Fatal(err)
+	}
+	c.rd.ReadValue()
+	if v, err := c.rd.ReadValue(); err != nil || v.String() != "hello world" {
+		t.Fatalf("expected the quoted value to be read back but got %q, %v", v.String(), err)
+	}
+
+	// Unbalanced quotes are a protocol error closing the connection.
+	if _, err := c.conn.Write([]byte("GET \"inline:key\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	v, err := c.rd.ReadValue()
+	if err != nil || v.String() != "ERR Protocol error: unbalanced quotes in request" {
+		t.Fatalf("expected a protocol error but got %q, %v", v.String(), err)
+	}
+	if _, err := c.rd.ReadValue(); err != io.EOF {
+		t.Fatalf("expected the connection to be closed but got %v", err)
+	}
+}
+
+func TestMultibulkCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"nested", strings.Repeat("*1\r\n", 64), "ERR Protocol error: expected '$', got '*'"},
+		{"not a bulk string", "*2\r\n$3\r\nGET\r\n:1\r\n", "ERR Protocol error: expected '$', got ':'"},
+		{"streamed", "*?\r\n", "ERR Protocol error: invalid multibulk length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newRawConn(t)
+			if _, err := c.conn.Write([]byte(tt.input)); err != nil {
+				t.Fatal(err)
+			}
+			v, err := c.rd.ReadValue()
+			if err != nil || v.Type() != serdes.Error || v.String() != tt.expected {
+				t.Fatalf("expected %q but got %q, %v", tt.expected, v.String(), err)
+			}
+			if _, err := c.rd.ReadValue(); err != io.EOF {
+				t.Fatalf("expected the connection to be closed but got %v", err)
+			}
+		})
+	}
+}
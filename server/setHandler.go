@@ -0,0 +1,157 @@
+package server
+
+import (
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var setOps = map[string]keyval.SetOp{
+	proto.CommandSINTER: keyval.SetInter,
+	proto.CommandSUNION: keyval.SetUnion,
+	proto.CommandSDIFF:  keyval.SetDiff,
+}
+
+func saddCommandHandler(s *Server, v proto.SaddCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SAdd(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func sremCommandHandler(s *Server, v proto.SremCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SRem(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func sismemberCommandHandler(s *Server, v proto.SismemberCommand, msg peer.Message) error {
+	found, err := s.db(msg.Peer).SMIsMember(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !v.Multi {
+		return msg.Peer.Writer().WriteValue(integerBool(found[0]))
+	}
+
+	ret := make([]serdes.Value, 0, len(found))
+	for _, ok := range found {
+		ret = append(ret, integerBool(ok))
+	}
+
+	return msg.Peer.Writer().WriteArray(ret)
+}
+
+func smembersCommandHandler(s *Server, v proto.SmembersCommand, msg peer.Message) error {
+	members, err := s.db(msg.Peer).SMembers(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeSet(msg.Peer, stringValues(members))
+}
+
+func scardCommandHandler(s *Server, v proto.ScardCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SCard(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func spopCommandHandler(s *Server, v proto.SpopCommand, msg peer.Message) error {
+	members, err := s.db(msg.Peer).SPop(v.Key, int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	if !v.HasCount {
+		if len(members) == 0 {
+			return msg.Peer.Writer().WriteNull()
+		}
+		return msg.Peer.Writer().WriteString(members[0])
+	}
+
+	return writeSet(msg.Peer, stringValues(members))
+}
+
+func srandmemberCommandHandler(s *Server, v proto.SrandmemberCommand, msg peer.Message) error {
+	// The members come by batches, the reply is streamed as they do.
+	replied := false
+	err := s.db(msg.Peer).SRandMember(v.Key, int(v.Count), func(n int, members []string) error {
+		if !v.HasCount {
+			replied = true
+			if n == 0 {
+				return msg.Peer.Writer().WriteNull()
+			}
+			return msg.Peer.Writer().WriteString(members[0])
+		}
+
+		var b []byte
+		if !replied {
+			b = serdes.AppendArrayLen(b, n)
+			replied = true
+		}
+		for _, member := range members {
+			b = serdes.AppendBulk(b, member)
+		}
+		_, err := msg.Peer.Send(b)
+		return err
+	})
+	if err != nil && !replied {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return err
+}
+
+func smoveCommandHandler(s *Server, v proto.SmoveCommand, msg peer.Message) error {
+	ok, err := s.db(msg.Peer).SMove(v.Source, v.Destination, v.Member)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(ok))
+}
+
+func setOpCommandHandler(s *Server, v proto.SetOpCommand, msg peer.Message) error {
+	if v.Destination != "" {
+		res, err := s.db(msg.Peer).SetOpStore(setOps[v.Op], v.Destination, v.Keys)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		return msg.Peer.Writer().WriteInteger(res)
+	}
+
+	members, err := s.db(msg.Peer).SetOp(setOps[v.Op], v.Keys)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeSet(msg.Peer, stringValues(members))
+}
+
+func sintercardCommandHandler(s *Server, v proto.SintercardCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SInterCard(v.Keys, int(v.Limit))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func sscanCommandHandler(s *Server, v proto.SscanCommand, msg peer.Message) error {
+	cursor, members, err := s.db(msg.Peer).SScan(v.Key, v.Cursor, v.Match, int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeScanReply(msg.Peer, cursor, members)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSetCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if n := rdb.SAdd(ctx, "set:a", "1", "2", "3", "2").Val(); n != 3 {
+		t.Fatalf("expected 3 new members but got %d", n)
+	}
+	if enc := rdb.ObjectEncoding(ctx, "set:a").Val(); enc != "intset" {
+		t.Fatalf("expected intset but got %s", enc)
+	}
+	if !rdb.SIsMember(ctx, "set:a", "2").Val() {
+		t.Fatal("expected 2 to be a member")
+	}
+	if found := rdb.SMIsMember(ctx, "set:a", "1", "4").Val(); !reflect.DeepEqual(found, []bool{true, false}) {
+		t.Fatalf("unexpected SMISMEMBER reply: %v", found)
+	}
+
+	rdb.SAdd(ctx, "set:b", "2", "3", "x")
+	if enc := rdb.ObjectEncoding(ctx, "set:b").Val(); enc != "hashtable" {
+		t.Fatalf("expected hashtable but got %s", enc)
+	}
+
+	inter := rdb.SInter(ctx, "set:a", "set:b").Val()
+	sort.Strings(inter)
+	if !reflect.DeepEqual(inter, []string{"2", "3"}) {
+		t.Fatalf("unexpected SINTER reply: %v", inter)
+	}
+	union := rdb.SUnion(ctx, "set:a", "set:b").Val()
+	sort.Strings(union)
+	if !reflect.DeepEqual(union, []string{"1", "2", "3", "x"}) {
+		t.Fatalf("unexpected SUNION reply: %v", union)
+	}
+	if diff := rdb.SDiff(ctx, "set:a", "set:b").Val(); !reflect.DeepEqual(diff, []string{"1"}) {
+		t.Fatalf("unexpected SDIFF reply: %v", diff)
+	}
+	if n := rdb.SInterCard(ctx, 1, "set:a", "set:b").Val(); n != 1 {
+		t.Fatalf("expected the LIMIT to stop at 1 but got %d", n)
+	}
+	if n := rdb.SUnionStore(ctx, "set:c", "set:a", "set:b").Val(); n != 4 {
+		t.Fatalf("expected 4 stored members but got %d", n)
+	}
+	if n := rdb.SInterStore(ctx, "set:c", "set:a", "set:missing").Val(); n != 0 {
+		t.Fatalf("expected an empty intersection but got %d", n)
+	}
+	if n := rdb.Exists(ctx, "set:c").Val(); n != 0 {
+		t.Fatal("expected the empty destination to be deleted")
+	}
+
+	if !rdb.SMove(ctx, "set:a", "set:d", "1").Val() {
+		t.Fatal("expected SMOVE to move the member")
+	}
+	if n := rdb.SCard(ctx, "set:a").Val(); n != 2 {
+		t.Fatalf("expected 2 members left but got %d", n)
+	}
+
+	if member := rdb.SPop(ctx, "set:d").Val(); member != "1" {
+		t.Fatalf("expected 1 but got %s", member)
+	}
+	if err := rdb.SPop(ctx, "set:d").Err(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+	if members := rdb.SRandMemberN(ctx, "set:b", -5).Val(); len(members) != 5 {
+		t.Fatalf("expected 5 random members but got %v", members)
+	}
+
+	if err := rdb.SInterCard(ctx, -1, "set:a").Err(); err == nil || err.Error() != "ERR LIMIT can't be negative" {
+		t.Fatalf("expected a negative LIMIT error but got %v", err)
+	}
+	rdb.Set(ctx, "set:string", "value", 0)
+	if err := rdb.SAdd(ctx, "set:string", "1").Err(); err == nil || err.Error() != "WRONGTYPE Operation against a key holding the wrong kind of value" {
+		t.Fatalf("expected a wrong type error but got %v", err)
+	}
+}
+
+func TestSetConversion(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	members := make([]interface{}, 0, 513)
+	for i := 0; i < 513; i++ {
+		members = append(members, strconv.Itoa(i))
+	}
+	rdb.SAdd(ctx, "set:big", members[:512]...)
+	if enc := rdb.ObjectEncoding(ctx, "set:big").Val(); enc != "intset" {
+		t.Fatalf("expected intset but got %s", enc)
+	}
+	rdb.SAdd(ctx, "set:big", members[512])
+	if enc := rdb.ObjectEncoding(ctx, "set:big").Val(); enc != "hashtable" {
+		t.Fatalf("expected hashtable but got %s", enc)
+	}
+
+	seen := map[string]bool{}
+	var cursor uint64
+	for {
+		keys, next, err := rdb.SScan(ctx, "set:big", cursor, "", 50).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			seen[key] = true
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	if len(seen) != 513 {
+		t.Fatalf("expected SSCAN to return the 513 members but got %d", len(seen))
+	}
+
+	if popped := rdb.SPopN(ctx, "set:big", 600).Val(); len(popped) != 513 {
+		t.Fatalf("expected the 513 members but got %d", len(popped))
+	}
+	if n := rdb.Exists(ctx, "set:big").Val(); n != 0 {
+		t.Fatal("expected the emptied set to be deleted")
+	}
+}
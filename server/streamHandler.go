@@ -0,0 +1,444 @@
+package server
+
+import (
+	"fmt"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func xaddCommandHandler(s *Server, v proto.XaddCommand, msg peer.Message) error {
+	args := keyval.XAddArgs{
+		ID:         keyval.StreamID(v.ID),
+		AutoID:     v.AutoID,
+		AutoSeq:    v.AutoSeq,
+		NoMkStream: v.NoMkStream,
+	}
+	if v.Trim != nil {
+		trim := streamTrim(*v.Trim)
+		args.Trim = &trim
+	}
+	id, ok, err := s.db(msg.Peer).XAdd(v.Key, args, v.Fields)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteString(id.String())
+}
+
+func xtrimCommandHandler(s *Server, v proto.XtrimCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).XTrim(v.Key, streamTrim(v.StreamTrim))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func xlenCommandHandler(s *Server, v proto.XlenCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).XLen(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func xdelCommandHandler(s *Server, v proto.XdelCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).XDel(v.Key, streamIDs(v.IDs))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func xrangeCommandHandler(s *Server, v proto.XrangeCommand, msg peer.Message) error {
+	if v.Count == 0 {
+		return msg.Peer.Writer().WriteArray([]serdes.Value{})
+	}
+	entries, err := s.db(msg.Peer).XRange(v.Key, keyval.StreamID(v.Start), keyval.StreamID(v.End), v.Rev, int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	_, err = msg.Peer.Send(appendEntries(nil, msg.Peer.Protocol, entries))
+	return err
+}
+
+// xreadCommandHandler replies with the entries following the given IDs, or
+// with BLOCK parks the peer until one of the streams gets new entries.
+func xreadCommandHandler(s *Server, v proto.XreadCommand, msg peer.Message) error {
+	// $ stands for the last ID of the stream when XREAD is called, the blocked
+	// peer waits for the entries added after it.
+	ids := append([]proto.StreamID{}, v.IDs...)
+	for i, key := range v.Keys {
+		if v.Last[i] {
+			last, err := s.db(msg.Peer).XLastID(key)
+			if err != nil {
+				return msg.Peer.Writer().WriteError(err)
+			}
+			ids[i] = proto.StreamID(last)
+		}
+	}
+	v.IDs = ids
+
+	reads, err := s.db(msg.Peer).XRead(v.Keys, streamIDs(v.IDs), int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if len(reads) == 0 && v.Block {
+		msg.Cmd = v
+		s.block(msg, v.Keys, v.Timeout)
+		return nil
+	}
+
+	return writeStreamReads(msg.Peer, reads)
+}
+
+// xreadgroupCommandHandler reads on behalf of a consumer of a group, with
+// BLOCK the peer is parked until new entries are added to one of the streams.
+func xreadgroupCommandHandler(s *Server, v proto.XreadgroupCommand, msg peer.Message) error {
+	reads, err := s.db(msg.Peer).XReadGroup(v.Group, v.Consumer, v.Keys, streamIDs(v.IDs), v.NewOnly, int(v.Count), v.NoAck)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if len(reads) == 0 && v.Block {
+		s.block(msg, v.Keys, v.Timeout)
+		return nil
+	}
+
+	return writeStreamReads(msg.Peer, reads)
+}
+
+func xgroupCommandHandler(s *Server, v proto.XgroupCommand, msg peer.Message) error {
+	var (
+		reply serdes.Value
+		err   error
+	)
+	switch v.Subcommand {
+	case "CREATE":
+		err = s.db(msg.Peer).XGroupCreate(v.Key, v.Group, keyval.StreamID(v.ID), v.UseLast, v.MkStream, v.EntriesRead)
+		reply = serdes.SimpleStringValue("OK")
+	case "SETID":
+		err = s.db(msg.Peer).XGroupSetID(v.Key, v.Group, keyval.StreamID(v.ID), v.UseLast, v.EntriesRead)
+		reply = serdes.SimpleStringValue("OK")
+	case "DESTROY":
+		var ok bool
+		ok, err = s.db(msg.Peer).XGroupDestroy(v.Key, v.Group)
+		reply = integerBool(ok)
+	case "CREATECONSUMER":
+		var ok bool
+		ok, err = s.db(msg.Peer).XGroupCreateConsumer(v.Key, v.Group, v.Consumer)
+		reply = integerBool(ok)
+	case "DELCONSUMER":
+		var n int
+		n, err = s.db(msg.Peer).XGroupDelConsumer(v.Key, v.Group, v.Consumer)
+		reply = serdes.IntegerValue(int64(n))
+	}
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(reply)
+}
+
+func xackCommandHandler(s *Server, v proto.XackCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).XAck(v.Key, v.Group, streamIDs(v.IDs))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func xpendingCommandHandler(s *Server, v proto.XpendingCommand, msg peer.Message) error {
+	if !v.Extended {
+		summary, err := s.db(msg.Peer).XPendingSummary(v.Key, v.Group)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		b := serdes.AppendInteger(serdes.AppendArrayLen(nil, 4), int64(summary.Count))
+		if summary.Count == 0 {
+			for i := 0; i < 3; i++ {
+				b = serdes.AppendNull(b, msg.Peer.Protocol)
+			}
+		} else {
+			b = serdes.AppendBulk(serdes.AppendBulk(b, summary.Lowest.String()), summary.Highest.String())
+			b = serdes.AppendArrayLen(b, len(summary.Consumers))
+			for _, c := range summary.Consumers {
+				b = serdes.AppendBulk(serdes.AppendBulk(serdes.AppendArrayLen(b, 2), c.Name), fmt.Sprint(c.Pending))
+			}
+		}
+		_, err = msg.Peer.Send(b)
+		return err
+	}
+
+	pending, err := s.db(msg.Peer).XPending(v.Key, v.Group, keyval.XPendingArgs{
+		MinIdle:  v.MinIdle,
+		Start:    keyval.StreamID(v.Start),
+		End:      keyval.StreamID(v.End),
+		Count:    int(v.Count),
+		Consumer: v.Consumer,
+	})
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	b := serdes.AppendArrayLen(nil, len(pending))
+	for _, p := range pending {
+		b = serdes.AppendBulk(serdes.AppendBulk(serdes.AppendArrayLen(b, 4), p.ID.String()), p.Consumer)
+		b = serdes.AppendInteger(serdes.AppendInteger(b, p.Idle), p.DeliveryCount)
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+func xclaimCommandHandler(s *Server, v proto.XclaimCommand, msg peer.Message) error {
+	args := keyval.XClaimArgs{
+		DeliveryTime: v.Time,
+		RetryCount:   v.RetryCount,
+		Force:        v.Force,
+		JustID:       v.JustID,
+	}
+	if v.Idle >= 0 {
+		args.DeliveryTime = keyval.Now() - v.Idle
+	}
+	if v.LastID != nil {
+		lastID := keyval.StreamID(*v.LastID)
+		args.LastID = &lastID
+	}
+	entries, err := s.db(msg.Peer).XClaim(v.Key, v.Group, v.Consumer, v.MinIdle, streamIDs(v.IDs), args)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	if v.JustID {
+		_, err = msg.Peer.Send(appendEntryIDs(nil, entries))
+	} else {
+		_, err = msg.Peer.Send(appendEntries(nil, msg.Peer.Protocol, entries))
+	}
+	return err
+}
+
+func xautoclaimCommandHandler(s *Server, v proto.XautoclaimCommand, msg peer.Message) error {
+	next, claimed, deleted, err := s.db(msg.Peer).XAutoClaim(v.Key, v.Group, v.Consumer, v.MinIdle, keyval.StreamID(v.Start), int(v.Count), v.JustID)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	b := serdes.AppendBulk(serdes.AppendArrayLen(nil, 3), next.String())
+	if v.JustID {
+		b = appendEntryIDs(b, claimed)
+	} else {
+		b = appendEntries(b, msg.Peer.Protocol, claimed)
+	}
+	b = serdes.AppendArrayLen(b, len(deleted))
+	for _, id := range deleted {
+		b = serdes.AppendBulk(b, id.String())
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+func xinfoCommandHandler(s *Server, v proto.XinfoCommand, msg peer.Message) error {
+	p := msg.Peer
+	var b []byte
+	switch v.Subcommand {
+	case "STREAM":
+		info, err := s.db(msg.Peer).XInfoStream(v.Key, v.Full, int(v.Count))
+		if err != nil {
+			return p.Writer().WriteError(err)
+		}
+		b = appendStreamInfo(nil, p.Protocol, info, v.Full)
+	case "GROUPS":
+		groups, err := s.db(msg.Peer).XInfoGroups(v.Key)
+		if err != nil {
+			return p.Writer().WriteError(err)
+		}
+		b = serdes.AppendArrayLen(b, len(groups))
+		for _, g := range groups {
+			b = serdes.AppendMapLen(b, p.Protocol, 6)
+			b = serdes.AppendBulk(serdes.AppendBulk(b, "name"), g.Name)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "consumers"), int64(g.Consumers))
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "pending"), int64(g.Pending))
+			b = serdes.AppendBulk(serdes.AppendBulk(b, "last-delivered-id"), g.LastID.String())
+			b = appendGroupCounters(b, p.Protocol, g)
+		}
+	case "CONSUMERS":
+		consumers, err := s.db(msg.Peer).XInfoConsumers(v.Key, v.Group)
+		if err != nil {
+			return p.Writer().WriteError(err)
+		}
+		b = serdes.AppendArrayLen(b, len(consumers))
+		for _, c := range consumers {
+			b = serdes.AppendMapLen(b, p.Protocol, 4)
+			b = serdes.AppendBulk(serdes.AppendBulk(b, "name"), c.Name)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "pending"), int64(c.Pending))
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "idle"), c.Idle)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "inactive"), c.Inactive)
+		}
+	}
+	_, err := p.Send(b)
+
+	return err
+}
+
+// appendStreamInfo appends the reply of XINFO STREAM, laid out like redis.
+func appendStreamInfo(b []byte, protocol int, info keyval.StreamInfo, full bool) []byte {
+	fields := 10
+	if full {
+		fields = 9
+	}
+	b = serdes.AppendMapLen(b, protocol, fields)
+	b = serdes.AppendInteger(serdes.AppendBulk(b, "length"), int64(info.Length))
+	b = serdes.AppendInteger(serdes.AppendBulk(b, "radix-tree-keys"), int64(info.RadixTreeKeys))
+	b = serdes.AppendInteger(serdes.AppendBulk(b, "radix-tree-nodes"), int64(info.RadixTreeNodes))
+	b = serdes.AppendBulk(serdes.AppendBulk(b, "last-generated-id"), info.LastID.String())
+	b = serdes.AppendBulk(serdes.AppendBulk(b, "max-deleted-entry-id"), info.MaxDeletedID.String())
+	b = serdes.AppendInteger(serdes.AppendBulk(b, "entries-added"), info.EntriesAdded)
+	b = serdes.AppendBulk(serdes.AppendBulk(b, "recorded-first-entry-id"), info.FirstID.String())
+	if !full {
+		b = serdes.AppendInteger(serdes.AppendBulk(b, "groups"), int64(info.Groups))
+		for _, e := range []struct {
+			name  string
+			entry *keyval.StreamEntry
+		}{{"first-entry", info.FirstEntry}, {"last-entry", info.LastEntry}} {
+			b = serdes.AppendBulk(b, e.name)
+			if e.entry == nil {
+				b = serdes.AppendNull(b, protocol)
+			} else {
+				b = appendEntry(b, protocol, *e.entry)
+			}
+		}
+		return b
+	}
+
+	b = appendEntries(serdes.AppendBulk(b, "entries"), protocol, info.Entries)
+	b = serdes.AppendArrayLen(serdes.AppendBulk(b, "groups"), len(info.GroupsInfo))
+	for _, g := range info.GroupsInfo {
+		b = serdes.AppendMapLen(b, protocol, 7)
+		b = serdes.AppendBulk(serdes.AppendBulk(b, "name"), g.Name)
+		b = serdes.AppendBulk(serdes.AppendBulk(b, "last-delivered-id"), g.LastID.String())
+		b = appendGroupCounters(b, protocol, g)
+		b = serdes.AppendInteger(serdes.AppendBulk(b, "pel-count"), int64(g.Pending))
+		b = serdes.AppendArrayLen(serdes.AppendBulk(b, "pending"), len(g.PEL))
+		for _, p := range g.PEL {
+			b = serdes.AppendBulk(serdes.AppendBulk(serdes.AppendArrayLen(b, 4), p.ID.String()), p.Consumer)
+			b = serdes.AppendInteger(serdes.AppendInteger(b, p.DeliveryTime), p.DeliveryCount)
+		}
+		b = serdes.AppendArrayLen(serdes.AppendBulk(b, "consumers"), len(g.ConsumersInfo))
+		for _, c := range g.ConsumersInfo {
+			b = serdes.AppendMapLen(b, protocol, 5)
+			b = serdes.AppendBulk(serdes.AppendBulk(b, "name"), c.Name)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "seen-time"), c.SeenTime)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "active-time"), c.ActiveTime)
+			b = serdes.AppendInteger(serdes.AppendBulk(b, "pel-count"), int64(c.Pending))
+			b = serdes.AppendArrayLen(serdes.AppendBulk(b, "pending"), len(c.PEL))
+			for _, p := range c.PEL {
+				b = serdes.AppendBulk(serdes.AppendArrayLen(b, 3), p.ID.String())
+				b = serdes.AppendInteger(serdes.AppendInteger(b, p.DeliveryTime), p.DeliveryCount)
+			}
+		}
+	}
+
+	return b
+}
+
+// appendGroupCounters appends the entries-read and lag fields of a consumer
+// group, null when they are unknown.
+func appendGroupCounters(b []byte, protocol int, g keyval.GroupInfo) []byte {
+	b = serdes.AppendBulk(b, "entries-read")
+	if g.EntriesRead < 0 {
+		b = serdes.AppendNull(b, protocol)
+	} else {
+		b = serdes.AppendInteger(b, g.EntriesRead)
+	}
+	b = serdes.AppendBulk(b, "lag")
+	if !g.HasLag {
+		return serdes.AppendNull(b, protocol)
+	}
+	return serdes.AppendInteger(b, g.Lag)
+}
+
+// writeStreamReads writes the reply of XREAD and XREADGROUP: the entries read
+// from each stream keyed by its name, as a RESP3 map for the peers that
+// negotiated protocol 3 and as an array of [key, entries] pairs for the
+// others. Nothing read at all is a null array.
+func writeStreamReads(p *peer.Peer, reads []keyval.StreamRead) error {
+	if len(reads) == 0 {
+		return p.Writer().WriteNullArray()
+	}
+
+	var b []byte
+	if p.Protocol >= 3 {
+		b = serdes.AppendMapLen(b, p.Protocol, len(reads))
+	} else {
+		b = serdes.AppendArrayLen(b, len(reads))
+	}
+	for _, r := range reads {
+		if p.Protocol < 3 {
+			b = serdes.AppendArrayLen(b, 2)
+		}
+		b = appendEntries(serdes.AppendBulk(b, r.Key), p.Protocol, r.Entries)
+	}
+	_, err := p.Send(b)
+
+	return err
+}
+
+// appendEntries appends stream entries as an array of [id, [field, value, ...]],
+// the fields of an entry deleted while pending being null.
+func appendEntries(b []byte, protocol int, entries []keyval.StreamEntry) []byte {
+	b = serdes.AppendArrayLen(b, len(entries))
+	for _, e := range entries {
+		b = appendEntry(b, protocol, e)
+	}
+	return b
+}
+
+func appendEntry(b []byte, protocol int, e keyval.StreamEntry) []byte {
+	b = serdes.AppendBulk(serdes.AppendArrayLen(b, 2), e.ID.String())
+	if e.Fields == nil {
+		return serdes.AppendNullArray(b, protocol)
+	}
+	b = serdes.AppendArrayLen(b, len(e.Fields))
+	for _, f := range e.Fields {
+		b = serdes.AppendBulk(b, f)
+	}
+	return b
+}
+
+// appendEntryIDs appends only the IDs of the entries, that's the JUSTID reply.
+func appendEntryIDs(b []byte, entries []keyval.StreamEntry) []byte {
+	b = serdes.AppendArrayLen(b, len(entries))
+	for _, e := range entries {
+		b = serdes.AppendBulk(b, e.ID.String())
+	}
+	return b
+}
+
+func streamIDs(ids []proto.StreamID) []keyval.StreamID {
+	ret := make([]keyval.StreamID, 0, len(ids))
+	for _, id := range ids {
+		ret = append(ret, keyval.StreamID(id))
+	}
+	return ret
+}
+
+func streamTrim(t proto.StreamTrim) keyval.StreamTrim {
+	return keyval.StreamTrim{
+		MaxLen:  t.MaxLen,
+		ByMinID: t.ByMinID,
+		MinID:   keyval.StreamID(t.MinID),
+		Approx:  t.Approx,
+		Limit:   t.Limit,
+	}
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStreamCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	for i, id := range []string{"1-1", "1-2", "2-0"} {
+		if got := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:log", ID: id, Values: []string{"n", string(rune('a' + i))}}).Val(); got != id {
+			t.Fatalf("expected XADD to return %s but got %s", id, got)
+		}
+	}
+	if got := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:log", ID: "2-*", Values: []string{"n", "d"}}).Val(); got != "2-1" {
+		t.Fatalf("expected 2-1 but got %s", got)
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:log", ID: "1-5", Values: []string{"n", "x"}}).Err(); err == nil ||
+		err.Error() != "ERR The ID specified in XADD is equal or smaller than the target stream top item" {
+		t.Fatalf("expected an ID too small error but got %v", err)
+	}
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:missing", NoMkStream: true, Values: []string{"n", "x"}}).Err(); err != redis.Nil {
+		t.Fatalf("expected NOMKSTREAM on a missing key to reply nil but got %v", err)
+	}
+	if n := rdb.XLen(ctx, "stream:log").Val(); n != 4 {
+		t.Fatalf("expected 4 entries but got %d", n)
+	}
+
+	expected := []redis.XMessage{
+		{ID: "1-2", Values: map[string]any{"n": "b"}},
+		{ID: "2-0", Values: map[string]any{"n": "c"}},
+	}
+	if entries := rdb.XRangeN(ctx, "stream:log", "(1-1", "+", 2).Val(); !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("unexpected XRANGE reply: %v", entries)
+	}
+	if entries := rdb.XRevRange(ctx, "stream:log", "2", "1-2").Val(); len(entries) != 3 || entries[0].ID != "2-1" {
+		t.Fatalf("unexpected XREVRANGE reply: %v", entries)
+	}
+
+	if n := rdb.XDel(ctx, "stream:log", "1-1", "9-9").Val(); n != 1 {
+		t.Fatalf("expected 1 deleted entry but got %d", n)
+	}
+	if n := rdb.XTrimMaxLen(ctx, "stream:log", 2).Val(); n != 1 {
+		t.Fatalf("expected XTRIM to remove 1 entry but got %d", n)
+	}
+	if n := rdb.XTrimMinID(ctx, "stream:log", "2-1").Val(); n != 1 {
+		t.Fatalf("expected XTRIM MINID to remove 1 entry but got %d", n)
+	}
+
+	streams := rdb.XRead(ctx, &redis.XReadArgs{Streams: []string{"stream:log", "stream:missing", "0", "0"}, Block: -1}).Val()
+	if len(streams) != 1 || streams[0].Stream != "stream:log" || len(streams[0].Messages) != 1 || streams[0].Messages[0].ID != "2-1" {
+		t.Fatalf("unexpected XREAD reply: %v", streams)
+	}
+
+	if typ := rdb.Type(ctx, "stream:log").Val(); typ != "stream" {
+		t.Fatalf("expected type stream but got %s", typ)
+	}
+	info := rdb.XInfoStream(ctx, "stream:log").Val()
+	if info.Length != 1 || info.LastGeneratedID != "2-1" || info.MaxDeletedEntryID != "1-1" || info.EntriesAdded != 4 || info.FirstEntry.ID != "2-1" {
+		t.Fatalf("unexpected XINFO STREAM reply: %+v", info)
+	}
+}
+
+func TestStreamConsumerGroups(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if err := rdb.XGroupCreate(ctx, "stream:jobs", "workers", "0").Err(); err == nil {
+		t.Fatal("expected XGROUP CREATE on a missing key to fail")
+	}
+	if err := rdb.XGroupCreateMkStream(ctx, "stream:jobs", "workers", "$").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rdb.XGroupCreate(ctx, "stream:jobs", "workers", "$").Err(); err == nil || err.Error() != "BUSYGROUP Consumer Group name already exists" {
+		t.Fatalf("expected a BUSYGROUP error but got %v", err)
+	}
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:jobs", ID: id, Values: []string{"job", id}})
+	}
+
+	streams := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers", Consumer: "alice", Streams: []string{"stream:jobs", ">"}, Count: 2}).Val()
+	if len(streams) != 1 || len(streams[0].Messages) != 2 {
+		t.Fatalf("unexpected XREADGROUP reply: %v", streams)
+	}
+	rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers", Consumer: "bob", Streams: []string{"stream:jobs", ">"}})
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers", Consumer: "bob", Streams: []string{"stream:jobs", ">"}, Block: -1}).Err(); err != redis.Nil {
+		t.Fatalf("expected nothing new to read but got %v", err)
+	}
+	if err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "nope", Consumer: "bob", Streams: []string{"stream:jobs", ">"}, Block: -1}).Err(); err == nil ||
+		err.Error() != "NOGROUP No such key 'stream:jobs' or consumer group 'nope' in XREADGROUP with GROUP option" {
+		t.Fatalf("expected a NOGROUP error but got %v", err)
+	}
+
+	pending := rdb.XPending(ctx, "stream:jobs", "workers").Val()
+	expected := &redis.XPending{Count: 3, Lower: "1-0", Higher: "3-0", Consumers: map[string]int64{"alice": 2, "bob": 1}}
+	if !reflect.DeepEqual(pending, expected) {
+		t.Fatalf("unexpected XPENDING summary: %+v", pending)
+	}
+	if n := rdb.XAck(ctx, "stream:jobs", "workers", "1-0", "9-0").Val(); n != 1 {
+		t.Fatalf("expected 1 acknowledged entry but got %d", n)
+	}
+
+	// alice's history only holds what she still has to acknowledge.
+	history := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "workers", Consumer: "alice", Streams: []string{"stream:jobs", "0"}, Block: -1}).Val()
+	if len(history) != 1 || len(history[0].Messages) != 1 || history[0].Messages[0].ID != "2-0" {
+		t.Fatalf("unexpected history: %v", history)
+	}
+
+	ext := rdb.XPendingExt(ctx, &redis.XPendingExtArgs{Stream: "stream:jobs", Group: "workers", Start: "-", End: "+", Count: 10, Consumer: "bob"}).Val()
+	if len(ext) != 1 || ext[0].ID != "3-0" || ext[0].Consumer != "bob" || ext[0].RetryCount != 1 {
+		t.Fatalf("unexpected extended XPENDING reply: %+v", ext)
+	}
+
+	if ids := rdb.XClaimJustID(ctx, &redis.XClaimArgs{Stream: "stream:jobs", Group: "workers", Consumer: "bob", MinIdle: time.Hour, Messages: []string{"2-0"}}).Val(); len(ids) != 0 {
+		t.Fatalf("expected nothing idle for an hour to be claimed but got %v", ids)
+	}
+	claimed := rdb.XClaim(ctx, &redis.XClaimArgs{Stream: "stream:jobs", Group: "workers", Consumer: "bob", Messages: []string{"2-0"}}).Val()
+	if len(claimed) != 1 || claimed[0].ID != "2-0" {
+		t.Fatalf("unexpected XCLAIM reply: %v", claimed)
+	}
+
+	rdb.XDel(ctx, "stream:jobs", "3-0")
+	ids, next := rdb.XAutoClaimJustID(ctx, &redis.XAutoClaimArgs{Stream: "stream:jobs", Group: "workers", Consumer: "carol", Start: "0"}).Val()
+	if !reflect.DeepEqual(ids, []string{"2-0"}) || next != "0-0" {
+		t.Fatalf("unexpected XAUTOCLAIM reply: %v %s", ids, next)
+	}
+
+	groups := rdb.XInfoGroups(ctx, "stream:jobs").Val()
+	if len(groups) != 1 || groups[0].Name != "workers" || groups[0].Pending != 1 || groups[0].Consumers != 3 || groups[0].LastDeliveredID != "3-0" {
+		t.Fatalf("unexpected XINFO GROUPS reply: %+v", groups)
+	}
+	consumers := rdb.XInfoConsumers(ctx, "stream:jobs", "workers").Val()
+	if len(consumers) != 3 || consumers[2].Name != "carol" || consumers[2].Pending != 1 {
+		t.Fatalf("unexpected XINFO CONSUMERS reply: %+v", consumers)
+	}
+	if full, err := rdb.XInfoStreamFull(ctx, "stream:jobs", 0).Result(); err != nil || len(full.Groups) != 1 || len(full.Groups[0].Pending) != 1 || len(full.Entries) != 2 {
+		t.Fatalf("unexpected XINFO STREAM FULL reply: %+v (%v)", full, err)
+	}
+
+	if n := rdb.XGroupDelConsumer(ctx, "stream:jobs", "workers", "carol").Val(); n != 1 {
+		t.Fatalf("expected carol to have 1 pending entry but got %d", n)
+	}
+	if n := rdb.XGroupDestroy(ctx, "stream:jobs", "workers").Val(); n != 1 {
+		t.Fatalf("expected the group to be destroyed but got %d", n)
+	}
+}
+
+func TestBlockingStreamRead(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:events", ID: "1-0", Values: []string{"old", "1"}})
+
+	type result struct {
+		streams []redis.XStream
+		err     error
+	}
+	ch := make(chan result)
+	waiter := newTestClient(t)
+	go func() {
+		streams, err := waiter.XRead(ctx, &redis.XReadArgs{Streams: []string{"stream:events", "$"}, Block: 0}).Result()
+		ch <- result{streams, err}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	rdb.XAdd(ctx, &redis.XAddArgs{Stream: "stream:events", ID: "2-0", Values: []string{"new", "2"}})
+	select {
+	case res := <-ch:
+		if res.err != nil || len(res.streams) != 1 || len(res.streams[0].Messages) != 1 || res.streams[0].Messages[0].ID != "2-0" {
+			t.Fatalf("unexpected blocked XREAD reply: %v (%v)", res.streams, res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the blocked reader was never served")
+	}
+
+	if err := rdb.Do(ctx, "XREAD", "BLOCK", "100", "STREAMS", "stream:events", "$").Err(); err != redis.Nil {
+		t.Fatalf("expected the blocked XREAD to time out but got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"fmt"
+
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+func incrbyCommandHandler(s *Server, v proto.IncrbyCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).IncrBy(v.Key, v.Delta)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(int(res))
+}
+
+func incrbyfloatCommandHandler(s *Server, v proto.IncrbyfloatCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).IncrByFloat(v.Key, v.Delta)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteBytes(res)
+}
+
+func mgetCommandHandler(s *Server, v proto.MgetCommand, msg peer.Message) error {
+	values := s.db(msg.Peer).MGet(v.Keys)
+	ret := make([]serdes.Value, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			ret = append(ret, serdes.NullValue())
+		} else {
+			ret = append(ret, serdes.BytesValue(value))
+		}
+	}
+
+	return msg.Peer.Writer().WriteArray(ret)
+}
+
+func msetCommandHandler(s *Server, v proto.MsetCommand, msg peer.Message) error {
+	ok := s.db(msg.Peer).MSet(v.Pairs, v.NX)
+	if v.NX {
+		return msg.Peer.Writer().WriteValue(integerBool(ok))
+	}
+
+	return msg.Peer.Writer().WriteSimpleString("OK")
+}
+
+func setnxCommandHandler(s *Server, v proto.SetnxCommand, msg peer.Message) error {
+	_, _, written, err := s.db(msg.Peer).SetWithOptions(v.Key, v.Value, keyval.SetOptions{NX: true})
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteValue(integerBool(written))
+}
+
+func getdelCommandHandler(s *Server, v proto.GetdelCommand, msg peer.Message) error {
+	value, ok, err := s.db(msg.Peer).GetDel(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteBytes(value)
+}
+
+func getexCommandHandler(s *Server, v proto.GetexCommand, msg peer.Message) error {
+	var at int64
+	if v.Expire != 0 {
+		var ok bool
+		if at, ok = absoluteExpireTime(v.Expire, v.ExpireMillis, v.ExpireAt); !ok {
+			return msg.Peer.Writer().WriteError(fmt.Errorf("ERR invalid expire time in 'getex' command"))
+		}
+	}
+
+	value, ok, err := s.db(msg.Peer).GetEx(v.Key, at, v.Persist)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+
+	return msg.Peer.Writer().WriteBytes(value)
+}
+
+func appendCommandHandler(s *Server, v proto.AppendCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).Append(v.Key, v.Value)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func strlenCommandHandler(s *Server, v proto.StrlenCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).StrLen(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func getrangeCommandHandler(s *Server, v proto.GetrangeCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).GetRange(v.Key, v.Start, v.End)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteBytes(res)
+}
+
+func setrangeCommandHandler(s *Server, v proto.SetrangeCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).SetRange(v.Key, v.Offset, v.Value)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+// lcsCommandHandler replies with the longest common subsequence itself, its
+// length with LEN, or with IDX the matching ranges and the length.
+func lcsCommandHandler(s *Server, v proto.LcsCommand, msg peer.Message) error {
+	seq, matches, err := s.db(msg.Peer).LCS(v.Key1, v.Key2)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	switch {
+	case v.Len:
+		return msg.Peer.Writer().WriteInteger(len(seq))
+	case !v.Idx:
+		return msg.Peer.Writer().WriteBytes(seq)
+	}
+
+	ranges := []serdes.Value{}
+	for _, m := range matches {
+		if int64(m.Len) < v.MinMatchLen {
+			continue
+		}
+		match := []serdes.Value{
+			serdes.ArrayValue([]serdes.Value{serdes.IntegerValue(int64(m.A[0])), serdes.IntegerValue(int64(m.A[1]))}),
+			serdes.ArrayValue([]serdes.Value{serdes.IntegerValue(int64(m.B[0])), serdes.IntegerValue(int64(m.B[1]))}),
+		}
+		if v.WithMatchLen {
+			match = append(match, serdes.IntegerValue(int64(m.Len)))
+		}
+		ranges = append(ranges, serdes.ArrayValue(match))
+	}
+
+	return writeMap(msg.Peer, []serdes.Value{
+		serdes.StringValue("matches"), serdes.ArrayValue(ranges),
+		serdes.StringValue("len"), serdes.IntegerValue(int64(len(seq))),
+	})
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestStringArithmetic(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if n := rdb.Incr(ctx, "string:counter").Val(); n != 1 {
+		t.Fatalf("expected INCR on a missing key to give 1 but got %d", n)
+	}
+	if n := rdb.DecrBy(ctx, "string:counter", 10).Val(); n != -9 {
+		t.Fatalf("expected -9 but got %d", n)
+	}
+	if n := rdb.IncrBy(ctx, "string:counter", 4).Val(); n != -5 {
+		t.Fatalf("expected -5 but got %d", n)
+	}
+	if n := rdb.Decr(ctx, "string:missing").Val(); n != -1 {
+		t.Fatalf("expected DECR on a missing key to give -1 but got %d", n)
+	}
+
+	rdb.Set(ctx, "string:max", math.MaxInt64, 0)
+	if err := rdb.Incr(ctx, "string:max").Err(); err == nil || err.Error() != "ERR increment or decrement would overflow" {
+		t.Fatalf("expected an overflow error but got %v", err)
+	}
+	if err := rdb.Do(ctx, "DECRBY", "string:counter", "-9223372036854775808").Err(); err == nil || err.Error() != "ERR decrement would overflow" {
+		t.Fatalf("expected a decrement overflow error but got %v", err)
+	}
+	rdb.Set(ctx, "string:text", "12 ", 0)
+	if err := rdb.Incr(ctx, "string:text").Err(); err == nil || err.Error() != "ERR value is not an integer or out of range" {
+		t.Fatalf("expected a not an integer error but got %v", err)
+	}
+
+	// INCR keeps the time to live of the key.
+	rdb.Set(ctx, "string:volatile", "5", time.Minute)
+	rdb.Incr(ctx, "string:volatile")
+	if ttl := rdb.TTL(ctx, "string:volatile").Val(); ttl <= 0 {
+		t.Fatalf("expected INCR to keep the ttl but got %v", ttl)
+	}
+
+	rdb.Set(ctx, "string:float", "10.5", 0)
+	if f := rdb.IncrByFloat(ctx, "string:float", 0.1).Val(); f != 10.6 {
+		t.Fatalf("expected 10.6 but got %v", f)
+	}
+	if s := rdb.Get(ctx, "string:float").Val(); s != "10.6" {
+		t.Fatalf("expected 10.6 to be stored but got %s", s)
+	}
+	if f := rdb.IncrByFloat(ctx, "string:float", 5.0e3).Val(); f != 5010.6 {
+		t.Fatalf("expected 5010.6 but got %v", f)
+	}
+	if err := rdb.IncrByFloat(ctx, "string:text", 1).Err(); err == nil || err.Error() != "ERR value is not a valid float" {
+		t.Fatalf("expected a not a valid float error but got %v", err)
+	}
+}
+
+func TestStringCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	if err := rdb.MSet(ctx, "string:a", "1", "string:b", "2").Err(); err != nil {
+		t.Fatal(err)
+	}
+	rdb.RPush(ctx, "string:list", "x")
+	values := rdb.MGet(ctx, "string:a", "string:list", "string:b", "string:none").Val()
+	if !reflect.DeepEqual(values, []any{"1", nil, "2", nil}) {
+		t.Fatalf("unexpected MGET reply: %v", values)
+	}
+	if ok := rdb.MSetNX(ctx, "string:c", "3", "string:a", "x").Val(); ok {
+		t.Fatal("expected MSETNX to fail as string:a exists")
+	}
+	if rdb.Exists(ctx, "string:c").Val() != 0 {
+		t.Fatal("expected MSETNX to set nothing")
+	}
+	if ok := rdb.SetNX(ctx, "string:a", "x", 0).Val(); ok {
+		t.Fatal("expected SETNX to fail on an existing key")
+	}
+
+	if err := rdb.SetEx(ctx, "string:ex", "v", time.Minute).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := rdb.TTL(ctx, "string:ex").Val(); ttl != time.Minute {
+		t.Fatalf("expected a ttl of one minute but got %v", ttl)
+	}
+	if err := rdb.Do(ctx, "PSETEX", "string:ex", "0", "v").Err(); err == nil || err.Error() != "ERR invalid expire time in 'psetex' command" {
+		t.Fatalf("expected an invalid expire time error but got %v", err)
+	}
+	if old := rdb.GetSet(ctx, "string:ex", "w").Val(); old != "v" {
+		t.Fatalf("expected GETSET to return v but got %s", old)
+	}
+
+	rdb.Set(ctx, "string:getex", "v", 0)
+	if v := rdb.GetEx(ctx, "string:getex", time.Hour).Val(); v != "v" {
+		t.Fatalf("expected v but got %s", v)
+	}
+	if ttl := rdb.TTL(ctx, "string:getex").Val(); ttl != time.Hour {
+		t.Fatalf("expected GETEX to set a one hour ttl but got %v", ttl)
+	}
+	rdb.Do(ctx, "GETEX", "string:getex", "PERSIST")
+	if ttl := rdb.TTL(ctx, "string:getex").Val(); ttl != -1 {
+		t.Fatalf("expected GETEX PERSIST to drop the ttl but got %v", ttl)
+	}
+	if v := rdb.GetDel(ctx, "string:getex").Val(); v != "v" || rdb.Exists(ctx, "string:getex").Val() != 0 {
+		t.Fatalf("expected GETDEL to return v and delete the key but got %s", v)
+	}
+
+	if n := rdb.Append(ctx, "string:greeting", "Hello").Val(); n != 5 {
+		t.Fatalf("expected 5 but got %d", n)
+	}
+	if n := rdb.Append(ctx, "string:greeting", " World").Val(); n != 11 {
+		t.Fatalf("expected 11 but got %d", n)
+	}
+	if n := rdb.StrLen(ctx, "string:greeting").Val(); n != 11 {
+		t.Fatalf("expected 11 but got %d", n)
+	}
+	for _, tc := range []struct {
+		start, end int64
+		expected   string
+	}{{0, 4, "Hello"}, {-5, -1, "World"}, {-1, -5, ""}, {5, 100, " World"}, {20, 30, ""}} {
+		if s := rdb.GetRange(ctx, "string:greeting", tc.start, tc.end).Val(); s != tc.expected {
+			t.Fatalf("GETRANGE %d %d: expected %q but got %q", tc.start, tc.end, tc.expected, s)
+		}
+	}
+	if n := rdb.SetRange(ctx, "string:greeting", 6, "Redis").Val(); n != 11 {
+		t.Fatalf("expected 11 but got %d", n)
+	}
+	if n := rdb.SetRange(ctx, "string:padded", 3, "x").Val(); n != 4 || rdb.Get(ctx, "string:padded").Val() != "\x00\x00\x00x" {
+		t.Fatalf("expected SETRANGE to pad with zero bytes but got %d", n)
+	}
+	if err := rdb.SetRange(ctx, "string:padded", -1, "x").Err(); err == nil || err.Error() != "ERR offset is out of range" {
+		t.Fatalf("expected an out of range error but got %v", err)
+	}
+	for _, key := range []string{"string:padded", "string:missing"} {
+		if err := rdb.SetRange(ctx, key, 9223372036854775800, "abcdefghijkl").Err(); err == nil || err.Error() != "ERR string exceeds maximum allowed size (proto-max-bulk-len)" {
+			t.Fatalf("expected a huge offset to exceed the maximum size but got %v", err)
+		}
+	}
+	if s := rdb.Get(ctx, "string:greeting").Val(); s != "Hello Redis" {
+		t.Fatalf("expected Hello Redis but got %s", s)
+	}
+}
+
+func TestLCS(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	rdb.MSet(ctx, "lcs:a", "ohmytext", "lcs:b", "mynewtext")
+	if s := rdb.LCS(ctx, &redis.LCSQuery{Key1: "lcs:a", Key2: "lcs:b"}).Val(); s.MatchString != "mytext" {
+		t.Fatalf("expected mytext but got %q", s.MatchString)
+	}
+	if s := rdb.LCS(ctx, &redis.LCSQuery{Key1: "lcs:a", Key2: "lcs:b", Len: true}).Val(); s.Len != 6 {
+		t.Fatalf("expected a length of 6 but got %d", s.Len)
+	}
+
+	res, err := rdb.LCS(ctx, &redis.LCSQuery{Key1: "lcs:a", Key2: "lcs:b", Idx: true, WithMatchLen: true}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []redis.LCSMatchedPosition{
+		{Key1: redis.LCSPosition{Start: 4, End: 7}, Key2: redis.LCSPosition{Start: 5, End: 8}, MatchLen: 4},
+		{Key1: redis.LCSPosition{Start: 2, End: 3}, Key2: redis.LCSPosition{Start: 0, End: 1}, MatchLen: 2},
+	}
+	if res.Len != 6 || !reflect.DeepEqual(res.Matches, expected) {
+		t.Fatalf("unexpected LCS IDX reply: %+v", res)
+	}
+	res = rdb.LCS(ctx, &redis.LCSQuery{Key1: "lcs:a", Key2: "lcs:b", Idx: true, MinMatchLen: 3}).Val()
+	if len(res.Matches) != 1 || res.Matches[0].Key1.Start != 4 {
+		t.Fatalf("expected MINMATCHLEN to keep only the first match but got %+v", res)
+	}
+}
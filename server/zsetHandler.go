@@ -0,0 +1,261 @@
+package server
+
+import (
+	"redis-clone/keyval"
+	"redis-clone/peer"
+	"redis-clone/proto"
+	"redis-clone/serdes"
+)
+
+var (
+	zsetOps = map[string]keyval.SetOp{
+		proto.CommandZINTER: keyval.SetInter,
+		proto.CommandZUNION: keyval.SetUnion,
+		proto.CommandZDIFF:  keyval.SetDiff,
+	}
+	zsetAggregates = map[string]keyval.ZAggregate{
+		"SUM": keyval.AggregateSum,
+		"MIN": keyval.AggregateMin,
+		"MAX": keyval.AggregateMax,
+	}
+)
+
+func zaddCommandHandler(s *Server, v proto.ZaddCommand, msg peer.Message) error {
+	opts := keyval.ZAddOptions{NX: v.NX, XX: v.XX, GT: v.GT, LT: v.LT, CH: v.CH}
+	if v.Incr {
+		score, ok, err := s.db(msg.Peer).ZIncrBy(v.Key, opts, v.Members[0].Member, v.Members[0].Score)
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		if !ok {
+			return msg.Peer.Writer().WriteNull()
+		}
+		return writeScore(msg.Peer, score)
+	}
+
+	members := make([]keyval.ZMember, 0, len(v.Members))
+	for _, m := range v.Members {
+		members = append(members, keyval.ZMember{Member: m.Member, Score: m.Score})
+	}
+	res, err := s.db(msg.Peer).ZAdd(v.Key, opts, members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func zincrbyCommandHandler(s *Server, v proto.ZincrbyCommand, msg peer.Message) error {
+	score, _, err := s.db(msg.Peer).ZIncrBy(v.Key, keyval.ZAddOptions{}, v.Member, v.Increment)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeScore(msg.Peer, score)
+}
+
+func zcardCommandHandler(s *Server, v proto.ZcardCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).ZCard(v.Key)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func zscoreCommandHandler(s *Server, v proto.ZscoreCommand, msg peer.Message) error {
+	scores, found, err := s.db(msg.Peer).ZMScore(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !v.Multi {
+		if !found[0] {
+			return msg.Peer.Writer().WriteNull()
+		}
+		return writeScore(msg.Peer, scores[0])
+	}
+
+	b := serdes.AppendArrayLen(nil, len(scores))
+	for i, score := range scores {
+		if !found[i] {
+			b = serdes.AppendNull(b, msg.Peer.Protocol)
+			continue
+		}
+		b = serdes.AppendDouble(b, msg.Peer.Protocol, score)
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+func zrankCommandHandler(s *Server, v proto.ZrankCommand, msg peer.Message) error {
+	rank, score, ok, err := s.db(msg.Peer).ZRank(v.Key, v.Member, v.Rev)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if !ok && v.WithScore {
+		return msg.Peer.Writer().WriteNullArray()
+	}
+	if !ok {
+		return msg.Peer.Writer().WriteNull()
+	}
+	if !v.WithScore {
+		return msg.Peer.Writer().WriteInteger(rank)
+	}
+
+	_, err = msg.Peer.Send(serdes.AppendDouble(serdes.AppendInteger(serdes.AppendArrayLen(nil, 2), int64(rank)), msg.Peer.Protocol, score))
+
+	return err
+}
+
+func zrangeCommandHandler(s *Server, v proto.ZrangeCommand, msg peer.Message) error {
+	if v.Destination != "" {
+		res, err := s.db(msg.Peer).ZRangeStore(v.Destination, v.Key, zrangeSpec(v.ZrangeSpec))
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		return msg.Peer.Writer().WriteInteger(res)
+	}
+
+	members, err := s.db(msg.Peer).ZRange(v.Key, zrangeSpec(v.ZrangeSpec))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeScoredMembers(msg.Peer, members, v.WithScores)
+}
+
+func zcountCommandHandler(s *Server, v proto.ZcountCommand, msg peer.Message) error {
+	var res int
+	var err error
+	if v.By == "BYLEX" {
+		res, err = s.db(msg.Peer).ZLexCount(v.Key, keyval.LexBound(v.LexMin), keyval.LexBound(v.LexMax))
+	} else {
+		res, err = s.db(msg.Peer).ZCount(v.Key, keyval.ScoreBound(v.Min), keyval.ScoreBound(v.Max))
+	}
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func zremCommandHandler(s *Server, v proto.ZremCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).ZRem(v.Key, v.Members)
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func zremrangeCommandHandler(s *Server, v proto.ZremrangeCommand, msg peer.Message) error {
+	res, err := s.db(msg.Peer).ZRemRange(v.Key, zrangeSpec(v.ZrangeSpec))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return msg.Peer.Writer().WriteInteger(res)
+}
+
+func zpopCommandHandler(s *Server, v proto.ZpopCommand, msg peer.Message) error {
+	members, err := s.db(msg.Peer).ZPop(v.Key, v.Max, int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+	if v.HasCount {
+		return writeScoredMembers(msg.Peer, members, true)
+	}
+
+	// Without a count the member and its score are not nested, even with protocol 3.
+	b := serdes.AppendArrayLen(nil, 0)
+	if len(members) > 0 {
+		b = serdes.AppendDouble(serdes.AppendBulk(serdes.AppendArrayLen(nil, 2), members[0].Member), msg.Peer.Protocol, members[0].Score)
+	}
+	_, err = msg.Peer.Send(b)
+
+	return err
+}
+
+func zrandmemberCommandHandler(s *Server, v proto.ZrandmemberCommand, msg peer.Message) error {
+	// The members come by batches, the reply is streamed as they do.
+	replied := false
+	err := s.db(msg.Peer).ZRandMember(v.Key, int(v.Count), func(n int, members []keyval.ZMember) error {
+		if !v.HasCount {
+			replied = true
+			if n == 0 {
+				return msg.Peer.Writer().WriteNull()
+			}
+			return msg.Peer.Writer().WriteString(members[0].Member)
+		}
+
+		var b []byte
+		if !replied {
+			b = appendScoredMembersLen(b, msg.Peer.Protocol, n, v.WithScores)
+			replied = true
+		}
+		_, err := msg.Peer.Send(appendScoredMembers(b, msg.Peer.Protocol, members, v.WithScores))
+		return err
+	})
+	if err != nil && !replied {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return err
+}
+
+func zsetOpCommandHandler(s *Server, v proto.ZsetOpCommand, msg peer.Message) error {
+	if v.Destination != "" {
+		res, err := s.db(msg.Peer).ZSetOpStore(zsetOps[v.Op], v.Destination, v.Keys, v.Weights, zsetAggregates[v.Aggregate])
+		if err != nil {
+			return msg.Peer.Writer().WriteError(err)
+		}
+		return msg.Peer.Writer().WriteInteger(res)
+	}
+
+	members, err := s.db(msg.Peer).ZSetOp(zsetOps[v.Op], v.Keys, v.Weights, zsetAggregates[v.Aggregate])
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	return writeScoredMembers(msg.Peer, members, v.WithScores)
+}
+
+// zrangeSpec converts the range parsed from a command to the one of the keyspace.
+func zrangeSpec(v proto.ZrangeSpec) keyval.ZRangeSpec {
+	spec := keyval.ZRangeSpec{
+		Rev:    v.Rev,
+		Start:  v.Start,
+		Stop:   v.Stop,
+		Min:    keyval.ScoreBound(v.Min),
+		Max:    keyval.ScoreBound(v.Max),
+		LexMin: keyval.LexBound(v.LexMin),
+		LexMax: keyval.LexBound(v.LexMax),
+		Offset: v.Offset,
+		Count:  v.Count,
+	}
+	switch v.By {
+	case "BYSCORE":
+		spec.By = keyval.ZRangeByScore
+	case "BYLEX":
+		spec.By = keyval.ZRangeByLex
+	}
+
+	return spec
+}
+
+// zscanCommandHandler replies like the other SCAN commands, each member being
+// followed by its score as a bulk string whatever the protocol.
+func zscanCommandHandler(s *Server, v proto.ZscanCommand, msg peer.Message) error {
+	cursor, members, err := s.db(msg.Peer).ZScan(v.Key, v.Cursor, v.Match, int(v.Count))
+	if err != nil {
+		return msg.Peer.Writer().WriteError(err)
+	}
+
+	values := make([]string, 0, len(members)*2)
+	for _, m := range members {
+		values = append(values, m.Member, serdes.FormatDouble(m.Score))
+	}
+
+	return writeScanReply(msg.Peer, cursor, values)
+}
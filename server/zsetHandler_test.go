@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSortedSetCommands(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	n := rdb.ZAdd(ctx, "zset:board",
+		redis.Z{Score: 10, Member: "alice"},
+		redis.Z{Score: 20, Member: "bob"},
+		redis.Z{Score: 30, Member: "carol"},
+	).Val()
+	if n != 3 {
+		t.Fatalf("expected 3 new members but got %d", n)
+	}
+	if n := rdb.ZAddArgs(ctx, "zset:board", redis.ZAddArgs{GT: true, Ch: true, Members: []redis.Z{
+		{Score: 5, Member: "alice"},
+		{Score: 25, Member: "bob"},
+	}}).Val(); n != 1 {
+		t.Fatalf("expected GT to update only bob but got %d changes", n)
+	}
+	if score, err := rdb.ZAddArgsIncr(ctx, "zset:board", redis.ZAddArgs{NX: true, Members: []redis.Z{{Score: 1, Member: "alice"}}}).Result(); err != redis.Nil {
+		t.Fatalf("expected NX INCR on an existing member to reply nil but got %v %v", score, err)
+	}
+	if score := rdb.ZIncrBy(ctx, "zset:board", 2.5, "alice").Val(); score != 12.5 {
+		t.Fatalf("expected 12.5 but got %v", score)
+	}
+	if err := rdb.Do(ctx, "ZADD", "zset:board", "NX", "XX", "1", "x").Err(); err == nil ||
+		err.Error() != "ERR XX and NX options at the same time are not compatible" {
+		t.Fatalf("expected an incompatible options error but got %v", err)
+	}
+
+	expected := []redis.Z{{Score: 12.5, Member: "alice"}, {Score: 25, Member: "bob"}, {Score: 30, Member: "carol"}}
+	if members := rdb.ZRangeWithScores(ctx, "zset:board", 0, -1).Val(); !reflect.DeepEqual(members, expected) {
+		t.Fatalf("unexpected ZRANGE WITHSCORES reply: %v", members)
+	}
+	if members := rdb.ZRangeArgs(ctx, redis.ZRangeArgs{Key: "zset:board", Start: "(12.5", Stop: "+inf", ByScore: true}).Val(); !reflect.DeepEqual(members, []string{"bob", "carol"}) {
+		t.Fatalf("unexpected ZRANGE BYSCORE reply: %v", members)
+	}
+	if members := rdb.ZRangeArgs(ctx, redis.ZRangeArgs{Key: "zset:board", Start: "-inf", Stop: "+inf", ByScore: true, Rev: true, Offset: 1, Count: 1}).Val(); !reflect.DeepEqual(members, []string{"bob"}) {
+		t.Fatalf("unexpected ZRANGE BYSCORE REV LIMIT reply: %v", members)
+	}
+	if rank := rdb.ZRevRank(ctx, "zset:board", "alice").Val(); rank != 2 {
+		t.Fatalf("expected rank 2 but got %d", rank)
+	}
+	if rs := rdb.ZRankWithScore(ctx, "zset:board", "bob").Val(); rs.Rank != 1 || rs.Score != 25 {
+		t.Fatalf("unexpected ZRANK WITHSCORE reply: %v", rs)
+	}
+	if err := rdb.ZRank(ctx, "zset:board", "nobody").Err(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+	if scores := rdb.ZMScore(ctx, "zset:board", "carol", "bob").Val(); !reflect.DeepEqual(scores, []float64{30, 25}) {
+		t.Fatalf("unexpected ZMSCORE reply: %v", scores)
+	}
+	if n := rdb.ZCount(ctx, "zset:board", "20", "(30").Val(); n != 1 {
+		t.Fatalf("expected 1 member but got %d", n)
+	}
+	if err := rdb.ZCount(ctx, "zset:board", "a", "b").Err(); err == nil || err.Error() != "ERR min or max is not a float" {
+		t.Fatalf("expected a float error but got %v", err)
+	}
+
+	if popped := rdb.ZPopMax(ctx, "zset:board").Val(); !reflect.DeepEqual(popped, []redis.Z{{Score: 30, Member: "carol"}}) {
+		t.Fatalf("unexpected ZPOPMAX reply: %v", popped)
+	}
+	if n := rdb.ZRemRangeByScore(ctx, "zset:board", "-inf", "20").Val(); n != 1 {
+		t.Fatalf("expected 1 removed member but got %d", n)
+	}
+	if n := rdb.ZRem(ctx, "zset:board", "bob", "nobody").Val(); n != 1 {
+		t.Fatalf("expected 1 removed member but got %d", n)
+	}
+	if n := rdb.Exists(ctx, "zset:board").Val(); n != 0 {
+		t.Fatal("expected the emptied sorted set to be deleted")
+	}
+}
+
+func TestSortedSetLexAndAlgebra(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		rdb.ZAdd(ctx, "zset:lex", redis.Z{Member: member})
+	}
+	if members := rdb.ZRangeByLex(ctx, "zset:lex", &redis.ZRangeBy{Min: "(a", Max: "[c"}).Val(); !reflect.DeepEqual(members, []string{"b", "c"}) {
+		t.Fatalf("unexpected ZRANGEBYLEX reply: %v", members)
+	}
+	if members := rdb.ZRevRangeByLex(ctx, "zset:lex", &redis.ZRangeBy{Min: "-", Max: "+", Count: 2}).Val(); !reflect.DeepEqual(members, []string{"e", "d"}) {
+		t.Fatalf("unexpected ZREVRANGEBYLEX reply: %v", members)
+	}
+	if n := rdb.ZLexCount(ctx, "zset:lex", "[b", "+").Val(); n != 4 {
+		t.Fatalf("expected 4 members but got %d", n)
+	}
+	if n := rdb.ZRemRangeByLex(ctx, "zset:lex", "-", "(c").Val(); n != 2 {
+		t.Fatalf("expected 2 removed members but got %d", n)
+	}
+
+	rdb.ZAdd(ctx, "zset:x", redis.Z{Score: 1, Member: "one"}, redis.Z{Score: 2, Member: "two"})
+	rdb.ZAdd(ctx, "zset:y", redis.Z{Score: 10, Member: "two"}, redis.Z{Score: 20, Member: "three"})
+	rdb.SAdd(ctx, "zset:plain", "two", "four")
+
+	union := rdb.ZUnionWithScores(ctx, redis.ZStore{Keys: []string{"zset:x", "zset:y"}, Weights: []float64{2, 1}}).Val()
+	if !reflect.DeepEqual(union, []redis.Z{{Score: 2, Member: "one"}, {Score: 14, Member: "two"}, {Score: 20, Member: "three"}}) {
+		t.Fatalf("unexpected ZUNION reply: %v", union)
+	}
+	inter := rdb.ZInterWithScores(ctx, &redis.ZStore{Keys: []string{"zset:x", "zset:y", "zset:plain"}, Aggregate: "MAX"}).Val()
+	if !reflect.DeepEqual(inter, []redis.Z{{Score: 10, Member: "two"}}) {
+		t.Fatalf("unexpected ZINTER reply: %v", inter)
+	}
+	if diff := rdb.ZDiff(ctx, "zset:x", "zset:y").Val(); !reflect.DeepEqual(diff, []string{"one"}) {
+		t.Fatalf("unexpected ZDIFF reply: %v", diff)
+	}
+	if n := rdb.ZUnionStore(ctx, "zset:dst", &redis.ZStore{Keys: []string{"zset:x", "zset:plain"}, Aggregate: "MIN"}).Val(); n != 3 {
+		t.Fatalf("expected 3 stored members but got %d", n)
+	}
+	if score := rdb.ZScore(ctx, "zset:dst", "two").Val(); score != 1 {
+		t.Fatalf("expected the MIN of 2 and 1 but got %v", score)
+	}
+
+	if members := rdb.ZRandMemberWithScores(ctx, "zset:y", -5).Val(); len(members) != 5 {
+		t.Fatalf("expected 5 random members but got %v", members)
+	}
+	if err := rdb.ZAdd(ctx, "zset:plain", redis.Z{Member: "x"}).Err(); err == nil || err.Error() != "WRONGTYPE Operation against a key holding the wrong kind of value" {
+		t.Fatalf("expected a wrong type error but got %v", err)
+	}
+}
+
+func TestSortedSetEncodingAndScores(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 128; i++ {
+		rdb.ZAdd(ctx, "zset:big", redis.Z{Score: float64(i), Member: fmt.Sprint("member:", i)})
+	}
+	if enc := rdb.ObjectEncoding(ctx, "zset:big").Val(); enc != "listpack" {
+		t.Fatalf("expected listpack but got %s", enc)
+	}
+	rdb.ZAdd(ctx, "zset:big", redis.Z{Score: 128, Member: "member:128"})
+	if enc := rdb.ObjectEncoding(ctx, "zset:big").Val(); enc != "skiplist" {
+		t.Fatalf("expected skiplist but got %s", enc)
+	}
+	if typ := rdb.Type(ctx, "zset:big").Val(); typ != "zset" {
+		t.Fatalf("expected zset but got %s", typ)
+	}
+
+	// RESP2 clients get the scores as bulk strings formatted like redis does.
+	rdb2 := redis.NewClient(&redis.Options{Addr: rdb.Options().Addr, Protocol: 2})
+	defer rdb2.Close()
+	rdb2.ZAdd(ctx, "zset:scores", redis.Z{Score: math.Inf(-1), Member: "low"}, redis.Z{Score: 1.5, Member: "mid"}, redis.Z{Score: 1e20, Member: "high"})
+	reply := rdb2.Do(ctx, "ZRANGE", "zset:scores", "0", "-1", "WITHSCORES").Val()
+	if !reflect.DeepEqual(reply, []interface{}{"low", "-inf", "mid", "1.5", "high", "1e+20"}) {
+		t.Fatalf("unexpected ZRANGE WITHSCORES reply: %v", reply)
+	}
+}
+
+func TestSortedSetScan(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		rdb.ZAdd(ctx, "zset:scan", redis.Z{Score: float64(i) / 2, Member: fmt.Sprint("member:", i)})
+	}
+
+	scores := map[string]string{}
+	var cursor uint64
+	for {
+		values, next, err := rdb.ZScan(ctx, "zset:scan", cursor, "member:1*", 20).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(values); i += 2 {
+			scores[values[i]] = values[i+1]
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	// member:1, member:10-19 and member:100-199.
+	if len(scores) != 111 {
+		t.Fatalf("expected 111 matching members but got %d", len(scores))
+	}
+	if scores["member:1"] != "0.5" || scores["member:150"] != "75" {
+		t.Fatalf("unexpected scores %s and %s", scores["member:1"], scores["member:150"])
+	}
+}
+
+func TestBlockingSortedSetPop(t *testing.T) {
+	rdb := newTestClient(t)
+	ctx := context.Background()
+
+	done := make(chan *redis.ZWithKey)
+	go func() {
+		waiter := newTestClient(t)
+		res, err := waiter.BZPopMin(ctx, 0, "zset:blocking").Result()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// A list created on the key does not wake the waiter up.
+	rdb.RPush(ctx, "zset:blocking", "value")
+	rdb.Del(ctx, "zset:blocking")
+	rdb.ZAdd(ctx, "zset:blocking", redis.Z{Score: 2, Member: "second"}, redis.Z{Score: 1, Member: "first"})
+	select {
+	case res := <-done:
+		if res == nil || res.Key != "zset:blocking" || res.Member != "first" || res.Score != 1 {
+			t.Fatalf("unexpected BZPOPMIN reply: %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiter was never served")
+	}
+
+	if err := rdb.Do(ctx, "BZPOPMAX", "zset:missing", "0.1").Err(); err != redis.Nil {
+		t.Fatalf("expected a nil reply but got %v", err)
+	}
+}